@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// --- Adaptive Concurrency ---
+
+// AdaptiveConcurrency lets the scheduler tune a container's MaxRunning from
+// the latency of its processes, by additive increase and multiplicative
+// decrease. After every Sample completions of processes started under the
+// current limit it compares their mean latency, from start to finish, with
+// TargetLatency: at or under the target, and with processes still queued,
+// the limit grows by one; over it, the limit is multiplied by Backoff. The
+// limit stays within Min and Max.
+type AdaptiveConcurrency struct {
+	Min           int // zero means 1
+	Max           int
+	TargetLatency time.Duration
+	// Sample is how many completions each decision is based on; zero
+	// means the current limit, i.e. roughly one batch.
+	Sample int
+	// Backoff is the factor applied on degraded latency, in (0, 1); zero
+	// means 0.75.
+	Backoff float64
+}
+
+// ConcurrencyStats reports an adaptive container's limit and the
+// controller's latest decision.
+type ConcurrencyStats struct {
+	Limit        int           `json:"limit"`
+	Pinned       bool          `json:"pinned,omitempty"`
+	LastLatency  time.Duration `json:"last_latency"`  // mean of the last sample
+	LastQueueing time.Duration `json:"last_queueing"` // mean queue wait of the last sample
+	LastDecision string        `json:"last_decision,omitempty"`
+	Increases    int           `json:"increases"`
+	Decreases    int           `json:"decreases"`
+}
+
+type adaptiveState struct {
+	cfg     AdaptiveConcurrency
+	pinned  bool
+	samples int
+	latency time.Duration // summed over the current sample
+	queued  time.Duration // summed over the current sample
+	since   time.Time     // when the limit last changed
+	stats   ConcurrencyStats
+}
+
+// WithAdaptiveConcurrency creates the container with adaptive concurrency.
+func WithAdaptiveConcurrency(cfg AdaptiveConcurrency) ContainerOption {
+	return func(c *Container) error {
+		return c.setAdaptiveLocked(cfg)
+	}
+}
+
+// SetAdaptiveConcurrency starts tuning MaxRunning under cfg, from cfg.Min.
+// A zero TargetLatency stops adaptation and leaves MaxRunning as it is.
+func (c *Container) SetAdaptiveConcurrency(cfg AdaptiveConcurrency) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.setAdaptiveLocked(cfg); err != nil {
+		return err
+	}
+	c.scheduleLocked()
+	return nil
+}
+
+func (c *Container) setAdaptiveLocked(cfg AdaptiveConcurrency) error {
+	if cfg.TargetLatency <= 0 {
+		c.adaptive = nil
+		return nil
+	}
+	cfg.Min = max(cfg.Min, 1)
+	if cfg.Backoff == 0 {
+		cfg.Backoff = 0.75
+	}
+	if cfg.Max < cfg.Min || cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		return fmt.Errorf("%w: container %s: adaptive concurrency needs Min <= Max and Backoff in (0, 1)", ErrInvalidContainerSpec, c.ID)
+	}
+	c.adaptive = &adaptiveState{cfg: cfg}
+	c.MaxRunning = cfg.Min
+	c.adaptive.stats.Limit = cfg.Min
+	return nil
+}
+
+// PinConcurrency sets MaxRunning to limit and suspends adaptation until
+// UnpinConcurrency.
+func (c *Container) PinConcurrency(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.MaxRunning = limit
+	if a := c.adaptive; a != nil {
+		a.pinned = true
+		a.stats.Limit, a.stats.Pinned = limit, true
+	}
+	c.adjustedLocked(fmt.Sprintf("pinned at %d", limit))
+	c.scheduleLocked()
+}
+
+// UnpinConcurrency resumes adaptation from the pinned limit, brought within
+// the configured bounds.
+func (c *Container) UnpinConcurrency() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a := c.adaptive
+	if a == nil || !a.pinned {
+		return
+	}
+	a.pinned, a.stats.Pinned = false, false
+	c.MaxRunning = min(max(c.MaxRunning, a.cfg.Min), a.cfg.Max)
+	a.stats.Limit = c.MaxRunning
+	c.adjustedLocked(fmt.Sprintf("unpinned at %d", c.MaxRunning))
+	c.scheduleLocked()
+}
+
+// adaptLocked feeds a process that ran to an end into the controller.
+func (c *Container) adaptLocked(p *Process) {
+	a := c.adaptive
+	if a == nil || a.pinned || p.debug || p.startedAt.Before(a.since) {
+		return
+	}
+	a.samples++
+	a.latency += p.finishedAt.Sub(p.startedAt)
+	a.queued += p.startedAt.Sub(p.addedAt)
+	sample := a.cfg.Sample
+	if sample <= 0 {
+		sample = c.MaxRunning
+	}
+	if a.samples < sample {
+		return
+	}
+	mean, queueing := a.latency/time.Duration(a.samples), a.queued/time.Duration(a.samples)
+	a.samples, a.latency, a.queued = 0, 0, 0
+	a.stats.LastLatency, a.stats.LastQueueing = mean, queueing
+
+	from, limit := c.MaxRunning, c.MaxRunning
+	var why string
+	switch {
+	case mean > a.cfg.TargetLatency:
+		limit = max(int(float64(limit)*a.cfg.Backoff), a.cfg.Min)
+		why = fmt.Sprintf("latency %v over %v target", mean, a.cfg.TargetLatency)
+	case c.hasProcessInLocked(Pending):
+		limit = min(limit+1, a.cfg.Max)
+		why = fmt.Sprintf("latency %v within %v target", mean, a.cfg.TargetLatency)
+	}
+	if limit == from {
+		return
+	}
+	if limit > from {
+		a.stats.Increases++
+	} else {
+		a.stats.Decreases++
+	}
+	c.MaxRunning, a.stats.Limit = limit, limit
+	c.adjustedLocked(fmt.Sprintf("%d -> %d: %s", from, limit, why))
+}
+
+func (c *Container) adjustedLocked(decision string) {
+	if a := c.adaptive; a != nil {
+		a.stats.LastDecision = decision
+		a.samples, a.latency, a.queued = 0, 0, 0
+		a.since = c.now()
+	}
+	fmt.Printf("[Kernel] Concurrency of %s: %s\n", c.Name, decision)
+	c.emit(EventLimitAdjusted, nil, decision)
+}
+
+func (k *Kernel) concurrencyStatsLocked() map[string]ConcurrencyStats {
+	var out map[string]ConcurrencyStats
+	for id, c := range k.Containers {
+		c.mu.Lock()
+		if c.adaptive != nil {
+			if out == nil {
+				out = make(map[string]ConcurrencyStats)
+			}
+			out[id] = c.adaptive.stats
+		}
+		c.mu.Unlock()
+	}
+	return out
+}
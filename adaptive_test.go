@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// degradingLatency is a workload that runs in 100ms up to 4 at a time and
+// gets 100ms slower for every process beyond that.
+func degradingLatency(running int) time.Duration {
+	return 100*time.Millisecond + time.Duration(max(running-4, 0))*100*time.Millisecond
+}
+
+// runBatch simulates the container running a batch of MaxRunning processes
+// under degradingLatency and returns the limit the controller picks.
+func runBatch(c *Container, clk *FakeClock) int {
+	c.mu.Lock()
+	limit := c.MaxRunning
+	c.mu.Unlock()
+	start := clk.Now()
+	clk.Advance(degradingLatency(limit))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < limit; i++ {
+		c.adaptLocked(&Process{addedAt: start, startedAt: start, finishedAt: clk.Now()})
+	}
+	return c.MaxRunning
+}
+
+func TestAdaptiveConcurrencyConvergesNearKnee(t *testing.T) {
+	k, clk := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventLimitAdjusted}})
+	defer cancel()
+	c := newTestContainer(t, k, "api", WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 16, TargetLatency: 150 * time.Millisecond}))
+	c.AddProcess(&Process{Name: "backlog"}) // keeps work queued
+
+	var limits []int
+	for i := 0; i < 40; i++ {
+		limits = append(limits, runBatch(c, clk))
+	}
+	sum := 0
+	for _, l := range limits[20:] {
+		if l < 3 || l > 5 {
+			t.Fatalf("limit wandered to %d; limits %v", l, limits)
+		}
+		sum += l
+	}
+	if mean := float64(sum) / 20; mean < 3.5 || mean > 4.5 {
+		t.Errorf("mean limit %.2f, want about 4; limits %v", mean, limits)
+	}
+	if e := nextEvent(t, events); e.Detail != "1 -> 2: latency 100ms within 150ms target" {
+		t.Errorf("first decision = %q", e.Detail)
+	}
+	s := k.Stats().Concurrency["api"]
+	if s.Increases == 0 || s.Decreases == 0 || s.Limit != limits[len(limits)-1] {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestPinnedConcurrencyStopsAdaptation(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "api", WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 16, TargetLatency: 150 * time.Millisecond}))
+	c.AddProcess(&Process{Name: "backlog"})
+	c.PinConcurrency(8)
+	for i := 0; i < 10; i++ {
+		if got := runBatch(c, clk); got != 8 {
+			t.Fatalf("pinned limit moved to %d", got)
+		}
+	}
+	if s := k.Stats().Concurrency["api"]; !s.Pinned || s.Limit != 8 || s.LastDecision != "pinned at 8" {
+		t.Errorf("stats = %+v", s)
+	}
+
+	c.UnpinConcurrency()
+	if got := runBatch(c, clk); got != 6 {
+		t.Errorf("after unpinning at 8 under degraded latency: limit %d, want 6", got)
+	}
+}
+
+func TestAdaptiveConcurrencyValidates(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, cfg := range []AdaptiveConcurrency{
+		{Min: 4, Max: 2, TargetLatency: time.Second},
+		{Max: 4, TargetLatency: time.Second, Backoff: 1.5},
+	} {
+		if _, err := k.CreateContainer("bad", "bad", 64, WithAdaptiveConcurrency(cfg)); !errors.Is(err, ErrInvalidContainerSpec) {
+			t.Errorf("%+v: %v", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Usage History and Anomaly Detection ---
+
+// Metric names used in usage samples and anomaly events.
+const (
+	MetricCPU      = "cpu"
+	MetricMemory   = "memory"
+	MetricMessages = "messages"
+)
+
+// usageHistorySize bounds the samples kept per container.
+const usageHistorySize = 256
+
+// AnomalyConfig controls the anomaly detector. A sample is anomalous when it
+// is more than Sigmas standard deviations from the mean of the previous
+// Window samples of the same metric. No metric is flagged until Window
+// samples have been seen.
+type AnomalyConfig struct {
+	Window int
+	Sigmas float64
+
+	CPU      bool
+	Memory   bool
+	Messages bool
+}
+
+func (a AnomalyConfig) enabled(metric string) bool {
+	if a.Window < 2 || a.Sigmas <= 0 {
+		return false
+	}
+	switch metric {
+	case MetricCPU:
+		return a.CPU
+	case MetricMemory:
+		return a.Memory
+	case MetricMessages:
+		return a.Messages
+	}
+	return false
+}
+
+// UsageSample is one monitor observation of a container. Anomalies lists the
+// metrics flagged by the detector.
+type UsageSample struct {
+	Time      time.Time `json:"time"`
+	CPU       float64   `json:"cpu"`
+	MemoryMB  int       `json:"memory_mb"`
+	Running   int       `json:"running"`
+	Messages  int       `json:"messages"`
+	Anomalies []string  `json:"anomalies,omitempty"`
+}
+
+type usageHistory struct {
+	samples   []UsageSample
+	baselines map[string]*rollingWindow
+}
+
+// rollingWindow holds the last len(values) observations of a metric.
+type rollingWindow struct {
+	values []float64
+	next   int
+	n      int
+}
+
+func (w *rollingWindow) add(v float64) {
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+	if w.n < len(w.values) {
+		w.n++
+	}
+}
+
+func (w *rollingWindow) meanStddev() (mean, stddev float64) {
+	for _, v := range w.values[:w.n] {
+		mean += v
+	}
+	mean /= float64(w.n)
+	for _, v := range w.values[:w.n] {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(w.n))
+}
+
+// Sample records a usage sample for every container and runs the anomaly
+// detector. Monitor samples each container the same way once per cycle.
+func (k *Kernel) Sample() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.sampleLocked()
+}
+
+func (k *Kernel) sampleLocked() {
+	cfg := k.Anomalies
+	for _, c := range k.sortedContainersLocked() {
+		c.mu.Lock()
+		c.sampleLocked(cfg)
+		c.mu.Unlock()
+	}
+}
+
+func (c *Container) sampleLocked(cfg AnomalyConfig) {
+	s := UsageSample{
+		Time:     c.now(),
+		CPU:      c.CPULoad,
+		MemoryMB: c.MemoryMB,
+		Messages: int(c.sentMessages.Swap(0)),
+	}
+	for _, p := range c.Processes {
+		if p.State == Running {
+			s.Running++
+			c.observeDriftLocked(p, p.usedMB)
+		}
+	}
+	h := &c.usage
+	for _, m := range []struct {
+		name  string
+		value float64
+	}{
+		{MetricCPU, s.CPU},
+		{MetricMemory, float64(s.MemoryMB)},
+		{MetricMessages, float64(s.Messages)},
+	} {
+		if !cfg.enabled(m.name) {
+			continue
+		}
+		if h.baselines == nil {
+			h.baselines = make(map[string]*rollingWindow)
+		}
+		w := h.baselines[m.name]
+		if w == nil || len(w.values) != cfg.Window {
+			w = &rollingWindow{values: make([]float64, cfg.Window)}
+			h.baselines[m.name] = w
+		}
+		if w.n == len(w.values) {
+			mean, stddev := w.meanStddev()
+			if dev := math.Abs(m.value - mean); dev > cfg.Sigmas*stddev {
+				s.Anomalies = append(s.Anomalies, m.name)
+				c.emit(EventAnomaly, nil, fmt.Sprintf("%s %.2f deviates from mean %.2f (stddev %.2f)", m.name, m.value, mean, stddev))
+			}
+		}
+		w.add(m.value)
+	}
+	h.samples = append(h.samples, s)
+	c.observeLoadLocked()
+	if len(h.samples) > usageHistorySize {
+		h.samples = h.samples[len(h.samples)-usageHistorySize:]
+	}
+}
+
+// UsageHistory returns the container's recorded usage samples, oldest first.
+func (c *Container) UsageHistory() []UsageSample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usageHistoryLocked()
+}
+
+func (c *Container) usageHistoryLocked() []UsageSample {
+	out := make([]UsageSample, len(c.usage.samples))
+	for i, s := range c.usage.samples {
+		s.Anomalies = append([]string(nil), s.Anomalies...)
+		out[i] = s
+	}
+	return out
+}
+
+// ExportHistoryCSV writes the container's usage history as CSV with a
+// header row. Timestamps are RFC 3339 with nanoseconds and anomalies are
+// separated by semicolons.
+func (c *Container) ExportHistoryCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"timestamp", "cpu", "memory_mb", "running", "messages", "anomalies"}); err != nil {
+		return err
+	}
+	for _, s := range c.UsageHistory() {
+		err := cw.Write([]string{
+			s.Time.Format(time.RFC3339Nano),
+			strconv.FormatFloat(s.CPU, 'f', -1, 64),
+			strconv.Itoa(s.MemoryMB),
+			strconv.Itoa(s.Running),
+			strconv.Itoa(s.Messages),
+			strings.Join(s.Anomalies, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// sampleSeries sets c's CPU load to each value in turn and samples it,
+// a second apart.
+func sampleSeries(k *Kernel, clk *FakeClock, c *Container, cpu ...float64) {
+	for _, v := range cpu {
+		c.SetCPULoad(v)
+		k.Sample()
+		clk.Advance(time.Second)
+	}
+}
+
+func TestAnomalyFlagsOnlyTheSpike(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.Anomalies = AnomalyConfig{Window: 4, Sigmas: 3, CPU: true, Memory: true}
+	c := newTestContainer(t, k, "web")
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventAnomaly}})
+	defer cancel()
+
+	sampleSeries(k, clk, c, 10, 12, 10, 12, 11, 10, 95, 11, 12, 10)
+	var flagged []int
+	for i, s := range c.UsageHistory() {
+		if len(s.Anomalies) > 0 {
+			flagged = append(flagged, i)
+			if len(s.Anomalies) != 1 || s.Anomalies[0] != MetricCPU {
+				t.Errorf("sample %d flagged %v, want [cpu]", i, s.Anomalies)
+			}
+		}
+	}
+	if len(flagged) != 1 || flagged[0] != 6 {
+		t.Fatalf("flagged samples %v, want only the spike at 6", flagged)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "web" || e.Detail != "cpu 95.00 deviates from mean 10.75 (stddev 0.83)" {
+		t.Errorf("event = %+v", e)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportHistoryCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows[7]; got[1] != "95" || got[5] != MetricCPU {
+		t.Errorf("exported spike row = %v", got)
+	}
+}
+
+func TestAnomalyWarmUpFlagsNothing(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.Anomalies = AnomalyConfig{Window: 5, Sigmas: 1, CPU: true}
+	c := newTestContainer(t, k, "web")
+	sampleSeries(k, clk, c, 1, 90, 3, 70, 0)
+	for i, s := range c.UsageHistory() {
+		if len(s.Anomalies) > 0 {
+			t.Errorf("warm-up sample %d flagged %v", i, s.Anomalies)
+		}
+	}
+}
+
+func TestExportHistoryCSVParsesBack(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	c.AddProcess(&Process{Name: "server", Action: blockUntil(nil)})
+	sampleSeries(k, clk, c, 5.5)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	eventually(t, "the server to run", func() bool { return c.Inspect().Processes[0].State == Running })
+	sampleSeries(k, clk, c, 20, 0.25)
+
+	var buf bytes.Buffer
+	if err := c.ExportHistoryCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"timestamp", "cpu", "memory_mb", "running", "messages", "anomalies"},
+		{"2024-01-01T00:00:00Z", "5.5", "1024", "0", "0", ""},
+		{"2024-01-01T00:00:01Z", "20", "1024", "1", "0", ""},
+		{"2024-01-01T00:00:02Z", "0.25", "1024", "1", "0", ""},
+	}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+}
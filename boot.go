@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Boot Phases ---
+
+// BootError reports the phase that halted StartAll and the containers of
+// that phase which failed to start.
+type BootError struct {
+	Phase   int
+	Blocked map[string]error // container ID -> why it did not start
+}
+
+func (e *BootError) Error() string {
+	ids := make([]string, 0, len(e.Blocked))
+	for id := range e.Blocked {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%s: %v", id, e.Blocked[id])
+	}
+	return fmt.Sprintf("boot halted at phase %d: %s", e.Phase, strings.Join(parts, "; "))
+}
+
+// Unwrap returns the errors of the blocked containers, so errors.Is finds
+// e.g. ErrQuarantined.
+func (e *BootError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Blocked))
+	for _, err := range e.Blocked {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+type bootPhase struct {
+	phase      int
+	containers []*Container // in start order
+}
+
+// bootPhasesLocked groups the containers by BootPhase, ascending. Within a
+// phase it repeatedly takes the first container, by ID, with no
+// dependency left to start in the same phase; a dependency cycle falls
+// back to ID order.
+func (k *Kernel) bootPhasesLocked() []bootPhase {
+	all := k.sortedContainersLocked()
+	sort.SliceStable(all, func(i, j int) bool { return all[i].BootPhase < all[j].BootPhase })
+	var phases []bootPhase
+	for len(all) > 0 {
+		n := 1
+		for n < len(all) && all[n].BootPhase == all[0].BootPhase {
+			n++
+		}
+		remaining := append([]*Container(nil), all[:n]...)
+		ph := bootPhase{phase: all[0].BootPhase}
+		for len(remaining) > 0 {
+			pick := 0
+			for i, c := range remaining {
+				if !dependsOnAny(c, remaining) {
+					pick = i
+					break
+				}
+			}
+			ph.containers = append(ph.containers, remaining[pick])
+			remaining = append(remaining[:pick], remaining[pick+1:]...)
+		}
+		phases = append(phases, ph)
+		all = all[n:]
+	}
+	return phases
+}
+
+// dependsOnAny reports whether c lists any other container in cs as a
+// dependency.
+func dependsOnAny(c *Container, cs []*Container) bool {
+	for _, dep := range c.DependsOn {
+		for _, o := range cs {
+			if o != c && o.ID == dep {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bootPhaseDoneLocked reports a started phase with an EventBootPhase, and
+// returns the *BootError that halts the boot if any of its containers
+// were blocked.
+func (k *Kernel) bootPhaseDoneLocked(ph bootPhase, phases int, blocked map[string]error) error {
+	if len(blocked) > 0 {
+		err := &BootError{Phase: ph.phase, Blocked: blocked}
+		fmt.Printf("[Kernel] %v\n", err)
+		k.emit(EventBootPhase, "", "", err.Error())
+		return err
+	}
+	detail := fmt.Sprintf("phase %d started: %d containers", ph.phase, len(ph.containers))
+	if phases > 1 {
+		fmt.Printf("[Kernel] Boot %s\n", detail)
+	}
+	k.emit(EventBootPhase, "", "", detail)
+	return nil
+}
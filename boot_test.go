@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// phasedKernel returns a kernel with containers in three boot phases: net
+// in 0; api, which depends on cache, broken and cache in 1; web in 2.
+func phasedKernel(t *testing.T) *Kernel {
+	t.Helper()
+	k, _ := newTestKernel(t)
+	for _, spec := range []struct {
+		id    string
+		phase int
+	}{{"net", 0}, {"api", 1}, {"broken", 1}, {"cache", 1}, {"web", 2}} {
+		c := newTestContainer(t, k, spec.id)
+		c.BootPhase = spec.phase
+	}
+	k.Containers["api"].DependsOn = []string{"cache"}
+	return k
+}
+
+func TestBootPhasesOrderStartup(t *testing.T) {
+	k := phasedKernel(t)
+	k.mu.Lock()
+	phases := k.bootPhasesLocked()
+	k.mu.Unlock()
+	var order []string
+	for _, ph := range phases {
+		var ids []string
+		for _, c := range ph.containers {
+			ids = append(ids, c.ID)
+		}
+		order = append(order, fmt.Sprintf("%d:%v", ph.phase, ids))
+	}
+	if fmt.Sprint(order) != "[0:[net] 1:[broken cache api] 2:[web]]" {
+		t.Errorf("boot order = %v, want phases ascending and dependencies first", order)
+	}
+}
+
+func TestFailedPhaseHaltsBoot(t *testing.T) {
+	k := phasedKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventBootPhase}})
+	defer cancel()
+	if err := k.Quarantine("broken", "under investigation"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := k.StartAll()
+	var boot *BootError
+	if !errors.As(err, &boot) || boot.Phase != 1 || len(boot.Blocked) != 1 || !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("StartAll = %v, want phase 1 blocked by the quarantined container", err)
+	}
+	for _, want := range []string{
+		"phase 0 started: 1 containers",
+		"boot halted at phase 1: broken: container is quarantined: broken",
+	} {
+		if e := nextEvent(t, events); e.Detail != want {
+			t.Errorf("event = %q, want %q", e.Detail, want)
+		}
+	}
+	for id, want := range map[string]ContainerState{
+		"net": ContainerRunning, "api": ContainerRunning, "cache": ContainerRunning, "web": ContainerCreated,
+	} {
+		if got := k.Containers[id].Inspect().State; got != want {
+			t.Errorf("%s = %v, want %v", id, got, want)
+		}
+	}
+
+	if err := k.Unquarantine("broken"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.StartAll(); err != nil {
+		t.Fatalf("StartAll after the fix: %v", err)
+	}
+	var stopped []string
+	for _, r := range k.StopAll(context.Background()).Containers {
+		stopped = append(stopped, r.ID)
+	}
+	if fmt.Sprint(stopped) != "[web api broken cache net]" {
+		t.Errorf("stop order = %v, want the phases reversed and dependents first", stopped)
+	}
+}
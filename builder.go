@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// --- Kernel Builder ---
+
+// DefaultBuilderMemoryMB is the memory limit of a builder container whose
+// Mem is not set.
+const DefaultBuilderMemoryMB = 256
+
+// builderFile is the SpecError.File of builder errors; their Line is the
+// builder step.
+const builderFile = "builder"
+
+var ErrBuilderUsed = errors.New("builder already built")
+
+// Builder declares a set of containers and their processes in code, for
+// one-shot setups and tests:
+//
+//	NewBuilder().
+//		Container("db").Mem(1024).Proc("engine", engine).Proc("backup", backup).
+//		Container("web").DependsOn("db").Proc("http", http).
+//		Build(k)
+//
+// Methods after Container configure the most recent container. Mistakes
+// are collected rather than reported one call at a time: Build returns
+// them all as SpecErrors, each with File "builder" and Line set to the
+// 1-based step (method call) that introduced it.
+type Builder struct {
+	step       int
+	containers []*builderContainer
+	errs       SpecErrors
+	built      bool
+}
+
+type builderContainer struct {
+	spec  locatedSpec
+	procs []builderProc
+}
+
+// builderProc is a process declared by Proc (with an action) or ProcKind
+// (with a registered kind).
+type builderProc struct {
+	step int
+	ProcessSpec
+	action ActionFunc
+}
+
+// NewBuilder returns an empty builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) errorf(step int, format string, args ...any) *SpecError {
+	return &SpecError{File: builderFile, Line: step, Err: fmt.Errorf(format, args...)}
+}
+
+// current advances to the next step and returns the container it
+// configures, or nil (recording an error) if there is none yet.
+func (b *Builder) current(method string) *builderContainer {
+	b.step++
+	if len(b.containers) == 0 {
+		b.errs = append(b.errs, b.errorf(b.step, "%s: no container; call Container first", method))
+		return nil
+	}
+	return b.containers[len(b.containers)-1]
+}
+
+// Container starts declaring container id, named id and limited to
+// DefaultBuilderMemoryMB until Name and Mem say otherwise.
+func (b *Builder) Container(id string) *Builder {
+	b.step++
+	b.containers = append(b.containers, &builderContainer{spec: locatedSpec{
+		Spec: Spec{ContainerSpec: ContainerSpec{ID: id, Name: id, MemoryMB: DefaultBuilderMemoryMB}},
+		File: builderFile,
+		Line: b.step,
+	}})
+	return b
+}
+
+// Name sets the container's display name.
+func (b *Builder) Name(name string) *Builder {
+	if bc := b.current("Name"); bc != nil {
+		bc.spec.Name = name
+	}
+	return b
+}
+
+// Mem sets the container's memory limit in MB.
+func (b *Builder) Mem(mb int) *Builder {
+	if bc := b.current("Mem"); bc != nil {
+		bc.spec.MemoryMB = mb
+	}
+	return b
+}
+
+// Group adds the container to group, which others can depend on as
+// "group:<name>".
+func (b *Builder) Group(group string) *Builder {
+	if bc := b.current("Group"); bc != nil {
+		bc.spec.Group = group
+	}
+	return b
+}
+
+// Label sets a container label.
+func (b *Builder) Label(key, value string) *Builder {
+	if bc := b.current("Label"); bc != nil {
+		if bc.spec.Labels == nil {
+			bc.spec.Labels = map[string]string{}
+		}
+		bc.spec.Labels[key] = value
+	}
+	return b
+}
+
+// Env sets a container environment variable.
+func (b *Builder) Env(key, value string) *Builder {
+	if bc := b.current("Env"); bc != nil {
+		if bc.spec.Env == nil {
+			bc.spec.Env = map[string]string{}
+		}
+		bc.spec.Env[key] = value
+	}
+	return b
+}
+
+// DependsOn adds dependencies on other containers, declared by this
+// builder or already in the kernel, or on groups as "group:<name>".
+func (b *Builder) DependsOn(ids ...string) *Builder {
+	if bc := b.current("DependsOn"); bc != nil {
+		bc.spec.DependsOn = append(bc.spec.DependsOn, ids...)
+	}
+	return b
+}
+
+// Proc adds a process running action. Such processes have no kind, so
+// ExportSpec cannot describe them; use ProcKind for those that must be
+// exported.
+func (b *Builder) Proc(name string, action ActionFunc) *Builder {
+	if bc := b.current("Proc"); bc != nil {
+		bc.procs = append(bc.procs, builderProc{step: b.step, ProcessSpec: ProcessSpec{Name: name}, action: action})
+	}
+	return b
+}
+
+// ProcKind adds a process built by the factory registered for kind.
+func (b *Builder) ProcKind(name, kind string, params map[string]string) *Builder {
+	if bc := b.current("ProcKind"); bc != nil {
+		bc.procs = append(bc.procs, builderProc{step: b.step,
+			ProcessSpec: ProcessSpec{Name: name, Kind: kind, Params: copyStringMap(params)}})
+	}
+	return b
+}
+
+// Build validates every declaration and, if all are sound, creates the
+// containers in dependency order and adds their processes, returning the
+// containers in that order. Nothing is created when anything is wrong:
+// the error is then SpecErrors listing every problem by step. A builder
+// builds once; later calls return ErrBuilderUsed.
+func (b *Builder) Build(k *Kernel) ([]*Container, error) {
+	if b.built {
+		return nil, ErrBuilderUsed
+	}
+	b.built = true
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+
+	errs := append(SpecErrors(nil), b.errs...)
+	specs := make([]locatedSpec, len(b.containers))
+	procs := make(map[string][]builderProc, len(b.containers))
+	for i, bc := range b.containers {
+		specs[i] = bc.spec
+		procs[bc.spec.ID] = bc.procs
+		seen := make(map[string]bool, len(bc.procs))
+		for _, bp := range bc.procs {
+			switch {
+			case bp.Name == "":
+				errs = append(errs, b.errorf(bp.step, "container %s: process name is required", bc.spec.ID))
+			case seen[bp.Name]:
+				errs = append(errs, b.errorf(bp.step, "container %s: process %s declared twice", bc.spec.ID, bp.Name))
+			}
+			seen[bp.Name] = true
+			if bp.Kind == "" {
+				if bp.action == nil {
+					errs = append(errs, b.errorf(bp.step, "container %s: process %s has no action", bc.spec.ID, bp.Name))
+				}
+			} else if err := k.checkKind(bp.Kind); err != nil {
+				errs = append(errs, b.errorf(bp.step, "container %s: process %s: %v", bc.spec.ID, bp.Name, err))
+			}
+		}
+	}
+	ordered, verrs := k.validateSpecs(specs)
+	errs = append(errs, verrs...)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, errs
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	built := make(map[string][]*Process, len(ordered))
+	names := make(map[string]string, len(ordered))
+	for _, ls := range ordered {
+		if _, taken := k.Containers[ls.ID]; taken {
+			errs = append(errs, ls.errorf("%w: %s", ErrContainerExists, ls.ID))
+		}
+		if err := k.checkNameLocked("", ls.Name); err != nil {
+			errs = append(errs, ls.errorf("%w", err))
+		} else if other, dup := names[ls.Name]; dup && k.RequireUniqueNames {
+			errs = append(errs, ls.errorf("%w: %s is used by %s", ErrNameTaken, ls.Name, other))
+		}
+		names[ls.Name] = ls.ID
+		for _, bp := range procs[ls.ID] {
+			if bp.Kind == "" {
+				built[ls.ID] = append(built[ls.ID], &Process{Name: bp.Name, Action: bp.action})
+				continue
+			}
+			p, err := k.newProcessLocked(bp.ProcessSpec)
+			if err != nil {
+				errs = append(errs, b.errorf(bp.step, "container %s: process %s: %w", ls.ID, bp.Name, err))
+				continue
+			}
+			built[ls.ID] = append(built[ls.ID], p)
+		}
+	}
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return nil, errs
+	}
+
+	out := make([]*Container, 0, len(ordered))
+	for _, ls := range ordered {
+		c := k.createContainerLocked(ls.ID, ls.Name, ls.MemoryMB)
+		c.mu.Lock()
+		c.applySpecLocked(ls.ContainerSpec)
+		for _, p := range built[ls.ID] {
+			c.addProcessLocked(p)
+		}
+		c.mu.Unlock()
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// ExportSpec writes the builder's declarations as a JSON spec array that
+// LoadSpec and ApplyDir accept. Processes added with Proc have no kind and
+// cannot be rebuilt from a spec, so their presence is an error.
+func (b *Builder) ExportSpec(w io.Writer) error {
+	specs := make([]Spec, 0, len(b.containers))
+	var kindless []string
+	for _, bc := range b.containers {
+		s := bc.spec.Spec
+		s.Processes = nil
+		for _, bp := range bc.procs {
+			if bp.Kind == "" {
+				kindless = append(kindless, bc.spec.ID+"/"+bp.Name)
+				continue
+			}
+			s.Processes = append(s.Processes, bp.ProcessSpec)
+		}
+		specs = append(specs, s)
+	}
+	if len(kindless) > 0 {
+		return fmt.Errorf("processes without a kind cannot be exported: %s", strings.Join(kindless, ", "))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(specs)
+}
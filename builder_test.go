@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBuilderCreatesDemoTopology(t *testing.T) {
+	k, _ := newTestKernel(t)
+	b := NewBuilder().
+		Container("web").Name("WebServer").Mem(512).DependsOn("db").
+		Proc("HTTP Server", noop).Proc("Worker", noop).
+		Container("db").Name("Database").Mem(1024).
+		Proc("DB Engine", noop).Proc("Backup", noop)
+	built, err := b.Build(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range built {
+		ids = append(ids, c.ID)
+	}
+	if fmt.Sprint(ids) != "[db web]" {
+		t.Errorf("built %v, want dependencies first", ids)
+	}
+	for _, want := range []struct {
+		id, name string
+		memory   int
+		procs    string
+	}{
+		{"web", "WebServer", 512, "[HTTP Server Worker]"},
+		{"db", "Database", 1024, "[DB Engine Backup]"},
+	} {
+		d := k.Containers[want.id].Inspect()
+		var procs []string
+		for _, p := range d.Processes {
+			procs = append(procs, p.Name)
+		}
+		if d.Name != want.name || d.MemoryMB != want.memory || fmt.Sprint(procs) != want.procs {
+			t.Errorf("%s = %s, %dMB, processes %v", want.id, d.Name, d.MemoryMB, procs)
+		}
+	}
+	if deps := k.Containers["web"].DependsOn; fmt.Sprint(deps) != "[db]" {
+		t.Errorf("web depends on %v", deps)
+	}
+
+	if _, err := b.Build(k); !errors.Is(err, ErrBuilderUsed) {
+		t.Errorf("building twice: %v", err)
+	}
+}
+
+func TestBuilderCollectsErrorsByStep(t *testing.T) {
+	k, _ := newTestKernel(t)
+	_, err := NewBuilder().
+		Container("db").Mem(-1).          // steps 1-2
+		Container("web").DependsOn("db"). // steps 3-4
+		Proc("http", nil).                // step 5
+		Build(k)
+	var errs SpecErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("err = %v, want two collected errors", err)
+	}
+	for i, line := range []int{1, 5} {
+		if errs[i].File != "builder" || errs[i].Line != line {
+			t.Errorf("error %d at %s:%d, want builder:%d: %v", i, errs[i].File, errs[i].Line, line, errs[i])
+		}
+	}
+	if len(k.Containers) != 0 {
+		t.Errorf("created %d containers despite the errors", len(k.Containers))
+	}
+
+	newTestContainer(t, k, "db")
+	_, err = NewBuilder().Container("cache").Container("db").Build(k)
+	if !errors.Is(err, ErrContainerExists) {
+		t.Errorf("redeclaring db: %v, want ErrContainerExists", err)
+	}
+	if _, ok := k.Containers["cache"]; ok {
+		t.Error("created cache although db failed")
+	}
+
+	_, err = NewBuilder().Mem(64).Build(k)
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 1 {
+		t.Errorf("Mem before Container: %v", err)
+	}
+}
+
+func TestBuilderExportRoundTripsThroughLoadSpec(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerWorker(k, "worker")
+	b := NewBuilder().
+		Container("db").Mem(1024).Group("data").Label("tier", "storage").
+		ProcKind("engine", "worker", map[string]string{"shards": "4"}).
+		Container("web").DependsOn("group:data").Env("PORT", "8080").
+		ProcKind("http", "worker", nil)
+	var buf bytes.Buffer
+	if err := b.ExportSpec(&buf); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := LoadSpec(&buf)
+	if err != nil {
+		t.Fatalf("LoadSpec: %v\n%s", err, buf.String())
+	}
+	want := []Spec{
+		{ContainerSpec: ContainerSpec{ID: "db", Name: "db", MemoryMB: 1024, Labels: map[string]string{"tier": "storage"}},
+			Group: "data", Processes: []ProcessSpec{{Name: "engine", Kind: "worker", Params: map[string]string{"shards": "4"}}}},
+		{ContainerSpec: ContainerSpec{ID: "web", Name: "web", MemoryMB: DefaultBuilderMemoryMB, DependsOn: []string{"group:data"}, Env: map[string]string{"PORT": "8080"}},
+			Processes: []ProcessSpec{{Name: "http", Kind: "worker"}}},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("loaded\n%+v\nwant\n%+v", specs, want)
+	}
+	if _, err := b.Build(k); err != nil {
+		t.Errorf("building the exported declarations: %v", err)
+	}
+
+	if err := NewBuilder().Container("api").Proc("handler", noop).ExportSpec(&buf); err == nil {
+		t.Error("exported a process without a kind")
+	}
+}
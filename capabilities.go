@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// --- Capabilities ---
+
+// ErrCapabilityDenied is returned by Handle methods that the calling
+// process's container is not allowed to use.
+var ErrCapabilityDenied = errors.New("capability denied")
+
+// Capability is a set of Handle powers a container's processes may use.
+type Capability uint
+
+const (
+	// CapSendMessages covers Send, SendAfter, Broadcast, Publish, Request,
+	// Reply and the Outbox.
+	CapSendMessages Capability = 1 << iota
+	// CapSpawnProcesses covers Spawn.
+	CapSpawnProcesses
+	// CapOpenHandles covers Open.
+	CapOpenHandles
+
+	AllCapabilities = CapSendMessages | CapSpawnProcesses | CapOpenHandles
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapSendMessages, "SendMessages"},
+	{CapSpawnProcesses, "SpawnProcesses"},
+	{CapOpenHandles, "OpenHandles"},
+}
+
+func (c Capability) String() string {
+	var names []string
+	for _, n := range capabilityNames {
+		if c&n.cap != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// WithCapabilities creates the container with only caps, less any
+// Kernel.DeniedCapabilities. Containers have every capability by default.
+// Capabilities cannot be changed afterwards except by
+// Kernel.GrantCapabilities.
+func WithCapabilities(caps Capability) ContainerOption {
+	return func(c *Container) error {
+		c.denied |= AllCapabilities &^ caps
+		return nil
+	}
+}
+
+// Capabilities returns what the container's processes may use.
+func (c *Container) Capabilities() Capability {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return AllCapabilities &^ c.denied
+}
+
+// GrantCapabilities gives container id the capabilities caps in addition
+// to those it has. It takes effect for the next call of each process.
+func (k *Kernel) GrantCapabilities(id string, caps Capability) error {
+	k.mu.Lock()
+	c, ok := k.Containers[id]
+	k.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	c.mu.Lock()
+	c.denied &^= caps
+	granted := AllCapabilities &^ c.denied
+	c.mu.Unlock()
+	fmt.Printf("[Kernel] Granted %v to %s, which now has %v\n", caps, c.Name, granted)
+	return nil
+}
+
+// checkCapability fails with ErrCapabilityDenied, and records an
+// EventCapabilityDenied, if the caller's container lacks cap for call.
+func (h *Handle) checkCapability(cap Capability, call string) error {
+	c := h.container
+	c.mu.Lock()
+	denied := c.denied&cap != 0
+	c.mu.Unlock()
+	if !denied {
+		return nil
+	}
+	fmt.Printf("[Kernel] Denied %s to %s in %s: lacks %v\n", call, h.proc.Name, c.Name, cap)
+	c.emit(EventCapabilityDenied, h.proc, fmt.Sprintf("%s: lacks %v", call, cap))
+	return fmt.Errorf("%w: %s needs %v in container %s", ErrCapabilityDenied, call, cap, c.ID)
+}
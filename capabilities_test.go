@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeniedCapabilitiesFailHandleCalls(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventCapabilityDenied}})
+	defer cancel()
+	newTestContainer(t, k, "peer").AddProcess(&Process{Name: "listener"})
+	c := newTestContainer(t, k, "sandbox", WithCapabilities(0))
+	if got := c.Inspect().Capabilities; got != 0 {
+		t.Errorf("inspect capabilities = %v, want none", got)
+	}
+
+	calls := []struct {
+		name string
+		cap  Capability
+		call func(h *Handle) error
+	}{
+		{"spawn", CapSpawnProcesses, func(h *Handle) error { _, err := h.Spawn(&Process{Name: "child", Action: noop}); return err }},
+		{"send", CapSendMessages, func(h *Handle) error { return h.Send("peer", "hi") }},
+		{"sendafter", CapSendMessages, func(h *Handle) error { _, err := h.SendAfter("peer", "hi", time.Second); return err }},
+		{"broadcast", CapSendMessages, func(h *Handle) error { _, err := h.Broadcast("hi"); return err }},
+		{"publish", CapSendMessages, func(h *Handle) error { _, err := h.Publish("news", "hi"); return err }},
+		{"request", CapSendMessages, func(h *Handle) error { _, err := h.Request("peer", "hi"); return err }},
+		{"open", CapOpenHandles, func(h *Handle) error { _, err := h.Open("db"); return err }},
+	}
+	errs := make([]error, len(calls))
+	p := &Process{Name: "untrusted", Action: func(ctx context.Context, h *Handle) error {
+		for i, tc := range calls {
+			errs[i] = tc.call(h)
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	for i, tc := range calls {
+		if !errors.Is(errs[i], ErrCapabilityDenied) {
+			t.Errorf("%s: %v, want ErrCapabilityDenied", tc.name, errs[i])
+		}
+		e := nextEvent(t, events)
+		if want := tc.name + ": lacks " + tc.cap.String(); e.ContainerID != "sandbox" || e.Detail != want {
+			t.Errorf("event = %+v, want %q", e, want)
+		}
+	}
+	if n := len(k.Containers["peer"].Inbox()); n != 0 {
+		t.Errorf("peer received %d messages from a container that may not send", n)
+	}
+}
+
+func TestGrantCapabilitiesTakesEffectOnNextCall(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.DeniedCapabilities = CapSendMessages
+	peer := newTestContainer(t, k, "peer")
+	peer.AddProcess(&Process{Name: "listener"})
+	c := newTestContainer(t, k, "tenant")
+	if got := c.Capabilities(); got != CapSpawnProcesses|CapOpenHandles {
+		t.Fatalf("capabilities = %v, want the kernel default withheld", got)
+	}
+
+	step, results := make(chan struct{}), make(chan error)
+	p := &Process{Name: "sender", Action: func(ctx context.Context, h *Handle) error {
+		for range step {
+			results <- h.Send("peer", "hi")
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer close(step)
+	step <- struct{}{}
+	if err := <-results; !errors.Is(err, ErrCapabilityDenied) {
+		t.Fatalf("before the grant: %v", err)
+	}
+	if err := k.GrantCapabilities("tenant", CapSendMessages); err != nil {
+		t.Fatal(err)
+	}
+	step <- struct{}{}
+	if err := <-results; err != nil {
+		t.Errorf("after the grant: %v", err)
+	}
+	if c.Capabilities() != AllCapabilities || len(peer.Inbox()) != 1 {
+		t.Errorf("capabilities %v, peer inbox %d", c.Capabilities(), len(peer.Inbox()))
+	}
+	if err := k.GrantCapabilities("missing", CapSendMessages); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("granting to a missing container: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Circuit Breakers ---
+
+var (
+	// ErrCircuitOpen is returned by Request while the target's circuit is
+	// open, without sending anything.
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrRequestTimeout is returned by RequestTimeout when no reply came in
+	// time.
+	ErrRequestTimeout = errors.New("request timed out")
+)
+
+// CircuitState is the state of a target's circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through and counts their failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests fast until the cooldown has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through; their outcome
+	// closes or reopens the circuit.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreakerPolicy configures the breakers Request keeps per target,
+// i.e. per container ID or "svc:<name>" as passed to Request. The zero
+// value disables circuit breaking.
+type CircuitBreakerPolicy struct {
+	// Window is the span over which requests and failures are counted; the
+	// counts restart when it elapses.
+	Window time.Duration
+	// FailureThreshold opens the circuit once that many requests failed in
+	// the window. Zero disables the check.
+	FailureThreshold int
+	// FailureRate opens the circuit once that fraction of at least
+	// MinRequests requests failed in the window. Zero disables the check.
+	FailureRate float64
+	MinRequests int
+	// Cooldown is how long the circuit stays open before half-opening.
+	Cooldown time.Duration
+	// HalfOpenProbes is how many requests may be in flight while the
+	// circuit is half-open; zero means 1.
+	HalfOpenProbes int
+}
+
+func (p CircuitBreakerPolicy) enabled() bool {
+	return p.FailureThreshold > 0 || p.FailureRate > 0
+}
+
+// CircuitStats describes one target's breaker.
+type CircuitStats struct {
+	State    string    `json:"state"`
+	Requests int       `json:"requests"` // in the current window
+	Failures int       `json:"failures"` // in the current window
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+}
+
+type circuit struct {
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // in flight while half-open
+}
+
+// circuitAllowLocked decides whether a request to target may be sent, and
+// reports whether it is a half-open probe.
+func (k *Kernel) circuitAllowLocked(target string) (probe bool, err error) {
+	policy := k.CircuitBreaker
+	if !policy.enabled() {
+		return false, nil
+	}
+	if k.circuits == nil {
+		k.circuits = make(map[string]*circuit)
+	}
+	cb := k.circuits[target]
+	if cb == nil {
+		cb = &circuit{}
+		k.circuits[target] = cb
+	}
+	now := k.Clock.Now()
+	switch cb.state {
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) < policy.Cooldown {
+			return false, fmt.Errorf("%w: %s", ErrCircuitOpen, target)
+		}
+		k.setCircuitLocked(target, cb, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.probes >= max(policy.HalfOpenProbes, 1) {
+			return false, fmt.Errorf("%w: %s (half-open)", ErrCircuitOpen, target)
+		}
+		cb.probes++
+		return true, nil
+	}
+	if policy.Window > 0 && now.Sub(cb.windowStart) >= policy.Window {
+		cb.windowStart, cb.requests, cb.failures = now, 0, 0
+	}
+	return false, nil
+}
+
+// circuitRecordLocked records the outcome of a request allowed by
+// circuitAllowLocked.
+func (k *Kernel) circuitRecordLocked(target string, probe, failed bool) {
+	policy := k.CircuitBreaker
+	cb := k.circuits[target]
+	if !policy.enabled() || cb == nil {
+		return
+	}
+	if probe {
+		k.circuitReleaseProbeLocked(target)
+		if cb.state != CircuitHalfOpen {
+			return
+		}
+		if failed {
+			k.setCircuitLocked(target, cb, CircuitOpen)
+		} else {
+			k.setCircuitLocked(target, cb, CircuitClosed)
+		}
+		return
+	}
+	if cb.state != CircuitClosed {
+		return
+	}
+	cb.requests++
+	if failed {
+		cb.failures++
+	}
+	rateHit := policy.FailureRate > 0 && cb.requests >= max(policy.MinRequests, 1) &&
+		float64(cb.failures)/float64(cb.requests) >= policy.FailureRate
+	if (policy.FailureThreshold > 0 && cb.failures >= policy.FailureThreshold) || rateHit {
+		k.setCircuitLocked(target, cb, CircuitOpen)
+	}
+}
+
+// circuitReleaseProbeLocked frees a half-open probe slot on target
+// without recording an outcome, for a probe whose caller gave up.
+func (k *Kernel) circuitReleaseProbeLocked(target string) {
+	if cb := k.circuits[target]; cb != nil && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+func (k *Kernel) setCircuitLocked(target string, cb *circuit, state CircuitState) {
+	from := cb.state
+	cb.state = state
+	now := k.Clock.Now()
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = now
+	case CircuitClosed:
+		cb.windowStart, cb.requests, cb.failures = now, 0, 0
+	}
+	fmt.Printf("[Kernel] Circuit %s: %s -> %s\n", target, from, state)
+	k.emit(EventCircuitChanged, "", "", fmt.Sprintf("%s: %s -> %s", target, from, state))
+}
+
+// Circuit reports the breaker state for target.
+func (k *Kernel) Circuit(target string) CircuitState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cb := k.circuits[target]; cb != nil {
+		return cb.state
+	}
+	return CircuitClosed
+}
+
+func (k *Kernel) circuitStatsLocked() map[string]CircuitStats {
+	if len(k.circuits) == 0 {
+		return nil
+	}
+	out := make(map[string]CircuitStats, len(k.circuits))
+	for target, cb := range k.circuits {
+		s := CircuitStats{State: cb.state.String(), Requests: cb.requests, Failures: cb.failures}
+		if cb.state != CircuitClosed {
+			s.OpenedAt = cb.openedAt
+		}
+		out[target] = s
+	}
+	return out
+}
+
+// --- Request Retries ---
+
+// RetryPolicy configures Handle.RequestWithRetry.
+type RetryPolicy struct {
+	// Attempts is the most tries per call, the first included; zero
+	// means 1.
+	Attempts int
+	// Backoff is the wait before the first retry; it doubles for each
+	// further retry, up to MaxBackoff if set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomises each wait by up to that fraction either way, so
+	// callers that failed together do not retry together.
+	Jitter float64
+	// BudgetRatio caps the process's retries at that fraction of its
+	// original calls, plus MinRetries, so retries cannot multiply the load
+	// on a struggling target. Zero means no budget.
+	BudgetRatio float64
+	MinRetries  int
+}
+
+// retryBudget counts a process's RequestWithRetry calls and retries.
+type retryBudget struct {
+	calls   int
+	retries int
+}
+
+// RequestWithRetry is RequestTimeout retried under policy. It stops early
+// when the circuit is open, the caller is stopped, or the retry budget is
+// spent, and returns the last error.
+func (h *Handle) RequestWithRetry(toID, payload string, timeout time.Duration, policy RetryPolicy) (Message, error) {
+	c := h.container
+	c.mu.Lock()
+	h.proc.retries.calls++
+	c.mu.Unlock()
+
+	backoff := Backoff{Initial: policy.Backoff, Max: policy.MaxBackoff}
+	for attempt := 1; ; attempt++ {
+		reply, err := h.RequestTimeout(toID, payload, timeout)
+		if err == nil || attempt >= max(policy.Attempts, 1) ||
+			errors.Is(err, ErrCircuitOpen) || h.context().Err() != nil || !h.takeRetry(policy) {
+			return reply, err
+		}
+		wait := backoff.Delay(attempt, nil)
+		if policy.Jitter > 0 {
+			wait = time.Duration(float64(wait) * (1 + policy.Jitter*(2*h.jitter()-1)))
+		}
+		select {
+		case <-h.after(wait):
+		case <-h.context().Done():
+			return Message{}, h.context().Err()
+		}
+	}
+}
+
+// takeRetry spends one retry from the process's budget, if any is left.
+func (h *Handle) takeRetry(policy RetryPolicy) bool {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &h.proc.retries
+	if policy.BudgetRatio > 0 && float64(b.retries) >= float64(policy.MinRetries)+policy.BudgetRatio*float64(b.calls) {
+		return false
+	}
+	b.retries++
+	return true
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// requestClient starts a process in container "client" that sends each
+// payload from the returned channel as a Request to "api" and reports the
+// outcome.
+func requestClient(t *testing.T, k *Kernel) (chan<- string, <-chan error) {
+	t.Helper()
+	payloads, results := make(chan string), make(chan error)
+	c := newTestContainer(t, k, "client")
+	c.AddProcess(&Process{Name: "caller", Action: func(ctx context.Context, h *Handle) error {
+		for payload := range payloads {
+			_, err := h.Request("api", payload)
+			results <- err
+		}
+		return nil
+	}})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { close(payloads) })
+	return payloads, results
+}
+
+func TestCircuitOpensFailsFastAndCloses(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.CircuitBreaker = CircuitBreakerPolicy{Window: time.Minute, FailureThreshold: 2, Cooldown: 30 * time.Second}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventCircuitChanged}})
+	defer cancel()
+	api := newTestContainer(t, k, "api")
+	api.MaxPayloadBytes = 4 // larger requests fail to deliver
+	api.AddProcess(&Process{Name: "echo", Action: func(ctx context.Context, h *Handle) error {
+		for {
+			req, err := h.Recv()
+			if err != nil {
+				return nil
+			}
+			h.Reply(req, req.Payload)
+		}
+	}})
+	if err := api.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer api.StopProcesses()
+	send, results := requestClient(t, k)
+	request := func(payload string) error {
+		send <- payload
+		return <-results
+	}
+	expectTransition := func(want string) {
+		t.Helper()
+		if e := nextEvent(t, events); e.Detail != want {
+			t.Errorf("transition = %q, want %q", e.Detail, want)
+		}
+	}
+
+	if err := request("ok"); err != nil {
+		t.Fatalf("healthy request: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := request("too large"); !errors.Is(err, ErrPayloadTooLarge) {
+			t.Fatalf("failing request %d: %v", i, err)
+		}
+	}
+	expectTransition("api: closed -> open")
+	if s := k.Stats().Circuits["api"]; s.State != "open" || !s.OpenedAt.Equal(testEpoch) {
+		t.Errorf("circuit stats = %+v", s)
+	}
+
+	// While open, requests fail fast.
+	if err := request("ok"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("during cooldown: %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe reopens the circuit for another cooldown.
+	clk.Advance(30 * time.Second)
+	if err := request("too large"); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("failing probe: %v", err)
+	}
+	expectTransition("api: open -> half-open")
+	expectTransition("api: half-open -> open")
+	if err := request("ok"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("after the failed probe: %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes it.
+	clk.Advance(30 * time.Second)
+	if err := request("ok"); err != nil {
+		t.Errorf("probe: %v", err)
+	}
+	expectTransition("api: open -> half-open")
+	expectTransition("api: half-open -> closed")
+	if got := k.Circuit("api"); got != CircuitClosed {
+		t.Errorf("circuit = %v after a successful probe", got)
+	}
+	if err := request("ok"); err != nil {
+		t.Errorf("after closing: %v", err)
+	}
+}
+
+// retryWaits runs a RequestWithRetry that always fails on a kernel seeded
+// with seed and returns its backoff waits, to the nearest 10ms.
+func retryWaits(t *testing.T, seed int64) []time.Duration {
+	t.Helper()
+	k, clk := newTestKernel(t)
+	k.Rand = rand.New(rand.NewSource(seed))
+	api := newTestContainer(t, k, "api")
+	api.MaxPayloadBytes = 4
+	client := newTestContainer(t, k, "client")
+	result := make(chan error, 1)
+	client.AddProcess(&Process{Name: "caller", Action: func(ctx context.Context, h *Handle) error {
+		_, err := h.RequestWithRetry("api", "too large", 0, RetryPolicy{
+			Attempts: 3, Backoff: time.Second, MaxBackoff: 1500 * time.Millisecond, Jitter: 0.5,
+		})
+		result <- err
+		return nil
+	}})
+	if err := client.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	var waits []time.Duration
+	for range 2 {
+		waitForWaiters(t, clk, 1)
+		var d time.Duration
+		for clk.Waiters() > 0 {
+			clk.Advance(10 * time.Millisecond)
+			d += 10 * time.Millisecond
+		}
+		waits = append(waits, d)
+	}
+	if err := <-result; !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("RequestWithRetry: %v", err)
+	}
+	return waits
+}
+
+func TestRequestWithRetryJitterFollowsKernelRand(t *testing.T) {
+	waits := retryWaits(t, 7)
+	for i, base := range []time.Duration{time.Second, 1500 * time.Millisecond} {
+		if w := waits[i]; w < base/2 || w > base*3/2+10*time.Millisecond {
+			t.Errorf("wait %d = %v, want %v ± 50%%", i+1, w, base)
+		}
+	}
+	if again := retryWaits(t, 7); again[0] != waits[0] || again[1] != waits[1] {
+		t.Errorf("waits %v, then %v with the same seed", waits, again)
+	}
+}
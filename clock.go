@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --- Clock ---
+
+// Clock abstracts time so that simulations can be driven deterministically.
+// The kernel uses its Clock for every timestamp and timer it owns.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// FakeClock is a manually advanced Clock. Sleepers and After channels fire
+// only when Advance or Set moves the clock past their deadline.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFakeClock returns a FakeClock starting at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	deadline := c.now.Add(d)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: deadline, ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing any timers that become due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	t := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(t)
+}
+
+// Set moves the clock to t, firing any timers that become due in deadline
+// order. Moving the clock backwards is ignored.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	if t.Before(c.now) {
+		c.mu.Unlock()
+		return
+	}
+	c.now = t
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+	var due []fakeWaiter
+	kept := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.deadline.After(t) {
+			due = append(due, w)
+		} else {
+			kept = append(kept, w)
+		}
+	}
+	c.waiters = kept
+	c.mu.Unlock()
+	for _, w := range due {
+		w.ch <- t
+	}
+}
+
+// Waiters reports how many timers are pending on the clock. Tests use it to
+// wait until a goroutine has blocked before advancing time.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// --- Accelerated Clock ---
+
+// AcceleratedClock runs Factor times faster than the wall clock, so that a
+// simulated day can pass in minutes. Timers are kept as simulated deadlines:
+// when the factor changes, pending timers still fire at the same simulated
+// time, with their remaining wall-clock wait rescaled.
+//
+// Install it as Kernel.Clock before creating containers. Components that
+// deal with real clients, such as HTTP handlers, keep using wall time.
+type AcceleratedClock struct {
+	mu         sync.Mutex
+	factor     float64
+	anchorReal time.Time // wall time at the last factor change
+	anchorSim  time.Time // simulated time at the last factor change
+	waiters    []fakeWaiter
+	running    bool          // the timer loop is active
+	wake       chan struct{} // nudges the timer loop to recompute its wait
+}
+
+// NewAcceleratedClock returns a clock starting at the current wall time and
+// running factor times faster. A factor of 1 behaves like the wall clock.
+func NewAcceleratedClock(factor float64) *AcceleratedClock {
+	now := time.Now()
+	return &AcceleratedClock{
+		factor:     validFactor(factor),
+		anchorReal: now,
+		anchorSim:  now,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+func validFactor(f float64) float64 {
+	if f <= 0 {
+		return 1
+	}
+	return f
+}
+
+func (c *AcceleratedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nowLocked(time.Now())
+}
+
+func (c *AcceleratedClock) nowLocked(real time.Time) time.Time {
+	return c.anchorSim.Add(time.Duration(float64(real.Sub(c.anchorReal)) * c.factor))
+}
+
+func (c *AcceleratedClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *AcceleratedClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	now := c.nowLocked(time.Now())
+	if d <= 0 {
+		ch <- now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: now.Add(d), ch: ch})
+	if !c.running {
+		c.running = true
+		go c.loop()
+	} else {
+		c.nudge()
+	}
+	return ch
+}
+
+// Factor reports the current acceleration factor.
+func (c *AcceleratedClock) Factor() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.factor
+}
+
+// SetFactor changes the acceleration from now on. Simulated time stays
+// continuous and pending timers keep their simulated deadlines. A
+// non-positive factor is treated as 1.
+func (c *AcceleratedClock) SetFactor(factor float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	real := time.Now()
+	c.anchorSim = c.nowLocked(real)
+	c.anchorReal = real
+	c.factor = validFactor(factor)
+	c.nudge()
+}
+
+func (c *AcceleratedClock) nudge() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop fires due timers in deadline order and sleeps in wall time until the
+// next one. It exits once no timers are pending.
+func (c *AcceleratedClock) loop() {
+	for {
+		c.mu.Lock()
+		now := c.nowLocked(time.Now())
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+		})
+		n := 0
+		for n < len(c.waiters) && !c.waiters[n].deadline.After(now) {
+			c.waiters[n].ch <- now
+			n++
+		}
+		c.waiters = c.waiters[n:]
+		if len(c.waiters) == 0 {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		wait := time.Duration(float64(c.waiters[0].deadline.Sub(now)) / c.factor)
+		c.mu.Unlock()
+
+		t := time.NewTimer(max(wait, time.Microsecond))
+		select {
+		case <-t.C:
+		case <-c.wake:
+			t.Stop()
+		}
+	}
+}
+
+// ErrClockNotAccelerated is returned by SetTimeAcceleration when the kernel
+// clock is not an AcceleratedClock.
+var ErrClockNotAccelerated = errors.New("kernel clock is not an AcceleratedClock")
+
+// SetTimeAcceleration changes the acceleration factor of the kernel clock at
+// runtime. The clock must have been installed with NewAcceleratedClock.
+func (k *Kernel) SetTimeAcceleration(factor float64) error {
+	ac, ok := k.Clock.(*AcceleratedClock)
+	if !ok {
+		return ErrClockNotAccelerated
+	}
+	ac.SetFactor(factor)
+	return nil
+}
+
+// TimeAcceleration reports how fast the kernel clock runs relative to wall
+// time: the factor of an AcceleratedClock, 1 for the wall clock and 0 for
+// any other clock.
+func (k *Kernel) TimeAcceleration() float64 {
+	switch c := k.Clock.(type) {
+	case *AcceleratedClock:
+		return c.Factor()
+	case realClock:
+		return 1
+	}
+	return 0
+}
+
+// --- Clock Skew ---
+
+// ErrInvalidClockSkew is returned for a drift that would stop or reverse a
+// container's clock.
+var ErrInvalidClockSkew = errors.New("invalid clock skew")
+
+// clockSkew makes a container's local clock read offset ahead of the kernel
+// clock at anchor, gaining drift per hour of kernel time from then on.
+type clockSkew struct {
+	offset time.Duration
+	drift  time.Duration // per hour
+	anchor time.Time
+}
+
+func (s *clockSkew) local(t time.Time) time.Time {
+	gained := time.Duration(float64(t.Sub(s.anchor)) * float64(s.drift) / float64(time.Hour))
+	return t.Add(s.offset + gained)
+}
+
+// rate is how fast local time passes relative to kernel time.
+func (s *clockSkew) rate() float64 {
+	return 1 + float64(s.drift)/float64(time.Hour)
+}
+
+// ContainerOption configures a container in CreateContainer.
+type ContainerOption func(*Container) error
+
+// WithClockSkew gives the container a local clock that reads offset ahead
+// of the kernel clock (behind if negative) and gains driftPerHour for every
+// hour of kernel time. See SetClockSkew.
+func WithClockSkew(offset, driftPerHour time.Duration) ContainerOption {
+	return func(c *Container) error {
+		return c.setClockSkew(offset, driftPerHour, false)
+	}
+}
+
+// SetClockSkew steps the container's local clock to read offset ahead of
+// the kernel clock from now on, drifting by driftPerHour, as an NTP step
+// would, and emits EventClockStep. A zero offset and drift remove the
+// skew.
+//
+// The local clock is what the container's processes see: Handle.Now, the
+// SentAt of messages they send, their log timestamps, and the durations of
+// Handle.Sleep and other waits, which pass at the local rate. The kernel's
+// own bookkeeping, such as process start and finish times, scheduling and
+// event times, stays on the kernel clock; events about a skewed container
+// also carry its LocalTime.
+func (c *Container) SetClockSkew(offset, driftPerHour time.Duration) error {
+	return c.setClockSkew(offset, driftPerHour, true)
+}
+
+func (c *Container) setClockSkew(offset, drift time.Duration, step bool) error {
+	if drift <= -time.Hour {
+		return fmt.Errorf("%w: container %s: drift %s per hour", ErrInvalidClockSkew, c.ID, drift)
+	}
+	old := c.skew.Load()
+	if offset == 0 && drift == 0 {
+		c.skew.Store(nil)
+	} else {
+		c.skew.Store(&clockSkew{offset: offset, drift: drift, anchor: c.now()})
+	}
+	if step {
+		var from time.Duration
+		if old != nil {
+			from = old.local(c.now()).Sub(c.now())
+		}
+		fmt.Printf("[Kernel] Clock step in %s: offset %s -> %s, drift %s/h\n", c.Name, from, offset, drift)
+		c.emit(EventClockStep, nil, fmt.Sprintf("offset %s -> %s, drift %s/h", from, offset, drift))
+	}
+	return nil
+}
+
+// LocalNow returns the time on the container's local clock.
+func (c *Container) LocalNow() time.Time {
+	return c.localTime(c.now())
+}
+
+// localTime converts kernel time t to the container's local clock.
+func (c *Container) localTime(t time.Time) time.Time {
+	if s := c.skew.Load(); s != nil {
+		return s.local(t)
+	}
+	return t
+}
+
+// kernelDuration converts a duration on the container's local clock to
+// kernel time.
+func (c *Container) kernelDuration(d time.Duration) time.Duration {
+	if s := c.skew.Load(); s != nil {
+		return time.Duration(float64(d) / s.rate())
+	}
+	return d
+}
+
+// Now returns the time on the process's container clock, which differs
+// from the kernel clock if the container is skewed.
+func (h *Handle) Now() time.Time {
+	if h.container == nil {
+		return time.Now()
+	}
+	return h.container.LocalNow()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAcceleratedClockRunsHourlyJobThroughADay(t *testing.T) {
+	k, _ := newTestKernel(t)
+	// A simulated day passes in about 50ms of wall time.
+	k.Clock = NewAcceleratedClock(24 * 60 * 60 * 20)
+	c := newTestContainer(t, k, "cron")
+	start := k.Clock.Now()
+	var fired []time.Time
+	p := &Process{Name: "hourly", Action: func(ctx context.Context, h *Handle) error {
+		for len(fired) < 24 {
+			if err := h.Sleep(time.Hour); err != nil {
+				return err
+			}
+			fired = append(fired, k.Clock.Now())
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if len(fired) != 24 {
+		t.Fatalf("job fired %d times in a simulated day, want 24", len(fired))
+	}
+	for i, at := range fired {
+		if want := start.Add(time.Duration(i+1) * time.Hour); at.Before(want) {
+			t.Errorf("run %d at %v, before its deadline %v", i+1, at.Sub(start), want.Sub(start))
+		}
+	}
+	if got := k.Stats().TimeAcceleration; got != 24*60*60*20 {
+		t.Errorf("Stats().TimeAcceleration = %v", got)
+	}
+}
+
+func TestAcceleratedClockFactorChangeKeepsDeadlines(t *testing.T) {
+	clk := NewAcceleratedClock(1)
+	start := clk.Now()
+	later := clk.After(2 * time.Hour)
+	sooner := clk.After(time.Hour)
+	// At 1x these would take hours; at 360000x an hour is 10ms.
+	clk.SetFactor(360000)
+
+	for i, want := range []struct {
+		ch       <-chan time.Time
+		deadline time.Duration
+	}{{sooner, time.Hour}, {later, 2 * time.Hour}} {
+		select {
+		case at := <-want.ch:
+			if at.Sub(start) < want.deadline {
+				t.Errorf("timer %d fired at %v, before its deadline %v", i, at.Sub(start), want.deadline)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timer %d did not fire after the speed-up", i)
+		}
+		select {
+		case <-later:
+			if i == 0 {
+				t.Error("the two-hour timer fired alongside the one-hour timer")
+			}
+		default:
+		}
+	}
+	if got := clk.Factor(); got != 360000 {
+		t.Errorf("Factor = %v", got)
+	}
+}
+
+func TestSetTimeAccelerationNeedsAcceleratedClock(t *testing.T) {
+	k, _ := newTestKernel(t)
+	if err := k.SetTimeAcceleration(10); !errors.Is(err, ErrClockNotAccelerated) {
+		t.Errorf("err = %v, want ErrClockNotAccelerated", err)
+	}
+	if got := k.TimeAcceleration(); got != 0 {
+		t.Errorf("TimeAcceleration with a FakeClock = %v, want 0", got)
+	}
+	k.Clock = NewAcceleratedClock(2)
+	if err := k.SetTimeAcceleration(60); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.TimeAcceleration(); got != 60 {
+		t.Errorf("TimeAcceleration = %v, want 60", got)
+	}
+}
+
+func TestSkewedContainersDisagreeAboutTime(t *testing.T) {
+	k, clk := newTestKernel(t)
+	east := newTestContainer(t, k, "east", WithClockSkew(5*time.Second, 0))
+	west := newTestContainer(t, k, "west", WithClockSkew(-3*time.Second, time.Minute))
+	west.AddProcess(&Process{Name: "listener"})
+	if err := k.SendMessage("east", "west", "ping"); err != nil {
+		t.Fatal(err)
+	}
+	sent := west.Inbox()[0].SentAt
+	if !sent.Equal(testEpoch.Add(5 * time.Second)) {
+		t.Errorf("SentAt = %v, want east's clock", sent.Sub(testEpoch))
+	}
+	if got := west.LocalNow().Sub(sent); got != -8*time.Second {
+		t.Errorf("west sees the message sent %v from now, want -8s", got)
+	}
+
+	clk.Advance(time.Hour)
+	if got := west.LocalNow().Sub(clk.Now()); got != -3*time.Second+time.Minute {
+		t.Errorf("west is %v off after an hour, want the offset plus a minute of drift", got)
+	}
+	if got := east.LocalNow().Sub(clk.Now()); got != 5*time.Second {
+		t.Errorf("east is %v off, want 5s", got)
+	}
+	if _, err := k.CreateContainer("broken", "broken", 64, WithClockSkew(0, -time.Hour)); !errors.Is(err, ErrInvalidClockSkew) {
+		t.Errorf("a clock that stops: %v", err)
+	}
+}
+
+func TestClockStepKeepsScheduleOrder(t *testing.T) {
+	k, clk := newTestKernel(t)
+	east := newTestContainer(t, k, "east", WithClockSkew(5*time.Second, 0))
+	west := newTestContainer(t, k, "west")
+	west.AddProcess(&Process{Name: "listener"})
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventClockStep}})
+	defer cancel()
+
+	if _, err := k.SendAfter("east", "west", "first", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := east.SetClockSkew(-time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+	e := nextEvent(t, events)
+	if e.ContainerID != "east" || e.Detail != "offset 5s -> -1h0m0s, drift 0s/h" {
+		t.Errorf("event = %+v", e)
+	}
+	if !e.Time.Equal(testEpoch) || !e.LocalTime.Equal(testEpoch.Add(-time.Hour)) {
+		t.Errorf("event at %v, local %v; want both clocks recorded", e.Time, e.LocalTime)
+	}
+	if _, err := k.SendAfter("east", "west", "second", 20*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deadlines are on the kernel clock, so the step moves neither.
+	for i := 1; i <= 2; i++ {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(10 * time.Second)
+		eventually(t, fmt.Sprintf("delivery %d", i), func() bool { return len(west.Inbox()) == i })
+	}
+	inbox := west.Inbox()
+	if inbox[0].Payload != "first" || inbox[1].Payload != "second" {
+		t.Fatalf("inbox = %v, want the schedule order", inbox)
+	}
+	for i, m := range inbox {
+		if want := testEpoch.Add(time.Duration(i+1)*10*time.Second - time.Hour); !m.SentAt.Equal(want) {
+			t.Errorf("%s SentAt = %v, want east's stepped clock", m.Payload, m.SentAt)
+		}
+	}
+	if got := east.LocalNow().Sub(clk.Now()); got != -time.Hour {
+		t.Errorf("east is %v off after the step, want -1h", got)
+	}
+}
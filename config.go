@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// --- Declarative Specs ---
+
+// Spec declares a container and its processes as written in spec files.
+// A spec may depend on container IDs or, with the "group:" prefix, on every
+// container of a group.
+type Spec struct {
+	ContainerSpec
+	Group     string        `json:"group,omitempty"`
+	Processes []ProcessSpec `json:"processes,omitempty"`
+}
+
+// SpecError locates a problem in a spec file.
+type SpecError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *SpecError) Unwrap() error { return e.Err }
+
+// SpecErrors collects every problem found while validating a set of specs.
+type SpecErrors []*SpecError
+
+func (e SpecErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap lets errors.Is and errors.As look through every collected error.
+func (e SpecErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+var ErrUnsupportedSpecFormat = errors.New("unsupported spec format")
+
+// LoadSpec decodes specs from r. The input is either a single JSON spec
+// object or an array of them.
+func LoadSpec(r io.Reader) ([]Spec, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	located, err := parseSpecs("<input>", data)
+	if err != nil {
+		return nil, err
+	}
+	specs := make([]Spec, len(located))
+	for i, ls := range located {
+		specs[i] = ls.Spec
+	}
+	return specs, nil
+}
+
+// locatedSpec is a Spec together with where it was defined.
+type locatedSpec struct {
+	Spec
+	File string
+	Line int
+}
+
+func (ls *locatedSpec) errorf(format string, args ...any) *SpecError {
+	return &SpecError{File: ls.File, Line: ls.Line, Err: fmt.Errorf(format, args...)}
+}
+
+func parseSpecs(file string, data []byte) ([]locatedSpec, error) {
+	switch strings.ToLower(path.Ext(file)) {
+	case ".yaml", ".yml":
+		return nil, &SpecError{File: file, Line: 1,
+			Err: fmt.Errorf("%w: YAML specs need a YAML decoder; convert to JSON", ErrUnsupportedSpecFormat)}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	decodeErr := func(off int64, err error) error {
+		var syn *json.SyntaxError
+		if errors.As(err, &syn) {
+			off = syn.Offset
+		}
+		return &SpecError{File: file, Line: lineAt(data, off), Err: err}
+	}
+
+	start := firstNonSpace(data)
+	if start < len(data) && data[start] == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, decodeErr(dec.InputOffset(), err)
+		}
+		var out []locatedSpec
+		for dec.More() {
+			off := dec.InputOffset()
+			var s Spec
+			if err := dec.Decode(&s); err != nil {
+				return nil, decodeErr(off, err)
+			}
+			out = append(out, locatedSpec{Spec: s, File: file, Line: lineAt(data, off+int64(firstNonSpace(data[off:])))})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, decodeErr(dec.InputOffset(), err)
+		}
+		return out, nil
+	}
+	var s Spec
+	if err := dec.Decode(&s); err != nil {
+		return nil, decodeErr(dec.InputOffset(), err)
+	}
+	return []locatedSpec{{Spec: s, File: file, Line: lineAt(data, int64(start))}}, nil
+}
+
+func firstNonSpace(data []byte) int {
+	for i, b := range data {
+		switch b {
+		case ' ', '\t', '\r', '\n', ',':
+		default:
+			return i
+		}
+	}
+	return len(data)
+}
+
+func lineAt(data []byte, off int64) int {
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+	return bytes.Count(data[:off], []byte("\n")) + 1
+}
+
+// Apply creates the container described by spec, or updates an existing one
+// with the same ID: its name, memory, labels, env, volumes and dependencies
+// are replaced and processes not yet present (by name) are added.
+func (k *Kernel) Apply(spec Spec) (*Container, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	if err := validateContainer(spec.ID, spec.Name, spec.MemoryMB); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	procs := make([]*Process, 0, len(spec.Processes))
+	for _, ps := range spec.Processes {
+		p, err := k.newProcessLocked(ps)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %w", spec.ID, err)
+		}
+		procs = append(procs, p)
+	}
+	c, ok := k.Containers[spec.ID]
+	if !ok {
+		c = k.createContainerLocked(spec.ID, spec.Name, spec.MemoryMB)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applySpecLocked(spec.ContainerSpec)
+	existing := make(map[string]bool, len(c.Processes))
+	for _, p := range c.Processes {
+		existing[p.Name] = true
+	}
+	for _, p := range procs {
+		if existing[p.Name] {
+			continue
+		}
+		c.addProcessLocked(p)
+	}
+	return c, nil
+}
+
+// ApplyResult reports what ApplyDir did.
+type ApplyResult struct {
+	Applied []string // container IDs applied, in order
+	Skipped []string // container IDs not applied because an earlier apply failed
+	Pruned  []string // container IDs removed by WithPrune
+}
+
+type applyOptions struct {
+	prune bool
+}
+
+// ApplyOption configures ApplyDir.
+type ApplyOption func(*applyOptions)
+
+// WithPrune removes kernel containers that are not defined by any spec.
+func WithPrune() ApplyOption {
+	return func(o *applyOptions) { o.prune = true }
+}
+
+// ApplyDir loads every spec file in fsys matching pattern, validates all of
+// them up front and applies them in dependency order. Validation reports
+// every problem (as SpecErrors) and applies nothing. If an apply fails part
+// way, the result lists what was applied and what was skipped.
+func ApplyDir(k *Kernel, fsys fs.FS, pattern string, opts ...ApplyOption) (*ApplyResult, error) {
+	var o applyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var specs []locatedSpec
+	var errs SpecErrors
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			errs = append(errs, &SpecError{File: file, Line: 0, Err: err})
+			continue
+		}
+		parsed, err := parseSpecs(file, data)
+		if err != nil {
+			var se *SpecError
+			if errors.As(err, &se) {
+				errs = append(errs, se)
+			} else {
+				errs = append(errs, &SpecError{File: file, Err: err})
+			}
+			continue
+		}
+		specs = append(specs, parsed...)
+	}
+
+	ordered, verrs := k.validateSpecs(specs)
+	errs = append(errs, verrs...)
+	if len(errs) > 0 {
+		return &ApplyResult{}, errs
+	}
+
+	res := &ApplyResult{}
+	for i, ls := range ordered {
+		if _, err := k.Apply(ls.Spec); err != nil {
+			for _, rest := range ordered[i:] {
+				res.Skipped = append(res.Skipped, rest.ID)
+			}
+			return res, ls.errorf("apply: %w", err)
+		}
+		res.Applied = append(res.Applied, ls.ID)
+	}
+
+	if o.prune {
+		defined := make(map[string]bool, len(ordered))
+		for _, ls := range ordered {
+			defined[ls.ID] = true
+		}
+		k.mu.Lock()
+		var stale []string
+		for id := range k.Containers {
+			if !defined[id] {
+				stale = append(stale, id)
+			}
+		}
+		k.mu.Unlock()
+		sort.Strings(stale)
+		for _, id := range stale {
+			if err := k.RemoveContainer(id); err == nil {
+				res.Pruned = append(res.Pruned, id)
+			}
+		}
+	}
+	return res, nil
+}
+
+// validateSpecs checks specs individually and as a set, and returns them in
+// dependency order.
+func (k *Kernel) validateSpecs(specs []locatedSpec) ([]*locatedSpec, SpecErrors) {
+	k.mu.Lock()
+	existing := make(map[string]bool, len(k.Containers))
+	for id := range k.Containers {
+		existing[id] = true
+	}
+	k.mu.Unlock()
+
+	var errs SpecErrors
+	byID := make(map[string]*locatedSpec, len(specs))
+	groups := make(map[string][]string)
+	for i := range specs {
+		ls := &specs[i]
+		if ls.ID == "" {
+			errs = append(errs, ls.errorf("container id is required"))
+			continue
+		}
+		if prev, dup := byID[ls.ID]; dup {
+			errs = append(errs, ls.errorf("container %s already defined at %s:%d", ls.ID, prev.File, prev.Line))
+			continue
+		}
+		byID[ls.ID] = ls
+		if ls.Name == "" {
+			errs = append(errs, ls.errorf("container %s: name is required", ls.ID))
+		}
+		if ls.MemoryMB <= 0 {
+			errs = append(errs, ls.errorf("container %s: memory_mb must be positive", ls.ID))
+		}
+		for _, ps := range ls.Processes {
+			if ps.Name == "" {
+				errs = append(errs, ls.errorf("container %s: process name is required", ls.ID))
+			}
+			if err := k.checkKind(ps.Kind); err != nil {
+				errs = append(errs, ls.errorf("container %s: process %s: %v", ls.ID, ps.Name, err))
+			}
+		}
+		if ls.Group != "" {
+			groups[ls.Group] = append(groups[ls.Group], ls.ID)
+		}
+	}
+
+	// Expand group references and check every dependency resolves.
+	deps := make(map[string][]string, len(byID))
+	for _, ls := range byID {
+		for _, dep := range ls.DependsOn {
+			if g, ok := strings.CutPrefix(dep, "group:"); ok {
+				members, found := groups[g]
+				if !found {
+					errs = append(errs, ls.errorf("container %s: unknown group %q", ls.ID, g))
+				}
+				for _, m := range members {
+					if m != ls.ID {
+						deps[ls.ID] = append(deps[ls.ID], m)
+					}
+				}
+				continue
+			}
+			if _, ok := byID[dep]; !ok && !existing[dep] {
+				errs = append(errs, ls.errorf("container %s: unknown dependency %q", ls.ID, dep))
+				continue
+			}
+			deps[ls.ID] = append(deps[ls.ID], dep)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	// Topological order; ties broken by ID for stable output.
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	mark := make(map[string]int, len(ids))
+	var ordered []*locatedSpec
+	var visit func(id string, chain []string) bool
+	visit = func(id string, chain []string) bool {
+		ls, ours := byID[id]
+		if !ours {
+			return true // already in the kernel
+		}
+		switch mark[id] {
+		case done:
+			return true
+		case visiting:
+			errs = append(errs, ls.errorf("dependency cycle: %s", strings.Join(append(chain, id), " -> ")))
+			return false
+		}
+		mark[id] = visiting
+		for _, dep := range deps[id] {
+			if !visit(dep, append(chain, id)) {
+				return false
+			}
+		}
+		mark[id] = done
+		ordered = append(ordered, ls)
+		return true
+	}
+	for _, id := range ids {
+		if mark[id] == unvisited && !visit(id, nil) {
+			return nil, errs
+		}
+	}
+
+	// Expanded group dependencies are what the kernel should record.
+	for _, ls := range ordered {
+		ls.DependsOn = deps[ls.ID]
+	}
+	return ordered, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func specFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return fsys
+}
+
+var validSpecs = map[string]string{
+	"specs/db.json": `[
+  {"id": "db", "name": "db", "memory_mb": 512, "group": "data",
+   "processes": [{"name": "engine", "kind": "worker"}]},
+  {"id": "cache", "name": "cache", "memory_mb": 256, "group": "data"}
+]`,
+	"specs/web.json": `{"id": "web", "name": "web", "memory_mb": 256, "depends_on": ["group:data", "auth"]}`,
+	"specs/auth.json": `{"id": "auth", "name": "auth", "memory_mb": 128, "depends_on": ["db"],
+ "processes": [{"name": "login", "kind": "worker", "priority": 2}]}`,
+}
+
+func TestApplyDirCreatesDependencyOrderedSet(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerWorker(k, "worker")
+
+	res, err := ApplyDir(k, specFS(validSpecs), "specs/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dependencies first, ties broken by ID.
+	if want := []string{"db", "auth", "cache", "web"}; !reflect.DeepEqual(res.Applied, want) {
+		t.Errorf("applied %v, want %v", res.Applied, want)
+	}
+	if len(k.Containers) != 4 {
+		t.Fatalf("%d containers, want 4", len(k.Containers))
+	}
+	if got := k.Containers["web"].DependsOn; !reflect.DeepEqual(got, []string{"db", "cache", "auth"}) {
+		t.Errorf("web depends on %v, want the data group expanded plus auth", got)
+	}
+	login := k.Containers["auth"].ProcessesByName("login")
+	if len(login) != 1 || login[0].Priority != 2 || login[0].Kind != "worker" {
+		t.Errorf("auth processes = %+v", login)
+	}
+}
+
+func TestApplyDirReportsEveryErrorAndAppliesNothing(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerWorker(k, "worker")
+	files := map[string]string{}
+	for name, data := range validSpecs {
+		files[name] = data
+	}
+	files["specs/bad.json"] = `[
+  {"id": "queue", "name": "queue", "memory_mb": 0},
+  {"id": "mailer", "name": "mailer", "memory_mb": 64,
+   "processes": [{"name": "send", "kind": "smtp"}]}
+]`
+
+	res, err := ApplyDir(k, specFS(files), "specs/*.json")
+	var errs SpecErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want SpecErrors", err)
+	}
+	want := []string{
+		"specs/bad.json:2: container queue: memory_mb must be positive",
+		`specs/bad.json:3: container mailer: process send: unknown process kind: "smtp"`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors:\n%v\nwant %d", err, len(want))
+	}
+	for i, w := range want {
+		if errs[i].Error() != w {
+			t.Errorf("error %d = %q, want %q", i, errs[i].Error(), w)
+		}
+	}
+	if len(res.Applied) != 0 || len(k.Containers) != 0 {
+		t.Errorf("applied %v with invalid specs", res.Applied)
+	}
+}
+
+func TestApplyDirRejectsCyclesAndYAML(t *testing.T) {
+	k, _ := newTestKernel(t)
+	_, err := ApplyDir(k, specFS(map[string]string{
+		"a.json": `{"id": "a", "name": "a", "memory_mb": 1, "depends_on": ["b"]}`,
+		"b.json": `{"id": "b", "name": "b", "memory_mb": 1, "depends_on": ["a"]}`,
+	}), "*.json")
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle: a -> b -> a") {
+		t.Errorf("cycle: err = %v", err)
+	}
+
+	_, err = ApplyDir(k, specFS(map[string]string{"a.yaml": "id: a\n"}), "*.yaml")
+	if !errors.Is(err, ErrUnsupportedSpecFormat) {
+		t.Errorf("yaml: err = %v, want ErrUnsupportedSpecFormat", err)
+	}
+	if len(k.Containers) != 0 {
+		t.Error("invalid specs created containers")
+	}
+}
+
+func TestApplyDirPrune(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerWorker(k, "worker")
+	newTestContainer(t, k, "legacy")
+
+	res, err := ApplyDir(k, specFS(validSpecs), "specs/*.json", WithPrune())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Pruned, []string{"legacy"}) {
+		t.Errorf("pruned %v, want [legacy]", res.Pruned)
+	}
+	if _, ok := k.Containers["legacy"]; ok {
+		t.Error("legacy container still registered")
+	}
+}
+
+func TestLoadSpecLocatesSyntaxErrors(t *testing.T) {
+	_, err := LoadSpec(strings.NewReader("[\n{\"id\": \"a\"},\n{\"id\": }\n]"))
+	var se *SpecError
+	if !errors.As(err, &se) || se.Line != 3 {
+		t.Errorf("err = %v, want a SpecError on line 3", err)
+	}
+}
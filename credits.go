@@ -0,0 +1,84 @@
+package main
+
+import "time"
+
+// --- CPU Burst Credits ---
+
+// CPUCredits is a token bucket modelling burstable CPU. A process earns
+// credits at RefillRate per second while it is not running and spends them
+// at BurnRate per second while it runs. The scheduler will not start a
+// process whose balance is exhausted; it is marked Throttled instead and
+// reconsidered once it has earned credits back.
+type CPUCredits struct {
+	Balance    float64 // credits currently available
+	Max        float64 // bucket capacity
+	RefillRate float64 // credits earned per idle second
+	BurnRate   float64 // credits spent per running second
+
+	updated time.Time
+}
+
+// NewCPUCredits returns a full bucket of max credits refilling at
+// refillRate per second and burning one credit per running second.
+func NewCPUCredits(max, refillRate float64) *CPUCredits {
+	return &CPUCredits{
+		Balance:    max,
+		Max:        max,
+		RefillRate: refillRate,
+		BurnRate:   1,
+	}
+}
+
+// settle brings the balance up to date at now. running reports whether the
+// owning process was running since the last update, i.e. whether the
+// elapsed time is spent or earned.
+func (b *CPUCredits) settle(now time.Time, running bool) {
+	if b.updated.IsZero() {
+		b.updated = now
+		return
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.updated = now
+	if elapsed <= 0 {
+		return
+	}
+	if running {
+		b.Balance -= elapsed * b.BurnRate
+		return
+	}
+	b.Balance += elapsed * b.RefillRate
+	if b.Balance > b.Max {
+		b.Balance = b.Max
+	}
+}
+
+// Exhausted reports whether the bucket has no credits left to spend.
+func (b *CPUCredits) Exhausted() bool {
+	return b.Balance <= 0
+}
+
+// refillWait is how long the bucket takes to earn back a positive balance
+// (rounded up a little so the balance is positive by then), or zero if it
+// never refills.
+func (b *CPUCredits) refillWait() time.Duration {
+	if b.RefillRate <= 0 || b.Balance > 0 {
+		return 0
+	}
+	return time.Duration(-b.Balance/b.RefillRate*float64(time.Second)) + time.Millisecond
+}
+
+// wakeOnRefillLocked arms a scheduling pass for when throttled p has
+// earned credits back, so it does not wait for an unrelated pass.
+func (c *Container) wakeOnRefillLocked(p *Process, now time.Time) {
+	wait := p.CPUCredits.refillWait()
+	if wait <= 0 || c.kernel == nil || now.Before(p.creditsDue) {
+		return // never refills, or already armed
+	}
+	p.creditsDue = now.Add(wait)
+	k := c.kernel
+	due := k.Clock.After(wait)
+	go func() {
+		<-due
+		k.kick()
+	}()
+}
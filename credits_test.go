@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// spendCredits runs p once for d of fake time, leaving its credits spent,
+// and queues it again to run until its container stops.
+func spendCredits(t *testing.T, c *Container, clk *FakeClock, p *Process, release chan struct{}, d time.Duration) {
+	t.Helper()
+	c.AddProcess(p)
+	if got := stateOf(c, p); got != Running {
+		t.Fatalf("%s = %v, want Running", p.Name, got)
+	}
+	clk.Advance(d)
+	close(release)
+	waitDone(t, p)
+	c.mu.Lock()
+	p.Action = blockUntil(nil)
+	c.requeueLocked([]*Process{p})
+	c.scheduleLocked()
+	c.mu.Unlock()
+}
+
+func TestExhaustedCreditsThrottle(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "burst")
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	throttled, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventProcessThrottled}})
+	defer cancel()
+
+	release := make(chan struct{})
+	hog := &Process{Name: "hog", Action: blockUntil(release), CPUCredits: NewCPUCredits(2, 0)}
+	spendCredits(t, c, clk, hog, release, 3*time.Second)
+
+	if got := stateOf(c, hog); got != Throttled {
+		t.Fatalf("hog = %v, want Throttled", got)
+	}
+	if e := nextEvent(t, throttled); e.Process != "hog" {
+		t.Errorf("throttle event for %q, want hog", e.Process)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	fresh := &Process{Name: "fresh", Action: blockUntil(done), CPUCredits: NewCPUCredits(2, 0)}
+	c.AddProcess(fresh)
+	if got := stateOf(c, fresh); got != Running {
+		t.Errorf("fresh = %v, want Running", got)
+	}
+	if got := stateOf(c, hog); got != Throttled {
+		t.Errorf("hog = %v after another pass, want Throttled", got)
+	}
+}
+
+func TestThrottledProcessStartsOnceCreditsRefill(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "burst")
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	p := &Process{Name: "hog", Action: blockUntil(release), CPUCredits: NewCPUCredits(2, 1)}
+	spendCredits(t, c, clk, p, release, 3*time.Second) // balance -1, back above zero in 1s
+	if got := stateOf(c, p); got != Throttled {
+		t.Fatalf("hog = %v, want Throttled", got)
+	}
+
+	// Nothing else happens in the container: only the refill timer can
+	// start the process again.
+	waitForWaiters(t, clk, 1)
+	clk.Advance(500 * time.Millisecond)
+	if got := stateOf(c, p); got != Throttled {
+		t.Fatalf("hog = %v before the refill, want Throttled", got)
+	}
+	clk.Advance(time.Second)
+	eventually(t, "hog to run after refilling", func() bool { return stateOf(c, p) == Running })
+	c.StopProcesses()
+}
+
+func TestCreditsSettle(t *testing.T) {
+	b := NewCPUCredits(10, 2)
+	b.settle(testEpoch, false)
+	b.settle(testEpoch.Add(4*time.Second), true)
+	if b.Balance != 6 {
+		t.Errorf("after 4s running: %v, want 6", b.Balance)
+	}
+	b.settle(testEpoch.Add(10*time.Second), false)
+	if b.Balance != 10 {
+		t.Errorf("after 6s idle: %v, want capped at 10", b.Balance)
+	}
+	b.Balance = -3
+	if got := b.refillWait(); got != 1500*time.Millisecond+time.Millisecond {
+		t.Errorf("refillWait = %v, want 1.501s", got)
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Debug Introspection ---
+
+// debugLockBudget bounds how long a dump waits for any single kernel or
+// container lock. Locks that cannot be taken in time are reported as busy
+// instead of stalling the dump, which matters most when the kernel is
+// deadlocked.
+const debugLockBudget = 50 * time.Millisecond
+
+// tryLockFor takes m if it becomes free within d.
+func tryLockFor(m *probedMutex, d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for {
+		if m.mu.TryLock() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+// ProcessGoroutines describes the goroutines running one process's action.
+type ProcessGoroutines struct {
+	Container  string    `json:"container"`
+	PID        int       `json:"pid"`
+	Process    string    `json:"process"`
+	State      string    `json:"state"`
+	Goroutines int       `json:"goroutines"`
+	Since      time.Time `json:"since"`
+	Abandoned  bool      `json:"abandoned,omitempty"` // force-killed by Drain
+}
+
+// QueuedProcess is one entry of a container's admission queue.
+type QueuedProcess struct {
+	PID        int    `json:"pid"`
+	Process    string `json:"process"`
+	Priority   int    `json:"priority"`
+	State      string `json:"state"`
+	WaitReason string `json:"wait_reason,omitempty"`
+}
+
+// ContainerDump is the per-container part of a DebugDump.
+type ContainerDump struct {
+	ID         string              `json:"id"`
+	Busy       bool                `json:"busy,omitempty"` // lock not available within the budget
+	Frozen     bool                `json:"frozen,omitempty"`
+	Goroutines []ProcessGoroutines `json:"goroutines,omitempty"`
+	Queue      []QueuedProcess     `json:"queue,omitempty"`
+}
+
+// DebugDump is a snapshot of process goroutines and admission queues.
+type DebugDump struct {
+	Time           time.Time       `json:"time"`
+	KernelBusy     bool            `json:"kernel_busy,omitempty"`
+	TotalGoroutine int             `json:"total_goroutines"`
+	Containers     []ContainerDump `json:"containers"`
+}
+
+// DebugDump collects the goroutines owned by each process and the contents
+// of each admission queue, in scheduling order. It never waits more than
+// debugLockBudget for any lock.
+func (k *Kernel) DebugDump() DebugDump {
+	d := DebugDump{Time: time.Now(), TotalGoroutine: runtime.NumGoroutine()}
+	if !tryLockFor(&k.mu, debugLockBudget) {
+		d.KernelBusy = true
+		return d
+	}
+	containers := k.sortedContainersLocked()
+	k.mu.Unlock()
+
+	for _, c := range containers {
+		cd := ContainerDump{ID: c.ID}
+		if !tryLockFor(&c.mu, debugLockBudget) {
+			cd.Busy = true
+			d.Containers = append(d.Containers, cd)
+			continue
+		}
+		cd.Frozen = c.frozen
+		var queue []*Process
+		for _, p := range c.Processes {
+			if live := p.liveGoroutines(); live > 0 {
+				cd.Goroutines = append(cd.Goroutines, ProcessGoroutines{
+					Container:  c.ID,
+					PID:        p.PID,
+					Process:    p.Name,
+					State:      p.State.String(),
+					Goroutines: live,
+					Since:      p.startedAt,
+					Abandoned:  p.State == Killed && p.stopping,
+				})
+			}
+			if p.State == Pending || p.State == Throttled {
+				queue = append(queue, p)
+			}
+		}
+		sort.SliceStable(queue, func(i, j int) bool { return queue[i].Priority > queue[j].Priority })
+		for _, p := range queue {
+			cd.Queue = append(cd.Queue, QueuedProcess{
+				PID:        p.PID,
+				Process:    p.Name,
+				Priority:   p.Priority,
+				State:      p.State.String(),
+				WaitReason: p.WaitReason,
+			})
+		}
+		c.mu.Unlock()
+		d.Containers = append(d.Containers, cd)
+	}
+	return d
+}
+
+// liveGoroutines counts the goroutines still executing p's current run.
+// The caller holds the container lock.
+func (p *Process) liveGoroutines() int {
+	if p.handle == nil || p.handle.run == nil {
+		return 0
+	}
+	return p.handle.run.live
+}
+
+// --- Lock Wait-For Graph ---
+
+// lockTracker records which goroutine holds and which goroutines wait for
+// each tracked lock. It uses its own mutex so it can be read while kernel
+// locks are stuck.
+type lockTracker struct {
+	enabled atomic.Bool
+	mu      sync.Mutex
+	holders map[*probedMutex]int64
+	waiters map[int64]*probedMutex
+}
+
+func (t *lockTracker) waiting(m *probedMutex, gid int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waiters == nil {
+		t.waiters = make(map[int64]*probedMutex)
+	}
+	t.waiters[gid] = m
+}
+
+func (t *lockTracker) acquired(m *probedMutex, gid int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.waiters, gid)
+	if t.holders == nil {
+		t.holders = make(map[*probedMutex]int64)
+	}
+	t.holders[m] = gid
+}
+
+func (t *lockTracker) released(m *probedMutex) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.holders, m)
+}
+
+// SetLockTracking turns recording of lock holders and waiters on or off.
+// Tracking costs a stack inspection per lock acquisition, so it is meant for
+// debugging sessions only.
+func (k *Kernel) SetLockTracking(enabled bool) {
+	t := &k.locks
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.holders, t.waiters = nil, nil
+	t.enabled.Store(enabled)
+}
+
+// LockEdge is one edge of the wait-for graph: goroutine Waiter waits for
+// Lock, which goroutine Holder holds (zero if the holder is unknown).
+type LockEdge struct {
+	Waiter int64  `json:"waiter"`
+	Lock   string `json:"lock"`
+	Holder int64  `json:"holder,omitempty"`
+}
+
+// LockGraph is the wait-for graph of the kernel's tracked locks.
+type LockGraph struct {
+	Held  map[string]int64 `json:"held"` // lock -> holding goroutine
+	Edges []LockEdge       `json:"edges"`
+}
+
+// LockGraph returns the current wait-for graph. It is empty unless lock
+// tracking is enabled.
+func (k *Kernel) LockGraph() LockGraph {
+	t := &k.locks
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g := LockGraph{Held: make(map[string]int64, len(t.holders))}
+	for m, gid := range t.holders {
+		g.Held[m.name] = gid
+	}
+	for gid, m := range t.waiters {
+		g.Edges = append(g.Edges, LockEdge{Waiter: gid, Lock: m.name, Holder: t.holders[m]})
+	}
+	sort.Slice(g.Edges, func(i, j int) bool { return g.Edges[i].Waiter < g.Edges[j].Waiter })
+	return g
+}
+
+// DOT renders the graph in Graphviz format: goroutines are ellipses, locks
+// are boxes, and a deadlock shows up as a cycle.
+func (g LockGraph) DOT() string {
+	var b bytes.Buffer
+	b.WriteString("digraph locks {\n")
+	locks := make([]string, 0, len(g.Held))
+	for name := range g.Held {
+		locks = append(locks, name)
+	}
+	sort.Strings(locks)
+	for _, name := range locks {
+		fmt.Fprintf(&b, "  %q [shape=box];\n", name)
+		fmt.Fprintf(&b, "  %q -> \"g%d\" [label=\"held by\"];\n", name, g.Held[name])
+	}
+	for _, e := range g.Edges {
+		if _, held := g.Held[e.Lock]; !held {
+			fmt.Fprintf(&b, "  %q [shape=box];\n", e.Lock)
+		}
+		fmt.Fprintf(&b, "  \"g%d\" -> %q [label=\"waits\"];\n", e.Waiter, e.Lock)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// goroutineID parses the current goroutine's ID from its stack header.
+func goroutineID() int64 {
+	var buf [32]byte
+	n := runtime.Stack(buf[:], false)
+	field := bytes.Fields(bytes.TrimPrefix(buf[:n], []byte("goroutine ")))
+	if len(field) == 0 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(field[0]), 10, 64)
+	return id
+}
+
+// --- Debug HTTP Handler ---
+
+// DebugHandler returns an http.Handler exposing the standard pprof endpoints
+// under /debug/pprof/ and bvisor dumps under /debug/bvisor/:
+//
+//	dump       goroutines per process and admission queues (JSON)
+//	locks      lock wait-for graph (JSON)
+//	locks.dot  lock wait-for graph (Graphviz)
+//	profiles   action and request handler execution profiles (JSON)
+//	metrics    kernel internals (Prometheus text format)
+//	containers/{id}/timeline
+//	           a container's timeline (JSON, or text with ?format=text),
+//	           filtered by ?category=a,b, ?since= and ?until= (RFC 3339)
+//	           and ?limit=
+//
+// Nothing is served unless the caller mounts the handler.
+func (k *Kernel) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/bvisor/dump", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, k.DebugDump())
+	})
+	mux.HandleFunc("/debug/bvisor/locks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, k.LockGraph())
+	})
+	mux.HandleFunc("/debug/bvisor/locks.dot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		fmt.Fprint(w, k.LockGraph().DOT())
+	})
+	mux.HandleFunc("/debug/bvisor/profiles", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, k.Profiles())
+	})
+	mux.HandleFunc("/debug/bvisor/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		k.WriteInternalMetrics(w)
+	})
+	mux.HandleFunc("GET /debug/bvisor/containers/{id}/timeline", k.serveTimeline)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (k *Kernel) serveTimeline(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var opts TimelineOptions
+	if cats := q.Get("category"); cats != "" {
+		opts.Categories = strings.Split(cats, ",")
+	}
+	for _, t := range []struct {
+		param string
+		dst   *time.Time
+	}{{"since", &opts.Since}, {"until", &opts.Until}} {
+		if v := q.Get(t.param); v != "" {
+			at, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s: %v", t.param, err), http.StatusBadRequest)
+				return
+			}
+			*t.dst = at
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("limit: %v", err), http.StatusBadRequest)
+			return
+		}
+		opts.Limit = n
+	}
+	entries, err := k.Timeline(r.PathValue("id"), opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if q.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		WriteTimeline(w, entries)
+		return
+	}
+	writeJSON(w, entries)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDebugDumpAttributesGoroutines(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	k.SetGroupLimit("solo", 1)
+	server := (&Process{Name: "server", Action: blockUntil(nil)}).WithConcurrencyGroup("solo")
+	c.AddProcess(server)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	eventually(t, "the server to run", func() bool { return stateOf(c, server) == Running })
+	queued := (&Process{Name: "queued", Priority: 3, Action: noop}).WithConcurrencyGroup("solo")
+	c.AddProcess(queued)
+
+	rec := httptest.NewRecorder()
+	k.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/bvisor/dump", nil))
+	var d DebugDump
+	mustDecode(t, rec.Body.Bytes(), &d)
+	if d.KernelBusy || len(d.Containers) != 1 {
+		t.Fatalf("dump = %+v", d)
+	}
+	cd := d.Containers[0]
+	if len(cd.Goroutines) != 1 {
+		t.Fatalf("goroutines = %+v, want the server's", cd.Goroutines)
+	}
+	if g := cd.Goroutines[0]; g.Container != "api" || g.PID != server.PID || g.Process != "server" || g.State != "Running" || g.Goroutines != 1 {
+		t.Errorf("goroutine entry = %+v", g)
+	}
+	if len(cd.Queue) != 1 || cd.Queue[0].Process != "queued" || cd.Queue[0].WaitReason != WaitGroupThrottled {
+		t.Errorf("queue = %+v", cd.Queue)
+	}
+}
+
+// dotStatement matches the node and edge statements LockGraph.DOT writes.
+var dotStatement = regexp.MustCompile(`^  "[^"]+"( -> "[^"]+")? \[(shape=box|label="[a-z ]+")\];$`)
+
+func TestLockGraphDOT(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	k.SetLockTracking(true)
+	defer k.SetLockTracking(false)
+
+	c.mu.Lock()
+	holder := goroutineID()
+	waiter := make(chan int64, 1)
+	go func() {
+		waiter <- goroutineID()
+		c.Inspect()
+	}()
+	eventually(t, "a waiter on the container lock", func() bool { return len(k.LockGraph().Edges) == 1 })
+	g := k.LockGraph()
+	dot := g.DOT()
+	c.mu.Unlock()
+
+	w := <-waiter
+	if e := g.Edges[0]; e.Waiter != w || e.Lock != "container:api" || e.Holder != holder {
+		t.Errorf("edge = %+v, want g%d waiting for container:api held by g%d", e, w, holder)
+	}
+	lines := strings.Split(strings.TrimSuffix(dot, "\n"), "\n")
+	if lines[0] != "digraph locks {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("DOT is not a digraph:\n%s", dot)
+	}
+	for _, l := range lines[1 : len(lines)-1] {
+		if !dotStatement.MatchString(l) {
+			t.Errorf("malformed DOT statement %q", l)
+		}
+	}
+	for _, want := range []string{
+		fmt.Sprintf(`"container:api" -> "g%d" [label="held by"];`, holder),
+		fmt.Sprintf(`"g%d" -> "container:api" [label="waits"];`, w),
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT lacks %s:\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// --- Dispatch Pools ---
+
+var (
+	ErrDispatchPoolNotFound = errors.New("dispatch pool not found")
+	ErrInvalidDispatchPool  = errors.New("invalid dispatch pool")
+)
+
+// dispatchPool spreads messages over its members by smooth weighted
+// round-robin: each pick adds every ready member's weight to its current
+// score, takes the highest, and subtracts the total from the winner. Over
+// any window of sum(weights) picks each member is chosen weight times, and
+// heavy members are interleaved with light ones rather than bunched.
+type dispatchPool struct {
+	name    string
+	members []string
+	weights []int
+	current []int
+}
+
+// CreatePool defines dispatch pool name over containerIDs with the matching
+// positive weights. A container with weight 3 receives three times the
+// messages of one with weight 1.
+func (k *Kernel) CreatePool(name string, containerIDs []string, weights []int) error {
+	if len(containerIDs) == 0 || len(containerIDs) != len(weights) {
+		return fmt.Errorf("%w: %s needs one weight per container", ErrInvalidDispatchPool, name)
+	}
+	for i, w := range weights {
+		if w <= 0 {
+			return fmt.Errorf("%w: %s: weight of %s must be positive, got %d", ErrInvalidDispatchPool, name, containerIDs[i], w)
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.dispatchPools[name]; ok {
+		return fmt.Errorf("%w: %s already exists", ErrInvalidDispatchPool, name)
+	}
+	seen := make(map[string]bool, len(containerIDs))
+	for _, id := range containerIDs {
+		if _, ok := k.Containers[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: %s lists %s twice", ErrInvalidDispatchPool, name, id)
+		}
+		seen[id] = true
+	}
+	if k.dispatchPools == nil {
+		k.dispatchPools = make(map[string]*dispatchPool)
+	}
+	k.dispatchPools[name] = &dispatchPool{
+		name:    name,
+		members: append([]string(nil), containerIDs...),
+		weights: append([]int(nil), weights...),
+		current: make([]int, len(containerIDs)),
+	}
+	return nil
+}
+
+// DeletePool removes dispatch pool name.
+func (k *Kernel) DeletePool(name string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.dispatchPools, name)
+}
+
+// SendToPool delivers msg from fromID to one member of dispatch pool
+// poolName, picked by weighted round-robin among the members that exist
+// and are not stopped, and returns the chosen container's ID.
+func (k *Kernel) SendToPool(fromID, poolName, msg string) (string, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	pool, ok := k.dispatchPools[poolName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrDispatchPoolNotFound, poolName)
+	}
+	target := k.pickLocked(pool)
+	if target == "" {
+		return "", fmt.Errorf("%w: pool %s", ErrNoReadyBackend, poolName)
+	}
+	if _, err := k.deliverLocked(Message{From: fromID, To: target, Payload: msg}, ""); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// pickLocked advances pool's weighted round-robin over its ready members
+// and returns the winner, or "" if none is ready.
+func (k *Kernel) pickLocked(pool *dispatchPool) string {
+	best, total := -1, 0
+	for i, id := range pool.members {
+		if c, ok := k.Containers[id]; !ok || !c.takesTraffic() {
+			continue
+		}
+		pool.current[i] += pool.weights[i]
+		total += pool.weights[i]
+		if best < 0 || pool.current[i] > pool.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	pool.current[best] -= total
+	return pool.members[best]
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSendToPoolFollowsWeights(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, id := range []string{"client", "small", "large"} {
+		newTestContainer(t, k, id).AddProcess(&Process{Name: "listener"})
+	}
+	if err := k.CreatePool("workers", []string{"small", "large"}, []int{1, 3}); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	var first []string
+	for i := 0; i < 400; i++ {
+		id, err := k.SendToPool("client", "workers", "job")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[id]++
+		if i < 4 {
+			first = append(first, id)
+		}
+	}
+	if counts["small"] != 100 || counts["large"] != 300 {
+		t.Errorf("distribution = %v, want 1:3", counts)
+	}
+	// Smooth round-robin interleaves the light member with the heavy one.
+	if fmt.Sprint(first) != "[large small large large]" {
+		t.Errorf("first picks = %v, want [large small large large]", first)
+	}
+	if got := k.Containers["large"].Inspect().InboxDepth; got != 300 {
+		t.Errorf("large holds %d messages, want 300", got)
+	}
+
+	k.Containers["large"].StopProcesses()
+	for i := 0; i < 3; i++ {
+		if id, err := k.SendToPool("client", "workers", "job"); err != nil || id != "small" {
+			t.Errorf("with large stopped: %s, %v", id, err)
+		}
+	}
+	k.Containers["small"].StopProcesses()
+	if _, err := k.SendToPool("client", "workers", "job"); !errors.Is(err, ErrNoReadyBackend) {
+		t.Errorf("with every member stopped: %v", err)
+	}
+}
+
+func TestCreatePoolValidates(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "a")
+	for _, tc := range []struct {
+		ids     []string
+		weights []int
+		want    error
+	}{
+		{nil, nil, ErrInvalidDispatchPool},
+		{[]string{"a"}, []int{1, 2}, ErrInvalidDispatchPool},
+		{[]string{"a"}, []int{0}, ErrInvalidDispatchPool},
+		{[]string{"a", "a"}, []int{1, 1}, ErrInvalidDispatchPool},
+		{[]string{"missing"}, []int{1}, ErrContainerNotFound},
+	} {
+		if err := k.CreatePool("p", tc.ids, tc.weights); !errors.Is(err, tc.want) {
+			t.Errorf("CreatePool(%v, %v) = %v, want %v", tc.ids, tc.weights, err, tc.want)
+		}
+	}
+	if _, err := k.SendToPool("a", "p", "job"); !errors.Is(err, ErrDispatchPoolNotFound) {
+		t.Errorf("SendToPool on a missing pool: %v", err)
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --- Graceful Drain ---
+
+// runState tracks the goroutines executing one run of a process.
+type runState struct {
+	done chan struct{} // closed once every goroutine has returned
+	live int
+}
+
+// Drain stops the container gracefully. Queued processes are stopped
+// straight away; running ones are cancelled and given until grace to return,
+// after which they are marked Stopped. Actions that ignore cancellation are
+// force-killed when grace expires: the process is marked Killed, an
+// EventForceKilled is emitted and its goroutines are abandoned and counted
+// in LeakedGoroutines. Drain returns the number of force-killed processes.
+func (c *Container) Drain(grace time.Duration) int {
+	return c.drain(context.Background(), c.clock().After(grace), fmt.Sprintf("did not stop within %s", grace)).Killed
+}
+
+// drain is Drain with the grace period ending when expired fires or ctx is
+// done, whichever comes first. reason is recorded on force-killed processes.
+func (c *Container) drain(ctx context.Context, expired <-chan time.Time, reason string) ContainerShutdown {
+	c.mu.Lock()
+	c.State = ContainerStopped
+	c.emit(EventContainerStopped, nil, "")
+	r := ContainerShutdown{ID: c.ID, Name: c.Name}
+	var draining []*Process
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled:
+			c.finishLocked(p, Stopped)
+			r.Stopped++
+		case Running:
+			p.stopping = true
+			p.cancel()
+			draining = append(draining, p)
+		}
+	}
+	c.mu.Unlock()
+
+	timedOut := false
+	for _, p := range draining {
+		if timedOut {
+			break
+		}
+		select {
+		case <-p.handle.run.done:
+		case <-expired:
+			timedOut = true
+		case <-ctx.Done():
+			timedOut = true
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range draining {
+		if p.State != Running {
+			if p.State == Stopped {
+				r.Stopped++
+			}
+			continue
+		}
+		live := p.handle.run.live
+		c.killLocked(p, reason)
+		c.emit(EventForceKilled, p, fmt.Sprintf("abandoned %d goroutine(s)", live))
+		if c.kernel != nil {
+			c.kernel.leaked.Add(int64(live))
+		}
+		r.Killed++
+	}
+	for _, p := range c.Processes {
+		if p.State == Completed {
+			r.Completed++
+		}
+	}
+	return r
+}
+
+// Drain drains every container concurrently with the same grace period and
+// returns the total number of force-killed processes.
+func (k *Kernel) Drain(grace time.Duration) int {
+	k.mu.Lock()
+	containers := k.sortedContainersLocked()
+	k.mu.Unlock()
+
+	start := k.Clock.Now()
+	report := ShutdownReport{Containers: make([]ContainerShutdown, len(containers))}
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			began := c.clock().Now()
+			r := c.drain(context.Background(), c.clock().After(grace), fmt.Sprintf("did not stop within %s", grace))
+			r.Duration = c.clock().Now().Sub(began)
+			report.Containers[i] = r
+		}()
+	}
+	wg.Wait()
+	report.Duration = k.Clock.Now().Sub(start)
+	k.recordShutdown(report)
+	return report.Killed()
+}
+
+// --- Shutdown Report ---
+
+// ContainerShutdown reports what Drain or StopAll did to one container's
+// processes. Stopped counts processes stopped gracefully, whether queued or
+// returning within their grace period; Killed those force-killed when it ran
+// out; Completed those that had completed by the end.
+type ContainerShutdown struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Stopped   int           `json:"stopped"`
+	Killed    int           `json:"killed"`
+	Completed int           `json:"completed"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// ShutdownReport is the outcome of the last Drain or StopAll, for
+// post-mortems. Containers are in ID order for Drain and in stop order for
+// StopAll.
+type ShutdownReport struct {
+	Containers []ContainerShutdown `json:"containers"`
+	Duration   time.Duration       `json:"duration"`
+}
+
+// Killed returns the number of force-killed processes across containers.
+func (r ShutdownReport) Killed() int {
+	n := 0
+	for _, c := range r.Containers {
+		n += c.Killed
+	}
+	return n
+}
+
+func (k *Kernel) recordShutdown(r ShutdownReport) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastShutdown = &r
+}
+
+// ShutdownReport returns the report of the last Drain or StopAll, and false
+// if there has been none.
+func (k *Kernel) ShutdownReport() (ShutdownReport, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.lastShutdown == nil {
+		return ShutdownReport{}, false
+	}
+	return *k.lastShutdown, true
+}
+
+// LeakedGoroutines reports how many action goroutines Drain has abandoned
+// because they ignored cancellation.
+func (k *Kernel) LeakedGoroutines() int64 {
+	return k.leaked.Load()
+}
+
+// --- Ordered Shutdown ---
+
+// ContainerStopResult reports how one container was stopped by StopAll.
+type ContainerStopResult struct {
+	ID       string
+	Name     string
+	Budget   time.Duration // share of the shutdown budget; zero if unbounded
+	Duration time.Duration // time actually taken
+	Killed   int           // processes force-killed when the share ran out
+}
+
+// StopResult is the outcome of StopAll, in stop order.
+type StopResult struct {
+	Containers []ContainerStopResult
+	Killed     int
+	Duration   time.Duration
+}
+
+// StopAll drains every container one after another. Boot phases stop in
+// reverse, the highest BootPhase first; within a phase containers stop in
+// ascending StopPriority, and among equal priorities a container stops
+// before the containers it depends on, with ties broken by ID.
+//
+// If ctx has a deadline, the time left is the shutdown budget, measured on
+// the kernel clock. Each container may use the remaining budget divided by
+// the number of containers still to stop, so time a fast container leaves
+// unused passes to the ones after it, while a container that overruns its
+// share is force-killed without eating into theirs. Without a deadline each
+// container is waited for until ctx is cancelled.
+func (k *Kernel) StopAll(ctx context.Context) StopResult {
+	k.mu.Lock()
+	order := k.stopOrderLocked()
+	k.mu.Unlock()
+
+	clock := k.Clock
+	start := clock.Now()
+	deadline, bounded := ctx.Deadline()
+	var res StopResult
+	var report ShutdownReport
+	for i, c := range order {
+		fmt.Printf("[Kernel] Stopping container: %s\n", c.Name)
+		began := clock.Now()
+		r := ContainerStopResult{ID: c.ID, Name: c.Name}
+		var expired <-chan time.Time
+		reason := "shutdown cancelled"
+		if bounded {
+			r.Budget = max(deadline.Sub(began)/time.Duration(len(order)-i), 0)
+			expired = clock.After(r.Budget)
+			reason = fmt.Sprintf("did not stop within its %s shutdown budget", r.Budget)
+		}
+		cs := c.drain(ctx, expired, reason)
+		r.Killed = cs.Killed
+		r.Duration = clock.Now().Sub(began)
+		cs.Duration = r.Duration
+		res.Containers = append(res.Containers, r)
+		res.Killed += r.Killed
+		report.Containers = append(report.Containers, cs)
+	}
+	res.Duration = clock.Now().Sub(start)
+	report.Duration = res.Duration
+	k.recordShutdown(report)
+	return res
+}
+
+// stopOrderLocked returns the containers in StopAll order.
+func (k *Kernel) stopOrderLocked() []*Container {
+	remaining := k.sortedContainersLocked()
+	sort.SliceStable(remaining, func(i, j int) bool {
+		a, b := remaining[i], remaining[j]
+		if a.BootPhase != b.BootPhase {
+			return a.BootPhase > b.BootPhase
+		}
+		return a.StopPriority < b.StopPriority
+	})
+
+	// Within a phase and priority, repeatedly take the first container that
+	// no other remaining container of that phase and priority depends on. A
+	// dependency cycle falls back to ID order.
+	var order []*Container
+	for len(remaining) > 0 {
+		first := remaining[0]
+		pick := 0
+		for i, c := range remaining {
+			if c.BootPhase != first.BootPhase || c.StopPriority != first.StopPriority {
+				break
+			}
+			if !dependedOn(c, remaining) {
+				pick = i
+				break
+			}
+		}
+		order = append(order, remaining[pick])
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+	}
+	return order
+}
+
+// dependedOn reports whether any container in cs with c's BootPhase and
+// StopPriority lists c as a dependency.
+func dependedOn(c *Container, cs []*Container) bool {
+	for _, o := range cs {
+		if o == c || o.BootPhase != c.BootPhase || o.StopPriority != c.StopPriority {
+			continue
+		}
+		for _, dep := range o.DependsOn {
+			if dep == c.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// --- Escalating Stop ---
+
+// StopStep is the step of an escalating stop at which a process stopped.
+type StopStep int
+
+const (
+	// StopStepNone: the process was queued, or already finished, and did
+	// not go through the sequence.
+	StopStepNone StopStep = iota
+	// StopStepSignal: it returned after the Shutdown signal.
+	StopStepSignal
+	// StopStepCancel: it returned after its context was cancelled.
+	StopStepCancel
+	// StopStepKill: it was force-killed.
+	StopStepKill
+)
+
+func (s StopStep) String() string {
+	switch s {
+	case StopStepNone:
+		return "none"
+	case StopStepSignal:
+		return "signal"
+	case StopStepCancel:
+		return "cancel"
+	case StopStepKill:
+		return "kill"
+	}
+	return fmt.Sprintf("StopStep(%d)", int(s))
+}
+
+// StopPolicy configures an escalating stop. Running processes are first
+// sent a Shutdown signal and given SignalGrace to return; the ones still
+// running then have their context cancelled and are given CancelGrace;
+// the rest are force-killed. A zero grace moves on to the next step at
+// once.
+type StopPolicy struct {
+	SignalGrace time.Duration
+	CancelGrace time.Duration
+}
+
+// StopRecord reports the step at which one process stopped.
+type StopRecord struct {
+	ContainerID string
+	PID         int
+	Name        string
+	Step        StopStep
+}
+
+// StopEscalating stops the container and its processes following policy.
+// Queued processes are stopped straight away. It returns a record per
+// process that was running, in container order.
+func (c *Container) StopEscalating(policy StopPolicy) []StopRecord {
+	c.mu.Lock()
+	c.State = ContainerStopped
+	c.emit(EventContainerStopped, nil, "")
+	var targets []stopTarget
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled:
+			c.finishLocked(p, Stopped)
+		case Running:
+			targets = append(targets, stopTarget{c, p})
+		}
+	}
+	c.mu.Unlock()
+	return escalateStop(c.clock(), targets, policy)
+}
+
+// StopGroup stops the processes of concurrency group group in every
+// container following policy, leaving their containers running. Queued
+// members are stopped straight away. It returns a record per member that
+// was running, in container ID order.
+func (k *Kernel) StopGroup(group string, policy StopPolicy) []StopRecord {
+	k.mu.Lock()
+	var targets []stopTarget
+	for _, c := range k.sortedContainersLocked() {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			if p.ConcurrencyGroup != group {
+				continue
+			}
+			switch p.State {
+			case Pending, Throttled:
+				c.finishLocked(p, Stopped)
+			case Running:
+				targets = append(targets, stopTarget{c, p})
+			}
+		}
+		c.mu.Unlock()
+	}
+	k.mu.Unlock()
+	return escalateStop(k.Clock, targets, policy)
+}
+
+type stopTarget struct {
+	c *Container
+	p *Process
+}
+
+// escalateStop runs the stop sequence over targets, which were running
+// when chosen.
+func escalateStop(clock Clock, targets []stopTarget, policy StopPolicy) []StopRecord {
+	records := make([]StopRecord, len(targets))
+	for i, t := range targets {
+		records[i] = StopRecord{ContainerID: t.c.ID, PID: t.p.PID, Name: t.p.Name}
+	}
+	steps := []struct {
+		step  StopStep
+		grace time.Duration
+		act   func(c *Container, p *Process)
+	}{
+		{StopStepSignal, policy.SignalGrace, func(c *Container, p *Process) {
+			p.stopping = true
+			p.handle.signal(Signal{Kind: SignalShutdown})
+		}},
+		{StopStepCancel, policy.CancelGrace, func(c *Container, p *Process) {
+			p.stopping = true
+			c.emit(EventStopEscalated, p, StopStepCancel.String())
+			p.cancel()
+		}},
+	}
+	for _, s := range steps {
+		var waiting []int
+		for i, t := range targets {
+			if records[i].Step != StopStepNone {
+				continue
+			}
+			t.c.mu.Lock()
+			if t.p.State == Running {
+				s.act(t.c, t.p)
+				waiting = append(waiting, i)
+			}
+			t.c.mu.Unlock()
+		}
+		expired := clock.After(s.grace)
+	wait:
+		for _, i := range waiting {
+			select {
+			case <-targets[i].p.handle.run.done:
+			case <-expired:
+				break wait
+			}
+		}
+		for _, i := range waiting {
+			t := targets[i]
+			t.c.mu.Lock()
+			if t.p.State != Running {
+				records[i].Step = s.step
+				t.c.stoppedAtLocked(t.p, s.step)
+			}
+			t.c.mu.Unlock()
+		}
+	}
+	for i, t := range targets {
+		if records[i].Step != StopStepNone {
+			continue
+		}
+		t.c.mu.Lock()
+		if t.p.State == Running {
+			live := t.p.handle.run.live
+			t.c.killLocked(t.p, "did not stop after Shutdown signal and cancellation")
+			t.c.emit(EventForceKilled, t.p, fmt.Sprintf("abandoned %d goroutine(s)", live))
+			if t.c.kernel != nil {
+				t.c.kernel.leaked.Add(int64(live))
+			}
+			records[i].Step = StopStepKill
+			t.c.stoppedAtLocked(t.p, StopStepKill)
+		}
+		t.c.mu.Unlock()
+	}
+	return records
+}
+
+// stoppedAtLocked records the step at which p stopped.
+func (c *Container) stoppedAtLocked(p *Process, step StopStep) {
+	p.stopStep = step
+	if c.kernel != nil {
+		c.kernel.stopSteps[step].Add(1)
+	}
+}
+
+func (k *Kernel) stopStepStats() map[string]int64 {
+	var out map[string]int64
+	for step := StopStepSignal; step <= StopStepKill; step++ {
+		if n := k.stopSteps[step].Load(); n > 0 {
+			if out == nil {
+				out = make(map[string]int64)
+			}
+			out[step.String()] = n
+		}
+	}
+	return out
+}
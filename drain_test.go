@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDrainForceKillsUncooperativeAction(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "svc")
+	stuck := make(chan struct{})
+	defer close(stuck)
+	polite := &Process{Name: "polite", Action: blockUntil(nil)}
+	rude := &Process{Name: "rude", Action: func(ctx context.Context, h *Handle) error {
+		<-stuck // ignores ctx
+		return nil
+	}}
+	c.AddProcess(polite)
+	c.AddProcess(rude)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventForceKilled}})
+	defer cancel()
+
+	killed := make(chan int, 1)
+	go func() { killed <- k.Drain(time.Second) }()
+	waitForWaiters(t, clk, 1)
+	clk.Advance(time.Second)
+	select {
+	case n := <-killed:
+		if n != 1 {
+			t.Errorf("Drain force-killed %d, want 1", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain did not return after the grace period")
+	}
+
+	for p, want := range map[*Process]ProcessState{polite: Stopped, rude: Killed} {
+		if got := stateOf(c, p); got != want {
+			t.Errorf("%s = %v, want %v", p.Name, got, want)
+		}
+	}
+	if e := nextEvent(t, events); e.Process != "rude" || e.Detail != "abandoned 1 goroutine(s)" {
+		t.Errorf("event = %+v", e)
+	}
+	if n := k.LeakedGoroutines(); n != 1 {
+		t.Errorf("LeakedGoroutines = %d, want 1", n)
+	}
+	if n := k.Stats().LeakedGoroutines; n != 1 {
+		t.Errorf("Stats().LeakedGoroutines = %d, want 1", n)
+	}
+}
+
+func TestStopAllOrder(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "logs").StopPriority = 10
+	newTestContainer(t, k, "batch").StopPriority = -1
+	newTestContainer(t, k, "db")
+	newTestContainer(t, k, "web").DependsOn = []string{"db"}
+
+	res := k.StopAll(context.Background())
+	var order []string
+	for _, r := range res.Containers {
+		order = append(order, r.ID)
+		if r.Budget != 0 {
+			t.Errorf("%s has a %s budget without a deadline", r.ID, r.Budget)
+		}
+	}
+	if got := strings.Join(order, " "); got != "batch web db logs" {
+		t.Errorf("stop order %s, want batch web db logs", got)
+	}
+}
+
+func TestStopAllDividesBudget(t *testing.T) {
+	k, clk := newTestKernel(t)
+	clk.Set(time.Now())
+	stuck := make(chan struct{})
+	defer close(stuck)
+	for i, id := range []string{"slow", "api", "logs"} {
+		c := newTestContainer(t, k, id)
+		c.StopPriority = i
+		action := blockUntil(nil)
+		if id == "slow" {
+			action = func(ctx context.Context, h *Handle) error {
+				<-stuck // ignores ctx
+				return nil
+			}
+		}
+		c.AddProcess(&Process{Name: id, Action: action})
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "every process to run", func() bool { return k.Stats().Running == 3 })
+
+	ctx, cancel := context.WithDeadline(context.Background(), clk.Now().Add(30*time.Second))
+	defer cancel()
+	done := make(chan StopResult, 1)
+	go func() { done <- k.StopAll(ctx) }()
+	waitForWaiters(t, clk, 1)
+	clk.Advance(10 * time.Second)
+	var res StopResult
+	select {
+	case res = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopAll did not return")
+	}
+
+	want := []ContainerStopResult{
+		{ID: "slow", Name: "slow", Budget: 10 * time.Second, Duration: 10 * time.Second, Killed: 1},
+		{ID: "api", Name: "api", Budget: 10 * time.Second},
+		{ID: "logs", Name: "logs", Budget: 20 * time.Second},
+	}
+	if len(res.Containers) != len(want) {
+		t.Fatalf("result = %+v", res)
+	}
+	for i := range want {
+		if res.Containers[i] != want[i] {
+			t.Errorf("container %d = %+v, want %+v", i, res.Containers[i], want[i])
+		}
+	}
+	if res.Killed != 1 || res.Duration != 10*time.Second {
+		t.Errorf("killed %d in %s, want 1 in 10s", res.Killed, res.Duration)
+	}
+	report, ok := k.ShutdownReport()
+	if !ok || len(report.Containers) != 3 || report.Containers[0].Duration != 10*time.Second || report.Containers[1].Stopped != 1 {
+		t.Errorf("shutdown report = %+v", report)
+	}
+}
+
+func TestShutdownReportCategorizesProcesses(t *testing.T) {
+	k, clk := newTestKernel(t)
+	if _, ok := k.ShutdownReport(); ok {
+		t.Error("report before any shutdown")
+	}
+	k.SetGroupLimit("solo", 1)
+	c := newTestContainer(t, k, "svc")
+	newTestContainer(t, k, "idle")
+	stuck := make(chan struct{})
+	defer close(stuck)
+	done := &Process{Name: "done", Action: noop}
+	c.AddProcess(done)
+	for _, p := range []*Process{
+		(&Process{Name: "polite", Action: blockUntil(nil)}).WithConcurrencyGroup("solo"),
+		(&Process{Name: "queued", Action: noop}).WithConcurrencyGroup("solo"),
+		{Name: "rude", Action: func(ctx context.Context, h *Handle) error {
+			<-stuck // ignores ctx
+			return nil
+		}},
+	} {
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, done)
+	eventually(t, "polite and rude to run", func() bool { return k.Stats().Running == 2 })
+
+	killed := make(chan int, 1)
+	go func() { killed <- k.Drain(time.Second) }()
+	waitForWaiters(t, clk, 2) // one grace timer per container
+	clk.Advance(time.Second)
+	if n := <-killed; n != 1 {
+		t.Errorf("Drain force-killed %d, want 1", n)
+	}
+
+	report, ok := k.ShutdownReport()
+	if !ok {
+		t.Fatal("no shutdown report after Drain")
+	}
+	want := []ContainerShutdown{
+		{ID: "idle", Name: "idle"},
+		{ID: "svc", Name: "svc", Stopped: 2, Killed: 1, Completed: 1, Duration: time.Second},
+	}
+	if len(report.Containers) != len(want) {
+		t.Fatalf("report = %+v", report)
+	}
+	for i := range want {
+		if report.Containers[i] != want[i] {
+			t.Errorf("container %d = %+v, want %+v", i, report.Containers[i], want[i])
+		}
+	}
+	if report.Duration != time.Second || report.Killed() != 1 {
+		t.Errorf("report took %s and killed %d, want 1s and 1", report.Duration, report.Killed())
+	}
+}
+
+func TestStopEscalatingRecordsStep(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "svc")
+	stuck := make(chan struct{})
+	defer close(stuck)
+	finished := &Process{Name: "finished", Action: noop}
+	c.AddProcess(finished)
+	procs := []*Process{
+		{Name: "graceful", Action: func(ctx context.Context, h *Handle) error {
+			for s := range h.Signals() {
+				if s.Kind == SignalShutdown {
+					return nil
+				}
+			}
+			return nil
+		}},
+		{Name: "ctx-only", Action: blockUntil(nil)},
+		{Name: "stubborn", Action: func(ctx context.Context, h *Handle) error {
+			<-stuck // ignores the signal and ctx
+			return nil
+		}},
+	}
+	for _, p := range procs {
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, finished)
+	eventually(t, "every process to run", func() bool { return k.Stats().Running == 3 })
+
+	done := make(chan []StopRecord, 1)
+	go func() {
+		done <- c.StopEscalating(StopPolicy{SignalGrace: 5 * time.Second, CancelGrace: 5 * time.Second})
+	}()
+	for i := 0; i < 2; i++ {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(5 * time.Second)
+	}
+	var records []StopRecord
+	select {
+	case records = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopEscalating did not return")
+	}
+
+	want := []StopStep{StopStepSignal, StopStepCancel, StopStepKill}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v, want one per running process", records)
+	}
+	for i, r := range records {
+		if r.Name != procs[i].Name || r.PID != procs[i].PID || r.Step != want[i] {
+			t.Errorf("record %d = %+v, want %s at %v", i, r, procs[i].Name, want[i])
+		}
+	}
+	for i, d := range c.Inspect().Processes[1:] {
+		if d.StopStep != want[i] {
+			t.Errorf("%s inspected at step %v, want %v", d.Name, d.StopStep, want[i])
+		}
+	}
+	if got := c.Inspect().Processes[0].StopStep; got != StopStepNone {
+		t.Errorf("finished process went through the sequence: %v", got)
+	}
+	if got := fmt.Sprint(k.Stats().StopSteps); got != "map[cancel:1 kill:1 signal:1]" {
+		t.Errorf("Stats().StopSteps = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// --- Declaration Drift ---
+
+// DriftPolicy compares the memory processes declare in MemoryMB with what
+// they are observed to use: at every usage sample while they run, and at
+// the peak of each run when they finish. A process name whose observations
+// exceed its declaration by Factor Persistence times in a row has drifted;
+// an EventDeclarationDrift reports it. With AutoAdjust the name's processes
+// added from then on declare the highest usage observed instead, capped at
+// MaxAdjustedMB and at their container's memory. The zero value disables
+// the check.
+//
+// Processes only report memory, through Handle.AllocateMemory and
+// ReleaseMemory; CPU weights are not measured and are not checked.
+type DriftPolicy struct {
+	Factor        float64 // e.g. 1.5; zero disables the check
+	Persistence   int     // zero means 1
+	AutoAdjust    bool
+	MaxAdjustedMB int // zero means no cap but the container's memory
+}
+
+// DriftStats reports the declarations and observations of one process
+// name.
+type DriftStats struct {
+	DeclaredMB   int // as last declared by a process of the name
+	ObservedMB   int // highest observation
+	Observations int
+	Exceeded     int // consecutive observations over the factor
+	Drifts       int // EventDeclarationDrift raised
+	AdjustedMB   int // declaration applied to new processes, zero if none
+}
+
+// driftTracker keeps DriftStats by process name. It has its own mutex so
+// observations are made under the container lock alone.
+type driftTracker struct {
+	mu    sync.Mutex
+	names map[string]*DriftStats
+}
+
+// observeDriftLocked checks p's memory usage, usedMB, against its
+// declaration.
+func (c *Container) observeDriftLocked(p *Process, usedMB int) {
+	k := c.kernel
+	if k == nil || p.debug || k.DriftPolicy.Factor <= 0 {
+		return
+	}
+	policy := k.DriftPolicy
+	t := &k.drift
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.names == nil {
+		t.names = make(map[string]*DriftStats)
+	}
+	s := t.names[p.Name]
+	if s == nil {
+		s = &DriftStats{}
+		t.names[p.Name] = s
+	}
+	s.DeclaredMB = p.MemoryMB
+	s.Observations++
+	s.ObservedMB = max(s.ObservedMB, usedMB)
+	if float64(usedMB) <= float64(p.MemoryMB)*policy.Factor {
+		s.Exceeded = 0
+		return
+	}
+	s.Exceeded++
+	if s.Exceeded < max(policy.Persistence, 1) {
+		return
+	}
+	s.Exceeded = 0
+	s.Drifts++
+	detail := fmt.Sprintf("memory observed %dMB, declared %dMB", usedMB, p.MemoryMB)
+	if policy.AutoAdjust {
+		s.AdjustedMB = s.ObservedMB
+		if policy.MaxAdjustedMB > 0 {
+			s.AdjustedMB = min(s.AdjustedMB, policy.MaxAdjustedMB)
+		}
+		detail += fmt.Sprintf("; new processes declare %dMB", s.AdjustedMB)
+	}
+	fmt.Printf("[Kernel] Declaration drift of %s in %s: %s\n", p.Name, c.Name, detail)
+	c.emit(EventDeclarationDrift, p, detail)
+}
+
+// adjustDeclarationLocked raises the MemoryMB of a process being added to
+// the adjusted declaration of its name, if there is one.
+func (c *Container) adjustDeclarationLocked(p *Process) {
+	k := c.kernel
+	if k == nil || !k.DriftPolicy.AutoAdjust {
+		return
+	}
+	t := &k.drift
+	t.mu.Lock()
+	adjusted := 0
+	if s := t.names[p.Name]; s != nil {
+		adjusted = s.AdjustedMB
+	}
+	t.mu.Unlock()
+	if c.MemoryMB > 0 {
+		adjusted = min(adjusted, c.MemoryMB)
+	}
+	if adjusted > p.MemoryMB {
+		p.MemoryMB = adjusted
+	}
+}
+
+// DriftStats returns the declaration drift statistics by process name.
+func (k *Kernel) DriftStats() map[string]DriftStats {
+	t := &k.drift
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]DriftStats, len(t.names))
+	for name, s := range t.names {
+		out[name] = *s
+	}
+	return out
+}
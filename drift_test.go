@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDeclarationDriftAdjustsNextAdmission(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.DriftPolicy = DriftPolicy{Factor: 2, Persistence: 3, AutoAdjust: true, MaxAdjustedMB: 80}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventDeclarationDrift}})
+	defer cancel()
+	c := newTestContainer(t, k, "batch")
+	// hog declares 10MB but uses 100MB; startMB reports what it was
+	// charged for when it started.
+	run := func() (declared, startMB int) {
+		t.Helper()
+		p := &Process{Name: "hog", MemoryMB: 10, Action: func(ctx context.Context, h *Handle) error {
+			startMB = h.MemoryUsageMB()
+			h.AllocateMemory(100 - startMB)
+			return nil
+		}}
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+		return p.MemoryMB, startMB
+	}
+
+	for i := 0; i < 2; i++ {
+		run()
+		if s := k.DriftStats()["hog"]; s.Drifts != 0 || s.Exceeded != i+1 {
+			t.Fatalf("after run %d: %+v, want no drift before the persistence threshold", i+1, s)
+		}
+	}
+	if declared, _ := run(); declared != 10 {
+		t.Errorf("third run declared %dMB", declared)
+	}
+	if e := nextEvent(t, events); e.Detail != "memory observed 100MB, declared 10MB; new processes declare 80MB" {
+		t.Errorf("event = %q", e.Detail)
+	}
+	s := k.DriftStats()["hog"]
+	if s.Drifts != 1 || s.ObservedMB != 100 || s.DeclaredMB != 10 || s.Observations != 3 || s.AdjustedMB != 80 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	if declared, startMB := run(); declared != 80 || startMB != 80 {
+		t.Errorf("next run declared %dMB and was charged %dMB, want the capped 80MB", declared, startMB)
+	}
+	if _, ok := k.DriftStats()["other"]; ok {
+		t.Error("stats for a name never observed")
+	}
+}
+
+func TestDeclarationDriftWithinFactor(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.DriftPolicy = DriftPolicy{Factor: 2}
+	c := newTestContainer(t, k, "batch")
+	p := &Process{Name: "fair", MemoryMB: 50, Action: func(ctx context.Context, h *Handle) error {
+		h.AllocateMemory(50)
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if s := k.DriftStats()["fair"]; s.Drifts != 0 || s.ObservedMB != 100 || s.AdjustedMB != 0 {
+		t.Errorf("stats = %+v, want usage at the factor not to count as drift", s)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// --- Ephemeral Debug Processes ---
+
+var (
+	// ErrDebugDisabled is returned by Debug on kernels created with
+	// WithDebugDisabled.
+	ErrDebugDisabled = errors.New("debug processes are disabled")
+	// ErrDebugReadOnly is returned when a debug process tries to take a
+	// message from the target's mailbox; it may only peek.
+	ErrDebugReadOnly = errors.New("debug process has read-only mailbox access")
+)
+
+// WithDebugDisabled makes Kernel.Debug fail with ErrDebugDisabled, for
+// locked-down setups.
+func WithDebugDisabled() KernelOption {
+	return func(k *Kernel) {
+		k.debugDisabled = true
+	}
+}
+
+// Debug runs p inside the running container targetID without changing its
+// spec, to inspect it from within. p shares the target's env, volumes,
+// metrics and pipes, and may peek at its mailbox with Handle.PeekInbox but
+// not take messages from it. It starts straight away, bypassing the
+// scheduler, and does not count against the container's quotas: MaxRunning,
+// CPU weight, concurrency groups and memory. Its ConcurrencyGroup and
+// CPUWeight are ignored. It is removed from the container once it finishes,
+// and EventDebugStarted and EventDebugFinished record it in the event
+// stream. Debug returns p's PID.
+func (k *Kernel) Debug(targetID string, p *Process) (int, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return 0, err
+	}
+	if k.debugDisabled {
+		return 0, ErrDebugDisabled
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[targetID]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrContainerNotFound, targetID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.State != ContainerRunning {
+		return 0, fmt.Errorf("cannot debug %s: container is %s", targetID, c.State)
+	}
+	p.debug = true
+	p.ConcurrencyGroup, p.CPUWeight = "", 0
+	p.State = Pending
+	p.reopenDone()
+	p.addedAt = c.now()
+	if p.PID == 0 {
+		p.PID = k.allocPID()
+	}
+	c.Processes = append(c.Processes, p)
+	fmt.Printf("[Kernel] Debug process %s (PID %d) attached to %s\n", p.Name, p.PID, c.Name)
+	c.emit(EventDebugStarted, p, "")
+	c.startLocked(p, p.addedAt)
+	return p.PID, nil
+}
+
+// removeDebugLocked drops a finished debug process from its container. The
+// slice is copied rather than edited in place, since callers may be ranging
+// over it.
+func (c *Container) removeDebugLocked(p *Process) {
+	procs := make([]*Process, 0, len(c.Processes))
+	for _, q := range c.Processes {
+		if q != p {
+			procs = append(procs, q)
+		}
+	}
+	c.Processes = procs
+	c.emit(EventDebugFinished, p, p.State.String())
+}
+
+// PeekInbox returns a copy of the container's queued messages without
+// taking any of them.
+func (h *Handle) PeekInbox() []Message {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.inbox...)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDebugProcessInspectsTarget(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventDebugStarted, EventDebugFinished}})
+	defer cancel()
+	newTestContainer(t, k, "client")
+	c := newTestContainer(t, k, "app")
+	c.Volumes = []string{"/data"}
+	c.Env = map[string]string{"MODE": "prod"}
+	c.AddProcess(&Process{Name: "server", Action: blockUntil(nil)})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	if err := k.SendMessage("client", "app", "queued"); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	var recvErr error
+	probe := &Process{Name: "probe", Action: func(ctx context.Context, h *Handle) error {
+		info := h.Container().Inspect()
+		var peeked []string
+		for _, m := range h.PeekInbox() {
+			peeked = append(peeked, m.Payload)
+		}
+		seen = fmt.Sprintf("%v %s %v", info.Volumes, info.Env["MODE"], peeked)
+		_, recvErr = h.Recv()
+		h.SetMetric("debug.peeked", float64(len(peeked)))
+		return nil
+	}}
+	pid, err := k.Debug("app", probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventDebugStarted || e.PID != pid {
+		t.Errorf("start event = %+v", e)
+	}
+	if e := nextEvent(t, events); e.Kind != EventDebugFinished || e.Detail != Completed.String() {
+		t.Errorf("finish event = %+v", e)
+	}
+	waitDone(t, probe)
+
+	if seen != "[/data] prod [queued]" {
+		t.Errorf("debug process saw %q", seen)
+	}
+	if !errors.Is(recvErr, ErrDebugReadOnly) {
+		t.Errorf("Recv from a debug process: %v, want ErrDebugReadOnly", recvErr)
+	}
+	if got := c.Inspect().InboxDepth; got != 1 {
+		t.Errorf("inbox holds %d messages after the peek, want 1", got)
+	}
+	if got := c.Metrics()["debug.peeked"]; got != 1 {
+		t.Errorf("metric set by the debug process = %v", got)
+	}
+	for _, d := range c.Inspect().Processes {
+		if d.Name == "probe" {
+			t.Error("finished debug process was not removed")
+		}
+	}
+}
+
+func TestDebugProcessOutsideQuotas(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.MemoryCeilingMB = 500
+	c := newTestContainer(t, k, "app")
+	c.MaxRunning = 1
+	server := &Process{Name: "server", MemoryMB: 400, Action: blockUntil(nil)}
+	c.AddProcess(server)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+
+	release := make(chan struct{})
+	probe := &Process{Name: "probe", MemoryMB: 300, Action: blockUntil(release)}
+	if _, err := k.Debug("app", probe); err != nil {
+		t.Fatal(err)
+	}
+	if got := stateOf(c, probe); got != Running {
+		t.Errorf("probe = %v with MaxRunning taken, want Running", got)
+	}
+	if d := c.Inspect().Processes[1]; !d.Debug {
+		t.Errorf("probe not marked as debug: %+v", d)
+	}
+	if got := k.CommittedMemoryMB(); got != 400 {
+		t.Errorf("committed %dMB, want the debug process left out", got)
+	}
+	if n := k.EnforceMemoryCeiling(); n != 0 {
+		t.Errorf("OOM killer killed %d with only the debug process over the ceiling", n)
+	}
+	close(release)
+	waitDone(t, probe)
+	if got := stateOf(c, server); got != Running {
+		t.Errorf("server = %v, want Running", got)
+	}
+}
+
+func TestDebugRejected(t *testing.T) {
+	k := NewKernel(WithDebugDisabled())
+	k.Clock = NewFakeClock(testEpoch)
+	newTestContainer(t, k, "app")
+	if _, err := k.Debug("app", &Process{Name: "probe", Action: noop}); !errors.Is(err, ErrDebugDisabled) {
+		t.Errorf("Debug with debugging disabled: %v", err)
+	}
+	k, _ = newTestKernel(t)
+	if _, err := k.Debug("nope", &Process{Name: "probe", Action: noop}); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Debug of a missing container: %v", err)
+	}
+	c := newTestContainer(t, k, "app")
+	c.StopProcesses()
+	if _, err := k.Debug("app", &Process{Name: "probe", Action: noop}); err == nil {
+		t.Error("debugged a stopped container")
+	}
+}
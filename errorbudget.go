@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Error Budgets ---
+
+// ErrFrozen is returned when starting a container frozen by its error
+// budget.
+var ErrFrozen = errors.New("container is frozen")
+
+// ErrorBudget bounds how often a container's processes may fail or be
+// killed by the kernel. More than Failures of them within Window exhaust
+// the budget: an EventBudgetExhausted is emitted and, unless AlertOnly is
+// set, the container is frozen. A frozen container starts no processes,
+// queued or new; running ones are left to finish and it still receives
+// messages. It stays frozen until Kernel.Unfreeze or, with AutoUnfreeze,
+// until Window has passed since it froze. The failure count starts afresh
+// each time the budget is exhausted. Zero Failures disables the budget.
+type ErrorBudget struct {
+	Failures     int
+	Window       time.Duration
+	AlertOnly    bool
+	AutoUnfreeze bool
+}
+
+// errorBudgetLocked is the container's budget, or the kernel default if it
+// has none.
+func (c *Container) errorBudgetLocked() ErrorBudget {
+	if c.ErrorBudget.Failures > 0 || c.kernel == nil {
+		return c.ErrorBudget
+	}
+	return c.kernel.DefaultErrorBudget
+}
+
+// chargeBudgetLocked counts a failed or kernel-killed process against the
+// error budget. Processes killed while being stopped or drained are not
+// charged.
+func (c *Container) chargeBudgetLocked(p *Process) {
+	budget := c.errorBudgetLocked()
+	if budget.Failures <= 0 || p.debug {
+		return
+	}
+	switch {
+	case p.State == Failed:
+	case p.State == Killed && !p.stopping && p.stopStep == StopStepNone:
+	default:
+		return
+	}
+	now := c.now()
+	c.budgetFailures = append(c.budgetFailures, now)
+	i := 0
+	for i < len(c.budgetFailures) && budget.Window > 0 && now.Sub(c.budgetFailures[i]) > budget.Window {
+		i++
+	}
+	c.budgetFailures = c.budgetFailures[i:]
+	if len(c.budgetFailures) <= budget.Failures {
+		return
+	}
+	detail := fmt.Sprintf("%d failures within %v, budget %d", len(c.budgetFailures), budget.Window, budget.Failures)
+	c.budgetFailures = nil
+	fmt.Printf("[Kernel] Error budget of %s exhausted: %s\n", c.Name, detail)
+	c.emit(EventBudgetExhausted, p, detail)
+	if budget.AlertOnly || c.frozen {
+		return
+	}
+	c.frozen = true
+	c.freezes++
+	if c.State == ContainerRunning {
+		c.State = ContainerFrozen
+	}
+	fmt.Printf("[Kernel] Froze container %s\n", c.Name)
+	c.emit(EventFrozen, nil, detail)
+	if budget.AutoUnfreeze && c.kernel != nil {
+		k, id, freeze := c.kernel, c.ID, c.freezes
+		due := k.Clock.After(budget.Window)
+		go func() {
+			<-due
+			k.unfreeze(id, freeze)
+		}()
+	}
+}
+
+// Unfreeze resumes container id after its error budget froze it. Its
+// failure count starts afresh.
+func (k *Kernel) Unfreeze(id string) error {
+	return k.unfreeze(id, 0)
+}
+
+// unfreeze thaws container id; a non-zero freeze only thaws that freeze,
+// so an automatic thaw cannot end a later one.
+func (k *Kernel) unfreeze(id string, freeze int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.frozen || (freeze != 0 && freeze != c.freezes) {
+		return nil
+	}
+	c.frozen = false
+	c.budgetFailures = nil
+	fmt.Printf("[Kernel] Unfroze container %s\n", c.Name)
+	c.emit(EventUnfrozen, nil, "")
+	if c.State == ContainerFrozen {
+		c.State = ContainerRunning
+		c.scheduleLocked()
+	}
+	return nil
+}
+
+// IsFrozen reports whether the container's error budget has frozen it.
+func (c *Container) IsFrozen() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frozen
+}
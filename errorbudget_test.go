@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failing returns n processes that fail as soon as they start.
+func failing(n int) []*Process {
+	var procs []*Process
+	for i := 0; i < n; i++ {
+		procs = append(procs, &Process{Name: fmt.Sprintf("crash-%d", i), Action: func(context.Context, *Handle) error {
+			return errors.New("crash")
+		}})
+	}
+	return procs
+}
+
+func TestErrorBudgetFreezesUntilUnfrozen(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventBudgetExhausted, EventFrozen, EventUnfrozen}})
+	defer cancel()
+	c := newTestContainer(t, k, "shaky")
+	c.MaxRunning = 1
+	c.ErrorBudget = ErrorBudget{Failures: 2, Window: time.Minute}
+	procs := failing(3)
+	for _, p := range procs {
+		c.AddProcess(p)
+	}
+	later := &Process{Name: "later", Action: noop}
+	c.AddProcess(later)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []EventKind{EventBudgetExhausted, EventFrozen} {
+		if e := nextEvent(t, events); e.Kind != want || e.Detail != "3 failures within 1m0s, budget 2" {
+			t.Errorf("event = %+v, want %s", e, want)
+		}
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if d := c.Inspect(); !d.Frozen || d.State != ContainerFrozen {
+		t.Fatalf("inspect = %v, frozen %v; want frozen", d.State, d.Frozen)
+	}
+	if got := stateOf(c, later); got != Pending {
+		t.Errorf("later = %v while frozen, want queued", got)
+	}
+	if err := c.StartProcesses(); !errors.Is(err, ErrFrozen) {
+		t.Errorf("StartProcesses while frozen: %v", err)
+	}
+	var out strings.Builder
+	if err := k.MonitorTo(&out, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Running Processes: 0 | Frozen |") {
+		t.Errorf("monitor does not show the freeze:\n%s", out.String())
+	}
+	if d := k.DebugDump(); len(d.Containers) != 1 || !d.Containers[0].Frozen {
+		t.Errorf("debug dump = %+v", d.Containers)
+	}
+
+	if err := k.Unfreeze("shaky"); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventUnfrozen {
+		t.Errorf("event = %+v", e)
+	}
+	waitDone(t, later)
+	if c.IsFrozen() {
+		t.Error("still frozen after Unfreeze")
+	}
+}
+
+func TestDefaultErrorBudgetAutoUnfreezes(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.DefaultErrorBudget = ErrorBudget{Failures: 1, Window: time.Minute, AutoUnfreeze: true}
+	c := newTestContainer(t, k, "shaky")
+	for _, p := range failing(2) {
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+	}
+	if !c.IsFrozen() {
+		t.Fatal("the kernel default budget did not freeze the container")
+	}
+	later := &Process{Name: "later", Action: noop}
+	c.AddProcess(later)
+
+	waitForWaiters(t, clk, 1)
+	clk.Advance(59 * time.Second)
+	if !c.IsFrozen() {
+		t.Fatal("thawed before the window passed")
+	}
+	clk.Advance(time.Second)
+	eventually(t, "automatic thaw", func() bool { return !c.IsFrozen() })
+	waitDone(t, later)
+}
+
+func TestAlertOnlyErrorBudget(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventBudgetExhausted}})
+	defer cancel()
+	c := newTestContainer(t, k, "noisy")
+	c.ErrorBudget = ErrorBudget{Failures: 1, AlertOnly: true}
+	for _, p := range failing(2) {
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+	}
+	if e := nextEvent(t, events); e.Kind != EventBudgetExhausted {
+		t.Errorf("event = %+v", e)
+	}
+	if c.IsFrozen() {
+		t.Error("an alert-only budget froze the container")
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Kernel Events ---
+
+type EventKind string
+
+const (
+	EventContainerCreated  EventKind = "ContainerCreated"
+	EventContainerStarted  EventKind = "ContainerStarted"
+	EventContainerStopped  EventKind = "ContainerStopped"
+	EventContainerRemoved  EventKind = "ContainerRemoved"
+	EventContainerRenamed  EventKind = "ContainerRenamed"
+	EventLabelsChanged     EventKind = "LabelsChanged"
+	EventProcessAdded      EventKind = "ProcessAdded"
+	EventProcessWaiting    EventKind = "ProcessWaiting"
+	EventProcessStarted    EventKind = "ProcessStarted"
+	EventProcessCompleted  EventKind = "ProcessCompleted"
+	EventProcessThrottled  EventKind = "ProcessThrottled"
+	EventProcessFailed     EventKind = "ProcessFailed"
+	EventProcessKilled     EventKind = "ProcessKilled"
+	EventMemoryPressure    EventKind = "MemoryPressure"
+	EventOOMKill           EventKind = "OOMKill"
+	EventMessageSent       EventKind = "MessageSent"
+	EventSessionReassigned EventKind = "SessionReassigned"
+	EventKernelPromoted    EventKind = "KernelPromoted"
+	EventKernelFenced      EventKind = "KernelFenced"
+	EventPlacementFallback EventKind = "PlacementFallback"
+	EventForceKilled       EventKind = "ForceKilled"
+	EventAnomaly           EventKind = "Anomaly"
+	EventLeakDetected      EventKind = "LeakDetected"
+	EventDeadLetter        EventKind = "DeadLetter"
+	EventKindDeprecated    EventKind = "KindDeprecated"
+	EventQueueBacklog      EventKind = "QueueBacklog"
+	EventPriorityInherited EventKind = "PriorityInherited"
+	EventPriorityRestored  EventKind = "PriorityRestored"
+	EventContainerIdle     EventKind = "ContainerIdle"
+	EventPoolEmpty         EventKind = "PoolEmpty"
+	EventSchedulerFault    EventKind = "SchedulerFault"
+	EventOverrideAdded     EventKind = "OverrideAdded"
+	EventOverrideRemoved   EventKind = "OverrideRemoved"
+	EventCircuitChanged    EventKind = "CircuitChanged"
+	EventDebugStarted      EventKind = "DebugStarted"
+	EventDebugFinished     EventKind = "DebugFinished"
+	EventStopEscalated     EventKind = "StopEscalated"
+	EventClockStep         EventKind = "ClockStep"
+	EventQuarantined       EventKind = "Quarantined"
+	EventUnquarantined     EventKind = "Unquarantined"
+	EventJobFinished       EventKind = "JobFinished"
+	EventBudgetExhausted   EventKind = "BudgetExhausted"
+	EventFrozen            EventKind = "Frozen"
+	EventUnfrozen          EventKind = "Unfrozen"
+	EventLimitAdjusted     EventKind = "LimitAdjusted"
+	EventMemoryLimitSet    EventKind = "MemoryLimitSet"
+	EventReplayCaughtUp    EventKind = "ReplayCaughtUp"
+	EventCapabilityDenied  EventKind = "CapabilityDenied"
+	EventBootPhase         EventKind = "BootPhase"
+	EventGroupCancelled    EventKind = "GroupCancelled"
+	EventDeclarationDrift  EventKind = "DeclarationDrift"
+	EventContainerRestored EventKind = "ContainerRestored"
+	EventContainerPurged   EventKind = "ContainerPurged"
+	EventMaintenanceStart  EventKind = "MaintenanceStart"
+	EventMaintenanceEnd    EventKind = "MaintenanceEnd"
+	EventMaintenanceSkip   EventKind = "MaintenanceSkip"
+	EventSlowExecution     EventKind = "SlowExecution"
+)
+
+// Event is a single entry on the kernel event stream.
+type Event struct {
+	Seq         uint64    `json:"seq"`
+	Kind        EventKind `json:"kind"`
+	Time        time.Time `json:"time"`
+	LocalTime   time.Time `json:"local_time,omitempty"` // on a skewed container's clock
+	ContainerID string    `json:"container_id,omitempty"`
+	Process     string    `json:"process,omitempty"`
+	PID         int       `json:"pid,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+	TraceID     uint64    `json:"trace_id,omitempty"` // message events only
+}
+
+const (
+	// eventBufferSize is the channel buffer given to each subscriber. Events
+	// are dropped for subscribers that fall this far behind.
+	eventBufferSize = 64
+	// replayCapacity bounds how many past events SubscribeWithReplay can
+	// deliver.
+	replayCapacity = 256
+)
+
+// eventBus fans events out to subscribers without ever blocking the
+// emitter. It keeps the most recent replayCapacity events for late joiners.
+type eventBus struct {
+	mu      sync.Mutex
+	seq     uint64
+	subs    map[chan Event]EventFilter
+	history []Event // ring buffer of the last replayCapacity events
+	next    int     // next write position in history once it is full
+	dropped uint64
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[chan Event]EventFilter)}
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e.Seq = b.seq
+	if len(b.history) < replayCapacity {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.next] = e
+		b.next = (b.next + 1) % replayCapacity
+	}
+	for ch, f := range b.subs {
+		if !f.Match(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			b.dropped++
+		}
+	}
+}
+
+// recent returns up to n of the most recent events, oldest first; none if
+// n is not positive.
+func (b *eventBus) recent(n int) []Event {
+	n = min(max(n, 0), len(b.history))
+	out := make([]Event, 0, n)
+	start := b.next + len(b.history) - n
+	for i := 0; i < n; i++ {
+		out = append(out, b.history[(start+i)%len(b.history)])
+	}
+	return out
+}
+
+func (b *eventBus) subscribe(replay int, f EventFilter) (<-chan Event, func()) {
+	ch, cancel, _ := b.subscribeSized(replay, eventBufferSize, f)
+	return ch, cancel
+}
+
+// subscribeSized subscribes with a channel buffer of size (plus room for the
+// replayed events) and also returns the sequence number of the last event
+// published before the subscription took effect. Only events matching f are
+// replayed or delivered.
+func (b *eventBus) subscribeSized(replay, size int, f EventFilter) (<-chan Event, func(), uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var past []Event
+	for _, e := range b.recent(replay) {
+		if f.Match(e) {
+			past = append(past, e)
+		}
+	}
+	ch := make(chan Event, size+len(past))
+	for _, e := range past {
+		ch <- e
+	}
+	b.subs[ch] = f
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subs, ch)
+			close(ch)
+		})
+	}, b.seq
+}
+
+func (b *eventBus) lastSeq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+func (b *eventBus) stats() EventBusStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := EventBusStats{Subscribers: len(b.subs), Dropped: b.dropped}
+	for ch := range b.subs {
+		s.QueueDepth += len(ch)
+	}
+	return s
+}
+
+// EventBusStats reports event bus queue depth and drops.
+type EventBusStats struct {
+	Subscribers int    `json:"subscribers"`
+	QueueDepth  int    `json:"queue_depth"`
+	Dropped     uint64 `json:"dropped"`
+}
+
+// Subscribe returns a channel receiving every event emitted from now on,
+// and a function that unsubscribes and closes the channel.
+func (k *Kernel) Subscribe() (<-chan Event, func()) {
+	return k.events.subscribe(0, EventFilter{})
+}
+
+// SubscribeWithReplay is like Subscribe but first delivers up to the last n
+// events already emitted (bounded by the replay buffer), so late joiners get
+// context before live events. A non-positive n replays nothing.
+func (k *Kernel) SubscribeWithReplay(n int) (<-chan Event, func()) {
+	return k.events.subscribe(n, EventFilter{})
+}
+
+// EventFilter selects events for SubscribeFiltered. An event matches when
+// its container is one of ContainerIDs and its kind one of Kinds; an empty
+// list matches anything. Kernel-wide events have no container, so they
+// never match a filter that lists containers.
+type EventFilter struct {
+	ContainerIDs []string
+	Kinds        []EventKind
+}
+
+// Match reports whether e passes the filter.
+func (f EventFilter) Match(e Event) bool {
+	return (len(f.ContainerIDs) == 0 || slices.Contains(f.ContainerIDs, e.ContainerID)) &&
+		(len(f.Kinds) == 0 || slices.Contains(f.Kinds, e.Kind))
+}
+
+// SubscribeFiltered is like Subscribe but only delivers events matching
+// filter. Events are filtered before they are queued, so unrelated traffic
+// neither fills the channel nor counts as dropped.
+func (k *Kernel) SubscribeFiltered(filter EventFilter) (<-chan Event, func()) {
+	filter.ContainerIDs = slices.Clone(filter.ContainerIDs)
+	filter.Kinds = slices.Clone(filter.Kinds)
+	return k.events.subscribe(0, filter)
+}
+
+func (k *Kernel) emit(kind EventKind, containerID, process, detail string) {
+	k.publish(Event{
+		Kind:        kind,
+		ContainerID: containerID,
+		Process:     process,
+		Detail:      detail,
+	})
+}
+
+// publish stamps e with the kernel clock and sends it to subscribers.
+func (k *Kernel) publish(e Event) {
+	e.Time = k.Clock.Now()
+	k.timeline.record(e)
+	k.events.publish(e)
+}
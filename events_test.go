@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSubscribeWithReplayDeliversBufferedEventsFirst(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for i := 0; i < 5; i++ {
+		k.emit(EventAnomaly, "", "", fmt.Sprint("old", i))
+	}
+	ch, cancel := k.SubscribeWithReplay(3)
+	defer cancel()
+	k.emit(EventAnomaly, "", "", "new")
+
+	for _, want := range []string{"old2", "old3", "old4", "new"} {
+		if e := nextEvent(t, ch); e.Detail != want {
+			t.Fatalf("got %q, want %q", e.Detail, want)
+		}
+	}
+}
+
+func TestSubscribeWithReplayBounds(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for i := 0; i < replayCapacity+10; i++ {
+		k.emit(EventAnomaly, "", "", fmt.Sprint(i))
+	}
+	for _, tc := range []struct {
+		n, want int
+		first   string
+	}{
+		{-1, 0, ""},
+		{0, 0, ""},
+		{2, 2, fmt.Sprint(replayCapacity + 8)},
+		{replayCapacity + 100, replayCapacity, "10"},
+	} {
+		ch, cancel := k.SubscribeWithReplay(tc.n)
+		if len(ch) != tc.want {
+			t.Errorf("SubscribeWithReplay(%d) replayed %d events, want %d", tc.n, len(ch), tc.want)
+		}
+		if tc.want > 0 {
+			if e := <-ch; e.Detail != tc.first {
+				t.Errorf("SubscribeWithReplay(%d) starts at %q, want %q", tc.n, e.Detail, tc.first)
+			}
+		}
+		cancel()
+	}
+}
+
+func TestEventSequenceNumbers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	ch, cancel := k.Subscribe()
+	defer cancel()
+	k.emit(EventAnomaly, "", "", "a")
+	k.emit(EventAnomaly, "", "", "b")
+	first, second := nextEvent(t, ch), nextEvent(t, ch)
+	if second.Seq != first.Seq+1 {
+		t.Errorf("seqs %d, %d; want consecutive", first.Seq, second.Seq)
+	}
+	if !first.Time.Equal(testEpoch) {
+		t.Errorf("event time %v, want the kernel clock's %v", first.Time, testEpoch)
+	}
+}
+
+func TestSubscribeFilteredByContainer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	ch, cancel := k.SubscribeFiltered(EventFilter{ContainerIDs: []string{"a"}})
+	defer cancel()
+	for i := 0; i < eventBufferSize*2; i++ {
+		k.emit(EventAnomaly, "b", "", "noise")
+	}
+	k.emit(EventAnomaly, "a", "", "mine")
+	k.emit(EventLabelsChanged, "a", "", "mine too")
+
+	for _, want := range []string{"mine", "mine too"} {
+		if e := nextEvent(t, ch); e.ContainerID != "a" || e.Detail != want {
+			t.Fatalf("got %+v, want %q from a", e, want)
+		}
+	}
+	if len(ch) != 0 {
+		t.Errorf("%d more events queued", len(ch))
+	}
+	if s := k.events.stats(); s.Dropped != 0 {
+		t.Errorf("%d events dropped; filtered-out events must not count", s.Dropped)
+	}
+}
+
+func TestSubscribeFilteredByKind(t *testing.T) {
+	k, _ := newTestKernel(t)
+	ch, cancel := k.SubscribeFiltered(EventFilter{ContainerIDs: []string{"a", "b"}, Kinds: []EventKind{EventLabelsChanged}})
+	defer cancel()
+	k.emit(EventAnomaly, "a", "", "wrong kind")
+	k.emit(EventLabelsChanged, "c", "", "wrong container")
+	k.emit(EventLabelsChanged, "b", "", "match")
+	if e := nextEvent(t, ch); e.Detail != "match" || len(ch) != 0 {
+		t.Errorf("got %+v and %d more", e, len(ch))
+	}
+}
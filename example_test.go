@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// newExampleKernel returns a kernel on a FakeClock with a fixed Rand seed,
+// so the examples print the same output on every run.
+func newExampleKernel() *Kernel {
+	k := NewKernel()
+	k.Clock = NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	k.Rand = rand.New(rand.NewSource(1))
+	return k
+}
+
+// awaitFinished reads events until n processes have completed or failed.
+func awaitFinished(events <-chan Event, n int) {
+	for e := range events {
+		if e.Kind == EventProcessCompleted || e.Kind == EventProcessFailed {
+			if n--; n == 0 {
+				return
+			}
+		}
+	}
+}
+
+func ExampleKernel_CreateContainer() {
+	k := newExampleKernel()
+	c, err := k.CreateContainer("web", "frontend", 512)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(c.ID, c.Name, c.MemoryMB)
+
+	_, err = k.CreateContainer("db", "database", 0)
+	fmt.Println(errors.Is(err, ErrInvalidContainerSpec), err)
+	// Output:
+	// [Kernel] Created container: frontend
+	// web frontend 512
+	// true invalid container spec: container db memory must be positive, got 0MB
+}
+
+func ExampleContainer_AddProcess() {
+	k := newExampleKernel()
+	events, cancel := k.Subscribe()
+	defer cancel()
+	c, _ := k.CreateContainer("jobs", "jobs", 256)
+	p := &Process{Name: "report", Action: func(ctx context.Context, h *Handle) error {
+		return nil
+	}}
+	c.AddProcess(p)
+	fmt.Println(p.Name, p.State)
+
+	c.StartProcesses()
+	awaitFinished(events, 1)
+	fmt.Println(p.Name, c.Inspect().Processes[0].State)
+	// Output:
+	// [Kernel] Created container: jobs
+	// report Pending
+	// report Completed
+}
+
+func ExampleKernel_SendMessage() {
+	k := newExampleKernel()
+	k.CreateContainer("web", "web", 256)
+	k.CreateContainer("db", "db", 256)
+	if err := k.SendMessage("web", "db", "SELECT 1"); err != nil {
+		panic(err)
+	}
+	fmt.Println(k.SendMessage("web", "cache", "GET users"))
+	// Output:
+	// [Kernel] Created container: web
+	// [Kernel] Created container: db
+	// [Kernel] web -> db : SELECT 1
+	// [Kernel] Messaging error: container not found
+	// container not found
+}
+
+func ExampleKernel_Monitor() {
+	k := newExampleKernel()
+	events, cancel := k.Subscribe()
+	defer cancel()
+	db, _ := k.CreateContainer("db", "database", 2048)
+	web, _ := k.CreateContainer("web", "frontend", 512)
+	db.CPULoad = 42.5
+	web.CPULoad = 7
+
+	db.AddProcess(&Process{Name: "migrate", Action: func(ctx context.Context, h *Handle) error { return nil }})
+	db.StartProcesses()
+	awaitFinished(events, 1)
+
+	k.Monitor(0, 1)
+	// Output:
+	// [Kernel] Created container: database
+	// [Kernel] Created container: frontend
+	// === Kernel Monitoring ===
+	// Container database | Memory: 2048MB | CPU: 42.50% | Running Processes: 0 | Done: 1 completed (+1)
+	// Container frontend | Memory: 512MB | CPU: 7.00% | Running Processes: 0
+}
+
+func ExampleKernel_Stats() {
+	k := newExampleKernel()
+	events, cancel := k.Subscribe()
+	defer cancel()
+	c, _ := k.CreateContainer("jobs", "jobs", 1024)
+	c.AddProcess(&Process{Name: "ok", Action: func(ctx context.Context, h *Handle) error { return nil }})
+	c.AddProcess(&Process{Name: "bad", Action: func(ctx context.Context, h *Handle) error { return errors.New("boom") }})
+	c.StartProcesses()
+	awaitFinished(events, 2)
+
+	s := k.Stats()
+	fmt.Printf("containers=%d processes=%d completed=%d failed=%d\n", s.Containers, s.Processes, s.Completed, s.Failed)
+	// Output:
+	// [Kernel] Created container: jobs
+	// containers=1 processes=2 completed=1 failed=1
+}
+
+func ExampleWithRetry() {
+	calls := 0
+	flaky := func(ctx context.Context, h *Handle) error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}
+	err := WithRetry(5, 0, flaky)(context.Background(), nil)
+	fmt.Println(calls, err)
+	// Output:
+	// 3 <nil>
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// --- Process Kinds and Container Export/Import ---
+
+// ProcessFactory builds a process from its definition. Factories are
+// registered per kind so that process definitions can be recreated on
+// another kernel, where Actions cannot be serialised.
+type ProcessFactory func(spec ProcessSpec) *Process
+
+// ProcessSpec is the serialisable definition of a process.
+type ProcessSpec struct {
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind"`
+	Priority int               `json:"priority"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// ContainerSpec is the serialisable definition of a container.
+type ContainerSpec struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	MemoryMB int               `json:"memory_mb"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Volumes  []string          `json:"volumes,omitempty"`
+	// DependsOn lists container IDs this container depends on. Bundles
+	// hold a single container, so exports leave it empty.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// StopPriority orders shutdown; see Container.StopPriority.
+	StopPriority int `json:"stop_priority,omitempty"`
+	// BootPhase orders startup; see Container.BootPhase.
+	BootPhase int `json:"boot_phase,omitempty"`
+}
+
+// ContainerBundle is the self-contained export format of a single container.
+type ContainerBundle struct {
+	Version   int           `json:"version"`
+	Container ContainerSpec `json:"container"`
+	Processes []ProcessSpec `json:"processes"`
+}
+
+const bundleVersion = 1
+
+var (
+	ErrContainerNotFound = errors.New("container not found")
+	ErrContainerExists   = errors.New("container already exists")
+	ErrUnknownKind       = errors.New("unknown process kind")
+)
+
+// MissingKindsError is returned by ImportContainer when the bundle refers to
+// process kinds that are not registered on the importing kernel.
+type MissingKindsError struct {
+	Kinds []string
+}
+
+func (e *MissingKindsError) Error() string {
+	return fmt.Sprintf("missing process kinds: %s", strings.Join(e.Kinds, ", "))
+}
+
+func (e *MissingKindsError) Unwrap() error { return ErrUnknownKind }
+
+// RegisterKind makes factory available for building processes of kind. It
+// registers the unversioned variant of the kind; see RegisterKindVersion.
+func (k *Kernel) RegisterKind(kind string, factory ProcessFactory) {
+	k.RegisterKindVersion(kind, "", factory)
+}
+
+// NewProcess builds a process from spec using the registered factory.
+func (k *Kernel) NewProcess(spec ProcessSpec) (*Process, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.newProcessLocked(spec)
+}
+
+func (k *Kernel) newProcessLocked(spec ProcessSpec) (*Process, error) {
+	factory, kind, version, err := k.resolveKindLocked(spec.Kind)
+	if err != nil {
+		return nil, err
+	}
+	return buildProcess(factory, kind, version, spec), nil
+}
+
+// buildProcess runs a resolved factory and stamps the result with spec.
+func buildProcess(factory ProcessFactory, kind, version string, spec ProcessSpec) *Process {
+	p := factory(spec)
+	p.Name = spec.Name
+	p.Kind = kind
+	p.KindVersion = version
+	p.Priority = spec.Priority
+	p.Params = copyStringMap(spec.Params)
+	return p
+}
+
+// ExportContainer writes a JSON bundle describing container id: its spec,
+// labels, env, volume references and process definitions. Processes without
+// a Kind cannot be rebuilt elsewhere, so their presence is an error.
+func (k *Kernel) ExportContainer(id string, w io.Writer) error {
+	k.mu.Lock()
+	c, ok := k.Containers[id]
+	k.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	b, err := c.bundle()
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+func (c *Container) bundle() (*ContainerBundle, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &ContainerBundle{
+		Version: bundleVersion,
+		Container: ContainerSpec{
+			ID:       c.ID,
+			Name:     c.Name,
+			MemoryMB: c.MemoryMB,
+			Labels:   copyStringMap(c.Labels),
+			Env:      copyStringMap(c.Env),
+			Volumes:  append([]string(nil), c.Volumes...),
+
+			StopPriority: c.StopPriority,
+			BootPhase:    c.BootPhase,
+		},
+	}
+	var kindless []string
+	for _, p := range c.Processes {
+		if p.Kind == "" {
+			kindless = append(kindless, p.Name)
+			continue
+		}
+		b.Processes = append(b.Processes, ProcessSpec{
+			Name:     p.Name,
+			Kind:     p.kindRef(),
+			Priority: p.Priority,
+			Params:   copyStringMap(p.Params),
+		})
+	}
+	if len(kindless) > 0 {
+		return nil, fmt.Errorf("container %s: processes without a kind cannot be exported: %s",
+			c.ID, strings.Join(kindless, ", "))
+	}
+	return b, nil
+}
+
+type importOptions struct {
+	renameOnConflict bool
+}
+
+// ImportOption configures ImportContainer.
+type ImportOption func(*importOptions)
+
+// WithRenameOnConflict imports under a fresh ID ("<id>-1", "<id>-2", ...)
+// when the bundle's ID is already taken, instead of failing.
+func WithRenameOnConflict() ImportOption {
+	return func(o *importOptions) { o.renameOnConflict = true }
+}
+
+// ImportContainer creates a container from a bundle written by
+// ExportContainer. The container is created Stopped; its processes are
+// rebuilt through the kernel's registered kinds and wait for the next
+// StartProcesses.
+func (k *Kernel) ImportContainer(r io.Reader, opts ...ImportOption) (*Container, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	var o importOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var b ContainerBundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("decode bundle: %w", err)
+	}
+	if b.Version != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	if err := validateContainer(b.Container.ID, b.Container.Name, b.Container.MemoryMB); err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.importBundleLocked(&b, o)
+}
+
+func (k *Kernel) importBundleLocked(b *ContainerBundle, o importOptions) (*Container, error) {
+	missing := map[string]bool{}
+	for _, ps := range b.Processes {
+		if !k.hasKindLocked(ps.Kind) {
+			missing[ps.Kind] = true
+		}
+	}
+	if len(missing) > 0 {
+		e := &MissingKindsError{}
+		for kind := range missing {
+			e.Kinds = append(e.Kinds, kind)
+		}
+		sort.Strings(e.Kinds)
+		return nil, e
+	}
+
+	id := b.Container.ID
+	if _, taken := k.Containers[id]; taken {
+		if !o.renameOnConflict {
+			return nil, fmt.Errorf("%w: %s", ErrContainerExists, id)
+		}
+		for n := 1; ; n++ {
+			candidate := fmt.Sprintf("%s-%d", b.Container.ID, n)
+			if _, taken := k.Containers[candidate]; !taken {
+				id = candidate
+				break
+			}
+		}
+	}
+
+	procs := make([]*Process, 0, len(b.Processes))
+	for _, ps := range b.Processes {
+		p, err := k.newProcessLocked(ps)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+
+	c := k.createContainerLocked(id, b.Container.Name, b.Container.MemoryMB)
+	c.State = ContainerStopped
+	c.Labels = copyStringMap(b.Container.Labels)
+	c.Env = copyStringMap(b.Container.Env)
+	c.Volumes = append([]string(nil), b.Container.Volumes...)
+	c.StopPriority = b.Container.StopPriority
+	c.BootPhase = b.Container.BootPhase
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range procs {
+		c.addProcessLocked(p)
+	}
+	return c, nil
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
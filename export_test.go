@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// registerWorker registers kind on k with a factory building no-op
+// processes.
+func registerWorker(k *Kernel, kind string) {
+	k.RegisterKind(kind, func(ProcessSpec) *Process { return &Process{Action: noop} })
+}
+
+// exportBundle exports container id from k, failing the test on error.
+func exportBundle(t *testing.T, k *Kernel, id string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := k.ExportContainer(id, &buf); err != nil {
+		t.Fatalf("ExportContainer(%s): %v", id, err)
+	}
+	return &buf
+}
+
+func newExportSource(t *testing.T) *Kernel {
+	t.Helper()
+	src, _ := newTestKernel(t)
+	registerWorker(src, "worker")
+	registerWorker(src, "cron")
+	c := newTestContainer(t, src, "api")
+	c.SetLabels(map[string]string{"tier": "web"})
+	c.mu.Lock()
+	c.Env = map[string]string{"PORT": "8080"}
+	c.Volumes = []string{"data"}
+	c.StopPriority = 3
+	c.BootPhase = 2
+	c.mu.Unlock()
+	for _, ps := range []ProcessSpec{
+		{Name: "serve", Kind: "worker", Priority: 5, Params: map[string]string{"port": "8080"}},
+		{Name: "tick", Kind: "cron", Priority: 1},
+	} {
+		p, err := src.NewProcess(ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.AddProcess(p)
+	}
+	return src
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := newExportSource(t)
+	want := exportBundle(t, src, "api")
+
+	dst, _ := newTestKernel(t)
+	registerWorker(dst, "worker")
+	registerWorker(dst, "cron")
+	c, err := dst.ImportContainer(bytes.NewReader(want.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "api" || c.State != ContainerStopped {
+		t.Errorf("imported %s in state %v, want api Stopped", c.ID, c.State)
+	}
+	if c.StopPriority != 3 || c.BootPhase != 2 {
+		t.Errorf("imported StopPriority %d and BootPhase %d, want 3 and 2", c.StopPriority, c.BootPhase)
+	}
+	for _, p := range c.Processes {
+		if p.State != Pending {
+			t.Errorf("process %s = %v, want Pending", p.Name, p.State)
+		}
+	}
+
+	var srcBundle, dstBundle ContainerBundle
+	mustDecode(t, want.Bytes(), &srcBundle)
+	mustDecode(t, exportBundle(t, dst, "api").Bytes(), &dstBundle)
+	if !reflect.DeepEqual(srcBundle, dstBundle) {
+		t.Errorf("round trip changed the bundle:\n got %+v\nwant %+v", dstBundle, srcBundle)
+	}
+}
+
+func TestImportRenamesOnConflict(t *testing.T) {
+	src := newExportSource(t)
+	bundle := exportBundle(t, src, "api").Bytes()
+
+	dst, _ := newTestKernel(t)
+	registerWorker(dst, "worker")
+	registerWorker(dst, "cron")
+	newTestContainer(t, dst, "api")
+	newTestContainer(t, dst, "api-1")
+
+	if _, err := dst.ImportContainer(bytes.NewReader(bundle)); !errors.Is(err, ErrContainerExists) {
+		t.Fatalf("import over an existing ID: %v, want ErrContainerExists", err)
+	}
+	c, err := dst.ImportContainer(bytes.NewReader(bundle), WithRenameOnConflict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "api-2" || dst.Containers["api-2"] != c {
+		t.Errorf("renamed to %q, want api-2", c.ID)
+	}
+}
+
+func TestImportReportsMissingKinds(t *testing.T) {
+	src := newExportSource(t)
+	bundle := exportBundle(t, src, "api").Bytes()
+
+	dst, _ := newTestKernel(t)
+	_, err := dst.ImportContainer(bytes.NewReader(bundle))
+	var missing *MissingKindsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("err = %v, want MissingKindsError", err)
+	}
+	if !reflect.DeepEqual(missing.Kinds, []string{"cron", "worker"}) {
+		t.Errorf("missing kinds = %v, want [cron worker]", missing.Kinds)
+	}
+	if !errors.Is(err, ErrUnknownKind) {
+		t.Error("MissingKindsError does not unwrap to ErrUnknownKind")
+	}
+	if len(dst.Containers) != 0 {
+		t.Error("failed import created a container")
+	}
+}
+
+func TestExportRejectsKindlessProcesses(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	c.AddProcess(&Process{Name: "adhoc", Action: noop})
+	if err := k.ExportContainer("api", &bytes.Buffer{}); err == nil {
+		t.Fatal("exported a process without a kind")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/BetnixTech/bvisor
+
+go 1.23
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// --- Process Handle ---
+
+// ActionFunc is the body of a process. ctx is cancelled when the process is
+// stopped or killed; h gives the action access to its kernel facilities.
+type ActionFunc func(ctx context.Context, h *Handle) error
+
+type SignalKind int
+
+const (
+	SignalMemoryPressure SignalKind = iota
+	// SignalReload asks a process to re-read its configuration; Params
+	// carries the settings to change.
+	SignalReload
+	// SignalShutdown asks a process to finish up and return; see
+	// StopPolicy.
+	SignalShutdown
+)
+
+func (k SignalKind) String() string {
+	switch k {
+	case SignalMemoryPressure:
+		return "MemoryPressure"
+	case SignalReload:
+		return "Reload"
+	case SignalShutdown:
+		return "Shutdown"
+	}
+	return "Unknown"
+}
+
+// Signal is an asynchronous notification delivered to a running process.
+type Signal struct {
+	Kind SignalKind
+	// UsageMB and LimitMB describe the container's memory at the time a
+	// MemoryPressure signal was raised.
+	UsageMB int
+	LimitMB int
+	// Params holds the settings a Reload signal changes.
+	Params map[string]string
+}
+
+// signalBufferSize bounds undelivered signals per process; further signals
+// are dropped until the action drains its channel.
+const signalBufferSize = 8
+
+// Handle is a running process's view of the kernel.
+type Handle struct {
+	proc      *Process
+	container *Container
+	signals   chan Signal
+	replica   int
+	run       *runState // shared by the replicas of one run
+	ctx       context.Context
+
+	// traceID and causeID identify the message the process is handling;
+	// messages it sends are traced as caused by it. Guarded by the
+	// container lock.
+	traceID uint64
+	causeID uint64
+
+	// handling is when each request being handled was received, while
+	// profiling. Guarded by the container lock.
+	handling map[uint64]time.Time
+}
+
+func newHandle(c *Container, p *Process) *Handle {
+	return &Handle{
+		proc:      p,
+		container: c,
+		signals:   make(chan Signal, signalBufferSize),
+	}
+}
+
+// forReplica returns a handle for replica i of the same process. Replicas
+// share the signal channel, so each signal reaches one of them.
+func (h *Handle) forReplica(i int) *Handle {
+	r := *h
+	r.replica = i
+	return &r
+}
+
+// Replica reports which replica of the process this handle belongs to,
+// counting from zero.
+func (h *Handle) Replica() int {
+	return h.replica
+}
+
+// Container returns the container the process runs in. Its exported
+// methods are safe to call from the action; its fields are guarded by the
+// container lock and should be read through Inspect.
+func (h *Handle) Container() *Container {
+	return h.container
+}
+
+type containerKey struct{}
+
+// ContainerFromContext returns the container of the process whose action
+// was given ctx, for code that is passed the context but not the Handle.
+func ContainerFromContext(ctx context.Context) (*Container, bool) {
+	c, ok := ctx.Value(containerKey{}).(*Container)
+	return c, ok
+}
+
+// Signals returns the channel on which the kernel delivers signals.
+func (h *Handle) Signals() <-chan Signal {
+	return h.signals
+}
+
+// Signal delivers s to every running process of the container and returns
+// how many were signalled. Like kernel signals, it is dropped for a process
+// whose signal buffer is full.
+func (c *Container) Signal(s Signal) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, p := range c.Processes {
+		if p.State == Running && p.handle != nil {
+			p.handle.signal(s)
+			n++
+		}
+	}
+	return n
+}
+
+func (h *Handle) signal(s Signal) {
+	select {
+	case h.signals <- s:
+	default:
+	}
+}
+
+// AllocateMemory grows the process's simulated memory usage by mb.
+func (h *Handle) AllocateMemory(mb int) {
+	h.container.adjustMemory(h.proc, mb)
+}
+
+// ReleaseMemory shrinks the process's simulated memory usage by mb, never
+// below zero.
+func (h *Handle) ReleaseMemory(mb int) {
+	h.container.adjustMemory(h.proc, -mb)
+}
+
+// MemoryUsageMB reports the process's current simulated memory usage.
+func (h *Handle) MemoryUsageMB() int {
+	h.container.mu.Lock()
+	defer h.container.mu.Unlock()
+	return h.proc.usedMB
+}
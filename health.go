@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// --- Health ---
+
+// Healthz reports whether the kernel is fit to serve, for liveness and
+// readiness probes. The kernel is unhealthy when any container has more
+// Failed processes than Kernel.HealthFailureThreshold. details maps every
+// container ID to "ok" or the reason it is unhealthy.
+func (k *Kernel) Healthz() (healthy bool, details map[string]string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ids := make([]string, 0, len(k.Containers))
+	for id := range k.Containers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	healthy = true
+	details = make(map[string]string, len(ids))
+	for _, id := range ids {
+		c := k.Containers[id]
+		c.mu.Lock()
+		failed := 0
+		for _, p := range c.Processes {
+			if p.State == Failed {
+				failed++
+			}
+		}
+		c.mu.Unlock()
+		if failed > k.HealthFailureThreshold {
+			healthy = false
+			details[id] = fmt.Sprintf("%d failed processes (threshold %d)", failed, k.HealthFailureThreshold)
+			continue
+		}
+		details[id] = "ok"
+	}
+	return healthy, details
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestHealthzReportsFailingContainer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.HealthFailureThreshold = 1
+	newTestContainer(t, k, "api")
+	c := newTestContainer(t, k, "jobs")
+	if healthy, details := k.Healthz(); !healthy || details["api"] != "ok" || details["jobs"] != "ok" {
+		t.Fatalf("fresh kernel: healthy %v, %v", healthy, details)
+	}
+
+	for i := 0; i < 2; i++ {
+		p := &Process{Name: fmt.Sprint("broken", i), Action: func(context.Context, *Handle) error { return errors.New("boom") }}
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+		healthy, details := k.Healthz()
+		if want := i == 0; healthy != want {
+			t.Errorf("after %d failures: healthy = %v, want %v (%v)", i+1, healthy, want, details)
+		}
+	}
+	_, details := k.Healthz()
+	if details["jobs"] != "2 failed processes (threshold 1)" || details["api"] != "ok" {
+		t.Errorf("details = %v", details)
+	}
+}
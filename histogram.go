@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// --- Scheduling Latency ---
+
+// schedulingBounds are the upper bounds of the scheduling latency buckets;
+// a last bucket catches everything above them.
+var schedulingBounds = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute,
+}
+
+// HistogramBucket counts the observations above the previous bucket's
+// UpperBound and up to its own. The last bucket's UpperBound is
+// math.MaxInt64.
+type HistogramBucket struct {
+	UpperBound time.Duration `json:"le"`
+	Count      uint64        `json:"count"`
+}
+
+// Histogram is a point-in-time copy of a duration histogram.
+type Histogram struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	Count   uint64            `json:"count"`
+	Sum     time.Duration     `json:"sum"`
+}
+
+// Mean is the average observation, zero if there were none.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// durationHistogram is a fixed-bucket histogram updated atomically, so it
+// can be recorded into under any lock.
+type durationHistogram struct {
+	bounds []time.Duration
+	counts []atomic.Uint64 // one per bound, plus the overflow bucket
+	sum    atomic.Int64
+}
+
+func newDurationHistogram(bounds []time.Duration) *durationHistogram {
+	return &durationHistogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+func (h *durationHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *durationHistogram) snapshot() Histogram {
+	s := Histogram{Buckets: make([]HistogramBucket, len(h.counts)), Sum: time.Duration(h.sum.Load())}
+	for i := range h.counts {
+		bound := time.Duration(math.MaxInt64)
+		if i < len(h.bounds) {
+			bound = h.bounds[i]
+		}
+		n := h.counts[i].Load()
+		s.Buckets[i] = HistogramBucket{UpperBound: bound, Count: n}
+		s.Count += n
+	}
+	return s
+}
+
+// SchedulingLatency returns the histogram of how long processes waited
+// between being queued and starting, on the kernel clock, across all
+// containers. Each start counts once, so a process queued again counts
+// again; debug processes are left out.
+func (k *Kernel) SchedulingLatency() Histogram {
+	return k.schedLatency.snapshot()
+}
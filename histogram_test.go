@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSchedulingLatencyCountsQueueWaits(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "serial")
+	c.MaxRunning = 1
+	var procs []*Process
+	for _, name := range []string{"a", "b", "c"} {
+		p := &Process{Name: name, Action: func(ctx context.Context, h *Handle) error {
+			return h.Sleep(2 * time.Second)
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(2 * time.Second)
+		waitDone(t, p)
+	}
+
+	h := k.SchedulingLatency()
+	if h.Count != 3 || h.Sum != 6*time.Second || h.Mean() != 2*time.Second {
+		t.Errorf("count %d, sum %v, mean %v; want waits of 0s, 2s and 4s", h.Count, h.Sum, h.Mean())
+	}
+	counts := make(map[time.Duration]uint64)
+	for _, b := range h.Buckets {
+		counts[b.UpperBound] = b.Count
+	}
+	if counts[time.Millisecond] != 1 || counts[5*time.Second] != 2 {
+		t.Errorf("buckets = %+v, want one immediate start and two waits up to 5s", h.Buckets)
+	}
+	if last := h.Buckets[len(h.Buckets)-1]; last.UpperBound != math.MaxInt64 || last.Count != 0 {
+		t.Errorf("overflow bucket = %+v", last)
+	}
+	if (Histogram{}).Mean() != 0 {
+		t.Error("mean of an empty histogram")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"maps"
+	"time"
+)
+
+// --- Container Inspection ---
+
+// ProcessDetail is a detached copy of a process's observable state.
+type ProcessDetail struct {
+	PID         int
+	Name        string
+	Kind        string
+	KindVersion string
+	Priority    int
+	State       ProcessState
+	MemoryMB    int
+	UsedMB      int
+	CPU         float64 // CPUWeight shaped by Warmup and Cooldown
+	AddedAt     time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Err         string
+
+	// EffectivePriority includes Nice and priority inherited through
+	// Request.
+	Nice              int
+	EffectivePriority int
+	ConcurrencyGroup  string
+	WaitReason        string
+	Placement         Placement
+	Replicas          []ReplicaDetail
+	OutboxPending     int
+	OpenHandles       []string
+	Debug             bool     // started through Kernel.Debug
+	StopStep          StopStep // step an escalating stop ended at
+}
+
+// ContainerDetail is a detached copy of a container and its processes. It
+// shares no pointers, maps or locks with the live container, so callers may
+// keep or modify it freely.
+type ContainerDetail struct {
+	ID            string
+	Name          string
+	State         ContainerState
+	MemoryMB      int
+	MemoryUsageMB int
+	CPULoad       float64
+	CPUUsage      float64 // summed ProcessDetail.CPU
+	Labels        map[string]string
+	Env           map[string]string
+	Volumes       []string
+	DependsOn     []string
+	Processes     []ProcessDetail
+	UsageHistory  []UsageSample
+	InboxDepth    int
+	InboxBytes    int
+	Pipes         []PipeDetail
+	Metrics       map[string]float64
+	Frozen        bool // by its ErrorBudget
+	Capabilities  Capability
+	Peaks         Peaks
+}
+
+// Inspect returns a deep copy of the container taken under its lock.
+func (c *Container) Inspect() ContainerDetail {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	d := ContainerDetail{
+		ID:            c.ID,
+		Name:          c.Name,
+		State:         c.State,
+		MemoryMB:      c.MemoryMB,
+		MemoryUsageMB: c.memoryUsageLocked(),
+		CPULoad:       c.CPULoad,
+		CPUUsage:      c.cpuUsageLocked(now),
+		Labels:        copyStringMap(c.Labels),
+		Env:           copyStringMap(c.Env),
+		Volumes:       append([]string(nil), c.Volumes...),
+		DependsOn:     append([]string(nil), c.DependsOn...),
+		Processes:     make([]ProcessDetail, 0, len(c.Processes)),
+		UsageHistory:  c.usageHistoryLocked(),
+		InboxDepth:    len(c.inbox),
+		InboxBytes:    c.inboxBytes,
+		Pipes:         c.pipeDetailsLocked(),
+		Metrics:       maps.Clone(c.metrics),
+		Frozen:        c.frozen,
+		Capabilities:  AllCapabilities &^ c.denied,
+		Peaks:         c.peaks,
+	}
+	for _, p := range c.Processes {
+		d.Processes = append(d.Processes, p.detail(now))
+	}
+	return d
+}
+
+func (p *Process) detail(now time.Time) ProcessDetail {
+	d := ProcessDetail{
+		PID:         p.PID,
+		Name:        p.Name,
+		Kind:        p.Kind,
+		KindVersion: p.KindVersion,
+		Priority:    p.Priority,
+		State:       p.State,
+		MemoryMB:    p.MemoryMB,
+		UsedMB:      p.usedMB,
+		CPU:         p.CPUWeight * p.cpuFactor(now),
+		AddedAt:     p.addedAt,
+		StartedAt:   p.startedAt,
+		FinishedAt:  p.finishedAt,
+
+		Nice:              p.Nice,
+		EffectivePriority: p.effectivePriorityLocked(),
+		ConcurrencyGroup:  p.ConcurrencyGroup,
+		WaitReason:        p.WaitReason,
+		Placement:         p.Placement,
+		Replicas:          p.replicaDetails(),
+		OutboxPending:     len(p.outbox),
+		Debug:             p.debug,
+		StopStep:          p.stopStep,
+	}
+	for _, r := range p.resources {
+		d.OpenHandles = append(d.OpenHandles, r.Name)
+	}
+	if p.Err != nil {
+		d.Err = p.Err.Error()
+	}
+	return d
+}
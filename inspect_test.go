@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspectReturnsDeepCopy(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "db")
+	c.SetLabels(map[string]string{"tier": "data"})
+	c.mu.Lock()
+	c.Env = map[string]string{"MODE": "primary"}
+	c.Volumes = []string{"pgdata"}
+	c.DependsOn = []string{"net"}
+	c.mu.Unlock()
+	c.AddProcess(&Process{Name: "engine", Priority: 3, Action: func(ctx context.Context, h *Handle) error {
+		h.SetMetric("qps", 10)
+		return errors.New("disk full")
+	}})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, c.Processes[0])
+	clk.Advance(time.Second)
+
+	before := c.Inspect()
+	d := c.Inspect()
+	if !reflect.DeepEqual(before, d) {
+		t.Fatal("two inspections of an unchanged container differ")
+	}
+	p := d.Processes[0]
+	if p.Name != "engine" || p.State != Failed || p.Priority != 3 || p.Err != "disk full" ||
+		!p.StartedAt.Equal(testEpoch) || !p.FinishedAt.Equal(testEpoch) {
+		t.Errorf("process detail = %+v", p)
+	}
+
+	d.Labels["tier"] = "changed"
+	d.Env["MODE"] = "changed"
+	d.Volumes[0] = "changed"
+	d.DependsOn[0] = "changed"
+	d.Metrics["qps"] = -1
+	d.Processes[0].Name = "changed"
+	d.Processes[0].State = Running
+
+	if after := c.Inspect(); !reflect.DeepEqual(before, after) {
+		t.Errorf("mutating the copy changed the container:\nbefore %+v\nafter  %+v", before, after)
+	}
+	if c.Processes[0].Name != "engine" || c.Labels["tier"] != "data" {
+		t.Error("live container changed")
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Kernel Internals Instrumentation ---
+
+// lockSampleRate controls how often an instrumented lock acquisition is
+// timed: one in every lockSampleRate acquisitions is measured.
+const lockSampleRate = 4
+
+// lockProbe aggregates sampled wait and hold times for a family of locks.
+// All fields are updated atomically so probes can be shared between many
+// mutexes (e.g. every container lock reports into the same probe).
+type lockProbe struct {
+	enabled      atomic.Bool
+	acquisitions atomic.Uint64
+	samples      atomic.Uint64
+	waitNanos    atomic.Int64
+	holdNanos    atomic.Int64
+	maxWaitNanos atomic.Int64
+	maxHoldNanos atomic.Int64
+}
+
+func (p *lockProbe) reset() {
+	p.acquisitions.Store(0)
+	p.samples.Store(0)
+	p.waitNanos.Store(0)
+	p.holdNanos.Store(0)
+	p.maxWaitNanos.Store(0)
+	p.maxHoldNanos.Store(0)
+}
+
+func (p *lockProbe) recordWait(d time.Duration) {
+	p.samples.Add(1)
+	p.waitNanos.Add(int64(d))
+	storeMax(&p.maxWaitNanos, int64(d))
+}
+
+func (p *lockProbe) recordHold(d time.Duration) {
+	p.holdNanos.Add(int64(d))
+	storeMax(&p.maxHoldNanos, int64(d))
+}
+
+func (p *lockProbe) stats() LockStats {
+	s := LockStats{
+		Acquisitions: p.acquisitions.Load(),
+		Samples:      p.samples.Load(),
+		MaxWait:      time.Duration(p.maxWaitNanos.Load()),
+		MaxHold:      time.Duration(p.maxHoldNanos.Load()),
+	}
+	if s.Samples > 0 {
+		s.AvgWait = time.Duration(p.waitNanos.Load() / int64(s.Samples))
+		s.AvgHold = time.Duration(p.holdNanos.Load() / int64(s.Samples))
+	}
+	return s
+}
+
+func storeMax(v *atomic.Int64, n int64) {
+	for {
+		cur := v.Load()
+		if n <= cur || v.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// probedMutex is a sync.Mutex that reports sampled wait/hold times to a
+// lockProbe. When the probe is nil or disabled it costs a single atomic load
+// on top of the plain mutex.
+type probedMutex struct {
+	mu        sync.Mutex
+	probe     *lockProbe
+	heldSince time.Time // only touched while mu is held
+
+	// tracker, when enabled, records holders and waiters for the lock
+	// wait-for graph; name identifies the lock in it.
+	tracker *lockTracker
+	name    string
+}
+
+func (m *probedMutex) Lock() {
+	if t := m.tracker; t != nil && t.enabled.Load() {
+		gid := goroutineID()
+		t.waiting(m, gid)
+		m.lock()
+		t.acquired(m, gid)
+		return
+	}
+	m.lock()
+}
+
+func (m *probedMutex) lock() {
+	p := m.probe
+	if p == nil || !p.enabled.Load() {
+		m.mu.Lock()
+		return
+	}
+	if p.acquisitions.Add(1)%lockSampleRate != 0 {
+		m.mu.Lock()
+		return
+	}
+	start := time.Now()
+	m.mu.Lock()
+	m.heldSince = time.Now()
+	p.recordWait(m.heldSince.Sub(start))
+}
+
+func (m *probedMutex) Unlock() {
+	if t := m.tracker; t != nil && t.enabled.Load() {
+		t.released(m)
+	}
+	if !m.heldSince.IsZero() {
+		m.probe.recordHold(time.Since(m.heldSince))
+		m.heldSince = time.Time{}
+	}
+	m.mu.Unlock()
+}
+
+// loopProbe tracks how long each Monitor cycle spends doing work (excluding
+// the sleep between cycles).
+type loopProbe struct {
+	cycles     atomic.Uint64
+	lastNanos  atomic.Int64
+	maxNanos   atomic.Int64
+	totalNanos atomic.Int64
+}
+
+func (p *loopProbe) record(d time.Duration) {
+	p.cycles.Add(1)
+	p.lastNanos.Store(int64(d))
+	p.totalNanos.Add(int64(d))
+	storeMax(&p.maxNanos, int64(d))
+}
+
+func (p *loopProbe) reset() {
+	p.cycles.Store(0)
+	p.lastNanos.Store(0)
+	p.maxNanos.Store(0)
+	p.totalNanos.Store(0)
+}
+
+// kernelProbes holds every probe owned by a kernel.
+type kernelProbes struct {
+	enabled       atomic.Bool
+	kernelLock    lockProbe
+	containerLock lockProbe
+	monitor       loopProbe
+}
+
+// LockStats summarises sampled timings for one family of locks.
+type LockStats struct {
+	Acquisitions uint64        `json:"acquisitions"`
+	Samples      uint64        `json:"samples"`
+	AvgWait      time.Duration `json:"avg_wait"`
+	MaxWait      time.Duration `json:"max_wait"`
+	AvgHold      time.Duration `json:"avg_hold"`
+	MaxHold      time.Duration `json:"max_hold"`
+}
+
+// LoopStats summarises Monitor loop durations.
+type LoopStats struct {
+	Cycles uint64        `json:"cycles"`
+	Last   time.Duration `json:"last"`
+	Avg    time.Duration `json:"avg"`
+	Max    time.Duration `json:"max"`
+}
+
+// InternalStats is the "kernel internals" section of Stats. It is only
+// populated while instrumentation is enabled.
+type InternalStats struct {
+	KernelLock    LockStats     `json:"kernel_lock"`
+	ContainerLock LockStats     `json:"container_lock"`
+	MonitorLoop   LoopStats     `json:"monitor_loop"`
+	EventBus      EventBusStats `json:"event_bus"`
+
+	// SchedulerQueues counts the processes waiting for admission (see
+	// Container.QueueDepth) by container ID.
+	SchedulerQueues map[string]int `json:"scheduler_queues"`
+}
+
+// SetInstrumentation toggles sampling of the kernel's own internals. Turning
+// it on resets previously collected samples.
+func (k *Kernel) SetInstrumentation(enabled bool) {
+	p := &k.probes
+	if enabled && !p.enabled.Load() {
+		p.kernelLock.reset()
+		p.containerLock.reset()
+		p.monitor.reset()
+	}
+	p.enabled.Store(enabled)
+	p.kernelLock.enabled.Store(enabled)
+	p.containerLock.enabled.Store(enabled)
+}
+
+func (k *Kernel) internalStatsLocked() *InternalStats {
+	p := &k.probes
+	if !p.enabled.Load() {
+		return nil
+	}
+	s := &InternalStats{
+		KernelLock:    p.kernelLock.stats(),
+		ContainerLock: p.containerLock.stats(),
+		MonitorLoop: LoopStats{
+			Cycles: p.monitor.cycles.Load(),
+			Last:   time.Duration(p.monitor.lastNanos.Load()),
+			Max:    time.Duration(p.monitor.maxNanos.Load()),
+		},
+		EventBus:        k.events.stats(),
+		SchedulerQueues: make(map[string]int, len(k.Containers)),
+	}
+	for id, c := range k.Containers {
+		c.mu.Lock()
+		s.SchedulerQueues[id] = c.queueDepthLocked()
+		c.mu.Unlock()
+	}
+	if s.MonitorLoop.Cycles > 0 {
+		s.MonitorLoop.Avg = time.Duration(p.monitor.totalNanos.Load() / int64(s.MonitorLoop.Cycles))
+	}
+	return s
+}
+
+// WriteInternalMetrics writes the "kernel internals" section of Stats in
+// the Prometheus text exposition format, for scraping without a client
+// library. Nothing is written while instrumentation is off.
+func (k *Kernel) WriteInternalMetrics(w io.Writer) error {
+	k.mu.Lock()
+	s := k.internalStatsLocked()
+	k.mu.Unlock()
+	if s == nil {
+		return nil
+	}
+	var b strings.Builder
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	locks := []struct {
+		name  string
+		stats LockStats
+	}{{"kernel", s.KernelLock}, {"container", s.ContainerLock}}
+
+	metric("bvisor_lock_acquisitions_total", "counter", "Instrumented lock acquisitions.")
+	for _, l := range locks {
+		fmt.Fprintf(&b, "bvisor_lock_acquisitions_total{lock=%q} %d\n", l.name, l.stats.Acquisitions)
+	}
+	metric("bvisor_lock_samples_total", "counter", "Lock acquisitions that were timed.")
+	for _, l := range locks {
+		fmt.Fprintf(&b, "bvisor_lock_samples_total{lock=%q} %d\n", l.name, l.stats.Samples)
+	}
+	metric("bvisor_lock_wait_seconds", "gauge", "Sampled lock wait time.")
+	for _, l := range locks {
+		fmt.Fprintf(&b, "bvisor_lock_wait_seconds{lock=%q,stat=\"avg\"} %g\n", l.name, l.stats.AvgWait.Seconds())
+		fmt.Fprintf(&b, "bvisor_lock_wait_seconds{lock=%q,stat=\"max\"} %g\n", l.name, l.stats.MaxWait.Seconds())
+	}
+	metric("bvisor_lock_hold_seconds", "gauge", "Sampled lock hold time.")
+	for _, l := range locks {
+		fmt.Fprintf(&b, "bvisor_lock_hold_seconds{lock=%q,stat=\"avg\"} %g\n", l.name, l.stats.AvgHold.Seconds())
+		fmt.Fprintf(&b, "bvisor_lock_hold_seconds{lock=%q,stat=\"max\"} %g\n", l.name, l.stats.MaxHold.Seconds())
+	}
+
+	metric("bvisor_monitor_cycles_total", "counter", "Monitor cycles run.")
+	fmt.Fprintf(&b, "bvisor_monitor_cycles_total %d\n", s.MonitorLoop.Cycles)
+	metric("bvisor_monitor_cycle_seconds", "gauge", "Time a Monitor cycle spent working.")
+	fmt.Fprintf(&b, "bvisor_monitor_cycle_seconds{stat=\"last\"} %g\n", s.MonitorLoop.Last.Seconds())
+	fmt.Fprintf(&b, "bvisor_monitor_cycle_seconds{stat=\"avg\"} %g\n", s.MonitorLoop.Avg.Seconds())
+	fmt.Fprintf(&b, "bvisor_monitor_cycle_seconds{stat=\"max\"} %g\n", s.MonitorLoop.Max.Seconds())
+
+	metric("bvisor_event_subscribers", "gauge", "Event stream subscribers.")
+	fmt.Fprintf(&b, "bvisor_event_subscribers %d\n", s.EventBus.Subscribers)
+	metric("bvisor_event_queue_depth", "gauge", "Events queued for subscribers.")
+	fmt.Fprintf(&b, "bvisor_event_queue_depth %d\n", s.EventBus.QueueDepth)
+	metric("bvisor_events_dropped_total", "counter", "Events dropped for slow subscribers.")
+	fmt.Fprintf(&b, "bvisor_events_dropped_total %d\n", s.EventBus.Dropped)
+
+	metric("bvisor_scheduler_queue_length", "gauge", "Processes waiting for admission.")
+	ids := make([]string, 0, len(s.SchedulerQueues))
+	for id := range s.SchedulerQueues {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Fprintf(&b, "bvisor_scheduler_queue_length{container=%q} %d\n", id, s.SchedulerQueues[id])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
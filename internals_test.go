@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInstrumentationRecordsContendedWaits(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	k.SetInstrumentation(true)
+
+	c.mu.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < lockSampleRate; j++ {
+				c.QueueDepth()
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Unlock()
+	wg.Wait()
+
+	in := k.Stats().Internals
+	if in == nil {
+		t.Fatal("Internals is nil with instrumentation on")
+	}
+	if in.ContainerLock.Samples == 0 || in.ContainerLock.MaxWait <= 0 {
+		t.Fatalf("container lock = %+v, want non-zero wait samples", in.ContainerLock)
+	}
+	if in.ContainerLock.MaxWait < 5*time.Millisecond {
+		t.Errorf("MaxWait = %v, want at least the time the lock was held", in.ContainerLock.MaxWait)
+	}
+}
+
+func TestInstrumentationOffOmitsInternals(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "web")
+	k.SetInstrumentation(true)
+	k.SetInstrumentation(false)
+
+	s := k.Stats()
+	if s.Internals != nil {
+		t.Fatalf("Internals = %+v, want nil", s.Internals)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"internals"`) {
+		t.Errorf("JSON has an internals section: %s", data)
+	}
+	var b strings.Builder
+	if err := k.WriteInternalMetrics(&b); err != nil || b.Len() != 0 {
+		t.Errorf("WriteInternalMetrics = %q, %v; want nothing", b.String(), err)
+	}
+}
+
+func TestInternalsReportSchedulerQueues(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	c.MaxRunning = 1
+	release := make(chan struct{})
+	defer close(release)
+	c.AddProcess(&Process{Name: "a", Action: blockUntil(release)})
+	c.AddProcess(&Process{Name: "b", Action: blockUntil(release)})
+	c.AddProcess(&Process{Name: "c", Action: blockUntil(release)})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	k.SetInstrumentation(true)
+
+	if got := k.Stats().Internals.SchedulerQueues["web"]; got != 2 {
+		t.Errorf("SchedulerQueues[web] = %d, want 2", got)
+	}
+	var b strings.Builder
+	if err := k.WriteInternalMetrics(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE bvisor_scheduler_queue_length gauge",
+		`bvisor_scheduler_queue_length{container="web"} 2`,
+		`bvisor_lock_acquisitions_total{lock="kernel"}`,
+		"bvisor_events_dropped_total 0",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Jobs ---
+
+// ErrJobFinished is returned by StartProcesses for a job that has already
+// succeeded or failed; jobs are never run again.
+var ErrJobFinished = errors.New("job finished")
+
+// JobState is the overall state of a job.
+type JobState int
+
+const (
+	JobActive JobState = iota
+	JobSucceeded
+	JobFailed
+)
+
+func (s JobState) String() string {
+	switch s {
+	case JobActive:
+		return "Active"
+	case JobSucceeded:
+		return "Succeeded"
+	case JobFailed:
+		return "Failed"
+	}
+	return fmt.Sprintf("JobState(%d)", int(s))
+}
+
+// JobSpec configures a job container.
+type JobSpec struct {
+	// Process defines each attempt; its Kind must be registered.
+	Process ProcessSpec
+	// Completions is how many attempts must complete for the job to
+	// succeed, and Parallelism how many may run at once; zero means 1.
+	Completions int
+	Parallelism int
+	// BackoffLimit is how many failed attempts are retried; one more
+	// failure fails the job.
+	BackoffLimit int
+}
+
+// job tracks a job container's attempts. It is guarded by the container
+// lock.
+type job struct {
+	spec      JobSpec
+	factory   ProcessFactory
+	kind      string
+	version   string
+	state     JobState
+	reason    string // why the job failed
+	attempts  []*Process
+	succeeded int
+	failed    int
+	started   time.Time
+	finished  time.Time
+}
+
+func (j *job) completions() int { return max(j.spec.Completions, 1) }
+func (j *job) parallelism() int { return max(j.spec.Parallelism, 1) }
+
+// JobAttempt is one run of a job's process.
+type JobAttempt struct {
+	Attempt    int // from 1, in the order the attempts were made
+	PID        int
+	State      ProcessState
+	Err        string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Duration   time.Duration
+}
+
+// JobStatus reports a job's progress and attempts.
+type JobStatus struct {
+	ID          string
+	Name        string
+	State       JobState
+	Reason      string
+	Completions int
+	Parallelism int
+	Succeeded   int
+	Failed      int
+	Active      int
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Duration    time.Duration // so far, for an active job
+	Attempts    []JobAttempt
+}
+
+// CreateJob creates a container that runs spec.Process to completion
+// rather than as a service. Starting it runs up to Parallelism attempts at
+// a time until Completions of them have completed, when the job succeeds.
+// Failed or killed attempts are replaced until more than BackoffLimit have
+// failed, when the job fails and its remaining attempts are stopped. A
+// finished job stays Succeeded or Failed: StartProcesses refuses it and
+// StartAll skips it.
+func (k *Kernel) CreateJob(id, name string, memory int, spec JobSpec, opts ...ContainerOption) (*Container, error) {
+	if spec.Completions < 0 || spec.Parallelism < 0 || spec.BackoffLimit < 0 {
+		return nil, fmt.Errorf("%w: job %s has negative completions, parallelism or backoff limit", ErrInvalidContainerSpec, id)
+	}
+	k.mu.Lock()
+	factory, kind, version, err := k.resolveKindLocked(spec.Process.Kind)
+	k.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %w", id, err)
+	}
+	spec.Process.Params = copyStringMap(spec.Process.Params)
+	j := &job{spec: spec, factory: factory, kind: kind, version: version}
+	opts = append(opts, func(c *Container) error {
+		c.job = j
+		return nil
+	})
+	c, err := k.CreateContainer(id, name, memory, opts...)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.jobs = append(k.jobs, c)
+	k.pruneJobsLocked()
+	k.mu.Unlock()
+	return c, nil
+}
+
+// jobFinished reports whether c is a job that has succeeded or failed.
+func (c *Container) jobFinished() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.job != nil && c.job.state != JobActive
+}
+
+// startJobLocked queues the attempts a starting job needs, or refuses a
+// finished one.
+func (c *Container) startJobLocked() error {
+	j := c.job
+	if j == nil {
+		return nil
+	}
+	if j.state != JobActive {
+		return fmt.Errorf("%w: %s %s", ErrJobFinished, c.ID, j.state)
+	}
+	if j.started.IsZero() {
+		j.started = c.now()
+	}
+	c.topUpJobLocked()
+	return nil
+}
+
+// topUpJobLocked queues attempts until Parallelism are live, without
+// exceeding the completions still needed.
+func (c *Container) topUpJobLocked() {
+	j := c.job
+	live := 0
+	for _, p := range j.attempts {
+		switch p.State {
+		case Pending, Throttled, Running:
+			live++
+		}
+	}
+	for ; live < min(j.parallelism(), j.completions()-j.succeeded); live++ {
+		spec := j.spec.Process
+		p := buildProcess(j.factory, j.kind, j.version, spec)
+		j.attempts = append(j.attempts, p)
+		p.jobAttempt = len(j.attempts)
+		c.addProcessLocked(p)
+	}
+}
+
+// jobAttemptDoneLocked counts a finished attempt and replaces it, or
+// finishes the job. Attempts stopped with the container are not counted;
+// starting the container again replaces them.
+func (c *Container) jobAttemptDoneLocked(p *Process, state ProcessState) {
+	j := c.job
+	if j == nil || p.jobAttempt == 0 || j.state != JobActive {
+		return
+	}
+	switch state {
+	case Completed:
+		j.succeeded++
+	case Failed, Killed:
+		j.failed++
+	default:
+		return
+	}
+	switch {
+	case j.succeeded >= j.completions():
+		c.finishJobLocked(JobSucceeded, "")
+	case j.failed > j.spec.BackoffLimit:
+		c.finishJobLocked(JobFailed, fmt.Sprintf("%d attempts failed, backoff limit %d", j.failed, j.spec.BackoffLimit))
+	case c.State != ContainerStopped:
+		// A frozen or quarantined job queues its attempts for later.
+		c.topUpJobLocked()
+		if c.kernel != nil {
+			c.kernel.kick()
+		}
+	}
+}
+
+func (c *Container) finishJobLocked(state JobState, reason string) {
+	j := c.job
+	j.state, j.reason, j.finished = state, reason, c.now()
+	detail := state.String()
+	if reason != "" {
+		detail += ": " + reason
+	}
+	fmt.Printf("[Kernel] Job %s %s after %v\n", c.Name, detail, j.finished.Sub(j.started))
+	c.emit(EventJobFinished, nil, detail)
+	c.stopProcessesLocked()
+}
+
+func (c *Container) jobStatusLocked() JobStatus {
+	j := c.job
+	s := JobStatus{
+		ID:          c.ID,
+		Name:        c.Name,
+		State:       j.state,
+		Reason:      j.reason,
+		Completions: j.completions(),
+		Parallelism: j.parallelism(),
+		Succeeded:   j.succeeded,
+		Failed:      j.failed,
+		StartedAt:   j.started,
+		FinishedAt:  j.finished,
+	}
+	switch {
+	case !j.finished.IsZero():
+		s.Duration = j.finished.Sub(j.started)
+	case !j.started.IsZero():
+		s.Duration = c.now().Sub(j.started)
+	}
+	for _, p := range j.attempts {
+		a := JobAttempt{Attempt: p.jobAttempt, PID: p.PID, State: p.State, StartedAt: p.startedAt, FinishedAt: p.finishedAt}
+		if p.Err != nil {
+			a.Err = p.Err.Error()
+		}
+		switch p.State {
+		case Pending, Throttled:
+		case Running:
+			s.Active++
+			a.Duration = c.now().Sub(p.startedAt)
+		default:
+			if !p.startedAt.IsZero() {
+				a.Duration = p.finishedAt.Sub(p.startedAt)
+			}
+		}
+		s.Attempts = append(s.Attempts, a)
+	}
+	return s
+}
+
+// Jobs lists the kernel's jobs in the order they were created, including
+// finished jobs whose containers have since been removed. Only the
+// JobHistoryLimit most recently finished jobs are kept.
+func (k *Kernel) Jobs() []JobStatus {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pruneJobsLocked()
+	out := make([]JobStatus, 0, len(k.jobs))
+	for _, c := range k.jobs {
+		c.mu.Lock()
+		out = append(out, c.jobStatusLocked())
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// pruneJobsLocked forgets the oldest finished jobs beyond JobHistoryLimit.
+// Their containers, if still present, are left alone.
+func (k *Kernel) pruneJobsLocked() {
+	if k.JobHistoryLimit <= 0 {
+		return
+	}
+	type finished struct {
+		c  *Container
+		at time.Time
+	}
+	var done []finished
+	for _, c := range k.jobs {
+		c.mu.Lock()
+		if c.job.state != JobActive {
+			done = append(done, finished{c, c.job.finished})
+		}
+		c.mu.Unlock()
+	}
+	if len(done) <= k.JobHistoryLimit {
+		return
+	}
+	sort.SliceStable(done, func(i, j int) bool { return done[i].at.Before(done[j].at) })
+	drop := make(map[*Container]bool)
+	for _, f := range done[:len(done)-k.JobHistoryLimit] {
+		drop[f.c] = true
+	}
+	kept := k.jobs[:0]
+	for _, c := range k.jobs {
+		if !drop[c] {
+			kept = append(kept, c)
+		}
+	}
+	clear(k.jobs[len(kept):])
+	k.jobs = kept
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitJob waits for job id to finish and returns its status.
+func waitJob(t *testing.T, k *Kernel, id string) JobStatus {
+	t.Helper()
+	var s JobStatus
+	eventually(t, "job "+id+" to finish", func() bool {
+		for _, s = range k.Jobs() {
+			if s.ID == id {
+				return s.State != JobActive
+			}
+		}
+		return false
+	})
+	return s
+}
+
+func TestJobRunsToCompletions(t *testing.T) {
+	k, clk := newTestKernel(t)
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+	k.RegisterKind("batch", func(ProcessSpec) *Process {
+		return &Process{Action: func(ctx context.Context, h *Handle) error {
+			n := running.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			defer running.Add(-1)
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}}
+	})
+	c, err := k.CreateJob("batch", "batch", 256, JobSpec{Process: ProcessSpec{Kind: "batch"}, Completions: 5, Parallelism: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		eventually(t, "two attempts running", func() bool { return running.Load() == min(2, 5-int32(i)) })
+		clk.Advance(time.Second)
+		release <- struct{}{}
+	}
+	s := waitJob(t, k, "batch")
+	if s.State != JobSucceeded || s.Succeeded != 5 || s.Failed != 0 || len(s.Attempts) != 5 {
+		t.Errorf("status = %+v, want 5 successful attempts", s)
+	}
+	if got := peak.Load(); got != 2 {
+		t.Errorf("up to %d attempts ran at once, want 2", got)
+	}
+	if s.Duration != 5*time.Second {
+		t.Errorf("duration = %v, want 5s", s.Duration)
+	}
+
+	if err := c.StartProcesses(); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("restarting a finished job: %v", err)
+	}
+	if err := k.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	if s := k.Jobs()[0]; len(s.Attempts) != 5 || s.Active != 0 {
+		t.Errorf("StartAll ran the finished job again: %+v", s)
+	}
+}
+
+func TestJobFailsAfterBackoffLimit(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerScriptKinds(k, new(atomic.Int32))
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventJobFinished}})
+	defer cancel()
+	c, err := k.CreateJob("doomed", "doomed", 256, JobSpec{Process: ProcessSpec{Kind: "broken"}, BackoffLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Detail != "Failed: 3 attempts failed, backoff limit 2" {
+		t.Errorf("event = %+v", e)
+	}
+	s := waitJob(t, k, "doomed")
+	if s.State != JobFailed || s.Failed != 3 || len(s.Attempts) != 3 {
+		t.Errorf("status = %+v, want failed after 3 attempts", s)
+	}
+	for i, a := range s.Attempts {
+		if a.Attempt != i+1 || a.State != Failed || a.Err != "boom" {
+			t.Errorf("attempt %d = %+v", i, a)
+		}
+	}
+	if err := c.StartProcesses(); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("restarting a failed job: %v", err)
+	}
+}
+
+func TestJobHistoryKeepsMostRecent(t *testing.T) {
+	k, clk := newTestKernel(t)
+	registerScriptKinds(k, new(atomic.Int32))
+	k.JobHistoryLimit = 2
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("job-%d", i)
+		c, err := k.CreateJob(id, id, 64, JobSpec{Process: ProcessSpec{Kind: "task"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitJob(t, k, id)
+		clk.Advance(time.Minute)
+	}
+	if _, err := k.CreateJob("pending", "pending", 64, JobSpec{Process: ProcessSpec{Kind: "task"}}); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range k.Jobs() {
+		ids = append(ids, s.ID)
+	}
+	if fmt.Sprint(ids) != "[job-1 job-2 pending]" {
+		t.Errorf("jobs = %v, want the two most recently finished and the active one", ids)
+	}
+}
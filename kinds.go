@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --- Versioned Process Kinds ---
+
+// kindVersions holds the registered versions of one process kind. Process
+// specs refer to a kind as "name" or "name@version"; the unversioned form
+// resolves to the default version.
+type kindVersions struct {
+	factories  map[string]ProcessFactory
+	order      []string // registration order
+	def        string
+	hasDef     bool
+	deprecated map[string]string // version -> reason
+}
+
+// KindVersionError is returned when a spec pins a version of a kind that is
+// not registered. It lists the versions that are.
+type KindVersionError struct {
+	Kind      string
+	Version   string
+	Available []string
+}
+
+func (e *KindVersionError) Error() string {
+	return fmt.Sprintf("process kind %s has no version %q (available: %s)", e.Kind, e.Version, strings.Join(e.Available, ", "))
+}
+
+func (e *KindVersionError) Unwrap() error { return ErrUnknownKind }
+
+// RegisterKindVersion makes factory available for building processes of
+// kind pinned to version ("kind@version"). Unless SetDefaultKindVersion says
+// otherwise, the most recently registered version is the default.
+func (k *Kernel) RegisterKindVersion(kind, version string, factory ProcessFactory) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	kv, ok := k.kinds[kind]
+	if !ok {
+		kv = &kindVersions{factories: make(map[string]ProcessFactory), deprecated: make(map[string]string)}
+		k.kinds[kind] = kv
+	}
+	if _, ok := kv.factories[version]; !ok {
+		kv.order = append(kv.order, version)
+	}
+	kv.factories[version] = factory
+}
+
+// SetDefaultKindVersion chooses the version unversioned references to kind
+// resolve to.
+func (k *Kernel) SetDefaultKindVersion(kind, version string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	kv, err := k.kindVersionLocked(kind, version)
+	if err != nil {
+		return err
+	}
+	kv.def, kv.hasDef = version, true
+	return nil
+}
+
+// DeprecateKindVersion marks a version of kind as deprecated. Building a
+// process from it through an unversioned reference emits an
+// EventKindDeprecated.
+func (k *Kernel) DeprecateKindVersion(kind, version, reason string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	kv, err := k.kindVersionLocked(kind, version)
+	if err != nil {
+		return err
+	}
+	kv.deprecated[version] = reason
+	return nil
+}
+
+// KindVersions lists the registered versions of kind in registration order.
+func (k *Kernel) KindVersions(kind string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if kv, ok := k.kinds[kind]; ok {
+		return append([]string(nil), kv.order...)
+	}
+	return nil
+}
+
+func (k *Kernel) kindVersionLocked(kind, version string) (*kindVersions, error) {
+	kv, ok := k.kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, kind)
+	}
+	if _, ok := kv.factories[version]; !ok {
+		return nil, &KindVersionError{Kind: kind, Version: version, Available: kv.available()}
+	}
+	return kv, nil
+}
+
+func (kv *kindVersions) available() []string {
+	out := append([]string(nil), kv.order...)
+	sort.Strings(out)
+	return out
+}
+
+// resolveKindLocked finds the factory for a "kind" or "kind@version"
+// reference.
+func (k *Kernel) resolveKindLocked(ref string) (factory ProcessFactory, kind, version string, err error) {
+	kind, version, pinned := strings.Cut(ref, "@")
+	kv, ok := k.kinds[kind]
+	if !ok {
+		return nil, "", "", fmt.Errorf("%w: %q", ErrUnknownKind, ref)
+	}
+	if !pinned {
+		version = kv.order[len(kv.order)-1]
+		if kv.hasDef {
+			version = kv.def
+		}
+		if reason, ok := kv.deprecated[version]; ok {
+			k.emit(EventKindDeprecated, "", "", fmt.Sprintf("%s resolved to deprecated version %s: %s", kind, version, reason))
+		}
+	}
+	factory, ok = kv.factories[version]
+	if !ok {
+		return nil, "", "", &KindVersionError{Kind: kind, Version: version, Available: kv.available()}
+	}
+	return factory, kind, version, nil
+}
+
+func (k *Kernel) hasKindLocked(ref string) bool {
+	kind, version, pinned := strings.Cut(ref, "@")
+	kv, ok := k.kinds[kind]
+	if !ok {
+		return false
+	}
+	if pinned {
+		_, ok = kv.factories[version]
+	}
+	return ok
+}
+
+// checkKind reports why ref cannot be resolved, without side effects.
+func (k *Kernel) checkKind(ref string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.hasKindLocked(ref) {
+		return nil
+	}
+	kind, version, pinned := strings.Cut(ref, "@")
+	if kv, ok := k.kinds[kind]; ok && pinned {
+		return &KindVersionError{Kind: kind, Version: version, Available: kv.available()}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownKind, ref)
+}
+
+// kindRef is the reference that rebuilds p from the same kind version.
+func (p *Process) kindRef() string {
+	if p.KindVersion == "" {
+		return p.Kind
+	}
+	return p.Kind + "@" + p.KindVersion
+}
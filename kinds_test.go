@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// registerBackup registers backup@v1 and backup@v2, whose processes carry
+// their version number in CPUWeight.
+func registerBackup(k *Kernel) {
+	for i, v := range []string{"v1", "v2"} {
+		weight := float64(i + 1)
+		k.RegisterKindVersion("backup", v, func(ProcessSpec) *Process {
+			return &Process{Action: noop, CPUWeight: weight}
+		})
+	}
+}
+
+func TestKindVersionResolution(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerBackup(k)
+	for _, tc := range []struct {
+		ref, version string
+		weight       float64
+	}{
+		{"backup@v1", "v1", 1},
+		{"backup@v2", "v2", 2},
+		{"backup", "v2", 2}, // the latest registration is the default
+	} {
+		p, err := k.NewProcess(ProcessSpec{Name: "b", Kind: tc.ref})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.ref, err)
+		}
+		if p.Kind != "backup" || p.KindVersion != tc.version || p.CPUWeight != tc.weight {
+			t.Errorf("%s built %s@%s with weight %v", tc.ref, p.Kind, p.KindVersion, p.CPUWeight)
+		}
+	}
+
+	if err := k.SetDefaultKindVersion("backup", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := k.NewProcess(ProcessSpec{Name: "b", Kind: "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestContainer(t, k, "jobs")
+	c.AddProcess(p)
+	if d := c.Inspect().Processes[0]; d.Kind != "backup" || d.KindVersion != "v1" {
+		t.Errorf("Inspect shows %s@%s, want backup@v1", d.Kind, d.KindVersion)
+	}
+}
+
+func TestMissingKindVersionListsAvailable(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerBackup(k)
+	_, err := k.NewProcess(ProcessSpec{Name: "b", Kind: "backup@v3"})
+	var kve *KindVersionError
+	if !errors.As(err, &kve) || !errors.Is(err, ErrUnknownKind) {
+		t.Fatalf("err = %v, want a KindVersionError", err)
+	}
+	if want := `process kind backup has no version "v3" (available: v1, v2)`; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+	if err := k.SetDefaultKindVersion("backup", "v3"); !errors.As(err, &kve) {
+		t.Errorf("default to a missing version: %v", err)
+	}
+}
+
+func TestDeprecatedDefaultEmitsEvent(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerBackup(k)
+	if err := k.SetDefaultKindVersion("backup", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.DeprecateKindVersion("backup", "v1", "use v2"); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventKindDeprecated}})
+	defer cancel()
+
+	// Pinning the deprecated version is deliberate and stays quiet.
+	if _, err := k.NewProcess(ProcessSpec{Name: "b", Kind: "backup@v1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.NewProcess(ProcessSpec{Name: "b", Kind: "backup"}); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Detail != "backup resolved to deprecated version v1: use v2" {
+		t.Errorf("event = %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// --- Spec Linting ---
+
+// LintSeverity grades a lint finding.
+type LintSeverity int
+
+const (
+	// LintWarning marks a spec that applies but is probably not what was
+	// meant.
+	LintWarning LintSeverity = iota
+	// LintError marks a spec that Apply or ApplyDir would reject.
+	LintError
+)
+
+func (s LintSeverity) String() string {
+	switch s {
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	}
+	return fmt.Sprintf("LintSeverity(%d)", int(s))
+}
+
+// Lint rule IDs, usable with WithoutRules.
+const (
+	RuleMissingID           = "missing-id"
+	RuleMissingName         = "missing-name"
+	RuleNoMemoryLimit       = "no-memory-limit"
+	RuleDuplicateID         = "duplicate-id"
+	RuleMissingKind         = "missing-kind"
+	RuleDuplicateProcess    = "duplicate-process"
+	RulePriorityRange       = "priority-range"
+	RuleUndefinedDependency = "undefined-dependency"
+	RuleSelfDependency      = "self-dependency"
+	RuleNoProcesses         = "no-processes"
+)
+
+// Recommended process priority range; the scheduler accepts any int.
+const (
+	minRecommendedPriority = 0
+	maxRecommendedPriority = 9
+)
+
+// LintIssue is one finding. Path locates the offending field using the
+// JSON field names, indexed by the spec's position in the linted set, e.g.
+// "[0].processes[1].priority".
+type LintIssue struct {
+	Rule     string
+	Severity LintSeverity
+	Path     string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", i.Path, i.Severity, i.Message, i.Rule)
+}
+
+// LintIssues is the result of Lint.
+type LintIssues []LintIssue
+
+// Failed reports whether the issues should fail a lint run: any error
+// does, and in strict mode any warning too.
+func (l LintIssues) Failed(strict bool) bool {
+	for _, i := range l {
+		if i.Severity == LintError || strict {
+			return true
+		}
+	}
+	return false
+}
+
+type lintOptions struct {
+	disabled map[string]bool
+}
+
+// LintOption configures Lint.
+type LintOption func(*lintOptions)
+
+// WithoutRules suppresses the given rule IDs.
+func WithoutRules(rules ...string) LintOption {
+	return func(o *lintOptions) {
+		for _, r := range rules {
+			o.disabled[r] = true
+		}
+	}
+}
+
+// Lint checks a set of specs, such as the contents of one spec file, for
+// mistakes and questionable settings. Unlike ApplyDir it does not need a
+// kernel, so it cannot check that process kinds are registered, and it
+// treats dependencies outside the set as suspect. Issues are reported in
+// spec order.
+func Lint(specs []Spec, opts ...LintOption) LintIssues {
+	o := lintOptions{disabled: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var issues LintIssues
+	report := func(rule string, sev LintSeverity, path, format string, args ...any) {
+		if !o.disabled[rule] {
+			issues = append(issues, LintIssue{Rule: rule, Severity: sev, Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+
+	defined := make(map[string]int, len(specs))
+	groups := make(map[string]bool)
+	for i, s := range specs {
+		if s.Group != "" {
+			groups[s.Group] = true
+		}
+		if s.ID == "" {
+			continue
+		}
+		if _, dup := defined[s.ID]; !dup {
+			defined[s.ID] = i
+		}
+	}
+
+	for i, s := range specs {
+		at := func(field string) string { return fmt.Sprintf("[%d].%s", i, field) }
+		if s.ID == "" {
+			report(RuleMissingID, LintError, at("id"), "container has no id")
+		} else if first := defined[s.ID]; first != i {
+			report(RuleDuplicateID, LintError, at("id"), "container %s is already defined at [%d]", s.ID, first)
+		}
+		if s.Name == "" {
+			report(RuleMissingName, LintError, at("name"), "container has no name")
+		}
+		if s.MemoryMB <= 0 {
+			report(RuleNoMemoryLimit, LintError, at("memory_mb"), "container has no memory limit")
+		}
+		if len(s.Processes) == 0 {
+			report(RuleNoProcesses, LintWarning, at("processes"), "container defines no processes")
+		}
+
+		seen := make(map[string]int, len(s.Processes))
+		for j, ps := range s.Processes {
+			pat := func(field string) string { return at(fmt.Sprintf("processes[%d].%s", j, field)) }
+			if ps.Kind == "" {
+				report(RuleMissingKind, LintError, pat("kind"), "process %s has no kind", ps.Name)
+			}
+			if first, dup := seen[ps.Name]; dup {
+				report(RuleDuplicateProcess, LintWarning, pat("name"), "process %s repeats processes[%d] and will not be added", ps.Name, first)
+			} else {
+				seen[ps.Name] = j
+			}
+			if ps.Priority < minRecommendedPriority || ps.Priority > maxRecommendedPriority {
+				report(RulePriorityRange, LintWarning, pat("priority"), "process priority %d is outside the recommended range %d-%d",
+					ps.Priority, minRecommendedPriority, maxRecommendedPriority)
+			}
+		}
+
+		for j, dep := range s.DependsOn {
+			path := at(fmt.Sprintf("depends_on[%d]", j))
+			if g, ok := strings.CutPrefix(dep, "group:"); ok {
+				if !groups[g] {
+					report(RuleUndefinedDependency, LintWarning, path, "dependency on group %q, which no container in this spec joins", g)
+				}
+				continue
+			}
+			if dep == s.ID {
+				report(RuleSelfDependency, LintError, path, "container depends on itself")
+				continue
+			}
+			if _, ok := defined[dep]; !ok {
+				report(RuleUndefinedDependency, LintWarning, path, "dependency on container %s, which is not defined in this spec", dep)
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const lintSpec = `[
+  {"id": "web", "name": "web", "memory_mb": 256, "depends_on": ["web", "auth"],
+   "processes": [
+     {"name": "serve", "kind": "worker", "priority": 2},
+     {"name": "tick", "priority": 15}
+   ]},
+  {"id": "db", "name": "db"}
+]`
+
+func lintFixture(t *testing.T, opts ...LintOption) LintIssues {
+	t.Helper()
+	specs, err := LoadSpec(strings.NewReader(lintSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Lint(specs, opts...)
+}
+
+func TestLintReportsRulesWithPaths(t *testing.T) {
+	issues := lintFixture(t)
+	want := []LintIssue{
+		{RuleMissingKind, LintError, "[0].processes[1].kind", "process tick has no kind"},
+		{RulePriorityRange, LintWarning, "[0].processes[1].priority", "process priority 15 is outside the recommended range 0-9"},
+		{RuleSelfDependency, LintError, "[0].depends_on[0]", "container depends on itself"},
+		{RuleUndefinedDependency, LintWarning, "[0].depends_on[1]", "dependency on container auth, which is not defined in this spec"},
+		{RuleNoMemoryLimit, LintError, "[1].memory_mb", "container has no memory limit"},
+		{RuleNoProcesses, LintWarning, "[1].processes", "container defines no processes"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues:\n%v", issues)
+	}
+	for i := range want {
+		if issues[i] != want[i] {
+			t.Errorf("issue %d = %v\nwant %v", i, issues[i], want[i])
+		}
+	}
+	if !issues.Failed(false) {
+		t.Error("errors did not fail the run")
+	}
+	if got := issues[0].String(); got != "[0].processes[1].kind: error: process tick has no kind [missing-kind]" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestLintSuppressesRules(t *testing.T) {
+	issues := lintFixture(t, WithoutRules(RuleMissingKind, RuleSelfDependency, RuleNoMemoryLimit))
+	for _, i := range issues {
+		if i.Severity == LintError {
+			t.Errorf("suppressed rule reported: %v", i)
+		}
+	}
+	if len(issues) != 3 {
+		t.Errorf("%d warnings left, want 3", len(issues))
+	}
+	if issues.Failed(false) || !issues.Failed(true) {
+		t.Error("warnings should fail only in strict mode")
+	}
+}
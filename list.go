@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// --- Container Listing ---
+
+// ErrUnknownField is returned when ListOptions.Fields names a field that
+// containers do not have.
+var ErrUnknownField = errors.New("unknown container field")
+
+// DefaultListLimit is the page size used when ListOptions.Limit is zero.
+const DefaultListLimit = 100
+
+// ListOptions filters, projects and pages Kernel.ListContainers.
+type ListOptions struct {
+	// Labels selects containers carrying every given label value.
+	Labels map[string]string
+	// States selects containers in any of the given states; empty means all.
+	States []ContainerState
+	// Fields limits each item to the named fields; empty means all of
+	// ContainerFields.
+	Fields []string
+	// Limit is the page size; zero means DefaultListLimit.
+	Limit int
+	// Cursor continues a previous listing from its NextCursor.
+	Cursor string
+}
+
+// ContainerFields are the field names ListContainers can project.
+var ContainerFields = []string{"id", "name", "state", "memory_mb", "memory_usage_mb", "labels", "processes"}
+
+// ContainerPage is one page of a container listing. Items are ordered by
+// container ID. NextCursor is empty on the last page.
+type ContainerPage struct {
+	Items      []map[string]any `json:"items"`
+	Total      int              `json:"total"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// ListContainers returns the containers matching opts, one page at a time.
+// Total counts every match, not just the page.
+func (k *Kernel) ListContainers(opts ListOptions) (ContainerPage, error) {
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = ContainerFields
+	}
+	for _, f := range fields {
+		if !containsString(ContainerFields, f) {
+			return ContainerPage{}, fmt.Errorf("%w: %s", ErrUnknownField, f)
+		}
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var matched []*Container
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		ok := c.matchesLocked(opts)
+		c.mu.Unlock()
+		if ok {
+			matched = append(matched, c)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	page := ContainerPage{Total: len(matched), Items: []map[string]any{}}
+	start := sort.Search(len(matched), func(i int) bool { return matched[i].ID > opts.Cursor })
+	end := min(start+limit, len(matched))
+	for _, c := range matched[start:end] {
+		c.mu.Lock()
+		page.Items = append(page.Items, c.projectLocked(fields))
+		c.mu.Unlock()
+	}
+	if end < len(matched) {
+		page.NextCursor = matched[end-1].ID
+	}
+	return page, nil
+}
+
+func (c *Container) matchesLocked(opts ListOptions) bool {
+	for k, v := range opts.Labels {
+		if got, ok := c.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	if len(opts.States) == 0 {
+		return true
+	}
+	for _, s := range opts.States {
+		if c.State == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Container) projectLocked(fields []string) map[string]any {
+	item := make(map[string]any, len(fields))
+	for _, f := range fields {
+		switch f {
+		case "id":
+			item[f] = c.ID
+		case "name":
+			item[f] = c.Name
+		case "state":
+			item[f] = c.State.String()
+		case "memory_mb":
+			item[f] = c.MemoryMB
+		case "memory_usage_mb":
+			item[f] = c.memoryUsageLocked()
+		case "labels":
+			item[f] = copyStringMap(c.Labels)
+		case "processes":
+			item[f] = len(c.Processes)
+		}
+	}
+	return item
+}
+
+// --- Process Listing ---
+
+// ListProcesses returns up to limit of the container's processes, in the
+// order they were added, skipping the first offset. With a non-nil state
+// only processes in that state are listed, and offset counts those. A
+// limit of zero or less means DefaultListLimit.
+func (c *Container) ListProcesses(offset, limit int, state *ProcessState) []ProcessDetail {
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	out := []ProcessDetail{}
+	for _, p := range c.Processes {
+		if len(out) == limit {
+			break
+		}
+		if state != nil && p.State != *state {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		out = append(out, p.detail(now))
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// manyContainers creates n containers c000, c001, ...; every third carries
+// tier=web and every even one is started.
+func manyContainers(t *testing.T, k *Kernel, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		c := newTestContainer(t, k, fmt.Sprintf("c%03d", i))
+		if i%3 == 0 {
+			c.SetLabels(map[string]string{"tier": "web"})
+		}
+		if i%2 == 0 {
+			if err := c.StartProcesses(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// listAll follows NextCursor until the last page and returns the IDs seen.
+func listAll(t *testing.T, k *Kernel, opts ListOptions) (ids []string, pages int) {
+	t.Helper()
+	for {
+		page, err := k.ListContainers(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, item := range page.Items {
+			ids = append(ids, item["id"].(string))
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func TestListContainersPages(t *testing.T) {
+	k, _ := newTestKernel(t)
+	manyContainers(t, k, 250)
+
+	first, err := k.ListContainers(ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 250 || len(first.Items) != DefaultListLimit || first.NextCursor != "c099" {
+		t.Fatalf("first page: total %d, %d items, cursor %q", first.Total, len(first.Items), first.NextCursor)
+	}
+	ids, pages := listAll(t, k, ListOptions{})
+	if pages != 3 || len(ids) != 250 {
+		t.Fatalf("%d pages with %d items, want 3 with 250", pages, len(ids))
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("c%03d", i); id != want {
+			t.Fatalf("item %d is %s, want %s: pages overlap or skip", i, id, want)
+		}
+	}
+}
+
+func TestListContainersProjectsFields(t *testing.T) {
+	k, _ := newTestKernel(t)
+	manyContainers(t, k, 1)
+	page, err := k.ListContainers(ListOptions{Fields: []string{"id", "name", "state"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := page.Items[0]
+	if len(item) != 3 || item["id"] != "c000" || item["name"] != "c000" || item["state"] != ContainerRunning.String() {
+		t.Errorf("item = %v", item)
+	}
+	if _, err := k.ListContainers(ListOptions{Fields: []string{"id", "owner"}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("unknown field: %v", err)
+	}
+}
+
+func TestListContainersFiltersAndPages(t *testing.T) {
+	k, _ := newTestKernel(t)
+	manyContainers(t, k, 250)
+	opts := ListOptions{
+		Labels: map[string]string{"tier": "web"},
+		States: []ContainerState{ContainerRunning},
+		Fields: []string{"id"},
+		Limit:  10,
+	}
+	page, err := k.ListContainers(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Multiples of six below 250.
+	if page.Total != 42 {
+		t.Errorf("total = %d, want 42", page.Total)
+	}
+	ids, pages := listAll(t, k, opts)
+	if pages != 5 || len(ids) != 42 {
+		t.Fatalf("%d pages with %d items, want 5 with 42", pages, len(ids))
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("c%03d", 6*i); id != want {
+			t.Errorf("item %d is %s, want %s", i, id, want)
+		}
+	}
+}
+
+func TestListProcessesPagesAndFilters(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "batch")
+	// p000, p003, ... finish at once; the rest keep running.
+	var quick []*Process
+	for i := 0; i < 250; i++ {
+		p := &Process{Name: fmt.Sprintf("p%03d", i), Action: blockUntil(nil)}
+		if i%3 == 0 {
+			p.Action = noop
+			quick = append(quick, p)
+		}
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	for _, p := range quick {
+		waitDone(t, p)
+	}
+	names := func(procs []ProcessDetail) string {
+		var out []string
+		for _, p := range procs {
+			out = append(out, p.Name)
+		}
+		return fmt.Sprint(out)
+	}
+	completed, running, pending := Completed, Running, Pending
+
+	if got := c.ListProcesses(0, 0, nil); len(got) != DefaultListLimit || got[0].Name != "p000" {
+		t.Errorf("default page: %d processes from %s", len(got), got[0].Name)
+	}
+	for _, tc := range []struct {
+		offset, limit int
+		state         *ProcessState
+		want          string
+	}{
+		{100, 3, nil, "[p100 p101 p102]"},
+		{245, 10, nil, "[p245 p246 p247 p248 p249]"},
+		{250, 10, nil, "[]"},
+		{0, 3, &running, "[p001 p002 p004]"},
+		{80, 10, &completed, "[p240 p243 p246 p249]"},
+		{0, 1, &pending, "[]"}, // nothing is left queued
+	} {
+		if got := names(c.ListProcesses(tc.offset, tc.limit, tc.state)); got != tc.want {
+			t.Errorf("ListProcesses(%d, %d, %v) = %s, want %s", tc.offset, tc.limit, tc.state, got, tc.want)
+		}
+	}
+	if n := len(c.ListProcesses(0, 1000, &completed)); n != len(quick) {
+		t.Errorf("%d completed processes listed, want %d", n, len(quick))
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Load Generator ---
+
+// LoadGenConfig configures a load generator container.
+type LoadGenConfig struct {
+	// Target is the container ID or "svc:<name>" service that receives
+	// the requests. It must answer them with Handle.Reply.
+	Target string
+	// Rate is the number of requests started per second.
+	Rate float64
+	// PayloadMin and PayloadMax bound the payload size in bytes; sizes are
+	// drawn uniformly from the range.
+	PayloadMin int
+	PayloadMax int
+	// Concurrency caps the requests in flight; a request that is due while
+	// the cap is reached is skipped. Zero means 1.
+	Concurrency int
+	// Duration stops the generator after that long on the kernel clock;
+	// zero runs until the container is stopped.
+	Duration time.Duration
+}
+
+// Load generator metric names, set on the generator container.
+const (
+	MetricLoadRequests  = "loadgen.requests"
+	MetricLoadErrors    = "loadgen.errors"
+	MetricLoadSkipped   = "loadgen.skipped"
+	MetricLoadLatency50 = "loadgen.latency_p50_ms"
+	MetricLoadLatency90 = "loadgen.latency_p90_ms"
+	MetricLoadLatency99 = "loadgen.latency_p99_ms"
+	MetricLoadLatencyMx = "loadgen.latency_max_ms"
+)
+
+// loadGenLatencySamples bounds the latencies kept for percentiles.
+const loadGenLatencySamples = 10000
+
+var ErrInvalidLoadGen = errors.New("invalid load generator config")
+
+func (cfg LoadGenConfig) validate() error {
+	switch {
+	case cfg.Target == "":
+		return fmt.Errorf("%w: target is required", ErrInvalidLoadGen)
+	case cfg.Rate <= 0:
+		return fmt.Errorf("%w: rate must be positive", ErrInvalidLoadGen)
+	case cfg.PayloadMin < 0 || cfg.PayloadMax < cfg.PayloadMin:
+		return fmt.Errorf("%w: payload range %d-%d", ErrInvalidLoadGen, cfg.PayloadMin, cfg.PayloadMax)
+	case cfg.Concurrency < 0 || cfg.Duration < 0:
+		return fmt.Errorf("%w: concurrency and duration must not be negative", ErrInvalidLoadGen)
+	}
+	return nil
+}
+
+// withParams returns cfg updated from Reload signal params: target, rate,
+// payload_min, payload_max, concurrency and duration.
+func (cfg LoadGenConfig) withParams(params map[string]string) (LoadGenConfig, error) {
+	for key, v := range params {
+		var err error
+		switch key {
+		case "target":
+			cfg.Target = v
+		case "rate":
+			cfg.Rate, err = strconv.ParseFloat(v, 64)
+		case "payload_min":
+			cfg.PayloadMin, err = strconv.Atoi(v)
+		case "payload_max":
+			cfg.PayloadMax, err = strconv.Atoi(v)
+		case "concurrency":
+			cfg.Concurrency, err = strconv.Atoi(v)
+		case "duration":
+			cfg.Duration, err = time.ParseDuration(v)
+		default:
+			err = fmt.Errorf("unknown parameter")
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("%w: %s=%q: %v", ErrInvalidLoadGen, key, v, err)
+		}
+	}
+	return cfg, cfg.validate()
+}
+
+// CreateLoadGenerator creates a container whose single process sends
+// requests to cfg.Target through the normal Request path and records
+// client-side latency percentiles as container metrics. It starts like any
+// container; stopping it part way leaves the metrics of the partial run.
+// A Reload signal with params (see LoadGenConfig) adjusts the running
+// generator; invalid params are logged to its stderr and ignored.
+func (k *Kernel) CreateLoadGenerator(id, name string, cfg LoadGenConfig) (*Container, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	c, err := k.CreateContainer(id, name, 64)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	seed := k.Rand.Int63()
+	k.mu.Unlock()
+	c.AddProcess(&Process{Name: "loadgen", Action: loadGenAction(cfg, rand.New(rand.NewSource(seed)))})
+	return c, nil
+}
+
+// loadStats accumulates results from concurrent requests.
+type loadStats struct {
+	mu        sync.Mutex
+	requests  int
+	errors    int
+	skipped   int
+	latencies []time.Duration
+}
+
+func (s *loadStats) record(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.latencies = append(s.latencies, d)
+	if len(s.latencies) > loadGenLatencySamples {
+		s.latencies = s.latencies[len(s.latencies)-loadGenLatencySamples:]
+	}
+}
+
+// publish writes the current results to the container's metrics.
+func (s *loadStats) publish(h *Handle) {
+	s.mu.Lock()
+	sorted := slices.Clone(s.latencies)
+	requests, errs, skipped := s.requests, s.errors, s.skipped
+	s.mu.Unlock()
+	slices.Sort(sorted)
+	h.SetMetric(MetricLoadRequests, float64(requests))
+	h.SetMetric(MetricLoadErrors, float64(errs))
+	h.SetMetric(MetricLoadSkipped, float64(skipped))
+	if len(sorted) == 0 {
+		return
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	pct := func(q float64) time.Duration {
+		return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+	}
+	h.SetMetric(MetricLoadLatency50, ms(pct(0.50)))
+	h.SetMetric(MetricLoadLatency90, ms(pct(0.90)))
+	h.SetMetric(MetricLoadLatency99, ms(pct(0.99)))
+	h.SetMetric(MetricLoadLatencyMx, ms(sorted[len(sorted)-1]))
+}
+
+func loadGenAction(cfg LoadGenConfig, rng *rand.Rand) ActionFunc {
+	return func(ctx context.Context, h *Handle) error {
+		clock := h.container.clock()
+		var stats loadStats
+		var wg sync.WaitGroup
+		inflight := make(chan struct{}, max(cfg.Concurrency, 1))
+
+		// Requests are issued open-loop: on every wake-up the generator
+		// starts whatever the rate says is due since the last change of
+		// rate, so coarse clock steps still produce the right count.
+		start, issued := clock.Now(), 0
+		var end <-chan time.Time
+		if cfg.Duration > 0 {
+			end = clock.After(cfg.Duration)
+		}
+		defer func() {
+			wg.Wait()
+			stats.publish(h)
+		}()
+		for {
+			due := int(clock.Now().Sub(start).Seconds()*cfg.Rate) - issued
+			for ; due > 0; due-- {
+				issued++
+				select {
+				case inflight <- struct{}{}:
+				default:
+					stats.mu.Lock()
+					stats.skipped++
+					stats.mu.Unlock()
+					continue
+				}
+				size := cfg.PayloadMin + rng.Intn(cfg.PayloadMax-cfg.PayloadMin+1)
+				payload := strings.Repeat("x", size)
+				wg.Add(1)
+				go func(target string, slots chan struct{}) {
+					defer wg.Done()
+					defer func() { <-slots }()
+					sent := clock.Now()
+					_, err := h.Request(target, payload)
+					if ctx.Err() != nil {
+						return // interrupted by stop, not a failure
+					}
+					stats.record(clock.Now().Sub(sent), err)
+				}(cfg.Target, inflight)
+			}
+			stats.publish(h)
+
+			tick := time.Duration(float64(time.Second) / cfg.Rate)
+			select {
+			case <-h.after(tick):
+			case <-end:
+				return nil
+			case <-ctx.Done():
+				return nil
+			case s := <-h.Signals():
+				if s.Kind != SignalReload {
+					continue
+				}
+				next, err := cfg.withParams(s.Params)
+				if err != nil {
+					fmt.Fprintf(h.Err(), "reload: %v\n", err)
+					continue
+				}
+				if next.Rate != cfg.Rate {
+					start, issued = clock.Now(), 0
+				}
+				if next.Duration != cfg.Duration {
+					// A new duration counts from the reload.
+					end = nil
+					if next.Duration > 0 {
+						end = clock.After(next.Duration)
+					}
+				}
+				if next.Concurrency != cfg.Concurrency {
+					// In-flight requests keep their slot in the old
+					// channel; the new cap applies to new requests.
+					inflight = make(chan struct{}, max(next.Concurrency, 1))
+				}
+				cfg = next
+			}
+		}
+	}
+}
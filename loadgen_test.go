@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadGeneratorAgainstEcho(t *testing.T) {
+	k, clk := newTestKernel(t)
+	echo := newTestContainer(t, k, "echo")
+	echo.AddProcess(&Process{Name: "echo", Action: func(ctx context.Context, h *Handle) error {
+		for {
+			req, err := h.Recv()
+			if err != nil {
+				return nil
+			}
+			if err := h.Reply(req, req.Payload); err != nil {
+				return err
+			}
+		}
+	}})
+	if err := echo.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer echo.StopProcesses()
+
+	gen, err := k.CreateLoadGenerator("gen", "gen", LoadGenConfig{
+		Target: "echo", Rate: 10, PayloadMin: 1, PayloadMax: 8, Concurrency: 4, Duration: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gen.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	p := gen.Processes[0]
+	for i := 0; i < 10; i++ {
+		waitForWaiters(t, clk, 2) // the next tick and the end of the run
+		clk.Advance(100 * time.Millisecond)
+	}
+	waitDone(t, p)
+
+	m := gen.Metrics()
+	// The last tick and the end of the run fire together, so the final
+	// request may or may not be issued.
+	if n := m[MetricLoadRequests] + m[MetricLoadSkipped]; n < 9 || n > 10 {
+		t.Errorf("%v requests issued at 10/s for 1s, want 9 or 10", n)
+	}
+	if m[MetricLoadErrors] != 0 {
+		t.Errorf("%v errors against a healthy echo", m[MetricLoadErrors])
+	}
+	for _, name := range []string{MetricLoadLatency50, MetricLoadLatency90, MetricLoadLatency99, MetricLoadLatencyMx} {
+		if _, ok := m[name]; !ok {
+			t.Errorf("metric %s not published", name)
+		}
+	}
+	if m[MetricLoadLatency50] > m[MetricLoadLatencyMx] {
+		t.Errorf("p50 %vms above max %vms", m[MetricLoadLatency50], m[MetricLoadLatencyMx])
+	}
+}
+
+func TestLoadGeneratorRejectsInvalidConfig(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, cfg := range []LoadGenConfig{
+		{Rate: 1},
+		{Target: "echo"},
+		{Target: "echo", Rate: 1, PayloadMin: 5, PayloadMax: 2},
+		{Target: "echo", Rate: 1, Concurrency: -1},
+	} {
+		if _, err := k.CreateLoadGenerator("gen", "gen", cfg); !errors.Is(err, ErrInvalidLoadGen) {
+			t.Errorf("%+v: err = %v, want ErrInvalidLoadGen", cfg, err)
+		}
+	}
+	if _, ok := k.Containers["gen"]; ok {
+		t.Error("an invalid generator left a container behind")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+)
+
+// --- Process Logs ---
+
+// LogStream identifies which of a process's output streams a line came from.
+type LogStream string
+
+const (
+	StreamStdout LogStream = "stdout"
+	StreamStderr LogStream = "stderr"
+)
+
+// processLogLines bounds the captured lines per process, shared by both
+// streams; the oldest lines are dropped first.
+const processLogLines = 1000
+
+// LogLine is one captured line of process output.
+type LogLine struct {
+	Seq     uint64 // container-wide write order
+	Time    time.Time
+	PID     int
+	Process string
+	Stream  LogStream
+	Text    string
+	TraceID uint64 // trace of the message being handled, if any
+}
+
+type processLog struct {
+	lines   []LogLine
+	partial map[LogStream][]byte
+}
+
+// Out returns a writer for the process's standard output. Output is
+// captured line by line; see Container.Logs.
+func (h *Handle) Out() io.Writer {
+	return &logWriter{h: h, stream: StreamStdout}
+}
+
+// Err returns a writer for the process's standard error.
+func (h *Handle) Err() io.Writer {
+	return &logWriter{h: h, stream: StreamStderr}
+}
+
+type logWriter struct {
+	h      *Handle
+	stream LogStream
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	c, p := w.h.container, w.h.proc
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p.logs.partial == nil {
+		p.logs.partial = make(map[LogStream][]byte)
+	}
+	buf := append(p.logs.partial[w.stream], b...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+		c.appendLogLocked(p, w.stream, string(buf[:i]), w.h.traceID)
+		buf = buf[i+1:]
+	}
+	p.logs.partial[w.stream] = append([]byte(nil), buf...)
+	return len(b), nil
+}
+
+func (c *Container) appendLogLocked(p *Process, stream LogStream, text string, traceID uint64) {
+	c.logSeq++
+	p.logs.lines = append(p.logs.lines, LogLine{
+		Seq:     c.logSeq,
+		Time:    c.LocalNow(),
+		PID:     p.PID,
+		Process: p.Name,
+		Stream:  stream,
+		Text:    text,
+		TraceID: traceID,
+	})
+	if len(p.logs.lines) > processLogLines {
+		p.logs.lines = p.logs.lines[len(p.logs.lines)-processLogLines:]
+	}
+}
+
+// flushLogsLocked captures unterminated output once the action returns.
+func (c *Container) flushLogsLocked(p *Process, traceID uint64) {
+	for _, stream := range []LogStream{StreamStdout, StreamStderr} {
+		if rest := p.logs.partial[stream]; len(rest) > 0 {
+			c.appendLogLocked(p, stream, string(rest), traceID)
+		}
+	}
+	p.logs.partial = nil
+}
+
+// LogOptions filters Container.Logs. Zero values match everything.
+type LogOptions struct {
+	Stream LogStream
+	PID    int
+	Tail   int // only the last Tail matching lines
+}
+
+// Logs returns the captured output of the container's processes in write
+// order.
+func (c *Container) Logs(opts LogOptions) []LogLine {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []LogLine
+	for _, p := range c.Processes {
+		if opts.PID != 0 && p.PID != opts.PID {
+			continue
+		}
+		for _, l := range p.logs.lines {
+			if opts.Stream == "" || l.Stream == opts.Stream {
+				out = append(out, l)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	if opts.Tail > 0 && len(out) > opts.Tail {
+		out = out[len(out)-opts.Tail:]
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// logTexts renders lines as "stream:text" in order.
+func logTexts(lines []LogLine) string {
+	var out []string
+	for _, l := range lines {
+		out = append(out, fmt.Sprintf("%s:%s", l.Stream, l.Text))
+	}
+	return fmt.Sprint(out)
+}
+
+func TestLogStreamsKeepOrderAndFilter(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "app")
+	p := &Process{Name: "chatty", Action: func(ctx context.Context, h *Handle) error {
+		fmt.Fprintln(h.Out(), "starting")
+		fmt.Fprint(h.Err(), "warn: disk ")
+		fmt.Fprintln(h.Out(), "working")
+		fmt.Fprintln(h.Err(), "low")
+		fmt.Fprint(h.Out(), "done") // flushed when the action returns
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+
+	for _, tc := range []struct {
+		stream LogStream
+		want   string
+	}{
+		{"", "[stdout:starting stdout:working stderr:warn: disk low stdout:done]"},
+		{StreamStdout, "[stdout:starting stdout:working stdout:done]"},
+		{StreamStderr, "[stderr:warn: disk low]"},
+	} {
+		if got := logTexts(c.Logs(LogOptions{Stream: tc.stream})); got != tc.want {
+			t.Errorf("stream %q: %s, want %s", tc.stream, got, tc.want)
+		}
+	}
+	if got := logTexts(c.Logs(LogOptions{Tail: 1})); got != "[stdout:done]" {
+		t.Errorf("tail: %s", got)
+	}
+}
+
+func TestLogBudgetSharedByStreams(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "app")
+	p := &Process{Name: "flood", Action: func(ctx context.Context, h *Handle) error {
+		for i := 0; i < processLogLines; i++ {
+			fmt.Fprintln(h.Out(), i)
+			fmt.Fprintln(h.Err(), i)
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	lines := c.Logs(LogOptions{})
+	if len(lines) != processLogLines {
+		t.Fatalf("%d lines kept, want the shared budget of %d", len(lines), processLogLines)
+	}
+	if first := lines[0]; first.Stream != StreamStdout || first.Text != fmt.Sprint(processLogLines/2) {
+		t.Errorf("oldest kept line = %+v", first)
+	}
+}
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,13 +23,176 @@ const (
 	Running ProcessState = iota
 	Stopped
 	Completed
+	Throttled
+	Failed
+	Killed
+	Pending // waiting to be admitted by the scheduler
 )
 
+func (s ProcessState) String() string {
+	switch s {
+	case Running:
+		return "Running"
+	case Stopped:
+		return "Stopped"
+	case Completed:
+		return "Completed"
+	case Throttled:
+		return "Throttled"
+	case Failed:
+		return "Failed"
+	case Killed:
+		return "Killed"
+	case Pending:
+		return "Pending"
+	}
+	return fmt.Sprintf("ProcessState(%d)", int(s))
+}
+
 type Process struct {
+	PID      int // assigned by the kernel when the process is added
 	Name     string
 	Priority int
-	Action   func()
+	Action   ActionFunc
 	State    ProcessState
+	Err      error // set when the Action returns an error
+
+	// Nice lowers the process's scheduling priority by its value without
+	// changing Priority, like Unix nice: the scheduler ranks the process
+	// at Priority - Nice. It is clamped to MinNice..MaxNice; negative
+	// values raise the process instead.
+	Nice int
+
+	// MemoryMB is the memory the process uses when it starts. Its Handle can
+	// grow or shrink the simulated usage while it runs. The process stays
+	// Pending while MemoryMB does not fit under its container's limit.
+	MemoryMB int
+
+	// HandleBudget caps the handles the process may hold open through
+	// Handle.Open at once; zero means unlimited.
+	HandleBudget int
+
+	// CPUWeight is the share of a CPU the process expects, e.g. 0.5. It
+	// is only used by Container.Validate.
+	CPUWeight float64
+
+	// CPUCredits, when set, makes the process burstable: it is only started
+	// while it has credits left. Nil means unlimited CPU.
+	CPUCredits *CPUCredits
+
+	// Warmup and Cooldown shape the CPU the process is reported to use
+	// (see ProcessDetail.CPU): it ramps up from zero to CPUWeight over
+	// Warmup after starting, and back down over Cooldown after it stops.
+	// Admission always reserves the full CPUWeight.
+	Warmup   time.Duration
+	Cooldown time.Duration
+
+	// Kind names the factory registered with Kernel.RegisterKind that built
+	// this process, and Params the arguments it was built with. Only
+	// processes with a Kind can be exported.
+	Kind   string
+	Params map[string]string
+	// KindVersion is the version of Kind the process was built from; empty
+	// for the unversioned variant.
+	KindVersion string
+
+	// ConcurrencyGroup names a kernel-wide group whose members share a
+	// running-process limit (see Kernel.SetGroupLimit).
+	ConcurrencyGroup string
+	// Group names the process group within its container that
+	// Container.RunGroup runs and waits for.
+	Group string
+	// WaitReason explains why a Pending process has not been started yet.
+	WaitReason string
+
+	// ExitCode is the code passed to Handle.Exit, if the action exited
+	// through it.
+	ExitCode int
+
+	// Replicas runs the Action as that many concurrent instances. The
+	// process completes once every replica has returned and fails if any
+	// of them failed. Zero or one means a single instance.
+	Replicas int
+
+	// Backoff sets the waits between the attempts of a WithRetry action,
+	// overriding its fixed backoff when Initial is set.
+	Backoff Backoff
+
+	// RetainOutbox keeps messages staged on the Handle's outbox when the
+	// action fails, so a later successful attempt (see WithRetry) delivers
+	// them too. By default they are discarded.
+	RetainOutbox bool
+
+	// Affinity steers Kernel.PlaceProcess towards a particular backend;
+	// Placement records where the process landed and why.
+	Affinity  Affinity
+	Placement Placement
+
+	// PublishResultsTo names a topic that receives a CompletionRecord
+	// when the process reaches a terminal state. It overrides the
+	// container's PublishResultsTo.
+	PublishResultsTo string
+
+	// Schedule, when set, only lets the process start within its windows;
+	// until then it stays Pending with WaitSchedule. A running process is
+	// not stopped when its window closes.
+	Schedule *Schedule
+
+	usedMB      int
+	peakMB      int // highest usedMB of the current run
+	handle      *Handle
+	replicas    []replicaStatus // per-replica outcome when Replicas > 1
+	outbox      []outboxMessage // staged until the action succeeds
+	stopping    bool            // cancelled by Drain, waiting for the action
+	resources   []*Resource     // handles opened through Handle.Open
+	permits     []*semaphore    // taken through Handle.Acquire, one per permit
+	inherited   map[uint64]int  // request ID -> priority lent by its waiter
+	result      any             // set through Handle.SetResult
+	sharedPipes []*pipe         // attached through Handle.AttachPipe
+	hasResult   bool
+	retries     retryBudget // spent through Handle.RequestWithRetry
+	debug       bool        // started through Kernel.Debug
+	stopStep    StopStep    // set by an escalating stop
+	jobAttempt  int         // attempt number within a job, from 1
+	windowDue   time.Time   // when a pass is due for Schedule, see inScheduleLocked
+	creditsDue  time.Time   // when a pass is due for refilled CPUCredits
+	runs        int         // times started, for the run history
+	logs        processLog
+	cancel      context.CancelFunc
+	doneMu      sync.Mutex
+	done        chan struct{} // closed on reaching a terminal state
+	doneClosed  bool
+	addedAt     time.Time
+	startedAt   time.Time
+	finishedAt  time.Time
+}
+
+type ContainerState int
+
+const (
+	ContainerCreated ContainerState = iota
+	ContainerRunning
+	ContainerStopped
+	// ContainerQuarantined: see Kernel.Quarantine.
+	ContainerQuarantined
+	// ContainerFrozen: see ErrorBudget.
+	ContainerFrozen
+)
+
+func (s ContainerState) String() string {
+	switch s {
+	case ContainerCreated:
+		return "Created"
+	case ContainerRunning:
+		return "Running"
+	case ContainerStopped:
+		return "Stopped"
+	case ContainerQuarantined:
+		return "Quarantined"
+	case ContainerFrozen:
+		return "Frozen"
+	}
+	return fmt.Sprintf("ContainerState(%d)", int(s))
 }
 
 type Container struct {
@@ -30,127 +201,1002 @@ type Container struct {
 	MemoryMB  int
 	CPULoad   float64
 	Processes []*Process
-	mu        sync.Mutex
+	State     ContainerState
+	Labels    map[string]string
+	Env       map[string]string
+	Volumes   []string // names of the volumes the container mounts
+	DependsOn []string // IDs of containers this one depends on
+
+	// StopPriority orders shutdown: StopAll stops containers with lower
+	// values first, so a container that must outlive the others (such as
+	// a log collector) gets a higher value.
+	StopPriority int
+
+	// BootPhase groups startup like runlevels: StartAll starts every
+	// container of a phase before moving on to the next higher one, and
+	// StopAll stops the phases in reverse.
+	BootPhase int
+
+	// MemoryPressure controls pressure signals and OOM kills as process
+	// memory usage approaches MemoryMB.
+	MemoryPressure MemoryPressurePolicy
+
+	// CPULimit is the number of CPUs the container's processes are
+	// expected to share; zero means unlimited. OvercommitRatio is how far
+	// the summed process demands may exceed the limits before Validate
+	// warns; zero means 1, i.e. any excess.
+	CPULimit        float64
+	OvercommitRatio float64
+
+	// MaxRunning caps the processes running at once; the Scheduler picks
+	// which queued processes fill free slots. Zero means unlimited. See
+	// SetAdaptiveConcurrency for tuning it automatically.
+	MaxRunning int
+
+	// CPUWeightLimit caps the summed CPUWeight of running processes;
+	// processes that would exceed it stay Pending until weight frees up.
+	// Zero means unlimited.
+	CPUWeightLimit float64
+
+	// HandleBudget caps the handles all of the container's processes may
+	// hold open at once; zero means unlimited.
+	HandleBudget int
+
+	// PublishResultsTo names a topic that receives a CompletionRecord for
+	// each of the container's processes as it reaches a terminal state.
+	PublishResultsTo string
+
+	// MaxPayloadBytes overrides Kernel.MaxPayloadBytes for messages to
+	// this container; zero uses the kernel default. MailboxLimit and
+	// MailboxMaxBytes bound the messages and payload bytes waiting in the
+	// inbox; zero means unbounded.
+	MaxPayloadBytes int
+	MailboxLimit    int
+	MailboxMaxBytes int
+
+	// IdleTimeout, when positive, lets the idle sweeper (see SweepIdle)
+	// stop the running container once it has had no running or queued
+	// process for that long. IdleRemove removes it instead.
+	IdleTimeout time.Duration
+	IdleRemove  bool
+
+	// RequestCache, when enabled, answers repeated Requests with the
+	// cached reply instead of delivering them.
+	RequestCache RequestCachePolicy
+
+	// Quarantine quarantines the container automatically when its
+	// processes keep failing.
+	Quarantine QuarantinePolicy
+
+	// ErrorBudget freezes the container when its processes keep failing;
+	// a zero budget uses Kernel.DefaultErrorBudget.
+	ErrorBudget ErrorBudget
+
+	mu             probedMutex
+	kernel         *Kernel
+	lastPressure   time.Time
+	overLimitSince time.Time
+	sentMessages   atomic.Int64 // messages sent since the last usage sample
+	openHandles    int
+	queueAlerted   bool
+	outcomes       OutcomeCounts // terminal states reached, see Snapshot
+	logSeq         uint64
+	inbox          []Message
+	inboxBytes     int // payload bytes held in inbox
+	denied         Capability
+	inboxReady     chan struct{} // closed when a message arrives
+	pipes          map[string]*pipe
+	metrics        map[string]float64 // set through Handle.SetMetric
+	usage          usageHistory
+	peaks          Peaks
+	idleSince      time.Time // when the last live process finished
+	skew           atomic.Pointer[clockSkew]
+	requestCache   requestCache
+	quarantined    bool
+	failures       []time.Time // recent process failures, for Quarantine
+	job            *job        // set by CreateJob
+	frozen         bool
+	freezes        int         // times frozen, to match automatic thaws
+	budgetFailures []time.Time // failures charged to ErrorBudget
+	adaptive       *adaptiveState
+}
+
+func (c *Container) now() time.Time {
+	return c.clock().Now()
+}
+
+// clock is the kernel clock, or the wall clock for detached containers.
+func (c *Container) clock() Clock {
+	if c.kernel != nil {
+		return c.kernel.Clock
+	}
+	return realClock{}
+}
+
+// emit publishes a container event, attributed to p when it is non-nil.
+func (c *Container) emit(kind EventKind, p *Process, detail string) {
+	if c.kernel == nil {
+		return
+	}
+	e := Event{Kind: kind, ContainerID: c.ID, Detail: detail}
+	if p != nil {
+		e.Process = p.Name
+		e.PID = p.PID
+	}
+	if s := c.skew.Load(); s != nil {
+		e.LocalTime = s.local(c.now())
+	}
+	c.kernel.publish(e)
 }
 
+// AddProcess queues p in the container. It starts straight away if the
+// container is running and the scheduler admits it. Process names need not
+// be unique; the PID identifies a process.
 func (c *Container) AddProcess(p *Process) {
+	if err := c.checkAuthoritative(); err != nil {
+		fmt.Printf("[Kernel] Refusing to add process %s to %s: %v\n", p.Name, c.Name, err)
+		return
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	p.State = Running
-	c.Processes = append(c.Processes, p)
+	c.addProcessLocked(p)
+	c.scheduleLocked()
 }
 
-func (c *Container) StartProcesses() {
+// ProcessesByName returns the container's processes named name, in the
+// order they were added. Anything that looks a process up by name alone
+// should act on the first of them.
+func (c *Container) ProcessesByName(name string) []*Process {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	var out []*Process
 	for _, p := range c.Processes {
-		if p.State == Running {
-			go func(proc *Process) {
-				proc.Action()
-				proc.State = Completed
-			}(p)
+		if p.Name == name {
+			out = append(out, p)
 		}
 	}
+	return out
 }
 
-func (c *Container) StopProcesses() {
+// EachProcess calls fn for each of the container's processes, in the order
+// they were added, over a copy of the list taken under the container lock.
+// fn runs without the lock, so it may add processes or otherwise call back
+// into the container; processes added meanwhile are not visited. The
+// kernel keeps updating the processes' fields, so fn should read their
+// state through Inspect rather than directly.
+func (c *Container) EachProcess(fn func(*Process)) {
+	c.mu.Lock()
+	procs := append([]*Process(nil), c.Processes...)
+	c.mu.Unlock()
+	for _, p := range procs {
+		fn(p)
+	}
+}
+
+func (c *Container) addProcessLocked(p *Process) {
+	p.State = Pending
+	c.adjustDeclarationLocked(p)
+	p.reopenDone()
+	p.addedAt = c.now()
+	if p.PID == 0 && c.kernel != nil {
+		p.PID = c.kernel.allocPID()
+	}
+	c.Processes = append(c.Processes, p)
+	c.idleSince = time.Time{}
+	c.emit(EventProcessAdded, p, "")
+	c.notifySchedulerLocked(p, Scheduler.OnProcessQueued)
+}
+
+// requeueLocked queues finished processes of c again, moving them to the
+// end of its process list.
+func (c *Container) requeueLocked(procs []*Process) {
+	c.Processes = slices.DeleteFunc(c.Processes, func(p *Process) bool { return slices.Contains(procs, p) })
+	for _, p := range procs {
+		c.addProcessLocked(p)
+	}
+}
+
+// SetLabels replaces the container's labels with a copy of labels.
+func (c *Container) SetLabels(labels map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Labels = copyStringMap(labels)
+	c.labelsChangedLocked()
+}
+
+// AddLabel sets a single label, replacing any previous value.
+func (c *Container) AddLabel(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Labels == nil {
+		c.Labels = make(map[string]string)
+	}
+	c.Labels[key] = value
+	c.labelsChangedLocked()
+}
+
+// labelsChangedLocked emits an EventLabelsChanged listing the labels as
+// sorted key=value pairs.
+func (c *Container) labelsChangedLocked() {
+	pairs := make([]string, 0, len(c.Labels))
+	for k, v := range c.Labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	c.emit(EventLabelsChanged, nil, strings.Join(pairs, ","))
+}
+
+// SetCPULoad sets the container's CPU load, clamped to 0..100. Nothing
+// measures CPU, so simulations and tests set the load they want observed.
+func (c *Container) SetCPULoad(v float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.CPULoad = min(max(v, 0), 100)
+	c.observeLoadLocked()
+}
+
+// StartProcesses marks the container running and lets the scheduler admit
+// its pending processes. In strict mode it fails with ErrNoPendingProcesses
+// if there is nothing to admit.
+func (c *Container) StartProcesses() error {
+	if err := c.checkAuthoritative(); err != nil {
+		fmt.Printf("[Kernel] Refusing to start container %s: %v\n", c.Name, err)
+		return err
+	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.quarantined {
+		return fmt.Errorf("%w: %s", ErrQuarantined, c.ID)
+	}
+	if c.frozen {
+		return fmt.Errorf("%w: %s", ErrFrozen, c.ID)
+	}
+	if err := c.startJobLocked(); err != nil {
+		return err
+	}
+	if c.kernel.strict() && !c.hasProcessInLocked(Pending, Throttled) {
+		return fmt.Errorf("%w: container %s", ErrNoPendingProcesses, c.ID)
+	}
+	c.State = ContainerRunning
+	c.emit(EventContainerStarted, nil, "")
+	c.scheduleLocked()
+	return nil
+}
+
+func (c *Container) run(ctx context.Context, p *Process, h *Handle) {
+	var err error
+	if p.Action != nil {
+		err = p.Action(ctx, h)
+	}
+	// Committed outbox messages are sent after the container lock is
+	// released, since sending takes the kernel lock.
+	var commit []outboxMessage
+	defer func() { c.deliverOutbox(commit) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h.run.live--; h.run.live == 0 {
+		close(h.run.done)
+	}
+	if p.replicas != nil {
+		if !p.replicaDoneLocked(h.replica, err) {
+			return
+		}
+		err = p.replicaErrLocked()
+	}
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		p.ExitCode = exit.Code
+	}
+	c.closeLeakedLocked(p)
+	c.releasePermitsLocked(p)
+	c.flushLogsLocked(p, h.traceID)
+	p.cancel()
+	if p.CPUCredits != nil {
+		p.CPUCredits.settle(c.now(), true)
+	}
+	if p.State != Running {
+		// Stopped or killed while the action was still running.
+		p.abortOutboxLocked()
+		if p.State == Pending {
+			c.scheduleLocked() // requeued meanwhile, see WaitPreviousRun
+		}
+		return
+	}
+	if p.stopping {
+		// Drained: the action returned within its grace period.
+		p.abortOutboxLocked()
+		c.finishLocked(p, Stopped)
+		return
+	}
+	if err != nil {
+		p.abortOutboxLocked()
+		p.Err = err
+		c.finishLocked(p, Failed)
+		c.emit(EventProcessFailed, p, err.Error())
+	} else {
+		commit, p.outbox = p.outbox, nil
+		c.finishLocked(p, Completed)
+		c.emit(EventProcessCompleted, p, "")
+	}
+	c.checkMemoryLocked()
+}
+
+// Done returns a channel that is closed once the process reaches a terminal
+// state: Completed, Failed, Killed or Stopped. The process's final state is
+// visible through Inspect by the time a receive from the channel returns.
+func (p *Process) Done() <-chan struct{} {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if p.done == nil {
+		p.done = make(chan struct{})
+		if p.doneClosed {
+			close(p.done)
+		}
+	}
+	return p.done
+}
+
+func (p *Process) closeDone() {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if p.doneClosed {
+		return
+	}
+	p.doneClosed = true
+	if p.done != nil {
+		close(p.done)
+	}
+}
+
+// reopenDone gives a process that is queued again a fresh Done channel.
+func (p *Process) reopenDone() {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if p.doneClosed {
+		p.done, p.doneClosed = nil, false
+	}
+}
+
+// finishLocked moves p into a terminal state and records when it ended.
+// A process leaving Running gives back its concurrency group slot and CPU
+// weight.
+func (c *Container) finishLocked(p *Process, state ProcessState) {
+	wasRunning := p.State == Running
+	p.State = state
+	p.inherited = nil
+	p.finishedAt = c.now()
+	p.closeDone()
+	c.recordOutcomeLocked(p)
+	c.noteFailureLocked(p)
+	c.chargeBudgetLocked(p)
+	c.pipesProcessDoneLocked(p)
+	if wasRunning {
+		c.observeLoadLocked()
+	}
+	if p.debug {
+		c.removeDebugLocked(p)
+		return
+	}
+	c.publishResultLocked(p)
+	if wasRunning {
+		c.recordRunLocked(p)
+		c.observeDriftLocked(p, p.peakMB)
+		c.profileActionLocked(p)
+	}
+	c.noteIdleLocked()
+	c.notifySchedulerLocked(p, Scheduler.OnProcessFinished)
+	if wasRunning && (state == Completed || state == Failed) {
+		c.adaptLocked(p)
+	}
+	if wasRunning && c.MaxRunning > 0 && c.kernel != nil {
+		c.kernel.kick()
+	}
+	if wasRunning && p.ConcurrencyGroup != "" && c.kernel != nil {
+		c.kernel.groups.release(p.ConcurrencyGroup)
+		c.kernel.kick()
+	}
+	if wasRunning && p.CPUWeight > 0 && c.CPUWeightLimit > 0 && c.kernel != nil {
+		c.kernel.kick()
+	}
+	c.jobAttemptDoneLocked(p, state)
+}
+
+// StopProcesses stops the container and every process it has not yet
+// finished. In strict mode it fails with ErrNothingToStop if no process is
+// queued or running.
+func (c *Container) StopProcesses() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.kernel.strict() && !c.hasProcessInLocked(Pending, Throttled, Running) {
+		return fmt.Errorf("%w: container %s", ErrNothingToStop, c.ID)
+	}
+	c.stopProcessesLocked()
+	return nil
+}
+
+func (c *Container) stopProcessesLocked() {
+	c.State = ContainerStopped
+	c.emit(EventContainerStopped, nil, "")
 	for _, p := range c.Processes {
-		if p.State == Running {
-			p.State = Stopped
+		switch p.State {
+		case Pending, Throttled, Running:
+			c.finishLocked(p, Stopped)
+			if p.cancel != nil {
+				p.cancel()
+			}
 		}
 	}
 }
 
 // --- Kernel ---
+
+// Kernel owns the container registry and everything shared between
+// containers.
+//
+// Lock ordering: Kernel.mu is taken before any Container.mu, and never
+// while a container lock is held; work that needs the kernel lock from
+// under a container lock is handed off with kick or a goroutine. Locks
+// private to one part of a container (pipes, Done channels, the signal
+// buffer) nest inside its Container.mu. Operations that only touch one
+// container's state, such as Recv, Inspect or a Monitor row, take just
+// that container's lock.
 type Kernel struct {
-	Containers map[string]*Container
-	mu         sync.Mutex
+	Containers    map[string]*Container
+	mu            probedMutex
+	probes        kernelProbes
+	locks         lockTracker
+	kinds         map[string]*kindVersions
+	events        *eventBus
+	services      map[string]*service
+	topics        map[string]map[string]*topicSub // topic -> subscribed container IDs
+	topicLogs     map[string]*topicLog
+	templates     map[string]Spec
+	warmPools     map[string]*warmPool // by template name
+	dispatchPools map[string]*dispatchPool
+	scheduler     Scheduler                    // nil for PriorityScheduler
+	overrides     map[string]map[string]string // scope -> destination -> container ID
+	circuits      map[string]*circuit          // by Request target
+	lastShutdown  *ShutdownReport
+	debugDisabled bool
+	spawns        spawnBucket
+
+	// Clock is the kernel's time source. It defaults to the wall clock;
+	// tests may swap in a FakeClock before creating containers.
+	Clock Clock
+
+	// AdmissionController, when set, is consulted before any process is
+	// started.
+	AdmissionController AdmissionController
+
+	// QueueDepthAlert, when positive, raises an EventQueueBacklog whenever
+	// a container has more processes waiting for admission than this.
+	QueueDepthAlert int
+
+	// SyscallFilter, when set, sees every syscall a process makes through
+	// its Handle before it runs; a non-nil error fails the syscall.
+	SyscallFilter func(SyscallRecord) error
+
+	// Anomalies configures the rolling-baseline anomaly detector run on
+	// each usage sample. The zero value disables it.
+	Anomalies AnomalyConfig
+
+	// DriftPolicy checks declared process memory against observed usage.
+	// The zero value disables it.
+	DriftPolicy DriftPolicy
+
+	// Rand drives the demo's simulated load and priorities. It is seeded
+	// from the wall clock; replace it with a fixed seed for reproducible
+	// runs. It is not safe for concurrent use.
+	Rand *rand.Rand
+
+	// MemoryCeilingMB caps the memory committed by running processes across
+	// all containers; zero means no ceiling. OOMPolicy picks the victims
+	// when the ceiling is exceeded.
+	MemoryCeilingMB int
+	OOMPolicy       OOMPolicy
+
+	// RequireUniqueNames rejects creating or renaming a container to a
+	// name another container already has.
+	RequireUniqueNames bool
+
+	// AllowSelfMessage permits a container to send messages to itself,
+	// which is otherwise rejected with ErrSelfMessage.
+	AllowSelfMessage bool
+
+	// MaxPayloadBytes rejects messages with larger payloads with
+	// ErrPayloadTooLarge, unless the receiver sets its own limit; zero
+	// means unlimited.
+	MaxPayloadBytes int
+
+	// StrictMode turns operations that would silently do nothing into
+	// errors: see ErrNothingToStop, ErrNoPendingProcesses, ErrNoContainers
+	// and ErrNoReceiver.
+	StrictMode bool
+
+	// CircuitBreaker, when enabled, makes Request fail fast with
+	// ErrCircuitOpen for targets that keep failing.
+	CircuitBreaker CircuitBreakerPolicy
+
+	// HealthFailureThreshold is how many Failed processes a container may
+	// have before Healthz reports the kernel unhealthy.
+	HealthFailureThreshold int
+
+	// MaxTotalProcesses caps the unfinished processes across all
+	// containers, and SpawnRate the Handle.Spawn calls per second, against
+	// runaway process trees. Spawns beyond either fail with
+	// ErrSpawnLimitExceeded; zero means unlimited.
+	MaxTotalProcesses int
+	SpawnRate         float64
+
+	// JobHistoryLimit is how many finished jobs Jobs keeps, the most
+	// recently finished first; zero keeps them all.
+	JobHistoryLimit int
+
+	// DefaultErrorBudget applies to containers without an ErrorBudget of
+	// their own.
+	DefaultErrorBudget ErrorBudget
+
+	// RunRetention bounds the run history, see Runs.
+	RunRetention RunRetention
+
+	// DeniedCapabilities are withheld from every container created, on top
+	// of those left out with WithCapabilities.
+	DeniedCapabilities Capability
+
+	// TrashRetention is how long RemoveContainer keeps a container
+	// restorable before purging it; zero means DefaultTrashRetention and a
+	// negative value removes containers outright.
+	TrashRetention time.Duration
+
+	groups         concurrencyGroups
+	volumeHomes    map[string]string // volume -> container that last used it
+	lastPID        atomic.Int64
+	ceilingPending atomic.Bool
+	kickPending    atomic.Bool
+	leaked         atomic.Int64
+	stopSteps      [StopStepKill + 1]atomic.Int64 // escalating stops by final step
+	leakedHandles  atomic.Int64
+	lastMsgID      atomic.Uint64
+	lastPipeID     atomic.Uint64
+	deadLetters    []DeadLetter
+	scheduled      map[uint64]*ScheduledMessage
+	lastTimerID    atomic.Uint64
+	requests       map[uint64]*pendingRequest
+	traces         traceLog
+	receipts       receiptLog
+	jobs           []*Container // created by CreateJob, oldest first
+	trash          map[string]*trashedContainer
+	maintaining    map[string]chan struct{} // container ID -> closed when its maintenance ends
+	semaphores     map[string]*semaphore
+	profiler       profiler
+	runs           runHistory
+	lastRunID      atomic.Uint64
+	schedLatency   *durationHistogram
+	peaks          peakTracker
+	drift          driftTracker
+	timeline       timelineLog
+	syscalls       syscallLog
+	messagesSent   atomic.Int64
+	role           atomic.Int32
+	replication    *Replication // set while following a primary
 }
 
-func NewKernel() *Kernel {
-	return &Kernel{
+func NewKernel(opts ...KernelOption) *Kernel {
+	k := &Kernel{
 		Containers: make(map[string]*Container),
+		Clock:      realClock{},
+		Rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		kinds:      make(map[string]*kindVersions),
+		events:     newEventBus(),
+		services:   make(map[string]*service),
+
+		volumeHomes: make(map[string]string),
+		scheduled:   make(map[uint64]*ScheduledMessage),
+		requests:    make(map[uint64]*pendingRequest),
+
+		schedLatency: newDurationHistogram(schedulingBounds),
 	}
+	k.mu.probe = &k.probes.kernelLock
+	k.mu.tracker, k.mu.name = &k.locks, "kernel"
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
 }
 
-func (k *Kernel) CreateContainer(id, name string, memory int) *Container {
+// ErrInvalidContainerSpec is returned when a container is created with an
+// empty ID or name or a non-positive memory limit.
+var ErrInvalidContainerSpec = errors.New("invalid container spec")
+
+// CreateContainer creates a container with the given memory limit in MB,
+// configured by opts.
+func (k *Kernel) CreateContainer(id, name string, memory int, opts ...ContainerOption) (*Container, error) {
+	if err := validateContainer(id, name, memory); err != nil {
+		return nil, err
+	}
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
 	k.mu.Lock()
 	defer k.mu.Unlock()
-	c := &Container{
+	if err := k.checkNameLocked("", name); err != nil {
+		return nil, err
+	}
+	c := k.newContainerLocked(id, name, memory)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	k.registerContainerLocked(c, id)
+	return c, nil
+}
+
+func validateContainer(id, name string, memory int) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: empty id", ErrInvalidContainerSpec)
+	case name == "":
+		return fmt.Errorf("%w: container %s has an empty name", ErrInvalidContainerSpec, id)
+	case memory <= 0:
+		return fmt.Errorf("%w: container %s memory must be positive, got %dMB", ErrInvalidContainerSpec, id, memory)
+	}
+	return nil
+}
+
+func (k *Kernel) createContainerLocked(id, name string, memory int) *Container {
+	c := k.newContainerLocked(id, name, memory)
+	k.registerContainerLocked(c, id)
+	return c
+}
+
+// newContainerLocked builds a container that is not yet registered with
+// the kernel.
+func (k *Kernel) newContainerLocked(id, name string, memory int) *Container {
+	return &Container{
 		ID:        id,
 		Name:      name,
 		MemoryMB:  memory,
 		CPULoad:   0,
 		Processes: []*Process{},
+		Labels:    map[string]string{},
+		Env:       map[string]string{},
+		kernel:    k,
+		denied:    k.DeniedCapabilities,
+
+		MemoryPressure: DefaultMemoryPressurePolicy(),
 	}
+}
+
+// registerContainerLocked adds c to the kernel under id.
+func (k *Kernel) registerContainerLocked(c *Container, id string) {
+	c.ID = id
+	c.mu.probe = &k.probes.containerLock
+	c.mu.tracker, c.mu.name = &k.locks, "container:"+id
 	k.Containers[id] = c
-	fmt.Printf("[Kernel] Created container: %s\n", name)
-	return c
+	fmt.Printf("[Kernel] Created container: %s\n", c.Name)
+	k.emit(EventContainerCreated, id, "", c.Name)
 }
 
-func (k *Kernel) StartAll() {
+func (k *Kernel) allocPID() int {
+	return int(k.lastPID.Add(1))
+}
+
+// RemoveContainer stops every process in container id and removes it from
+// the kernel. The container goes to the trash, from which RestoreContainer
+// can bring it back until TrashRetention has passed; PurgeContainer
+// removes it for good.
+func (k *Kernel) RemoveContainer(id string) error {
+	if err := k.checkAuthoritative(); err != nil {
+		return err
+	}
 	k.mu.Lock()
 	defer k.mu.Unlock()
-	for _, c := range k.Containers {
-		fmt.Printf("[Kernel] Starting container: %s\n", c.Name)
-		c.StartProcesses()
+	c, ok := k.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	k.trashContainerLocked(c)
+	return nil
+}
+
+// ErrNameTaken is returned when Kernel.RequireUniqueNames is set and a name
+// is already in use.
+var ErrNameTaken = errors.New("container name already in use")
+
+// RenameContainer changes the display name of container id.
+func (k *Kernel) RenameContainer(id, newName string) error {
+	if newName == "" {
+		return fmt.Errorf("%w: container %s has an empty name", ErrInvalidContainerSpec, id)
+	}
+	if err := k.checkAuthoritative(); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	if err := k.checkNameLocked(id, newName); err != nil {
+		return err
 	}
+	c.mu.Lock()
+	old := c.Name
+	c.Name = newName
+	c.mu.Unlock()
+	fmt.Printf("[Kernel] Renamed container %s: %s -> %s\n", id, old, newName)
+	k.emit(EventContainerRenamed, id, "", newName)
+	return nil
 }
 
-func (k *Kernel) StopAll() {
+// checkNameLocked enforces RequireUniqueNames for a container (other than
+// self) taking name.
+func (k *Kernel) checkNameLocked(self, name string) error {
+	if !k.RequireUniqueNames {
+		return nil
+	}
+	for id, c := range k.Containers {
+		if id == self {
+			continue
+		}
+		c.mu.Lock()
+		taken := c.Name == name
+		c.mu.Unlock()
+		if taken {
+			return fmt.Errorf("%w: %s is used by %s", ErrNameTaken, name, id)
+		}
+	}
+	return nil
+}
+
+// removeContainerLocked removes c for good.
+func (k *Kernel) removeContainerLocked(c *Container) {
+	k.unregisterContainerLocked(c)
+	k.purgeLocked(c.ID)
+}
+
+// unregisterContainerLocked stops c and takes it out of the registry and
+// everything that routes work to it.
+func (k *Kernel) unregisterContainerLocked(c *Container) {
+	c.mu.Lock()
+	c.stopProcessesLocked()
+	c.expireInboxLocked()
+	c.mu.Unlock()
+	delete(k.Containers, c.ID)
+	k.peaks.remove(c.ID, k.Clock.Now())
+	k.removeBackendLocked(c.ID)
+	k.unsubscribeAllLocked(c.ID)
+	fmt.Printf("[Kernel] Removed container: %s\n", c.Name)
+	k.emit(EventContainerRemoved, c.ID, "", c.Name)
+}
+
+// StartAll starts the containers phase by phase, in ascending BootPhase;
+// within a phase dependencies start first, ties broken by ID. If any
+// container of a phase fails to start, the boot halts with a *BootError
+// and later phases are left alone; earlier ones keep running. Finished
+// jobs are skipped.
+func (k *Kernel) StartAll() error {
+	if err := k.checkAuthoritative(); err != nil {
+		fmt.Printf("[Kernel] Refusing to start containers: %v\n", err)
+		return err
+	}
 	k.mu.Lock()
 	defer k.mu.Unlock()
+	phases := k.bootPhasesLocked()
+	for _, ph := range phases {
+		blocked := make(map[string]error)
+		for _, c := range ph.containers {
+			if c.jobFinished() {
+				continue
+			}
+			fmt.Printf("[Kernel] Starting container: %s\n", c.Name)
+			// A container with nothing queued is not a boot failure,
+			// even in strict mode.
+			if err := c.StartProcesses(); err != nil && !errors.Is(err, ErrNoPendingProcesses) {
+				blocked[c.ID] = err
+			}
+		}
+		if err := k.bootPhaseDoneLocked(ph, len(phases), blocked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedContainersLocked returns the containers ordered by ID so that
+// kernel-wide output is stable.
+func (k *Kernel) sortedContainersLocked() []*Container {
+	out := make([]*Container, 0, len(k.Containers))
 	for _, c := range k.Containers {
-		fmt.Printf("[Kernel] Stopping container: %s\n", c.Name)
-		c.StopProcesses()
+		out = append(out, c)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
 }
 
-func (k *Kernel) Monitor(interval time.Duration, cycles int) {
+// Monitor prints a status line per container, ordered by ID, every interval
+// on the kernel clock, followed by a line per limit violation (see
+// Violations). In strict mode it fails with ErrNoContainers if there
+// is nothing to monitor.
+func (k *Kernel) Monitor(interval time.Duration, cycles int) error {
+	return k.MonitorTo(os.Stdout, interval, cycles)
+}
+
+// MonitorTo is Monitor writing to w.
+func (k *Kernel) MonitorTo(w io.Writer, interval time.Duration, cycles int) error {
+	if k.strict() {
+		k.mu.Lock()
+		n := len(k.Containers)
+		k.mu.Unlock()
+		if n == 0 {
+			return ErrNoContainers
+		}
+	}
+	prev := make(map[string]OutcomeCounts)
 	for i := 0; i < cycles; i++ {
-		fmt.Println("=== Kernel Monitoring ===")
+		start := time.Now()
+		// The kernel lock is held only to copy the registry; each
+		// container is then read, and sampled, under its own lock, and
+		// the report is written with no lock held, so a slow writer
+		// does not stall messaging or scheduling.
 		k.mu.Lock()
-		for _, c := range k.Containers {
+		containers := k.sortedContainersLocked()
+		k.mu.Unlock()
+		var b strings.Builder
+		b.WriteString("=== Kernel Monitoring ===\n")
+		var violations []LimitViolation
+		for _, c := range containers {
+			c.mu.Lock()
 			active := 0
 			for _, p := range c.Processes {
 				if p.State == Running {
 					active++
 				}
 			}
-			fmt.Printf("Container %s | Memory: %dMB | CPU: %.2f%% | Running Processes: %d\n",
-				c.Name, c.MemoryMB, c.CPULoad, active)
+			fmt.Fprintf(&b, "Container %s | Memory: %dMB | CPU: %.2f%% | Running Processes: %d%s\n",
+				c.Name, c.MemoryMB, c.CPULoad, active, c.monitorColumnsLocked(prev[c.ID]))
+			prev[c.ID] = c.outcomes
+			violations = append(violations, c.violationsLocked()...)
+			c.sampleLocked(k.Anomalies)
+			c.mu.Unlock()
 		}
-		k.mu.Unlock()
-		time.Sleep(interval)
+		for _, v := range violations {
+			fmt.Fprintf(&b, "Violation: %s\n", v)
+		}
+		if k.probes.enabled.Load() {
+			k.probes.monitor.record(time.Since(start))
+		}
+		io.WriteString(w, b.String())
+		k.Clock.Sleep(interval)
 	}
+	return nil
+}
+
+// ErrSelfMessage is returned when a container sends a message to itself
+// while Kernel.AllowSelfMessage is false.
+var ErrSelfMessage = errors.New("container cannot message itself")
+
+// Inter-container messaging. toID may name a service as "svc:<name>", in
+// which case the sender's ID is used as the sticky session key.
+func (k *Kernel) SendMessage(fromID, toID, msg string) error {
+	return k.SendMessageWithKey(fromID, toID, "", msg)
+}
+
+// SendMessageWithKey is SendMessage with an explicit session key for sticky
+// service routing.
+func (k *Kernel) SendMessageWithKey(fromID, toID, sessionKey, msg string) error {
+	return k.sendMessage(Message{From: fromID, To: toID, Payload: msg}, sessionKey)
 }
 
-// Inter-container messaging
-func (k *Kernel) SendMessage(fromID, toID, msg string) {
+// sendMessage delivers m, which carries From, To, Payload and optionally
+// its trace.
+func (k *Kernel) sendMessage(m Message, sessionKey string) error {
+	if err := k.checkAuthoritative(); err != nil {
+		fmt.Printf("[Kernel] Messaging error: %v\n", err)
+		return err
+	}
 	k.mu.Lock()
 	defer k.mu.Unlock()
+	_, err := k.deliverLocked(m, sessionKey)
+	return err
+}
+
+// sendLocked delivers msg to the inbox of the resolved destination and
+// returns the delivered message.
+func (k *Kernel) sendLocked(fromID, toID, sessionKey, msg string) (Message, error) {
+	return k.deliverLocked(Message{From: fromID, To: toID, Payload: msg}, sessionKey)
+}
+
+// deliverLocked resolves m.To, stamps m with an ID, unless it was given one
+// when queued, and a send time and appends
+// it to the destination inbox. A message without a trace starts its own.
+func (k *Kernel) deliverLocked(m Message, sessionKey string) (Message, error) {
+	targetID, err := k.resolveDestinationLocked(m.From, m.To, sessionKey)
+	if err != nil {
+		fmt.Printf("[Kernel] Messaging error: %v\n", err)
+		return Message{}, err
+	}
+	return k.deliverToLocked(m, targetID)
+}
+
+// deliverToLocked is deliverLocked to the already resolved targetID.
+func (k *Kernel) deliverToLocked(m Message, targetID string) (Message, error) {
+	fromID := m.From
 	from, ok1 := k.Containers[fromID]
-	to, ok2 := k.Containers[toID]
-	if ok1 && ok2 {
-		fmt.Printf("[Kernel] %s -> %s : %s\n", from.Name, to.Name, msg)
-	} else {
+	to, ok2 := k.Containers[targetID]
+	if m.Replayed && !ok1 {
+		// A replayed message outlives its publisher.
+		from, ok1 = &Container{ID: fromID, Name: fromID}, true
+	}
+	if !ok1 || !ok2 {
 		fmt.Println("[Kernel] Messaging error: container not found")
+		return Message{}, ErrContainerNotFound
+	}
+	if fromID == targetID && !k.AllowSelfMessage {
+		fmt.Printf("[Kernel] Messaging error: %s tried to message itself\n", from.Name)
+		return Message{}, fmt.Errorf("%w: %s", ErrSelfMessage, fromID)
+	}
+	to.mu.Lock()
+	if k.StrictMode && !to.hasProcessInLocked(Pending, Throttled, Running) {
+		to.mu.Unlock()
+		fmt.Printf("[Kernel] Messaging error: %s has no process to receive\n", to.Name)
+		return Message{}, fmt.Errorf("%w: %s", ErrNoReceiver, targetID)
+	}
+	if err := to.admitMessageLocked(m); err != nil {
+		to.mu.Unlock()
+		fmt.Printf("[Kernel] Messaging error: %v\n", err)
+		return Message{}, err
+	}
+	if m.ID == 0 {
+		m.ID = k.lastMsgID.Add(1)
+	}
+	m.To = targetID
+	if !m.Replayed {
+		m.SentAt = from.localTime(k.Clock.Now())
+	}
+	if m.TraceID == 0 {
+		m.TraceID = m.ID
 	}
+	to.inbox = append(to.inbox, m)
+	to.inboxBytes += len(m.Payload)
+	to.observeLoadLocked()
+	if to.inboxReady != nil {
+		close(to.inboxReady)
+		to.inboxReady = nil
+	}
+	to.mu.Unlock()
+	fmt.Printf("[Kernel] %s -> %s : %s\n", from.Name, to.Name, m.Payload)
+	if !m.Replayed {
+		k.messagesSent.Add(1)
+		from.sentMessages.Add(1)
+	}
+	k.traces.record(m, TraceDelivered, "")
+	k.receipts.record(m, MessageDelivered, "", k.Clock.Now())
+	k.publish(Event{Kind: EventMessageSent, ContainerID: fromID, Detail: targetID, TraceID: m.TraceID})
+	return m, nil
 }
 
 // --- Example Process ---
-func exampleProcess(name string, duration time.Duration) *Process {
+func exampleProcess(rng *rand.Rand, name string, duration time.Duration) *Process {
 	return &Process{
 		Name:     name,
-		Priority: rand.Intn(10),
-		Action: func() {
+		Priority: rng.Intn(10),
+		Action: func(ctx context.Context, h *Handle) error {
 			fmt.Printf("Process %s started\n", name)
-			time.Sleep(duration)
+			select {
+			case <-h.after(duration):
+			case <-ctx.Done():
+				if errors.Is(ctx.Err(), context.Canceled) {
+					fmt.Printf("Process %s stopped\n", name)
+					return nil
+				}
+				return ctx.Err()
+			}
 			fmt.Printf("Process %s completed\n", name)
+			return nil
 		},
-		State: Running,
+		State: Pending,
 	}
 }
 
@@ -159,14 +1205,22 @@ func main() {
 	kernel := NewKernel()
 
 	// Create containers
-	c1 := kernel.CreateContainer("c1", "WebServer", 512)
-	c2 := kernel.CreateContainer("c2", "Database", 1024)
+	c1, err := kernel.CreateContainer("c1", "WebServer", 512)
+	if err != nil {
+		fmt.Println("[Kernel]", err)
+		return
+	}
+	c2, err := kernel.CreateContainer("c2", "Database", 1024)
+	if err != nil {
+		fmt.Println("[Kernel]", err)
+		return
+	}
 
 	// Add processes
-	c1.AddProcess(exampleProcess("HTTP Server", 2*time.Second))
-	c1.AddProcess(exampleProcess("Worker", 3*time.Second))
-	c2.AddProcess(exampleProcess("DB Engine", 4*time.Second))
-	c2.AddProcess(exampleProcess("Backup", 5*time.Second))
+	c1.AddProcess(exampleProcess(kernel.Rand, "HTTP Server", 2*time.Second))
+	c1.AddProcess(exampleProcess(kernel.Rand, "Worker", 3*time.Second))
+	c2.AddProcess(exampleProcess(kernel.Rand, "DB Engine", 4*time.Second))
+	c2.AddProcess(exampleProcess(kernel.Rand, "Backup", 5*time.Second))
 
 	// Start all containers
 	kernel.StartAll()
@@ -175,16 +1229,28 @@ func main() {
 	kernel.SendMessage("c1", "c2", "Query: SELECT * FROM users;")
 	kernel.SendMessage("c2", "c1", "Response: 42 records returned.")
 
-	// Dynamic CPU/Memory simulation
+	// Dynamic CPU/Memory simulation. The kernel lock guards the registry
+	// and Rand; memory limits change through SetMemory, which reacts to
+	// the new limit.
 	go func() {
 		for i := 0; i < 5; i++ {
 			kernel.mu.Lock()
-			for _, c := range kernel.Containers {
-				c.CPULoad = rand.Float64() * 100
-				c.MemoryMB = c.MemoryMB + rand.Intn(50) - 25
+			containers := kernel.sortedContainersLocked()
+			loads := make([]float64, len(containers))
+			deltas := make([]int, len(containers))
+			for j := range containers {
+				loads[j] = kernel.Rand.Float64() * 100
+				deltas[j] = kernel.Rand.Intn(50) - 25
 			}
 			kernel.mu.Unlock()
-			time.Sleep(1 * time.Second)
+			for j, c := range containers {
+				c.SetCPULoad(loads[j])
+				c.mu.Lock()
+				mb := max(c.MemoryMB+deltas[j], 1)
+				c.mu.Unlock()
+				c.SetMemory(mb)
+			}
+			kernel.Clock.Sleep(1 * time.Second)
 		}
 	}()
 
@@ -192,6 +1258,8 @@ func main() {
 	kernel.Monitor(1*time.Second, 5)
 
 	// Stop all containers
-	kernel.StopAll()
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	kernel.StopAll(shutdown)
 	fmt.Println("[Kernel] All containers stopped.")
 }
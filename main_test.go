@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEpoch is where every test kernel's FakeClock starts.
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestKernel returns a kernel on a FakeClock with a fixed Rand seed.
+func newTestKernel(t testing.TB) (*Kernel, *FakeClock) {
+	t.Helper()
+	clk := NewFakeClock(testEpoch)
+	k := NewKernel()
+	k.Clock = clk
+	k.Rand = rand.New(rand.NewSource(1))
+	return k, clk
+}
+
+// newTestContainer creates container id (also its name) with 1024MB.
+func newTestContainer(t testing.TB, k *Kernel, id string, opts ...ContainerOption) *Container {
+	t.Helper()
+	c, err := k.CreateContainer(id, id, 1024, opts...)
+	if err != nil {
+		t.Fatalf("CreateContainer(%s): %v", id, err)
+	}
+	return c
+}
+
+// blockUntil returns an action that runs until release is closed or its
+// context is cancelled.
+func blockUntil(release <-chan struct{}) ActionFunc {
+	return func(ctx context.Context, h *Handle) error {
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// noop is an action that returns straight away.
+func noop(context.Context, *Handle) error { return nil }
+
+// waitDone waits for p to reach a terminal state.
+func waitDone(t *testing.T, p *Process) {
+	t.Helper()
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("process %s did not finish", p.Name)
+	}
+}
+
+// stateOf reads p's state under its container's lock.
+func stateOf(c *Container, p *Process) ProcessState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return p.State
+}
+
+// eventually polls cond until it holds, failing the test after a few
+// seconds of wall time.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForWaiters waits until at least n timers are pending on clk.
+func waitForWaiters(t *testing.T, clk *FakeClock, n int) {
+	t.Helper()
+	eventually(t, "clock waiters", func() bool { return clk.Waiters() >= n })
+}
+
+// nextEvent receives from ch, failing the test if nothing arrives.
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return Event{}
+	}
+}
+
+// mustDecode unmarshals JSON data into v.
+func mustDecode(t *testing.T, data []byte, v any) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+}
+
+func TestCreateContainerValidatesSpec(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, tc := range []struct {
+		id, name string
+		memory   int
+	}{
+		{"web", "web", -1},
+		{"web", "web", 0},
+		{"", "web", 512},
+		{"web", "", 512},
+	} {
+		if _, err := k.CreateContainer(tc.id, tc.name, tc.memory); !errors.Is(err, ErrInvalidContainerSpec) {
+			t.Errorf("CreateContainer(%q, %q, %d) = %v, want ErrInvalidContainerSpec", tc.id, tc.name, tc.memory, err)
+		}
+	}
+	if len(k.Containers) != 0 {
+		t.Errorf("%d containers registered from invalid specs", len(k.Containers))
+	}
+}
+
+func TestRenameContainer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "a")
+	newTestContainer(t, k, "b")
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventContainerRenamed}})
+	defer cancel()
+
+	if err := k.RenameContainer("a", "frontend"); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.Containers["a"].Inspect().Name; got != "frontend" {
+		t.Errorf("name = %q, want frontend", got)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "a" || e.Detail != "frontend" {
+		t.Errorf("event = %+v", e)
+	}
+
+	// Duplicates are allowed until uniqueness is required.
+	if err := k.RenameContainer("b", "frontend"); err != nil {
+		t.Fatal(err)
+	}
+	k.RequireUniqueNames = true
+	if err := k.RenameContainer("b", "backend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.RenameContainer("b", "backend"); err != nil {
+		t.Errorf("renaming to its own name: %v", err)
+	}
+	if err := k.RenameContainer("b", "frontend"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("duplicate rename: %v, want ErrNameTaken", err)
+	}
+	if _, err := k.CreateContainer("c", "frontend", 512); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("duplicate create: %v, want ErrNameTaken", err)
+	}
+	if err := k.RenameContainer("nope", "x"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("unknown container: %v", err)
+	}
+	if err := k.RenameContainer("a", ""); !errors.Is(err, ErrInvalidContainerSpec) {
+		t.Errorf("empty name: %v", err)
+	}
+}
+
+func TestMonitorToWritesEachCycle(t *testing.T) {
+	k, clk := newTestKernel(t)
+	db := newTestContainer(t, k, "db")
+	newTestContainer(t, k, "web")
+	db.SetCPULoad(12.5)
+
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- k.MonitorTo(&buf, time.Second, 2) }()
+	for i := 0; i < 2; i++ {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(time.Second)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("MonitorTo did not finish its cycles")
+	}
+
+	cycle := "=== Kernel Monitoring ===\n" +
+		"Container db | Memory: 1024MB | CPU: 12.50% | Running Processes: 0\n" +
+		"Container web | Memory: 1024MB | CPU: 0.00% | Running Processes: 0\n"
+	if got, want := buf.String(), strings.Repeat(cycle, 2); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAddLabelMakesSelectorMatch(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	c.SetLabels(map[string]string{"tier": "frontend"})
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventLabelsChanged}})
+	defer cancel()
+	selector := ListOptions{Labels: map[string]string{"tier": "frontend", "env": "prod"}}
+
+	if page, err := k.ListContainers(selector); err != nil || page.Total != 0 {
+		t.Fatalf("before AddLabel: %+v, %v", page, err)
+	}
+	c.AddLabel("env", "prod")
+	if page, err := k.ListContainers(selector); err != nil || page.Total != 1 {
+		t.Errorf("after AddLabel: %+v, %v", page, err)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "web" || e.Detail != "env=prod,tier=frontend" {
+		t.Errorf("event = %+v", e)
+	}
+
+	c.SetLabels(map[string]string{"env": "prod"})
+	if page, _ := k.ListContainers(selector); page.Total != 0 {
+		t.Errorf("SetLabels kept the old tier label")
+	}
+	if e := nextEvent(t, events); e.Detail != "env=prod" {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+func TestProcessDone(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	release := make(chan struct{})
+	p := &Process{Name: "work", Action: func(ctx context.Context, h *Handle) error {
+		<-release
+		return errors.New("boom")
+	}}
+	done := p.Done() // taken before the process is even added
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+		t.Fatal("Done closed while the action runs")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done not closed after the process failed")
+	}
+	if d := c.Inspect().Processes[0]; d.State != Failed || d.Err != "boom" {
+		t.Errorf("after Done: %v with error %q", d.State, d.Err)
+	}
+
+	// A channel taken after the fact is already closed, and so is one of a
+	// queued process that is stopped.
+	<-p.Done()
+	queued := &Process{Name: "queued", Action: noop}
+	c.StopProcesses()
+	c.AddProcess(queued)
+	c.StopProcesses()
+	select {
+	case <-queued.Done():
+	default:
+		t.Errorf("stopped queued process = %v, Done still open", stateOf(c, queued))
+	}
+}
+
+func TestActionReachesItsContainer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c, err := k.CreateContainer("web-1", "frontend", 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromHandle, fromCtx string
+	p := &Process{Name: "probe", Action: func(ctx context.Context, h *Handle) error {
+		fromHandle = h.Container().Inspect().Name
+		owner, ok := ContainerFromContext(ctx)
+		if !ok {
+			return errors.New("no container in the context")
+		}
+		fromCtx = owner.Inspect().Name
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Completed {
+		t.Fatalf("probe = %v (%v)", got, p.Err)
+	}
+	if fromHandle != "frontend" || fromCtx != "frontend" {
+		t.Errorf("container name through the handle %q, through the context %q; want frontend", fromHandle, fromCtx)
+	}
+	if _, ok := ContainerFromContext(context.Background()); ok {
+		t.Error("found a container in a bare context")
+	}
+}
+
+func TestProcessesByNameReturnsEveryMatch(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	first, other, second := &Process{Name: "worker"}, &Process{Name: "cron"}, &Process{Name: "worker"}
+	for _, p := range []*Process{first, other, second} {
+		c.AddProcess(p)
+	}
+	got := c.ProcessesByName("worker")
+	if len(got) != 2 || got[0] != first || got[1] != second {
+		t.Fatalf("ProcessesByName = %v, want both workers in the order added", got)
+	}
+	if got[0].PID == got[1].PID {
+		t.Errorf("same-named processes share PID %d", got[0].PID)
+	}
+	if got := c.ProcessesByName("missing"); got != nil {
+		t.Errorf("no match = %v, want nil", got)
+	}
+}
+
+func TestEachProcessWhileAdding(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "busy")
+	c.AddProcess(&Process{Name: "first"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			c.AddProcess(&Process{Name: "added"})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		seen := 0
+		c.EachProcess(func(p *Process) {
+			seen++
+			if seen == 1 && p.Name != "first" {
+				t.Errorf("first process = %s", p.Name)
+			}
+			// fn runs without the lock, so it may call back in.
+			c.QueueDepth()
+		})
+		if seen == 0 {
+			t.Fatal("EachProcess visited nothing")
+		}
+	}
+	wg.Wait()
+	n := 0
+	c.EachProcess(func(*Process) { n++ })
+	if n != 201 {
+		t.Errorf("visited %d processes, want 201", n)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Maintenance Windows ---
+
+// DefaultMaintenanceGrace is how long a maintenance window gives the
+// container's processes to stop when MaintenanceWindow.Grace is zero.
+const DefaultMaintenanceGrace = 30 * time.Second
+
+// ErrInvalidMaintenanceWindow is returned by ScheduleMaintenance for a
+// window outside the day or a task whose kind is not registered.
+var ErrInvalidMaintenanceWindow = errors.New("invalid maintenance window")
+
+// MaintenanceWindow is a daily span of the container's clock in which the
+// kernel takes the container down for maintenance Tasks, such as compaction
+// or backups.
+type MaintenanceWindow struct {
+	Name   string // reported in events; defaults to the window's times
+	Window TimeWindow
+	// Tasks are built from their Kind and queued together each time the
+	// window opens.
+	Tasks []ProcessSpec
+	// Grace is how long the container's processes get to stop; zero means
+	// DefaultMaintenanceGrace.
+	Grace time.Duration
+}
+
+func (w MaintenanceWindow) name() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return Between(w.Window.Start, w.Window.End).String()
+}
+
+// length is how long the window stays open.
+func (w MaintenanceWindow) length() time.Duration {
+	d := w.Window.End - w.Window.Start
+	if d <= 0 {
+		d += 24 * time.Hour
+	}
+	return d
+}
+
+// ScheduleMaintenance runs c's maintenance in windows, read on its clock,
+// until stop is called or the container is purged. When a window opens:
+//
+//   - a frozen or quarantined container is skipped with an
+//     EventMaintenanceSkip;
+//   - while a container c depends on, or one that depends on c, is in
+//     maintenance, c waits for it, so that overlapping windows of
+//     dependent containers are staggered; if the window closes meanwhile it
+//     is skipped;
+//   - otherwise an EventMaintenanceStart is emitted, the container is
+//     drained within Grace, and the Tasks are queued through the
+//     scheduler. Once they have finished, or the window closes and the
+//     rest are stopped, the processes the drain stopped are queued again,
+//     the container returns to its previous state and an
+//     EventMaintenanceEnd reports the tasks' outcomes.
+func (c *Container) ScheduleMaintenance(windows ...MaintenanceWindow) (stop func(), err error) {
+	k := c.kernel
+	if k == nil {
+		return nil, fmt.Errorf("%w: container %s has no kernel", ErrInvalidMaintenanceWindow, c.ID)
+	}
+	k.mu.Lock()
+	for _, w := range windows {
+		for _, d := range []time.Duration{w.Window.Start, w.Window.End} {
+			if d < 0 || d >= 24*time.Hour {
+				k.mu.Unlock()
+				return nil, fmt.Errorf("%w: %s: %v is not within a day", ErrInvalidMaintenanceWindow, w.name(), d)
+			}
+		}
+		for _, spec := range w.Tasks {
+			if _, _, _, err := k.resolveKindLocked(spec.Kind); err != nil {
+				k.mu.Unlock()
+				return nil, fmt.Errorf("%w: %s: task %s: %w", ErrInvalidMaintenanceWindow, w.name(), spec.Name, err)
+			}
+		}
+	}
+	k.mu.Unlock()
+	done := make(chan struct{})
+	go c.maintenanceLoop(slices.Clone(windows), done)
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+func (c *Container) maintenanceLoop(windows []MaintenanceWindow, done <-chan struct{}) {
+	k := c.kernel
+	var last time.Time // when the last window opened
+	for {
+		from := c.LocalNow()
+		if from.Before(last) {
+			from = last
+		}
+		var w MaintenanceWindow
+		var open time.Time
+		for _, mw := range windows {
+			next := Between(mw.Window.Start, mw.Window.End).NextOpen(from)
+			if open.IsZero() || next.Before(open) {
+				w, open = mw, next
+			}
+		}
+		if open.IsZero() {
+			return
+		}
+		select {
+		case <-k.Clock.After(c.kernelDuration(open.Sub(c.LocalNow()))):
+		case <-done:
+			return
+		}
+		last = open
+
+		k.mu.Lock()
+		registered := k.Containers[c.ID] == c
+		t := k.trash[c.ID]
+		k.mu.Unlock()
+		switch {
+		case registered:
+			c.maintain(w, open.Add(w.length()), done)
+		case t == nil || t.c != c:
+			return // purged
+		}
+	}
+}
+
+// maintain runs one window of c's maintenance that closes at end, on c's
+// clock.
+func (c *Container) maintain(w MaintenanceWindow, end time.Time, done <-chan struct{}) {
+	k := c.kernel
+	c.mu.Lock()
+	reason := ""
+	switch {
+	case c.quarantined:
+		reason = "container is quarantined"
+	case c.frozen:
+		reason = "container is frozen"
+	}
+	c.mu.Unlock()
+	if reason != "" {
+		c.skipMaintenance(w, reason)
+		return
+	}
+
+	for {
+		k.mu.Lock()
+		busy, other := k.relatedMaintenanceLocked(c)
+		if busy == nil {
+			if k.maintaining == nil {
+				k.maintaining = make(map[string]chan struct{})
+			}
+			k.maintaining[c.ID] = make(chan struct{})
+		}
+		k.mu.Unlock()
+		if busy == nil {
+			break
+		}
+		fmt.Printf("[Kernel] Maintenance %s of %s waiting for %s\n", w.name(), c.Name, other)
+		select {
+		case <-busy:
+		case <-k.Clock.After(c.kernelDuration(end.Sub(c.LocalNow()))):
+			c.skipMaintenance(w, "window closed while "+other+" was in maintenance")
+			return
+		case <-done:
+			return
+		}
+	}
+	defer func() {
+		k.mu.Lock()
+		close(k.maintaining[c.ID])
+		delete(k.maintaining, c.ID)
+		k.mu.Unlock()
+	}()
+
+	fmt.Printf("[Kernel] Maintenance %s of %s started\n", w.name(), c.Name)
+	c.emit(EventMaintenanceStart, nil, w.name())
+	c.mu.Lock()
+	prev := c.State
+	var drained []*Process
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled, Running:
+			if !p.debug {
+				drained = append(drained, p)
+			}
+		}
+	}
+	c.mu.Unlock()
+	grace := w.Grace
+	if grace <= 0 {
+		grace = DefaultMaintenanceGrace
+	}
+	c.drain(context.Background(), c.clock().After(grace), fmt.Sprintf("did not stop within %s for maintenance", grace))
+
+	k.mu.Lock()
+	c.mu.Lock()
+	var tasks []*Process
+	for _, spec := range w.Tasks {
+		p, err := k.newProcessLocked(spec)
+		if err != nil {
+			fmt.Printf("[Kernel] Maintenance %s of %s: task %s: %v\n", w.name(), c.Name, spec.Name, err)
+			continue
+		}
+		c.addProcessLocked(p)
+		tasks = append(tasks, p)
+	}
+	c.State = ContainerRunning
+	c.scheduleLocked()
+	c.mu.Unlock()
+	k.mu.Unlock()
+
+	closed := k.Clock.After(c.kernelDuration(end.Sub(c.LocalNow())))
+wait:
+	for _, p := range tasks {
+		select {
+		case <-p.Done():
+		case <-closed:
+			break wait
+		case <-done:
+			break wait
+		}
+	}
+
+	c.mu.Lock()
+	var completed, failed, stopped int
+	for _, p := range tasks {
+		switch p.State {
+		case Pending, Throttled, Running:
+			c.finishLocked(p, Stopped)
+			if p.cancel != nil {
+				p.cancel()
+			}
+			stopped++
+		case Completed:
+			completed++
+		default:
+			failed++
+		}
+	}
+	c.requeueLocked(drained)
+	if c.State == ContainerRunning {
+		c.State = prev
+	}
+	if c.State == ContainerRunning {
+		c.scheduleLocked()
+	}
+	c.mu.Unlock()
+	detail := fmt.Sprintf("%s: %d tasks completed, %d failed, %d stopped", w.name(), completed, failed, stopped)
+	fmt.Printf("[Kernel] Maintenance %s of %s finished: %d completed, %d failed, %d stopped\n", w.name(), c.Name, completed, failed, stopped)
+	c.emit(EventMaintenanceEnd, nil, detail)
+}
+
+func (c *Container) skipMaintenance(w MaintenanceWindow, reason string) {
+	fmt.Printf("[Kernel] Maintenance %s of %s skipped: %s\n", w.name(), c.Name, reason)
+	c.emit(EventMaintenanceSkip, nil, w.name()+": "+reason)
+}
+
+// relatedMaintenanceLocked returns the ID of a container in maintenance
+// that c depends on or that depends on c, and a channel closed when its
+// maintenance ends; nil if there is none.
+func (k *Kernel) relatedMaintenanceLocked(c *Container) (<-chan struct{}, string) {
+	c.mu.Lock()
+	deps := slices.Clone(c.DependsOn)
+	c.mu.Unlock()
+	for id, ch := range k.maintaining {
+		if slices.Contains(deps, id) {
+			return ch, id
+		}
+		o, ok := k.Containers[id]
+		if !ok || o == c {
+			continue
+		}
+		o.mu.Lock()
+		dependent := slices.Contains(o.DependsOn, c.ID)
+		o.mu.Unlock()
+		if dependent {
+			return ch, id
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowDrainsRunsTasksAndResumes(t *testing.T) {
+	k, clk := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventMaintenanceStart, EventMaintenanceEnd, EventMaintenanceSkip}})
+	defer cancel()
+	c := newTestContainer(t, k, "db")
+	server := &Process{Name: "server", Action: blockUntil(nil)}
+	c.AddProcess(server)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	eventually(t, "server running", func() bool { return stateOf(c, server) == Running })
+	serverDuringTask := make(chan ProcessState, 1)
+	k.RegisterKind("compact", func(ProcessSpec) *Process {
+		return &Process{Action: func(context.Context, *Handle) error {
+			serverDuringTask <- stateOf(c, server)
+			return nil
+		}}
+	})
+	stop, err := c.ScheduleMaintenance(MaintenanceWindow{
+		Window: TimeWindow{Start: 2 * time.Hour, End: 3 * time.Hour},
+		Tasks:  []ProcessSpec{{Name: "compact", Kind: "compact"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitForWaiters(t, clk, 1)
+	clk.Advance(2 * time.Hour)
+	if e := nextEvent(t, events); e.Kind != EventMaintenanceStart || e.Detail != "02:00-03:00" {
+		t.Fatalf("event = %+v, want the window to start", e)
+	}
+	if e := nextEvent(t, events); e.Kind != EventMaintenanceEnd || e.Detail != "02:00-03:00: 1 tasks completed, 0 failed, 0 stopped" {
+		t.Errorf("event = %+v, want the window to end", e)
+	}
+	if got := <-serverDuringTask; got == Running {
+		t.Error("server kept running during maintenance")
+	}
+	eventually(t, "server running again", func() bool { return stateOf(c, server) == Running })
+	if got := c.Inspect().State; got != ContainerRunning {
+		t.Errorf("container = %v after maintenance", got)
+	}
+}
+
+func TestMaintenanceWindowSkipsFrozenContainer(t *testing.T) {
+	k, clk := newTestKernel(t)
+	registerScriptKinds(k, nil)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventFrozen, EventMaintenanceStart, EventMaintenanceSkip}})
+	defer cancel()
+	c := newTestContainer(t, k, "db")
+	c.ErrorBudget = ErrorBudget{Failures: 1}
+	procs := failing(2)
+	for _, p := range procs {
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventFrozen {
+		t.Fatalf("event = %+v, want the container frozen", e)
+	}
+	stop, err := c.ScheduleMaintenance(MaintenanceWindow{
+		Name:   "nightly",
+		Window: TimeWindow{Start: 2 * time.Hour, End: 3 * time.Hour},
+		Tasks:  []ProcessSpec{{Name: "backup", Kind: "task"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitForWaiters(t, clk, 1)
+	clk.Advance(2 * time.Hour)
+	if e := nextEvent(t, events); e.Kind != EventMaintenanceSkip || e.Detail != "nightly: container is frozen" {
+		t.Errorf("event = %+v, want the window skipped", e)
+	}
+	if n := len(c.Inspect().Processes); n != 2 {
+		t.Errorf("%d processes after a skipped window, want no tasks added", n)
+	}
+}
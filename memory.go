@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Container Memory Pressure ---
+
+// MemoryPressurePolicy controls how a container reacts as its processes'
+// memory usage approaches MemoryMB, the container's limit.
+type MemoryPressurePolicy struct {
+	// SoftLimitRatio is the fraction of the limit at which running processes
+	// receive MemoryPressure signals. Zero disables pressure signals.
+	SoftLimitRatio float64
+	// GraceWindow is how long usage may stay above the hard limit before
+	// processes are OOM-killed.
+	GraceWindow time.Duration
+	// SignalInterval rate-limits pressure signals.
+	SignalInterval time.Duration
+}
+
+// DefaultMemoryPressurePolicy signals at 80% of the limit, at most once a
+// second, and OOM-kills after five seconds over the limit.
+func DefaultMemoryPressurePolicy() MemoryPressurePolicy {
+	return MemoryPressurePolicy{
+		SoftLimitRatio: 0.8,
+		GraceWindow:    5 * time.Second,
+		SignalInterval: time.Second,
+	}
+}
+
+// SetMemory changes the container's memory limit to mb. Shrinking below
+// current usage arms the OOM grace timer as if usage had grown. Growing
+// runs a scheduling pass, so processes held Pending for want of memory
+// are reconsidered at once rather than on the next unrelated pass.
+func (c *Container) SetMemory(mb int) error {
+	if mb <= 0 {
+		return fmt.Errorf("%w: container %s memory must be positive, got %dMB", ErrInvalidContainerSpec, c.ID, mb)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.MemoryMB
+	if mb == old {
+		return nil
+	}
+	c.MemoryMB = mb
+	fmt.Printf("[Kernel] Container %s memory limit %dMB -> %dMB\n", c.Name, old, mb)
+	c.emit(EventMemoryLimitSet, nil, fmt.Sprintf("%dMB -> %dMB", old, mb))
+	if mb > old {
+		c.scheduleLocked()
+		return nil
+	}
+	c.checkMemoryLocked()
+	return nil
+}
+
+// MemoryUsageMB reports the summed memory usage of running processes.
+func (c *Container) MemoryUsageMB() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.memoryUsageLocked()
+}
+
+func (c *Container) memoryUsageLocked() int {
+	used := 0
+	for _, p := range c.Processes {
+		if p.State == Running && !p.debug {
+			used += p.usedMB
+		}
+	}
+	return used
+}
+
+func (c *Container) adjustMemory(p *Process, deltaMB int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p.usedMB += deltaMB
+	if p.usedMB < 0 {
+		p.usedMB = 0
+	}
+	p.peakMB = max(p.peakMB, p.usedMB)
+	c.checkMemoryLocked()
+}
+
+// checkMemoryLocked raises pressure signals once usage crosses the soft
+// threshold and arms the OOM grace timer once it crosses the hard limit.
+func (c *Container) checkMemoryLocked() {
+	c.observeLoadLocked()
+	if c.kernel != nil {
+		c.kernel.scheduleCeilingCheck()
+	}
+	limit := c.MemoryMB
+	if limit <= 0 {
+		return
+	}
+	now := c.now()
+	usage := c.memoryUsageLocked()
+	policy := c.MemoryPressure
+
+	soft := int(float64(limit) * policy.SoftLimitRatio)
+	if policy.SoftLimitRatio > 0 && usage >= soft &&
+		(c.lastPressure.IsZero() || now.Sub(c.lastPressure) >= policy.SignalInterval) {
+		c.lastPressure = now
+		sig := Signal{Kind: SignalMemoryPressure, UsageMB: usage, LimitMB: limit}
+		for _, p := range c.Processes {
+			if p.State == Running && p.handle != nil {
+				p.handle.signal(sig)
+			}
+		}
+		c.emit(EventMemoryPressure, nil, fmt.Sprintf("usage %dMB of %dMB", usage, limit))
+	}
+
+	if usage <= limit {
+		c.overLimitSince = time.Time{}
+		return
+	}
+	if c.overLimitSince.IsZero() {
+		c.overLimitSince = now
+		if c.kernel != nil {
+			after := c.kernel.Clock.After(policy.GraceWindow)
+			go func() {
+				<-after
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				c.enforceMemoryLocked()
+			}()
+		}
+	}
+}
+
+// enforceMemoryLocked OOM-kills the largest processes once usage has stayed
+// above the limit for the whole grace window.
+func (c *Container) enforceMemoryLocked() {
+	if c.overLimitSince.IsZero() || c.now().Sub(c.overLimitSince) < c.MemoryPressure.GraceWindow {
+		return
+	}
+	c.overLimitSince = time.Time{}
+	var running []*Process
+	for _, p := range c.Processes {
+		if p.State == Running && !p.debug {
+			running = append(running, p)
+		}
+	}
+	sort.SliceStable(running, func(i, j int) bool {
+		return running[i].usedMB > running[j].usedMB
+	})
+	usage := c.memoryUsageLocked()
+	for _, p := range running {
+		if usage <= c.MemoryMB {
+			break
+		}
+		usage -= p.usedMB
+		c.killLocked(p, fmt.Sprintf("OOM: container %s over %dMB limit", c.Name, c.MemoryMB))
+		c.emit(EventOOMKill, p, fmt.Sprintf("freed %dMB", p.usedMB))
+	}
+}
+
+// killLocked marks p Killed and cancels its context.
+func (c *Container) killLocked(p *Process, reason string) {
+	c.finishLocked(p, Killed)
+	if p.cancel != nil {
+		p.cancel()
+	}
+	fmt.Printf("[Kernel] Killed process %s in %s: %s\n", p.Name, c.Name, reason)
+	c.emit(EventProcessKilled, p, reason)
+}
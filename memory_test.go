@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pressureContainer returns a running 100MB container with the default
+// pressure policy: signals from 80MB, OOM kills after 5s over 100MB.
+func pressureContainer(t *testing.T) (*Kernel, *FakeClock, *Container) {
+	t.Helper()
+	k, clk := newTestKernel(t)
+	c, err := k.CreateContainer("app", "app", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	return k, clk, c
+}
+
+func TestCooperativeProcessAvoidsOOM(t *testing.T) {
+	k, clk, c := pressureContainer(t)
+	kills, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventOOMKill}})
+	defer cancel()
+
+	relieved := make(chan Signal, 1)
+	p := &Process{Name: "cache", MemoryMB: 50, Action: func(ctx context.Context, h *Handle) error {
+		h.AllocateMemory(70)
+		sig := <-h.Signals()
+		h.ReleaseMemory(40)
+		relieved <- sig
+		<-ctx.Done()
+		return nil
+	}}
+	c.AddProcess(p)
+
+	var sig Signal
+	select {
+	case sig = <-relieved:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pressure signal")
+	}
+	if sig.Kind != SignalMemoryPressure || sig.UsageMB != 120 || sig.LimitMB != 100 {
+		t.Errorf("signal = %+v, want MemoryPressure at 120MB of 100MB", sig)
+	}
+	clk.Advance(10 * time.Second)
+	time.Sleep(20 * time.Millisecond) // let a (wrongly) armed OOM timer run
+	if got := stateOf(c, p); got != Running {
+		t.Fatalf("cache = %v, want Running", got)
+	}
+	if len(kills) != 0 {
+		t.Errorf("OOM kill after memory was released: %+v", <-kills)
+	}
+	if got := c.MemoryUsageMB(); got != 80 {
+		t.Errorf("usage = %dMB, want 80", got)
+	}
+	c.StopProcesses()
+}
+
+func TestIgnoringProcessIsKilledAfterGrace(t *testing.T) {
+	k, clk, c := pressureContainer(t)
+	kills, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventOOMKill}})
+	defer cancel()
+
+	allocated := make(chan struct{})
+	p := &Process{Name: "hog", MemoryMB: 50, Action: func(ctx context.Context, h *Handle) error {
+		h.AllocateMemory(70)
+		close(allocated)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	c.AddProcess(p)
+	<-allocated
+
+	clk.Advance(4 * time.Second)
+	if got := stateOf(c, p); got != Running {
+		t.Fatalf("hog = %v within the grace window, want Running", got)
+	}
+	clk.Advance(time.Second)
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Killed {
+		t.Errorf("hog = %v, want Killed", got)
+	}
+	if e := nextEvent(t, kills); e.Process != "hog" {
+		t.Errorf("OOM kill of %q, want hog", e.Process)
+	}
+}
+
+func TestPressureSignalsAreRateLimited(t *testing.T) {
+	k, clk, c := pressureContainer(t)
+	pressure, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventMemoryPressure}})
+	defer cancel()
+
+	step := make(chan int)
+	done := make(chan struct{})
+	p := &Process{Name: "grow", MemoryMB: 70, Action: func(ctx context.Context, h *Handle) error {
+		for mb := range step {
+			h.AllocateMemory(mb)
+			done <- struct{}{}
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	grow := func(mb int) {
+		step <- mb
+		<-done
+	}
+
+	grow(10) // 80MB: first signal
+	grow(5)  // 85MB: within SignalInterval
+	if n := len(pressure); n != 1 {
+		t.Fatalf("%d pressure events, want 1", n)
+	}
+	clk.Advance(time.Second)
+	grow(1)
+	if n := len(pressure); n != 2 {
+		t.Errorf("%d pressure events after SignalInterval, want 2", n)
+	}
+	close(step)
+	waitDone(t, p)
+}
+
+func TestSetMemoryReadmitsProcessesHeldForMemory(t *testing.T) {
+	k, _, c := pressureContainer(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventMemoryLimitSet}})
+	defer cancel()
+	resident := &Process{Name: "resident", MemoryMB: 60, Action: blockUntil(nil)}
+	c.AddProcess(resident)
+	defer c.StopProcesses()
+	big := &Process{Name: "big", MemoryMB: 150, Action: noop}
+	c.AddProcess(big)
+	if d := c.Inspect().Processes[1]; d.State != Pending || d.WaitReason != WaitMemory {
+		t.Fatalf("big = %v waiting for %q, want held back", d.State, d.WaitReason)
+	}
+
+	// 60MB in use plus 150MB still does not fit.
+	if err := c.SetMemory(200); err != nil {
+		t.Fatal(err)
+	}
+	if got := stateOf(c, big); got != Pending {
+		t.Errorf("big = %v at 200MB, want still held back", got)
+	}
+	// No StartProcesses: growing the limit alone reconsiders it.
+	if err := c.SetMemory(300); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, big)
+	if got := stateOf(c, big); got != Completed {
+		t.Errorf("big = %v after the limit grew", got)
+	}
+	for _, want := range []string{"100MB -> 200MB", "200MB -> 300MB"} {
+		if e := nextEvent(t, events); e.Detail != want {
+			t.Errorf("event = %+v, want %q", e, want)
+		}
+	}
+	if err := c.SetMemory(0); !errors.Is(err, ErrInvalidContainerSpec) {
+		t.Errorf("SetMemory(0): %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Mailboxes ---
+
+// Message is a delivered inter-container message.
+type Message struct {
+	ID      uint64
+	From    string
+	To      string
+	Payload string
+	SentAt  time.Time
+
+	// RequestID is set on messages sent with Handle.Request and on their
+	// replies; Priority is the requester's effective priority.
+	RequestID uint64
+	Priority  int
+
+	// TraceID groups the messages of one causal chain; it is the ID of the
+	// message that started the chain. CausationID is the message being
+	// handled by the sender when this one was sent, zero for a root.
+	TraceID     uint64
+	CausationID uint64
+
+	// Topic is set on messages delivered through a topic subscription, and
+	// Offset to the message's position in the topic, from 1. Replayed
+	// marks a retained message replayed to a new subscriber; its SentAt is
+	// when it was first published.
+	Topic    string
+	Offset   uint64
+	Replayed bool
+
+	// Cached is set on replies served from the receiver's request cache.
+	Cached bool
+}
+
+// Inbox returns a copy of the messages delivered to the container, oldest
+// first.
+func (c *Container) Inbox() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.inbox...)
+}
+
+// DrainInbox removes and returns the messages delivered to the container
+// but not yet received, oldest first, e.g. to handle or log them when
+// stopping it. Their receipts count them as consumed.
+func (c *Container) DrainInbox() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drained := c.inbox
+	c.inbox, c.inboxBytes = nil, 0
+	c.observeLoadLocked()
+	if k := c.kernel; k != nil {
+		now := k.Clock.Now()
+		for _, m := range drained {
+			k.receipts.update(m.ID, MessageConsumed, "drained", now)
+		}
+	}
+	return drained
+}
+
+// --- Mailbox Limits ---
+
+var (
+	ErrPayloadTooLarge = errors.New("message payload too large")
+	ErrMailboxFull     = errors.New("mailbox full")
+)
+
+// payloadLimitLocked is the largest payload c accepts, zero for no limit.
+func (c *Container) payloadLimitLocked() int {
+	if c.MaxPayloadBytes > 0 {
+		return c.MaxPayloadBytes
+	}
+	if c.kernel != nil {
+		return c.kernel.MaxPayloadBytes
+	}
+	return 0
+}
+
+// admitMessageLocked checks m against the receiver's payload limit and
+// mailbox bounds.
+func (c *Container) admitMessageLocked(m Message) error {
+	size := len(m.Payload)
+	if limit := c.payloadLimitLocked(); limit > 0 && size > limit {
+		return fmt.Errorf("%w: %d bytes to %s exceeds its limit of %d", ErrPayloadTooLarge, size, c.ID, limit)
+	}
+	if c.MailboxLimit > 0 && len(c.inbox) >= c.MailboxLimit {
+		return fmt.Errorf("%w: %s holds %d messages, limit %d", ErrMailboxFull, c.ID, len(c.inbox), c.MailboxLimit)
+	}
+	if c.MailboxMaxBytes > 0 && c.inboxBytes+size > c.MailboxMaxBytes {
+		return fmt.Errorf("%w: %s holds %d bytes, %d more would exceed its limit of %d", ErrMailboxFull, c.ID, c.inboxBytes, size, c.MailboxMaxBytes)
+	}
+	return nil
+}
+
+// undeliverable reports whether a failed delivery was refused by the
+// receiver's limits, so that fan-out sends dead-letter it.
+func undeliverable(err error) bool {
+	return errors.Is(err, ErrPayloadTooLarge) || errors.Is(err, ErrMailboxFull)
+}
+
+// --- Broadcast ---
+
+// Broadcast delivers payload from fromID to every other container, in ID
+// order, and returns how many copies were delivered.
+func (k *Kernel) Broadcast(fromID, payload string) (int, error) {
+	return k.broadcast(Message{From: fromID, Payload: payload})
+}
+
+// broadcast delivers a copy of m to every container but the sender. All
+// copies share m's trace and cause; if m has no trace yet, one is started
+// before the fan-out.
+func (k *Kernel) broadcast(m Message) (int, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return 0, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Containers[m.From]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrContainerNotFound, m.From)
+	}
+	if m.TraceID == 0 {
+		m.TraceID = k.lastMsgID.Add(1)
+	}
+	n := 0
+	for _, c := range k.sortedContainersLocked() {
+		if c.ID == m.From {
+			continue
+		}
+		m.To = c.ID
+		if _, err := k.deliverLocked(m, ""); err == nil {
+			n++
+		} else if undeliverable(err) {
+			k.deadLetterLocked(m, err.Error())
+		}
+	}
+	return n, nil
+}
+
+// --- Dead Letters ---
+
+// deadLetterLimit bounds the dead-letter queue; the oldest entries are
+// dropped first.
+const deadLetterLimit = 1024
+
+// DeadLetter is a message that could not be delivered.
+type DeadLetter struct {
+	Message Message
+	Reason  string
+	At      time.Time
+}
+
+// DeadLetters returns the undeliverable messages, oldest first.
+func (k *Kernel) DeadLetters() []DeadLetter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]DeadLetter(nil), k.deadLetters...)
+}
+
+func (k *Kernel) deadLetterLocked(m Message, reason string) {
+	if m.ID == 0 {
+		m.ID = k.lastMsgID.Add(1)
+	}
+	if m.TraceID == 0 {
+		m.TraceID = m.ID
+	}
+	k.traces.record(m, TraceDeadLettered, reason)
+	k.receipts.record(m, MessageDropped, reason, k.Clock.Now())
+	k.deadLetters = append(k.deadLetters, DeadLetter{Message: m, Reason: reason, At: k.Clock.Now()})
+	if len(k.deadLetters) > deadLetterLimit {
+		k.deadLetters = k.deadLetters[len(k.deadLetters)-deadLetterLimit:]
+	}
+	fmt.Printf("[Kernel] Dead letter %s -> %s: %s\n", m.From, m.To, reason)
+	k.publish(Event{Kind: EventDeadLetter, ContainerID: m.To, Detail: reason, TraceID: m.TraceID})
+}
+
+// --- Scheduled Messages ---
+
+// ScheduledMessage is a message held by the kernel until DueAt.
+type ScheduledMessage struct {
+	ID      uint64
+	From    string
+	To      string
+	Payload string
+	DueAt   time.Time
+
+	// MessageID is the ID the message will be delivered with; see
+	// Kernel.MessageStatus.
+	MessageID uint64
+
+	// TraceID and CausationID are carried over to the delivered message.
+	TraceID     uint64
+	CausationID uint64
+
+	kernel    *Kernel
+	cancelled chan struct{}
+}
+
+// SendAfter delivers payload from fromID to toID once delay has passed on
+// the kernel clock. Service destinations are resolved at delivery time.
+// A message whose destination is gone by then goes to the dead-letter
+// queue.
+func (k *Kernel) SendAfter(fromID, toID, payload string, delay time.Duration) (*ScheduledMessage, error) {
+	return k.sendAfter(Message{From: fromID, To: toID, Payload: payload}, delay)
+}
+
+// sendAfter schedules m, which carries From, To, Payload and its trace.
+func (k *Kernel) sendAfter(m Message, delay time.Duration) (*ScheduledMessage, error) {
+	fromID := m.From
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Containers[fromID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, fromID)
+	}
+	sm := &ScheduledMessage{
+		ID:        k.lastTimerID.Add(1),
+		MessageID: k.lastMsgID.Add(1),
+		From:      fromID,
+		To:        m.To,
+		Payload:   m.Payload,
+		DueAt:     k.Clock.Now().Add(delay),
+
+		TraceID:     m.TraceID,
+		CausationID: m.CausationID,
+		kernel:      k,
+		cancelled:   make(chan struct{}),
+	}
+	k.scheduled[sm.ID] = sm
+	k.receipts.record(Message{ID: sm.MessageID, From: fromID, To: m.To}, MessageQueued, "", k.Clock.Now())
+	// Arm the timer before returning so that a fake clock advanced right
+	// after the call still fires it.
+	due := k.Clock.After(delay)
+	go func() {
+		select {
+		case <-due:
+			k.deliverScheduled(sm)
+		case <-sm.cancelled:
+		}
+	}()
+	return sm, nil
+}
+
+// SendAt is SendAfter with an absolute delivery time.
+func (k *Kernel) SendAt(fromID, toID, payload string, at time.Time) (*ScheduledMessage, error) {
+	return k.SendAfter(fromID, toID, payload, at.Sub(k.Clock.Now()))
+}
+
+// Cancel withdraws the message. It reports false if the message was
+// already delivered or cancelled.
+func (sm *ScheduledMessage) Cancel() bool {
+	k := sm.kernel
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.scheduled[sm.ID]; !ok {
+		return false
+	}
+	delete(k.scheduled, sm.ID)
+	close(sm.cancelled)
+	k.receipts.update(sm.MessageID, MessageDropped, "cancelled", k.Clock.Now())
+	return true
+}
+
+// ScheduledMessages lists the messages waiting for delivery, soonest first.
+func (k *Kernel) ScheduledMessages() []ScheduledMessage {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	out := make([]ScheduledMessage, 0, len(k.scheduled))
+	for _, sm := range k.scheduled {
+		out = append(out, ScheduledMessage{ID: sm.ID, MessageID: sm.MessageID, From: sm.From, To: sm.To, Payload: sm.Payload, DueAt: sm.DueAt, TraceID: sm.TraceID, CausationID: sm.CausationID})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].DueAt.Equal(out[j].DueAt) {
+			return out[i].DueAt.Before(out[j].DueAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+func (k *Kernel) deliverScheduled(sm *ScheduledMessage) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.scheduled[sm.ID]; !ok {
+		return
+	}
+	delete(k.scheduled, sm.ID)
+	m := Message{ID: sm.MessageID, From: sm.From, To: sm.To, Payload: sm.Payload, TraceID: sm.TraceID, CausationID: sm.CausationID}
+	if _, err := k.deliverLocked(m, ""); err != nil {
+		m.SentAt = k.Clock.Now()
+		k.deadLetterLocked(m, err.Error())
+	}
+}
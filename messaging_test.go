@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSelfMessageRejectedByDefault(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "loop")
+	if err := k.SendMessage("loop", "loop", "hi"); !errors.Is(err, ErrSelfMessage) {
+		t.Fatalf("self-send: %v, want ErrSelfMessage", err)
+	}
+	if n := len(c.Inbox()); n != 0 {
+		t.Errorf("rejected self-send left %d messages", n)
+	}
+
+	k.AllowSelfMessage = true
+	if err := k.SendMessage("loop", "loop", "hi"); err != nil {
+		t.Fatalf("self-send with AllowSelfMessage: %v", err)
+	}
+	if inbox := c.Inbox(); len(inbox) != 1 || inbox[0].Payload != "hi" {
+		t.Errorf("inbox = %+v", inbox)
+	}
+}
+
+func TestSelfMessageThroughService(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "echo", "loop")
+	if err := k.SendMessage("loop", "svc:echo", "hi"); !errors.Is(err, ErrSelfMessage) {
+		t.Errorf("send to own service: %v, want ErrSelfMessage", err)
+	}
+}
+
+func TestSendAfterDeliversWhenDue(t *testing.T) {
+	k, clk := newTestKernel(t)
+	inbox := newSink(t, k)
+	newTestContainer(t, k, "cron")
+	sm, err := k.SendAfter("cron", "sink", "tick", 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := k.ScheduledMessages(); len(got) != 1 || got[0].ID != sm.ID || !got[0].DueAt.Equal(testEpoch.Add(10*time.Second)) {
+		t.Fatalf("scheduled = %+v", got)
+	}
+
+	clk.Advance(9 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if got := inbox(); got != "[]" {
+		t.Fatalf("delivered %s a second early", got)
+	}
+	clk.Advance(time.Second)
+	eventually(t, "the scheduled message", func() bool { return inbox() == "[tick]" })
+	if got := k.ScheduledMessages(); len(got) != 0 {
+		t.Errorf("still scheduled after delivery: %+v", got)
+	}
+	if sm.Cancel() {
+		t.Error("cancelled a delivered message")
+	}
+}
+
+func TestScheduledMessageCancel(t *testing.T) {
+	k, clk := newTestKernel(t)
+	inbox := newSink(t, k)
+	newTestContainer(t, k, "cron")
+	sm, err := k.SendAt("cron", "sink", "tick", testEpoch.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sm.Cancel() {
+		t.Fatal("Cancel reported the message already gone")
+	}
+	clk.Advance(time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	if got := inbox(); got != "[]" {
+		t.Errorf("cancelled message delivered: %s", got)
+	}
+	if sm.Cancel() {
+		t.Error("cancelled twice")
+	}
+}
+
+func TestScheduledMessageToRemovedContainerIsDeadLettered(t *testing.T) {
+	k, clk := newTestKernel(t)
+	newSink(t, k)
+	newTestContainer(t, k, "cron")
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventDeadLetter}})
+	defer cancel()
+	sm, err := k.SendAfter("cron", "sink", "tick", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.RemoveContainer("sink"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	e := nextEvent(t, events)
+	dl := k.DeadLetters()
+	if len(dl) != 1 || dl[0].Message.ID != sm.MessageID || dl[0].Message.Payload != "tick" {
+		t.Fatalf("dead letters = %+v", dl)
+	}
+	if !strings.Contains(dl[0].Reason, ErrContainerNotFound.Error()) || e.Detail != dl[0].Reason {
+		t.Errorf("reason %q, event %+v", dl[0].Reason, e)
+	}
+}
+
+func TestPayloadLimitOverridesKernelDefault(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.MaxPayloadBytes = 4
+	for _, id := range []string{"sender", "strict", "roomy"} {
+		newTestContainer(t, k, id).AddProcess(&Process{Name: "listener"})
+	}
+	k.Containers["roomy"].MaxPayloadBytes = 16
+
+	err := k.SendMessage("sender", "strict", "12345")
+	if !errors.Is(err, ErrPayloadTooLarge) || !strings.Contains(err.Error(), "5 bytes to strict exceeds its limit of 4") {
+		t.Errorf("over the default: %v", err)
+	}
+	if err := k.SendMessage("sender", "roomy", "12345"); err != nil {
+		t.Errorf("within the override: %v", err)
+	}
+	if err := k.SendMessage("sender", "roomy", strings.Repeat("x", 17)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("over the override: %v", err)
+	}
+
+	// A broadcast delivers where it fits and dead-letters the rest.
+	if n, err := k.Broadcast("sender", "1234567"); n != 1 || err != nil {
+		t.Errorf("Broadcast = %d, %v; want 1 delivery", n, err)
+	}
+	dead := k.DeadLetters()
+	if len(dead) != 1 || dead[0].Message.To != "strict" || !strings.Contains(dead[0].Reason, "limit of 4") {
+		t.Errorf("dead letters = %+v, want the copy for strict", dead)
+	}
+}
+
+func TestMailboxByteBound(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "sender")
+	c := newTestContainer(t, k, "inbox")
+	c.AddProcess(&Process{Name: "listener"})
+	c.MailboxLimit = 10
+	c.MailboxMaxBytes = 8
+	for _, msg := range []string{"abc", "defg"} {
+		if err := k.SendMessage("sender", "inbox", msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.SendMessage("sender", "inbox", "hi"); !errors.Is(err, ErrMailboxFull) {
+		t.Errorf("9 bytes into an 8-byte mailbox with 2 of 10 messages: %v", err)
+	}
+	if err := k.SendMessage("sender", "inbox", "!"); err != nil {
+		t.Errorf("a message that fits: %v", err)
+	}
+	if got := c.Inspect().InboxBytes; got != 8 {
+		t.Errorf("InboxBytes = %d, want 8", got)
+	}
+	if got := k.Stats().MailboxBytes; got != 8 {
+		t.Errorf("Stats().MailboxBytes = %d, want 8", got)
+	}
+}
+
+// meshKernel returns a kernel with n containers, c0 to c(n-1), each able
+// to receive.
+func meshKernel(tb testing.TB, n int) *Kernel {
+	k, _ := newTestKernel(tb)
+	for i := 0; i < n; i++ {
+		newTestContainer(tb, k, fmt.Sprintf("c%d", i)).AddProcess(&Process{Name: "listener"})
+	}
+	return k
+}
+
+func BenchmarkSendMessageParallel(b *testing.B) {
+	const n = 100
+	k := meshKernel(b, n)
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := int(next.Add(1))
+			if err := k.SendMessage(fmt.Sprintf("c%d", i%n), fmt.Sprintf("c%d", (i+1)%n), "ping"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestConcurrentKernelOperations exercises the kernel and container locks
+// together; run it with -race.
+func TestConcurrentKernelOperations(t *testing.T) {
+	const n = 10
+	k := meshKernel(t, n)
+	c0, c1 := k.Containers["c0"], k.Containers["c1"]
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f(i)
+			}
+		}()
+	}
+	for g := 0; g < 4; g++ {
+		run(func(i int) {
+			if err := k.SendMessage(fmt.Sprintf("c%d", i%n), fmt.Sprintf("c%d", (i+g+1)%n), "ping"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	run(func(i int) {
+		if err := c0.SetMemory(512 + i); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func(i int) {
+		id := fmt.Sprintf("tmp%d", i)
+		if _, err := k.CreateContainer(id, id, 64); err != nil {
+			t.Error(err)
+		}
+		if err := k.RemoveContainer(id); err != nil {
+			t.Error(err)
+		}
+	})
+	run(func(int) {
+		k.Stats()
+		c1.Inspect()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := k.MonitorTo(io.Discard, 0, 50); err != nil {
+			t.Error(err)
+		}
+	}()
+	wg.Wait()
+	total := 0
+	for i := 0; i < n; i++ {
+		total += len(k.Containers[fmt.Sprintf("c%d", i)].Inbox())
+	}
+	if total != 4*200 {
+		t.Errorf("%d messages delivered, want %d", total, 4*200)
+	}
+}
+
+func TestDrainInboxEmptiesMailbox(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "sender")
+	c := newTestContainer(t, k, "closing")
+	c.AddProcess(&Process{Name: "listener"})
+	c.MailboxMaxBytes = 6
+	for _, payload := range []string{"one", "two"} {
+		if err := k.SendMessage("sender", "closing", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := c.Inbox()
+	drained := c.DrainInbox()
+	if len(drained) != 2 || drained[0].ID != want[0].ID || drained[1].Payload != "two" {
+		t.Fatalf("drained %v, want %v", drained, want)
+	}
+	if n := len(c.Inbox()); n != 0 {
+		t.Errorf("%d messages left after draining", n)
+	}
+	if r, _ := k.MessageReceipt(drained[0].ID); r.Status != MessageConsumed || r.Reason != "drained" {
+		t.Errorf("receipt = %+v", r)
+	}
+	// The byte bound counts afresh.
+	if err := k.SendMessage("sender", "closing", "three!"); err != nil {
+		t.Errorf("send after draining: %v", err)
+	}
+	if got := c.DrainInbox(); len(got) != 1 {
+		t.Errorf("second drain = %v", got)
+	}
+	if got := c.DrainInbox(); len(got) != 0 {
+		t.Errorf("draining an empty inbox = %v", got)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "maps"
+
+// --- Custom Metrics ---
+
+// SetMetric records a named value in the process's container, replacing
+// the previous value. Metrics are container-wide and survive the process.
+func (h *Handle) SetMetric(name string, value float64) {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metrics == nil {
+		c.metrics = make(map[string]float64)
+	}
+	c.metrics[name] = value
+}
+
+// Metrics returns a copy of the custom metrics set by the container's
+// processes.
+func (c *Container) Metrics() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.metrics)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Migration ---
+
+// MigrateContainer moves container id to dest. The container's definition
+// and every process it holds are recreated on dest as fresh, pending
+// processes; work in progress does not move, since goroutines cannot.
+// Processes with a Kind are rebuilt from dest's registered kinds, so their
+// actions are rebound there; processes without one keep their Action. The
+// container is then stopped and removed on the source, and started on dest
+// if it was running.
+//
+// If dest cannot take the container (its ID is taken or a kind is not
+// registered there), the source container is left as it was.
+func (k *Kernel) MigrateContainer(id string, dest *Kernel) error {
+	if dest == k {
+		return fmt.Errorf("migrate %s: destination is the source kernel", id)
+	}
+	if err := k.checkAuthoritative(); err != nil {
+		return err
+	}
+	if err := dest.checkAuthoritative(); err != nil {
+		return err
+	}
+
+	// Freeze the source: with the container no longer running, nothing
+	// else is admitted while the copy is made.
+	k.mu.Lock()
+	src, ok := k.Containers[id]
+	if !ok {
+		k.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	src.mu.Lock()
+	prevState := src.State
+	snap := src.migrationSnapshotLocked()
+	src.State = ContainerStopped
+	src.mu.Unlock()
+	k.mu.Unlock()
+
+	c, err := dest.adoptContainer(snap)
+	if err != nil {
+		src.mu.Lock()
+		src.State = prevState
+		src.scheduleLocked()
+		src.mu.Unlock()
+		return fmt.Errorf("migrate %s: %w", id, err)
+	}
+	if err := k.PurgeContainer(id); err != nil {
+		return fmt.Errorf("migrate %s: remove from source: %w", id, err)
+	}
+	fmt.Printf("[Kernel] Migrated container %s (%d processes)\n", snap.Name, len(snap.processes))
+	if prevState == ContainerRunning {
+		c.StartProcesses()
+	}
+	return nil
+}
+
+// containerSnapshot is the part of a container that survives migration.
+type containerSnapshot struct {
+	ID, Name     string
+	MemoryMB     int
+	Labels, Env  map[string]string
+	Volumes      []string
+	DependsOn    []string
+	StopPriority int
+	BootPhase    int
+
+	MemoryPressure  MemoryPressurePolicy
+	CPULimit        float64
+	OvercommitRatio float64
+	CPUWeightLimit  float64
+	HandleBudget    int
+
+	PublishResultsTo string
+	IdleTimeout      time.Duration
+	IdleRemove       bool
+
+	MaxPayloadBytes, MailboxLimit, MailboxMaxBytes int
+	MaxRunning                                     int
+	RequestCache                                   RequestCachePolicy
+
+	skew      *clockSkew
+	processes []*Process
+}
+
+func (c *Container) migrationSnapshotLocked() containerSnapshot {
+	s := containerSnapshot{
+		ID: c.ID, Name: c.Name, MemoryMB: c.MemoryMB,
+		Labels:          copyStringMap(c.Labels),
+		Env:             copyStringMap(c.Env),
+		Volumes:         append([]string(nil), c.Volumes...),
+		DependsOn:       append([]string(nil), c.DependsOn...),
+		StopPriority:    c.StopPriority,
+		BootPhase:       c.BootPhase,
+		MemoryPressure:  c.MemoryPressure,
+		CPULimit:        c.CPULimit,
+		OvercommitRatio: c.OvercommitRatio,
+		CPUWeightLimit:  c.CPUWeightLimit,
+		HandleBudget:    c.HandleBudget,
+
+		PublishResultsTo: c.PublishResultsTo,
+		IdleTimeout:      c.IdleTimeout,
+		IdleRemove:       c.IdleRemove,
+
+		MaxPayloadBytes: c.MaxPayloadBytes,
+		MailboxLimit:    c.MailboxLimit,
+		MailboxMaxBytes: c.MailboxMaxBytes,
+		MaxRunning:      c.MaxRunning,
+		RequestCache:    c.RequestCache,
+
+		skew: c.skew.Load(),
+	}
+	for _, p := range c.Processes {
+		s.processes = append(s.processes, migratedProcess(p))
+	}
+	return s
+}
+
+// adoptContainer creates a stopped container on k from a snapshot taken on
+// another kernel, with fresh PIDs for its processes.
+func (k *Kernel) adoptContainer(s containerSnapshot) (*Container, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, taken := k.Containers[s.ID]; taken {
+		return nil, fmt.Errorf("%w: %s", ErrContainerExists, s.ID)
+	}
+	if err := k.checkNameLocked(s.ID, s.Name); err != nil {
+		return nil, err
+	}
+	missing := map[string]bool{}
+	for _, p := range s.processes {
+		if p.Kind != "" && !k.hasKindLocked(p.kindRef()) {
+			missing[p.kindRef()] = true
+		}
+	}
+	if len(missing) > 0 {
+		e := &MissingKindsError{}
+		for kind := range missing {
+			e.Kinds = append(e.Kinds, kind)
+		}
+		sort.Strings(e.Kinds)
+		return nil, e
+	}
+
+	procs := make([]*Process, 0, len(s.processes))
+	for _, p := range s.processes {
+		if p.Kind == "" {
+			procs = append(procs, p)
+			continue
+		}
+		np, err := k.newProcessLocked(ProcessSpec{Name: p.Name, Kind: p.kindRef(), Priority: p.Priority, Params: p.Params})
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, np)
+	}
+
+	c := k.createContainerLocked(s.ID, s.Name, s.MemoryMB)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.State = ContainerStopped
+	c.Labels, c.Env, c.Volumes, c.DependsOn = s.Labels, s.Env, s.Volumes, s.DependsOn
+	c.StopPriority, c.BootPhase = s.StopPriority, s.BootPhase
+	c.MemoryPressure = s.MemoryPressure
+	c.CPULimit, c.OvercommitRatio = s.CPULimit, s.OvercommitRatio
+	c.CPUWeightLimit, c.HandleBudget = s.CPUWeightLimit, s.HandleBudget
+	c.PublishResultsTo = s.PublishResultsTo
+	c.IdleTimeout, c.IdleRemove = s.IdleTimeout, s.IdleRemove
+	c.MaxPayloadBytes, c.MailboxLimit, c.MailboxMaxBytes = s.MaxPayloadBytes, s.MailboxLimit, s.MailboxMaxBytes
+	c.MaxRunning, c.RequestCache = s.MaxRunning, s.RequestCache
+	c.skew.Store(s.skew)
+	for _, p := range procs {
+		c.addProcessLocked(p)
+	}
+	return c, nil
+}
+
+// migratedProcess copies the definition of p, keeping its Action. The
+// caller holds p's container lock.
+func migratedProcess(p *Process) *Process {
+	return &Process{
+		Name:             p.Name,
+		Kind:             p.Kind,
+		KindVersion:      p.KindVersion,
+		Priority:         p.Priority,
+		Nice:             p.Nice,
+		Action:           p.Action,
+		MemoryMB:         p.MemoryMB,
+		HandleBudget:     p.HandleBudget,
+		CPUWeight:        p.CPUWeight,
+		CPUCredits:       p.CPUCredits,
+		Warmup:           p.Warmup,
+		Cooldown:         p.Cooldown,
+		Params:           copyStringMap(p.Params),
+		ConcurrencyGroup: p.ConcurrencyGroup,
+		Replicas:         p.Replicas,
+		RetainOutbox:     p.RetainOutbox,
+		Affinity:         p.Affinity,
+		PublishResultsTo: p.PublishResultsTo,
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMigrateContainerMovesDefinitionAndProcesses(t *testing.T) {
+	src, _ := newTestKernel(t)
+	dest, _ := newTestKernel(t)
+	var srcRuns, destRuns atomic.Int32
+	for k, runs := range map[*Kernel]*atomic.Int32{src: &srcRuns, dest: &destRuns} {
+		k.RegisterKind("server", func(ProcessSpec) *Process {
+			return &Process{Action: func(ctx context.Context, h *Handle) error {
+				runs.Add(1)
+				<-ctx.Done()
+				return nil
+			}}
+		})
+	}
+
+	c := newTestContainer(t, src, "api")
+	c.SetLabels(map[string]string{"tier": "web"})
+	c.CPUWeightLimit = 2
+	server, err := src.NewProcess(ProcessSpec{Name: "server", Kind: "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddProcess(server)
+	c.AddProcess(&Process{Name: "sidecar", Action: blockUntil(nil)})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the server to start", func() bool { return srcRuns.Load() == 1 })
+
+	if err := src.MigrateContainer("api", dest); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, server)
+	if _, ok := src.Containers["api"]; ok {
+		t.Error("source still has the migrated container")
+	}
+	moved, ok := dest.Containers["api"]
+	if !ok {
+		t.Fatal("destination does not have the migrated container")
+	}
+	defer moved.StopProcesses()
+	eventually(t, "the server to restart on the destination", func() bool { return destRuns.Load() == 1 })
+	info := moved.Inspect()
+	if info.Labels["tier"] != "web" || moved.CPUWeightLimit != 2 {
+		t.Errorf("definition not carried over: labels %v, CPU weight limit %v", info.Labels, moved.CPUWeightLimit)
+	}
+	if len(info.Processes) != 2 || info.Processes[0].Name != "server" || info.Processes[1].Name != "sidecar" {
+		t.Fatalf("destination processes = %+v", info.Processes)
+	}
+	for _, p := range info.Processes {
+		if p.State != Running {
+			t.Errorf("%s = %v on the destination, want Running", p.Name, p.State)
+		}
+	}
+	if srcRuns.Load() != 1 {
+		t.Errorf("server ran %d times on the source, want 1", srcRuns.Load())
+	}
+}
+
+func TestMigrateContainerRejectedLeavesSource(t *testing.T) {
+	src, _ := newTestKernel(t)
+	dest, _ := newTestKernel(t)
+	src.RegisterKind("task", func(ProcessSpec) *Process { return &Process{Action: blockUntil(nil)} })
+	c := newTestContainer(t, src, "jobs")
+	p, err := src.NewProcess(ProcessSpec{Name: "task", Kind: "task"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+
+	err = src.MigrateContainer("jobs", dest)
+	var missing *MissingKindsError
+	if !errors.As(err, &missing) || len(missing.Kinds) != 1 || missing.Kinds[0] != "task" {
+		t.Fatalf("err = %v, want the missing task kind", err)
+	}
+	newTestContainer(t, dest, "jobs")
+	dest.RegisterKind("task", func(ProcessSpec) *Process { return &Process{Action: noop} })
+	if err := src.MigrateContainer("jobs", dest); !errors.Is(err, ErrContainerExists) {
+		t.Errorf("err = %v, want ErrContainerExists", err)
+	}
+
+	if src.Containers["jobs"] != c || c.Inspect().State != ContainerRunning {
+		t.Error("a rejected migration changed the source container")
+	}
+	if got := stateOf(c, p); got != Running {
+		t.Errorf("task = %v after the rejected migration, want Running", got)
+	}
+	if err := src.MigrateContainer("nope", dest); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("unknown container: %v", err)
+	}
+}
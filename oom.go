@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// --- Kernel OOM Killer ---
+
+// OOMPolicy selects which process the kernel kills when committed memory
+// exceeds Kernel.MemoryCeilingMB.
+type OOMPolicy int
+
+const (
+	// OOMKillLargest kills the process using the most memory, preferring
+	// the lowest priority among equals.
+	OOMKillLargest OOMPolicy = iota
+	// OOMKillLowestPriority kills the lowest-priority process, preferring
+	// the largest among equals.
+	OOMKillLowestPriority
+	// OOMDisabled only reports the overcommit.
+	OOMDisabled
+)
+
+func (p OOMPolicy) String() string {
+	switch p {
+	case OOMKillLargest:
+		return "KillLargest"
+	case OOMKillLowestPriority:
+		return "KillLowestPriority"
+	case OOMDisabled:
+		return "Disabled"
+	}
+	return fmt.Sprintf("OOMPolicy(%d)", int(p))
+}
+
+// CommittedMemoryMB reports the memory used by running processes across
+// every container.
+func (k *Kernel) CommittedMemoryMB() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	total := 0
+	for _, c := range k.Containers {
+		total += c.MemoryUsageMB()
+	}
+	return total
+}
+
+// scheduleCeilingCheck arranges for the memory ceiling to be enforced.
+// Memory changes happen under container locks, which must not be held
+// while taking the kernel lock, so enforcement runs asynchronously;
+// concurrent requests are coalesced.
+func (k *Kernel) scheduleCeilingCheck() {
+	if k.MemoryCeilingMB <= 0 {
+		return
+	}
+	if !k.ceilingPending.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		k.ceilingPending.Store(false)
+		k.EnforceMemoryCeiling()
+	}()
+}
+
+type oomCandidate struct {
+	c        *Container
+	p        *Process
+	priority int // effective priority when the candidate was collected
+	usedMB   int // memory in use when the candidate was collected
+}
+
+// EnforceMemoryCeiling kills victims chosen by OOMPolicy until committed
+// memory fits under MemoryCeilingMB. It returns the number of processes
+// killed. The kernel calls it automatically whenever memory usage changes.
+func (k *Kernel) EnforceMemoryCeiling() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ceiling := k.MemoryCeilingMB
+	if ceiling <= 0 {
+		return 0
+	}
+
+	var candidates []oomCandidate
+	committed := 0
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			if p.State == Running && !p.debug {
+				committed += p.usedMB
+				candidates = append(candidates, oomCandidate{c, p, p.effectivePriorityLocked(), p.usedMB})
+			}
+		}
+		c.mu.Unlock()
+	}
+	if committed <= ceiling {
+		return 0
+	}
+	if k.OOMPolicy == OOMDisabled {
+		k.emit(EventOOMKill, "", "", fmt.Sprintf("committed %dMB over %dMB ceiling; OOM killer disabled", committed, ceiling))
+		return 0
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		pa, pb := a.priority, b.priority
+		if k.OOMPolicy == OOMKillLowestPriority && pa != pb {
+			return pa < pb
+		}
+		if a.usedMB != b.usedMB {
+			return a.usedMB > b.usedMB
+		}
+		return pa < pb
+	})
+
+	killed := 0
+	for _, cand := range candidates {
+		if committed <= ceiling {
+			break
+		}
+		c, p := cand.c, cand.p
+		c.mu.Lock()
+		if p.State == Running {
+			used := p.usedMB // may have changed since it was collected
+			committed -= used
+			c.killLocked(p, fmt.Sprintf("OOM: kernel committed memory over %dMB ceiling", ceiling))
+			c.emit(EventOOMKill, p, fmt.Sprintf("freed %dMB", used))
+			killed++
+		}
+		c.mu.Unlock()
+	}
+	return killed
+}
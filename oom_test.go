@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// overcommit starts processes using the given memory and priorities,
+// spread over two containers, and returns them in order.
+func overcommit(t *testing.T, k *Kernel, procs ...Process) []*Process {
+	t.Helper()
+	cs := []*Container{newTestContainer(t, k, "a"), newTestContainer(t, k, "b")}
+	var out []*Process
+	for i := range procs {
+		p := &procs[i]
+		p.Action = blockUntil(nil)
+		c := cs[i%len(cs)]
+		c.AddProcess(p)
+		out = append(out, p)
+	}
+	for _, c := range cs {
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, c := range cs {
+			c.StopProcesses()
+		}
+	})
+	return out
+}
+
+// killedNames lists the killed processes of containers a and b, sorted.
+func killedNames(k *Kernel) []string {
+	var names []string
+	for _, id := range []string{"a", "b"} {
+		c := k.Containers[id]
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			if p.State == Killed {
+				names = append(names, p.Name)
+			}
+		}
+		c.mu.Unlock()
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestOOMKillsLargestProcess(t *testing.T) {
+	k, _ := newTestKernel(t)
+	procs := overcommit(t, k,
+		Process{Name: "small", MemoryMB: 100, Priority: 1},
+		Process{Name: "big", MemoryMB: 300, Priority: 9},
+		Process{Name: "medium", MemoryMB: 200, Priority: 1},
+	)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventOOMKill}})
+	defer cancel()
+	k.MemoryCeilingMB = 400
+
+	if n := k.EnforceMemoryCeiling(); n != 1 {
+		t.Fatalf("killed %d processes, want 1", n)
+	}
+	waitDone(t, procs[1])
+	if got := killedNames(k); len(got) != 1 || got[0] != "big" {
+		t.Errorf("killed %v, want [big]", got)
+	}
+	e := nextEvent(t, events)
+	if e.Process != "big" || e.ContainerID != "b" || e.Detail != "freed 300MB" {
+		t.Errorf("event = %+v", e)
+	}
+	if got := k.CommittedMemoryMB(); got != 300 {
+		t.Errorf("committed %dMB after the kill, want 300", got)
+	}
+}
+
+func TestOOMPrefersLowestPriorityAmongEquals(t *testing.T) {
+	k, _ := newTestKernel(t)
+	overcommit(t, k,
+		Process{Name: "important", MemoryMB: 200, Priority: 9},
+		Process{Name: "batch", MemoryMB: 200, Priority: 1},
+	)
+	k.MemoryCeilingMB = 300
+	k.EnforceMemoryCeiling()
+	if got := killedNames(k); len(got) != 1 || got[0] != "batch" {
+		t.Errorf("killed %v, want [batch]", got)
+	}
+}
+
+func TestOOMKillLowestPriorityPolicy(t *testing.T) {
+	k, _ := newTestKernel(t)
+	overcommit(t, k,
+		Process{Name: "big", MemoryMB: 300, Priority: 5},
+		Process{Name: "low", MemoryMB: 50, Priority: 0},
+		Process{Name: "lower", MemoryMB: 60, Priority: 0},
+	)
+	k.MemoryCeilingMB = 300
+	k.OOMPolicy = OOMKillLowestPriority
+	if n := k.EnforceMemoryCeiling(); n != 2 {
+		t.Fatalf("killed %d, want 2", n)
+	}
+	if got := killedNames(k); len(got) != 2 || got[0] != "low" || got[1] != "lower" {
+		t.Errorf("killed %v, want [low lower]", got)
+	}
+}
+
+func TestOOMDisabledOnlyReports(t *testing.T) {
+	k, _ := newTestKernel(t)
+	overcommit(t, k, Process{Name: "big", MemoryMB: 300})
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventOOMKill}})
+	defer cancel()
+	k.MemoryCeilingMB = 100
+	k.OOMPolicy = OOMDisabled
+	if n := k.EnforceMemoryCeiling(); n != 0 {
+		t.Errorf("killed %d with the OOM killer disabled", n)
+	}
+	if e := nextEvent(t, events); e.Process != "" {
+		t.Errorf("event names a victim: %+v", e)
+	}
+}
+
+func TestOOMRunsWhenUsageGrows(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.MemoryCeilingMB = 250
+	procs := overcommit(t, k,
+		Process{Name: "steady", MemoryMB: 100},
+		Process{Name: "grower", MemoryMB: 100},
+	)
+	grower := procs[1]
+	c := k.Containers["b"]
+	c.adjustMemory(grower, 100)
+	waitDone(t, grower)
+	if got := stateOf(c, grower); got != Killed {
+		t.Errorf("grower = %v, want Killed", got)
+	}
+}
+
+func TestOOMWhileProcessesAllocate(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "churn")
+	var procs []*Process
+	for i := 0; i < 100; i++ {
+		p := &Process{Name: fmt.Sprintf("p%d", i), MemoryMB: 4, Action: func(ctx context.Context, h *Handle) error {
+			for ctx.Err() == nil {
+				h.AllocateMemory(1)
+				h.ReleaseMemory(1)
+			}
+			return ctx.Err()
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	eventually(t, "processes running", func() bool { return stateOf(c, procs[99]) == Running })
+
+	// Usage keeps changing while the victims are sorted and killed; under
+	// -race this catches usage read outside the container locks.
+	k.mu.Lock()
+	k.MemoryCeilingMB = 300
+	k.mu.Unlock()
+	k.EnforceMemoryCeiling()
+	killed := 0
+	for _, p := range procs {
+		if stateOf(c, p) == Killed {
+			killed++
+		}
+	}
+	if killed < 25 {
+		t.Errorf("%d killed, want at least 25 to fit 400MB under 300MB", killed)
+	}
+}
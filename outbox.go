@@ -0,0 +1,79 @@
+package main
+
+import "fmt"
+
+// --- Process Outbox ---
+
+type outboxMessage struct {
+	msg Message // From is filled in at delivery
+}
+
+// Outbox stages messages from a running process. Staged messages are sent
+// only when the process's action returns successfully; if it fails or is
+// stopped they are discarded, unless Process.RetainOutbox is set.
+type Outbox struct {
+	h *Handle
+}
+
+// Outbox returns the process's outbox.
+func (h *Handle) Outbox() *Outbox {
+	return &Outbox{h: h}
+}
+
+// Send stages msg for the container or service destination toID. Messages
+// are delivered in the order they were staged, from the process's
+// container.
+func (o *Outbox) Send(toID, msg string) {
+	c := o.h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := o.h.tracedLocked(Message{To: toID, Payload: msg})
+	o.h.proc.outbox = append(o.h.proc.outbox, outboxMessage{msg: m})
+}
+
+// Pending reports how many messages are staged and not yet delivered.
+func (o *Outbox) Pending() int {
+	c := o.h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(o.h.proc.outbox)
+}
+
+// abortOutboxLocked handles a failed or interrupted attempt: staged messages
+// are dropped unless the process retains them for its next attempt.
+func (p *Process) abortOutboxLocked() {
+	if !p.RetainOutbox {
+		p.outbox = nil
+	}
+}
+
+// deliverOutbox sends committed messages. The caller must not hold the
+// container lock.
+func (c *Container) deliverOutbox(msgs []outboxMessage) {
+	if c.kernel == nil {
+		return
+	}
+	c.mu.Lock()
+	denied := c.denied&CapSendMessages != 0
+	c.mu.Unlock()
+	if denied && len(msgs) > 0 {
+		fmt.Printf("[Kernel] Dropped %d outbox messages of %s: lacks %v\n", len(msgs), c.Name, CapSendMessages)
+		c.emit(EventCapabilityDenied, nil, fmt.Sprintf("outbox: lacks %v", CapSendMessages))
+		return
+	}
+	for _, m := range msgs {
+		m.msg.From = c.ID
+		c.kernel.sendMessage(m.msg, "")
+	}
+}
+
+// abortOutbox is abortOutboxLocked for a handle, tolerating handles that
+// are not attached to a container.
+func (h *Handle) abortOutbox() {
+	if h == nil || h.container == nil {
+		return
+	}
+	h.container.mu.Lock()
+	defer h.container.mu.Unlock()
+	h.proc.abortOutboxLocked()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newSink creates a container with an idle process, so it accepts
+// messages, and returns a func listing the payloads in its inbox.
+func newSink(t *testing.T, k *Kernel) func() string {
+	c := newTestContainer(t, k, "sink")
+	c.AddProcess(&Process{Name: "listener"})
+	return func() string {
+		var payloads []string
+		for _, m := range c.Inbox() {
+			payloads = append(payloads, m.Payload)
+		}
+		return fmt.Sprint(payloads)
+	}
+}
+
+func TestOutboxDeliversInOrderOnSuccess(t *testing.T) {
+	k, _ := newTestKernel(t)
+	inbox := newSink(t, k)
+	c := newTestContainer(t, k, "writer")
+	release := make(chan struct{})
+	p := &Process{Name: "commit", Action: func(ctx context.Context, h *Handle) error {
+		for _, msg := range []string{"a", "b", "c"} {
+			h.Outbox().Send("sink", msg)
+		}
+		<-release
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "messages to be staged", func() bool { return c.Inspect().Processes[0].OutboxPending == 3 })
+	if got := inbox(); got != "[]" {
+		t.Fatalf("delivered %s before the action returned", got)
+	}
+	close(release)
+	eventually(t, "the outbox to be delivered", func() bool { return inbox() == "[a b c]" })
+	if n := c.Inspect().Processes[0].OutboxPending; n != 0 {
+		t.Errorf("%d messages still staged after commit", n)
+	}
+}
+
+func TestOutboxDiscardedOnFailure(t *testing.T) {
+	k, _ := newTestKernel(t)
+	inbox := newSink(t, k)
+	c := newTestContainer(t, k, "writer")
+	p := &Process{Name: "crash", Action: func(ctx context.Context, h *Handle) error {
+		h.Outbox().Send("sink", "half-done")
+		return errors.New("crashed")
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if got := inbox(); got != "[]" {
+		t.Errorf("failed action delivered %s", got)
+	}
+	if n := c.Inspect().Processes[0].OutboxPending; n != 0 {
+		t.Errorf("%d messages kept after failure", n)
+	}
+}
+
+func TestRetainOutboxAcrossRetries(t *testing.T) {
+	for _, retain := range []bool{false, true} {
+		k, _ := newTestKernel(t)
+		inbox := newSink(t, k)
+		c := newTestContainer(t, k, "writer")
+		attempt := 0
+		p := &Process{Name: "flaky", RetainOutbox: retain, Action: WithRetry(2, 0, func(ctx context.Context, h *Handle) error {
+			attempt++
+			h.Outbox().Send("sink", fmt.Sprint("attempt", attempt))
+			if attempt == 1 {
+				return errors.New("transient")
+			}
+			return nil
+		})}
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+		want := "[attempt2]"
+		if retain {
+			want = "[attempt1 attempt2]"
+		}
+		eventually(t, "the outbox to be delivered", func() bool { return inbox() == want })
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- Process Outcomes ---
+
+// OutcomeCounts counts the processes of a container that reached each
+// terminal state since it was created. TimedOut counts failures caused by a
+// deadline or request timeout; they are not also counted in Failed.
+type OutcomeCounts struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	TimedOut  int `json:"timed_out"`
+	Killed    int `json:"killed"`
+	Stopped   int `json:"stopped"`
+}
+
+func (o OutcomeCounts) sub(prev OutcomeCounts) OutcomeCounts {
+	return OutcomeCounts{
+		Completed: o.Completed - prev.Completed,
+		Failed:    o.Failed - prev.Failed,
+		TimedOut:  o.TimedOut - prev.TimedOut,
+		Killed:    o.Killed - prev.Killed,
+		Stopped:   o.Stopped - prev.Stopped,
+	}
+}
+
+// LongestRunning identifies the process that has been running longest in a
+// container.
+type LongestRunning struct {
+	PID       int       `json:"pid"`
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// recordOutcomeLocked counts p, which has just reached a terminal state.
+func (c *Container) recordOutcomeLocked(p *Process) {
+	switch p.State {
+	case Completed:
+		c.outcomes.Completed++
+	case Failed:
+		if errors.Is(p.Err, context.DeadlineExceeded) || errors.Is(p.Err, ErrRequestTimeout) {
+			c.outcomes.TimedOut++
+		} else {
+			c.outcomes.Failed++
+		}
+	case Killed:
+		c.outcomes.Killed++
+	case Stopped:
+		c.outcomes.Stopped++
+	}
+}
+
+// longestRunningLocked returns the running process that started first, or
+// nil if none is running.
+func (c *Container) longestRunningLocked() *LongestRunning {
+	var longest *Process
+	for _, p := range c.Processes {
+		if p.State == Running && (longest == nil || p.startedAt.Before(longest.startedAt)) {
+			longest = p
+		}
+	}
+	if longest == nil {
+		return nil
+	}
+	return &LongestRunning{PID: longest.PID, Name: longest.Name, StartedAt: longest.startedAt}
+}
+
+// monitorColumnsLocked formats the optional monitor columns: a frozen
+// marker, outcome counts with their change since prev, and the longest
+// running process. Columns with nothing to show are left out.
+func (c *Container) monitorColumnsLocked(prev OutcomeCounts) string {
+	var b strings.Builder
+	if c.frozen {
+		b.WriteString(" | Frozen")
+	}
+	delta := c.outcomes.sub(prev)
+	var parts []string
+	for _, col := range []struct {
+		name       string
+		total, new int
+	}{
+		{"completed", c.outcomes.Completed, delta.Completed},
+		{"failed", c.outcomes.Failed, delta.Failed},
+		{"timed out", c.outcomes.TimedOut, delta.TimedOut},
+		{"killed", c.outcomes.Killed, delta.Killed},
+		{"stopped", c.outcomes.Stopped, delta.Stopped},
+	} {
+		if col.total == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%d %s", col.total, col.name)
+		if col.new > 0 {
+			part += fmt.Sprintf(" (+%d)", col.new)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(&b, " | Done: %s", strings.Join(parts, ", "))
+	}
+	if l := c.longestRunningLocked(); l != nil {
+		fmt.Fprintf(&b, " | Longest: %s (PID %d) %s", l.Name, l.PID, c.now().Sub(l.StartedAt).Round(time.Millisecond))
+	}
+	return b.String()
+}
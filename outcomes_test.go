@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutcomeCountsAndLongestRunning(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	if err := c.StartProcesses(); err != nil && !errors.Is(err, ErrNoPendingProcesses) {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	start := func(name string, action ActionFunc) *Process {
+		p := &Process{Name: name, Action: action}
+		c.AddProcess(p)
+		eventually(t, name+" to start", func() bool { return stateOf(c, p) != Pending })
+		return p
+	}
+	var finished []*Process
+	for _, spec := range []struct {
+		name   string
+		action ActionFunc
+	}{
+		{"ok1", noop},
+		{"ok2", noop},
+		{"broken", func(context.Context, *Handle) error { return errors.New("boom") }},
+		{"slow", func(context.Context, *Handle) error { return fmt.Errorf("fetch: %w", context.DeadlineExceeded) }},
+	} {
+		finished = append(finished, start(spec.name, spec.action))
+	}
+	old := start("old", blockUntil(nil))
+	victim := start("victim", blockUntil(nil))
+	c.mu.Lock()
+	c.killLocked(victim, "test")
+	c.mu.Unlock()
+	for _, p := range append(finished, victim) {
+		waitDone(t, p)
+	}
+	clk.Advance(time.Minute)
+	start("new", blockUntil(nil))
+	clk.Advance(time.Minute)
+
+	s := k.Snapshot().Containers["jobs"]
+	if want := (OutcomeCounts{Completed: 2, Failed: 1, TimedOut: 1, Killed: 1}); s.Outcomes != want {
+		t.Errorf("outcomes = %+v, want %+v", s.Outcomes, want)
+	}
+	if l := s.LongestRunning; l == nil || l.PID != old.PID || l.Name != "old" || !l.StartedAt.Equal(testEpoch) {
+		t.Errorf("longest running = %+v, want old", l)
+	}
+
+	var out bytes.Buffer
+	if err := k.MonitorTo(&out, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("| Running Processes: 2 | Done: 2 completed (+2), 1 failed (+1), 1 timed out (+1), 1 killed (+1) | Longest: old (PID %d) 2m0s\n", old.PID)
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("monitor output:\n%s\nwant a row ending %q", out.String(), want)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// --- Peaks ---
+
+// Peak is the highest value a metric has reached and when it first did.
+type Peak struct {
+	Value float64   `json:"value"`
+	At    time.Time `json:"at,omitempty"`
+}
+
+func (p *Peak) observe(v float64, at time.Time) {
+	if v > p.Value {
+		p.Value, p.At = v, at
+	}
+}
+
+// Peaks are the high-water marks of a container, or of the whole kernel
+// with every container's usage summed: memory in use by running processes,
+// CPU load, running processes and messages waiting in inboxes. They are
+// taken whenever the kernel changes one of them, when processes start and
+// finish, memory is adjusted and messages are delivered or received, as
+// well as at every usage sample, so spikes between samples are not missed.
+type Peaks struct {
+	MemoryMB     Peak `json:"memory_mb"`
+	CPU          Peak `json:"cpu"`
+	Running      Peak `json:"running"`
+	MailboxDepth Peak `json:"mailbox_depth"`
+}
+
+// load is the current value of each peak metric.
+type load struct {
+	memoryMB int
+	cpu      float64
+	running  int
+	mailbox  int
+}
+
+func (p *Peaks) observe(l load, at time.Time) {
+	p.MemoryMB.observe(float64(l.memoryMB), at)
+	p.CPU.observe(l.cpu, at)
+	p.Running.observe(float64(l.running), at)
+	p.MailboxDepth.observe(float64(l.mailbox), at)
+}
+
+func (c *Container) loadLocked() load {
+	l := load{memoryMB: c.memoryUsageLocked(), cpu: c.CPULoad, mailbox: len(c.inbox)}
+	for _, p := range c.Processes {
+		if p.State == Running && !p.debug {
+			l.running++
+		}
+	}
+	return l
+}
+
+// observeLoadLocked updates the container's peaks, and the kernel's, with
+// its current load.
+func (c *Container) observeLoadLocked() {
+	l, now := c.loadLocked(), c.now()
+	c.peaks.observe(l, now)
+	if c.kernel != nil {
+		c.kernel.peaks.update(c.ID, l, now)
+	}
+}
+
+// peakTracker sums the latest load of every container into the kernel's
+// peaks. It has its own mutex so loads are reported under the container
+// lock alone.
+type peakTracker struct {
+	mu    sync.Mutex
+	loads map[string]load
+	total load
+	peaks Peaks
+}
+
+func (t *peakTracker) update(id string, l load, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loads == nil {
+		t.loads = make(map[string]load)
+	}
+	old := t.loads[id]
+	t.loads[id] = l
+	t.total.memoryMB += l.memoryMB - old.memoryMB
+	t.total.cpu += l.cpu - old.cpu
+	t.total.running += l.running - old.running
+	t.total.mailbox += l.mailbox - old.mailbox
+	t.peaks.observe(t.total, now)
+}
+
+// remove drops a removed container's load from the totals; the peaks it
+// contributed to are kept.
+func (t *peakTracker) remove(id string, now time.Time) {
+	t.update(id, load{}, now)
+	t.mu.Lock()
+	delete(t.loads, id)
+	t.mu.Unlock()
+}
+
+func (t *peakTracker) snapshot() Peaks {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.peaks
+}
+
+// Peaks returns the container's high-water marks.
+func (c *Container) Peaks() Peaks {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peaks
+}
+
+// ResetPeaks restarts high-water marks from the current load. An empty
+// scope resets the kernel-wide peaks and those of every container; a
+// container ID resets just that container's.
+func (k *Kernel) ResetPeaks(scope string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.Clock.Now()
+	if scope != "" {
+		c, ok := k.Containers[scope]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrContainerNotFound, scope)
+		}
+		c.mu.Lock()
+		c.peaks = Peaks{}
+		c.peaks.observe(c.loadLocked(), now)
+		c.mu.Unlock()
+		return nil
+	}
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		c.peaks = Peaks{}
+		c.peaks.observe(c.loadLocked(), now)
+		c.mu.Unlock()
+	}
+	t := &k.peaks
+	t.mu.Lock()
+	t.peaks = Peaks{}
+	t.peaks.observe(t.total, now)
+	t.mu.Unlock()
+	return nil
+}
+
+// ExportHistoryJSON writes the container's usage history together with
+// its peaks as one JSON object.
+func (c *Container) ExportHistoryJSON(w io.Writer) error {
+	c.mu.Lock()
+	out := struct {
+		Samples []UsageSample `json:"samples"`
+		Peaks   Peaks         `json:"peaks"`
+	}{c.usageHistoryLocked(), c.peaks}
+	c.mu.Unlock()
+	return json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// memoryScript starts a process in c and returns a function that has it
+// allocate a positive amount of memory or release a negative one.
+func memoryScript(t *testing.T, c *Container) func(mb int) {
+	t.Helper()
+	steps, acks := make(chan int), make(chan struct{})
+	c.AddProcess(&Process{Name: "spiky", Action: func(ctx context.Context, h *Handle) error {
+		for mb := range steps {
+			if mb > 0 {
+				h.AllocateMemory(mb)
+			} else {
+				h.ReleaseMemory(-mb)
+			}
+			acks <- struct{}{}
+		}
+		return nil
+	}})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { close(steps) })
+	return func(mb int) {
+		steps <- mb
+		<-acks
+	}
+}
+
+func TestPeaksRecordSpikes(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "spiky")
+	other := newTestContainer(t, k, "other")
+	other.AddProcess(&Process{Name: "listener"})
+	step := memoryScript(t, c)
+
+	step(100)
+	clk.Advance(time.Minute)
+	step(400) // the spike: 500MB at 1m
+	clk.Advance(time.Minute)
+	step(-450)
+	for i := 0; i < 3; i++ {
+		if err := k.SendMessage("spiky", "other", "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clk.Advance(time.Minute)
+	c.DrainInbox()
+	other.DrainInbox()
+
+	spike := Peak{Value: 500, At: testEpoch.Add(time.Minute)}
+	p := c.Peaks()
+	if p.MemoryMB != spike || p.Running != (Peak{Value: 1, At: testEpoch}) {
+		t.Errorf("container peaks = %+v, want the 500MB spike at 1m", p)
+	}
+	if d := c.Inspect().Peaks; d != p {
+		t.Errorf("inspect peaks = %+v", d)
+	}
+	kp := k.Stats().Peaks
+	if kp.MemoryMB != spike || kp.MailboxDepth != (Peak{Value: 3, At: testEpoch.Add(2 * time.Minute)}) {
+		t.Errorf("kernel peaks = %+v", kp)
+	}
+	var out bytes.Buffer
+	if err := c.ExportHistoryJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var exported struct{ Peaks Peaks }
+	mustDecode(t, out.Bytes(), &exported)
+	if exported.Peaks != p {
+		t.Errorf("exported peaks = %+v", exported.Peaks)
+	}
+
+	// Resetting one container starts its peaks again from the current
+	// load and leaves the kernel's alone.
+	if err := k.ResetPeaks("spiky"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Peaks().MemoryMB; got != (Peak{Value: 50, At: testEpoch.Add(3 * time.Minute)}) {
+		t.Errorf("after reset: memory peak %+v, want the current 50MB", got)
+	}
+	if got := k.Stats().Peaks.MemoryMB; got != spike {
+		t.Errorf("kernel memory peak after a container reset = %+v", got)
+	}
+	if err := k.ResetPeaks(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := k.Stats().Peaks; got.MemoryMB.Value != 50 || got.MailboxDepth.Value != 0 {
+		t.Errorf("kernel peaks after a full reset = %+v", got)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// --- Pipes ---
+
+// pipeBufferSize bounds the bytes a named pipe holds; writers block once
+// it is full until a reader catches up.
+const pipeBufferSize = 64 << 10
+
+// ErrBrokenPipe is returned by a pipe write once every process that read
+// from the pipe has finished.
+var ErrBrokenPipe = errors.New("broken pipe")
+
+// pipe is a named byte stream between processes. Its state has its own
+// lock, which may be taken while holding a container lock but not the
+// other way round.
+type pipe struct {
+	name     string
+	capacity int // bytes buffered before writers block
+
+	mu      sync.Mutex
+	buf     []byte
+	changed chan struct{}     // closed and replaced on every state change
+	writers map[*Process]bool // attached writers; false once finished or closed
+	readers map[*Process]bool // attached readers; false once finished
+}
+
+func newPipe(name string, capacity int) *pipe {
+	return &pipe{
+		name:     name,
+		capacity: capacity,
+		changed:  make(chan struct{}),
+		writers:  make(map[*Process]bool),
+		readers:  make(map[*Process]bool),
+	}
+}
+
+// notifyLocked wakes goroutines waiting for the pipe to change.
+func (pp *pipe) notifyLocked() {
+	close(pp.changed)
+	pp.changed = make(chan struct{})
+}
+
+// eofLocked reports whether writers have attached and all of them are done.
+func (pp *pipe) eofLocked() bool {
+	return len(pp.writers) > 0 && !anyActive(pp.writers)
+}
+
+// brokenLocked reports whether readers have attached and all of them are
+// gone.
+func (pp *pipe) brokenLocked() bool {
+	return len(pp.readers) > 0 && !anyActive(pp.readers)
+}
+
+func anyActive(m map[*Process]bool) bool {
+	for _, active := range m {
+		if active {
+			return true
+		}
+	}
+	return false
+}
+
+// processDone detaches p from both ends of the pipe.
+func (pp *pipe) processDone(p *Process) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	changed := false
+	if pp.writers[p] {
+		pp.writers[p] = false
+		changed = true
+	}
+	if pp.readers[p] {
+		pp.readers[p] = false
+		changed = true
+	}
+	if changed {
+		pp.notifyLocked()
+	}
+}
+
+// Pipe is a process's view of a named pipe in its container.
+type Pipe struct {
+	pipe *pipe
+	h    *Handle
+}
+
+// Pipe creates the named pipe in the process's container, or attaches to it
+// if another process already created it. Data written to the Writer end is
+// read from the Reader end in order. Reads return io.EOF once every process
+// that took the Writer has closed it or finished; writes fail with
+// ErrBrokenPipe once every process that took the Reader has finished.
+func (h *Handle) Pipe(name string) *Pipe {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pp, ok := c.pipes[name]
+	if !ok {
+		if c.pipes == nil {
+			c.pipes = make(map[string]*pipe)
+		}
+		pp = newPipe(name, pipeBufferSize)
+		c.pipes[name] = pp
+	}
+	return &Pipe{pipe: pp, h: h}
+}
+
+// Name returns the pipe's name.
+func (p *Pipe) Name() string {
+	return p.pipe.name
+}
+
+// Pressure returns how full the pipe's buffer is, from 0 (empty) to 1
+// (full, so writers are blocked).
+func (p *Pipe) Pressure() float64 {
+	return p.pipe.pressure()
+}
+
+func (pp *pipe) pressure() float64 {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	return pp.pressureLocked()
+}
+
+func (pp *pipe) pressureLocked() float64 {
+	return float64(len(pp.buf)) / float64(pp.capacity)
+}
+
+// Writer attaches the process as a writer and returns its end. Writes block
+// while the pipe is full; they return the context error if the process is
+// stopped meanwhile.
+func (p *Pipe) Writer() io.WriteCloser {
+	pp := p.pipe
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if _, ok := pp.writers[p.h.proc]; !ok {
+		pp.writers[p.h.proc] = true
+		pp.notifyLocked()
+	}
+	return &pipeWriter{p: p}
+}
+
+// Reader attaches the process as a reader and returns its end. Reads block
+// while the pipe is empty; they return the context error if the process is
+// stopped meanwhile.
+func (p *Pipe) Reader() io.Reader {
+	pp := p.pipe
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if _, ok := pp.readers[p.h.proc]; !ok {
+		pp.readers[p.h.proc] = true
+		pp.notifyLocked()
+	}
+	return &pipeReader{p: p}
+}
+
+type pipeWriter struct {
+	p *Pipe
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	pp, ctx := w.p.pipe, w.p.h.context()
+	n := 0
+	for {
+		pp.mu.Lock()
+		if !pp.writers[w.p.h.proc] {
+			pp.mu.Unlock()
+			return n, io.ErrClosedPipe
+		}
+		if pp.brokenLocked() {
+			pp.mu.Unlock()
+			return n, ErrBrokenPipe
+		}
+		if space := pp.capacity - len(pp.buf); space > 0 {
+			k := min(space, len(b)-n)
+			pp.buf = append(pp.buf, b[n:n+k]...)
+			n += k
+			pp.notifyLocked()
+		}
+		if n == len(b) {
+			pp.mu.Unlock()
+			return n, nil
+		}
+		changed := pp.changed
+		pp.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return n, ctx.Err()
+		}
+	}
+}
+
+// Close detaches the writer; readers see io.EOF once every writer has.
+func (w *pipeWriter) Close() error {
+	pp := w.p.pipe
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	if pp.writers[w.p.h.proc] {
+		pp.writers[w.p.h.proc] = false
+		pp.notifyLocked()
+	}
+	return nil
+}
+
+type pipeReader struct {
+	p *Pipe
+}
+
+func (r *pipeReader) Read(b []byte) (int, error) {
+	pp, ctx := r.p.pipe, r.p.h.context()
+	for {
+		pp.mu.Lock()
+		if len(pp.buf) > 0 {
+			n := copy(b, pp.buf)
+			pp.buf = pp.buf[n:]
+			if len(pp.buf) == 0 {
+				pp.buf = nil
+			}
+			pp.notifyLocked()
+			pp.mu.Unlock()
+			return n, nil
+		}
+		if pp.eofLocked() {
+			pp.mu.Unlock()
+			return 0, io.EOF
+		}
+		changed := pp.changed
+		pp.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// pipesProcessDoneLocked detaches a finished process from every pipe in the
+// container and every shared pipe it attached to.
+func (c *Container) pipesProcessDoneLocked(p *Process) {
+	for _, pp := range c.pipes {
+		pp.processDone(p)
+	}
+	for _, pp := range p.sharedPipes {
+		pp.processDone(p)
+	}
+	p.sharedPipes = nil
+}
+
+// PipeDetail describes a pipe in ContainerDetail.
+type PipeDetail struct {
+	Name     string
+	Buffered int // bytes written and not yet read
+	Writers  int // attached writers still active
+	Readers  int // attached readers still active
+	EOF      bool
+	Broken   bool
+	Pressure float64 // Buffered as a fraction of the pipe's capacity
+}
+
+func (c *Container) pipeDetailsLocked() []PipeDetail {
+	out := make([]PipeDetail, 0, len(c.pipes))
+	for _, pp := range c.pipes {
+		pp.mu.Lock()
+		d := PipeDetail{Name: pp.name, Buffered: len(pp.buf), EOF: pp.eofLocked(), Broken: pp.brokenLocked(), Pressure: pp.pressureLocked()}
+		for _, active := range pp.writers {
+			if active {
+				d.Writers++
+			}
+		}
+		for _, active := range pp.readers {
+			if active {
+				d.Readers++
+			}
+		}
+		pp.mu.Unlock()
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// --- Shared Pipes ---
+
+// ErrInvalidPipeCapacity is returned by CreatePipe for a capacity below 1.
+var ErrInvalidPipeCapacity = errors.New("pipe capacity must be positive")
+
+// SharedPipe is a kernel-level pipe that processes in any container can
+// attach to with Handle.AttachPipe. It behaves like a named pipe with a
+// buffer of the capacity given to CreatePipe.
+type SharedPipe struct {
+	pipe *pipe
+}
+
+// CreatePipe creates a shared pipe that buffers up to capacity bytes;
+// writers block while it is full, and Pressure shows how close it is.
+func (k *Kernel) CreatePipe(capacity int) (*SharedPipe, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidPipeCapacity, capacity)
+	}
+	name := fmt.Sprintf("pipe-%d", k.lastPipeID.Add(1))
+	return &SharedPipe{pipe: newPipe(name, capacity)}, nil
+}
+
+// Name returns the kernel-assigned name of the pipe.
+func (sp *SharedPipe) Name() string {
+	return sp.pipe.name
+}
+
+// Capacity returns the bytes the pipe buffers before writers block.
+func (sp *SharedPipe) Capacity() int {
+	return sp.pipe.capacity
+}
+
+// Pressure returns how full the pipe's buffer is, from 0 (empty) to 1
+// (full, so writers are blocked).
+func (sp *SharedPipe) Pressure() float64 {
+	return sp.pipe.pressure()
+}
+
+// AttachPipe returns the process's view of a shared pipe; take its Writer
+// or Reader end as with Handle.Pipe. The process is detached from the pipe
+// when it finishes.
+func (h *Handle) AttachPipe(sp *SharedPipe) *Pipe {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !slices.Contains(h.proc.sharedPipes, sp.pipe) {
+		h.proc.sharedPipes = append(h.proc.sharedPipes, sp.pipe)
+	}
+	return &Pipe{pipe: sp.pipe, h: h}
+}
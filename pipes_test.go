@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPipeStreamsUntilProducerCompletes(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1MB
+	var got []byte
+	var readErr error
+	producer := &Process{Name: "producer", Action: func(ctx context.Context, h *Handle) error {
+		_, err := h.Pipe("rows").Writer().Write(data)
+		return err // completing without Close still ends the stream
+	}}
+	consumer := &Process{Name: "consumer", Action: func(ctx context.Context, h *Handle) error {
+		got, readErr = io.ReadAll(h.Pipe("rows").Reader())
+		return readErr
+	}}
+	c.AddProcess(producer)
+	c.AddProcess(consumer)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, producer)
+	waitDone(t, consumer)
+	if readErr != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes (err %v), want the 1MB written", len(got), readErr)
+	}
+	if d := c.Inspect().Pipes; len(d) != 1 || d[0].Name != "rows" || d[0].Buffered != 0 || !d[0].EOF {
+		t.Errorf("pipes = %+v", d)
+	}
+}
+
+func TestPipeInspectShowsBufferedBytes(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	producer := &Process{Name: "producer", Action: func(ctx context.Context, h *Handle) error {
+		h.Pipe("rows").Writer().Write(make([]byte, 100))
+		<-ctx.Done()
+		return nil
+	}}
+	c.AddProcess(producer)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	eventually(t, "the write to be buffered", func() bool {
+		d := c.Inspect().Pipes
+		return len(d) == 1 && d[0].Buffered == 100
+	})
+	if d := c.Inspect().Pipes[0]; d.Writers != 1 || d.Readers != 0 || d.EOF || d.Broken {
+		t.Errorf("pipe = %+v", d)
+	}
+}
+
+func TestPipeBreaksWhenReaderDies(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	attached := make(chan struct{})
+	reader := &Process{Name: "reader", Action: func(ctx context.Context, h *Handle) error {
+		h.Pipe("rows").Reader()
+		close(attached)
+		<-ctx.Done()
+		return nil
+	}}
+	var writeErr error
+	var written int
+	writer := &Process{Name: "writer", Action: func(ctx context.Context, h *Handle) error {
+		<-attached
+		written, writeErr = h.Pipe("rows").Writer().Write(make([]byte, 2*pipeBufferSize))
+		return nil
+	}}
+	c.AddProcess(reader)
+	c.AddProcess(writer)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the writer to fill the pipe", func() bool {
+		d := c.Inspect().Pipes
+		return len(d) == 1 && d[0].Buffered == pipeBufferSize
+	})
+	c.mu.Lock()
+	c.killLocked(reader, "test")
+	c.mu.Unlock()
+	waitDone(t, writer)
+	if !errors.Is(writeErr, ErrBrokenPipe) || written != pipeBufferSize {
+		t.Errorf("write = %d, %v; want %d, ErrBrokenPipe", written, writeErr, pipeBufferSize)
+	}
+	if d := c.Inspect().Pipes[0]; !d.Broken {
+		t.Errorf("pipe = %+v, want Broken", d)
+	}
+}
+
+func TestPipePressureRisesWithSlowConsumer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	start := make(chan struct{})
+	var read int64
+	producer := &Process{Name: "producer", Action: func(ctx context.Context, h *Handle) error {
+		w := h.Pipe("rows").Writer()
+		defer w.Close()
+		for i := 0; i < 4*pipeBufferSize/1024; i++ {
+			if _, err := w.Write(make([]byte, 1024)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+	consumer := &Process{Name: "consumer", Action: func(ctx context.Context, h *Handle) error {
+		r := h.Pipe("rows").Reader()
+		<-start
+		var err error
+		read, err = io.Copy(io.Discard, r)
+		return err
+	}}
+	c.AddProcess(producer)
+	c.AddProcess(consumer)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+
+	var last float64
+	eventually(t, "the pipe to fill", func() bool {
+		d := c.Inspect().Pipes
+		if len(d) != 1 {
+			return false
+		}
+		if d[0].Pressure < last {
+			t.Fatalf("pressure fell from %v to %v with no reader", last, d[0].Pressure)
+		}
+		last = d[0].Pressure
+		return last == 1
+	})
+	if got := stateOf(c, producer); got != Running {
+		t.Errorf("producer = %v with the pipe full, want blocked in Running", got)
+	}
+	close(start)
+	waitDone(t, producer)
+	waitDone(t, consumer)
+	if read != 4*pipeBufferSize {
+		t.Errorf("consumer read %d bytes, want %d", read, 4*pipeBufferSize)
+	}
+	if d := c.Inspect().Pipes[0]; d.Pressure != 0 {
+		t.Errorf("pressure = %v once drained", d.Pressure)
+	}
+}
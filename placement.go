@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// --- Placement ---
+
+// Affinity is a placement hint for processes started through
+// Kernel.PlaceProcess.
+type Affinity struct {
+	// PreferredContainer names the backend the process should run in.
+	PreferredContainer string
+	// FollowVolume names a volume whose data the process needs. The process
+	// goes back to the container that last ran a process following the
+	// volume, or else to any backend that mounts it.
+	FollowVolume string
+}
+
+// Placement records where PlaceProcess put a process and why.
+type Placement struct {
+	Container string
+	Reason    string
+}
+
+// Placement reasons.
+const (
+	PlacedPreferred  = "preferred container"
+	PlacedVolume     = "follows volume"
+	PlacedRoundRobin = "round-robin"
+	PlacedBestFit    = "best fit"
+)
+
+// PlaceProcess adds p to one of the ready backends of service, honouring
+// p.Affinity. When the preferred location is not available the process
+// falls back to the service's normal round-robin choice and an
+// EventPlacementFallback is emitted. The chosen container and reason are
+// recorded in p.Placement.
+func (k *Kernel) PlaceProcess(service string, p *Process) (*Container, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	svc, ok := k.services[service]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, service)
+	}
+	ready := k.readyBackendsLocked(svc)
+	if len(ready) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoReadyBackend, service)
+	}
+
+	id, reason, wanted := k.preferredLocked(p.Affinity, ready)
+	if id == "" {
+		id, reason = ready[svc.next%len(ready)], PlacedRoundRobin
+		svc.next++
+		if wanted != "" {
+			k.emit(EventPlacementFallback, id, p.Name, fmt.Sprintf("%s unavailable, placed on %s", wanted, id))
+		}
+	}
+
+	c := k.Containers[id]
+	if v := p.Affinity.FollowVolume; v != "" {
+		k.volumeHomes[v] = id
+	}
+	p.Placement = Placement{Container: id, Reason: reason}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addProcessLocked(p)
+	c.scheduleLocked()
+	return c, nil
+}
+
+// preferredLocked returns the ready backend a's hints point at, or an empty
+// id and a description of what was wanted when none of them is available.
+func (k *Kernel) preferredLocked(a Affinity, ready []string) (id, reason, wanted string) {
+	if pc := a.PreferredContainer; pc != "" {
+		if containsString(ready, pc) {
+			return pc, PlacedPreferred, ""
+		}
+		wanted = "preferred container " + pc
+	}
+	if v := a.FollowVolume; v != "" {
+		if home, ok := k.volumeHomes[v]; ok {
+			if containsString(ready, home) {
+				return home, PlacedVolume + " " + v, ""
+			}
+			if wanted == "" {
+				wanted = fmt.Sprintf("volume %s home %s", v, home)
+			}
+		}
+		for _, id := range ready {
+			c := k.Containers[id]
+			c.mu.Lock()
+			mounted := containsString(c.Volumes, v)
+			c.mu.Unlock()
+			if mounted {
+				return id, PlacedVolume + " " + v, ""
+			}
+		}
+		if wanted == "" {
+			wanted = "volume " + v
+		}
+	}
+	return "", "", wanted
+}
+
+// --- Bin Packing ---
+
+// ErrNoCapacity is returned by Place when no container has room for the
+// process.
+var ErrNoCapacity = errors.New("no container has capacity for the process")
+
+// Place adds p to the container it fits best, and returns its ID. A
+// container has room for p when the memory and CPU weight requested by its
+// queued and running processes leave at least p.MemoryMB and, if it has a
+// CPULimit, p.CPUWeight free. Of those, the one left with the least free
+// memory wins, then the least free CPU, then the lowest ID, so processes
+// are packed tightly and large gaps stay open for large processes. Only
+// created or running containers are considered; jobs never are.
+func (k *Kernel) Place(p *Process) (string, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var best *Container
+	var bestMem int
+	var bestCPU float64
+	for _, c := range k.sortedContainersLocked() {
+		c.mu.Lock()
+		mem, cpu, ok := c.spareForLocked(p)
+		c.mu.Unlock()
+		if ok && (best == nil || mem < bestMem || mem == bestMem && cpu < bestCPU) {
+			best, bestMem, bestCPU = c, mem, cpu
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("%w: %s needs %dMB and %.2f CPU", ErrNoCapacity, p.Name, p.MemoryMB, p.CPUWeight)
+	}
+	p.Placement = Placement{Container: best.ID, Reason: PlacedBestFit}
+	best.mu.Lock()
+	defer best.mu.Unlock()
+	best.addProcessLocked(p)
+	best.scheduleLocked()
+	fmt.Printf("[Kernel] Placed %s in %s, leaving %dMB free\n", p.Name, best.Name, bestMem)
+	return best.ID, nil
+}
+
+// spareForLocked reports whether p fits in c and the memory and CPU weight
+// that would be left free; a container without a CPULimit has unlimited
+// CPU, counted as zero spare so it does not lose ties to tight ones.
+func (c *Container) spareForLocked(p *Process) (mem int, cpu float64, ok bool) {
+	if c.State != ContainerCreated && c.State != ContainerRunning || c.job != nil {
+		return 0, 0, false
+	}
+	reqCPU, reqMem := c.requestedLocked()
+	mem = c.MemoryMB - reqMem - p.MemoryMB
+	if c.CPULimit > 0 {
+		cpu = c.CPULimit - reqCPU - p.CPUWeight
+	}
+	return mem, cpu, mem >= 0 && cpu >= 0
+}
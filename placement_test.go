@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlaceProcessFollowsAffinity(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "cache", "r1", "r2", "r3")
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventPlacementFallback}})
+	defer cancel()
+
+	warm := func() *Process {
+		return &Process{Name: "warm", Action: noop, Affinity: Affinity{FollowVolume: "data"}}
+	}
+	k.Containers["r2"].Volumes = []string{"data"}
+	first := warm()
+	if _, err := k.PlaceProcess("cache", first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Placement != (Placement{Container: "r2", Reason: PlacedVolume + " data"}) {
+		t.Fatalf("first run placed %+v, want r2 for its volume", first.Placement)
+	}
+
+	// The re-run goes back to r2 while it is healthy.
+	again := warm()
+	if _, err := k.PlaceProcess("cache", again); err != nil {
+		t.Fatal(err)
+	}
+	if again.Placement.Container != "r2" {
+		t.Errorf("re-run placed on %s, want r2", again.Placement.Container)
+	}
+	if got := k.Containers["r2"].Inspect().Processes[1].Placement; got != again.Placement {
+		t.Errorf("Inspect placement = %+v, want %+v", got, again.Placement)
+	}
+
+	k.Containers["r2"].StopProcesses()
+	fallback := warm()
+	c, err := k.PlaceProcess("cache", fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID == "r2" || fallback.Placement.Reason != PlacedRoundRobin {
+		t.Errorf("placed %+v with r2 stopped", fallback.Placement)
+	}
+	e := nextEvent(t, events)
+	if e.ContainerID != c.ID || e.Process != "warm" || e.Detail != "volume data home r2 unavailable, placed on "+c.ID {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+func TestPlaceProcessPreferredContainer(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "cache", "r1", "r2")
+	p := &Process{Name: "job", Action: noop, Affinity: Affinity{PreferredContainer: "r2"}}
+	if _, err := k.PlaceProcess("cache", p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Placement != (Placement{Container: "r2", Reason: PlacedPreferred}) {
+		t.Errorf("placed %+v, want the preferred r2", p.Placement)
+	}
+}
+
+func TestPlaceBinPacksIntoBestFit(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, spec := range []struct {
+		id     string
+		memory int
+	}{{"big", 1024}, {"medium", 512}, {"small", 256}, {"stopped", 128}} {
+		if _, err := k.CreateContainer(spec.id, spec.id, spec.memory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k.Containers["small"].AddProcess(&Process{Name: "resident", MemoryMB: 200})
+	k.Containers["stopped"].StopProcesses()
+	place := func(p *Process) string {
+		t.Helper()
+		id, err := k.Place(p)
+		if err != nil {
+			t.Fatalf("Place(%s): %v", p.Name, err)
+		}
+		if p.Placement != (Placement{Container: id, Reason: PlacedBestFit}) {
+			t.Errorf("%s placement = %+v", p.Name, p.Placement)
+		}
+		return id
+	}
+
+	// 100MB fits in big and medium; medium is the tighter fit, and the
+	// stopped container is never considered.
+	if got := place(&Process{Name: "api", MemoryMB: 100}); got != "medium" {
+		t.Errorf("100MB process placed in %s, want medium", got)
+	}
+	if got := place(&Process{Name: "sidecar", MemoryMB: 50}); got != "small" {
+		t.Errorf("50MB process placed in %s, want small with 56MB free", got)
+	}
+	// Placed processes count: small now has 6MB free.
+	if got := place(&Process{Name: "worker", MemoryMB: 50}); got != "medium" {
+		t.Errorf("second 50MB process placed in %s, want medium", got)
+	}
+	if _, err := k.Place(&Process{Name: "huge", MemoryMB: 2048}); !errors.Is(err, ErrNoCapacity) {
+		t.Errorf("oversized process: %v, want ErrNoCapacity", err)
+	}
+}
+
+func TestPlaceRespectsCPULimit(t *testing.T) {
+	k, _ := newTestKernel(t)
+	tight := newTestContainer(t, k, "a-tight")
+	tight.CPULimit = 1
+	tight.AddProcess(&Process{Name: "busy", CPUWeight: 0.5})
+	newTestContainer(t, k, "b-loose")
+	if id, err := k.Place(&Process{Name: "cruncher", CPUWeight: 0.75}); err != nil || id != "b-loose" {
+		t.Errorf("Place = %s, %v; want the container with CPU to spare", id, err)
+	}
+	if id, err := k.Place(&Process{Name: "light", CPUWeight: 0.5}); err != nil || id != "a-tight" {
+		t.Errorf("Place = %s, %v; want the exact CPU fit", id, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// --- Process Groups ---
+
+// RunGroup starts the container if it is not running and waits for every
+// unfinished process whose Group is group, like an errgroup. It returns
+// the first member failure, an error wrapping the member's Err, or nil
+// once all members have finished without one. With failFast the first
+// failed or killed member cancels the rest: they are stopped and an
+// EventGroupCancelled is emitted. Cancelling ctx stops the remaining
+// members and returns ctx's error.
+func (c *Container) RunGroup(ctx context.Context, group string, failFast bool) error {
+	c.mu.Lock()
+	var members []*Process
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled, Running:
+			if p.Group == group {
+				members = append(members, p)
+			}
+		}
+	}
+	running := c.State == ContainerRunning
+	c.mu.Unlock()
+	if len(members) == 0 {
+		return nil
+	}
+	if !running {
+		if err := c.StartProcesses(); err != nil {
+			return err
+		}
+	}
+
+	done := make(chan *Process, len(members))
+	for _, p := range members {
+		go func() {
+			<-p.Done()
+			done <- p
+		}()
+	}
+	var first error
+	for range members {
+		select {
+		case p := <-done:
+			c.mu.Lock()
+			err := memberErrLocked(p)
+			if err != nil && first == nil {
+				first = fmt.Errorf("group %s: %w", group, err)
+				if failFast {
+					c.cancelGroupLocked(group, members, fmt.Sprintf("%s %s", p.Name, p.State))
+				}
+			}
+			c.mu.Unlock()
+		case <-ctx.Done():
+			c.mu.Lock()
+			c.cancelGroupLocked(group, members, ctx.Err().Error())
+			c.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	return first
+}
+
+// memberErrLocked is the failure of a finished group member, nil if it
+// completed or was stopped.
+func memberErrLocked(p *Process) error {
+	switch p.State {
+	case Failed, Killed:
+		if p.Err != nil {
+			return fmt.Errorf("process %s %s: %w", p.Name, p.State, p.Err)
+		}
+		return fmt.Errorf("process %s %s", p.Name, p.State)
+	}
+	return nil
+}
+
+// cancelGroupLocked stops the group members that have not finished.
+func (c *Container) cancelGroupLocked(group string, members []*Process, reason string) {
+	n := 0
+	for _, p := range members {
+		switch p.State {
+		case Pending, Throttled, Running:
+			c.finishLocked(p, Stopped)
+			if p.cancel != nil {
+				p.cancel()
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	detail := fmt.Sprintf("%s: cancelled %d members after %s", group, n, reason)
+	fmt.Printf("[Kernel] Group %s in %s\n", detail, c.Name)
+	c.emit(EventGroupCancelled, nil, detail)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+var errBadRow = errors.New("bad row")
+
+func failWith(err error) ActionFunc {
+	return func(context.Context, *Handle) error { return err }
+}
+
+func TestFailFastGroupCancelsSiblings(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventGroupCancelled}})
+	defer cancel()
+	c := newTestContainer(t, k, "etl")
+	extract := &Process{Name: "extract", Group: "pipeline", Action: blockUntil(nil)}
+	load := &Process{Name: "load", Group: "pipeline", Action: failWith(errBadRow)}
+	index := &Process{Name: "index", Group: "pipeline", Action: blockUntil(nil)}
+	other := &Process{Name: "other", Action: blockUntil(nil)}
+	for _, p := range []*Process{extract, load, index, other} {
+		c.AddProcess(p)
+	}
+
+	err := c.RunGroup(context.Background(), "pipeline", true)
+	if !errors.Is(err, errBadRow) || err.Error() != "group pipeline: process load Failed: bad row" {
+		t.Fatalf("RunGroup = %v, want the first failure", err)
+	}
+	for _, p := range []*Process{extract, index} {
+		if got := stateOf(c, p); got != Stopped {
+			t.Errorf("sibling %s = %v, want Stopped", p.Name, got)
+		}
+	}
+	if got := stateOf(c, other); got != Running {
+		t.Errorf("non-member = %v, want left running", got)
+	}
+	if e := nextEvent(t, events); e.Detail != "pipeline: cancelled 2 members after load Failed" {
+		t.Errorf("event = %q", e.Detail)
+	}
+	c.StopProcesses()
+}
+
+func TestGroupWithoutFailFastWaitsForAll(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	release := make(chan struct{})
+	slow := &Process{Name: "slow", Group: "g", Action: blockUntil(release)}
+	c.AddProcess(slow)
+	bad := &Process{Name: "bad", Group: "g", Action: failWith(errBadRow)}
+	c.AddProcess(bad)
+	result := make(chan error)
+	go func() { result <- c.RunGroup(context.Background(), "g", false) }()
+	waitDone(t, bad)
+	select {
+	case err := <-result:
+		t.Fatalf("RunGroup returned %v before every member finished", err)
+	default:
+	}
+	close(release)
+	if err := <-result; !errors.Is(err, errBadRow) {
+		t.Errorf("RunGroup = %v, want the member's failure", err)
+	}
+	if got := stateOf(c, slow); got != Completed {
+		t.Errorf("slow = %v, want left to complete", got)
+	}
+	if err := c.RunGroup(context.Background(), "empty", true); err != nil {
+		t.Errorf("group with no members: %v", err)
+	}
+}
+
+func TestCancelledContextStopsGroup(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "etl")
+	p := &Process{Name: "forever", Group: "g", Action: blockUntil(nil)}
+	c.AddProcess(p)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.RunGroup(ctx, "g", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunGroup = %v, want context.Canceled", err)
+	}
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Stopped {
+		t.Errorf("member = %v, want Stopped", got)
+	}
+}
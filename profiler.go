@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Execution Profiling ---
+
+const (
+	// profileReservoirSize bounds the durations kept per profiled name;
+	// beyond it, reservoir sampling keeps a uniform sample.
+	profileReservoirSize = 256
+	// profileSlowest is how many of the slowest executions are kept per
+	// profiled name.
+	profileSlowest = 5
+)
+
+// Kinds of profiled executions.
+const (
+	ProfileAction  = "action"  // a process Action, from start to finish
+	ProfileHandler = "handler" // a Request, from Recv to Reply
+)
+
+// ProfilingConfig configures execution profiling, see SetProfiling.
+type ProfilingConfig struct {
+	Enabled bool
+	// SlowThreshold, when positive, raises an EventSlowExecution for each
+	// execution that takes longer.
+	SlowThreshold time.Duration
+}
+
+// SlowExecution is one of the slowest executions of a profiled name.
+type SlowExecution struct {
+	Duration  time.Duration `json:"duration"`
+	Container string        `json:"container"`
+	PID       int           `json:"pid"`
+	TraceID   uint64        `json:"trace_id,omitempty"`
+	At        time.Time     `json:"at"` // when it ended
+}
+
+// ExecutionProfile summarises the executions of the actions or request
+// handlers of processes named Name. The percentiles are estimated from a
+// uniform sample of the executions.
+type ExecutionProfile struct {
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	Count   uint64          `json:"count"`
+	P50     time.Duration   `json:"p50"`
+	P95     time.Duration   `json:"p95"`
+	P99     time.Duration   `json:"p99"`
+	Slowest []SlowExecution `json:"slowest"` // slowest first
+}
+
+type profileKey struct{ kind, name string }
+
+type execSamples struct {
+	count     uint64
+	reservoir []time.Duration
+	slowest   []SlowExecution
+}
+
+// profiler keeps execution samples by kind and process name. It has its
+// own mutex so executions are recorded under container locks.
+type profiler struct {
+	enabled   atomic.Bool
+	threshold atomic.Int64
+	mu        sync.Mutex
+	samples   map[profileKey]*execSamples
+}
+
+// SetProfiling turns execution profiling on or off. While it is on, every
+// process action and request handler costs one clock reading when it ends.
+// Turning it on resets previously collected samples.
+func (k *Kernel) SetProfiling(cfg ProfilingConfig) {
+	p := &k.profiler
+	p.threshold.Store(int64(cfg.SlowThreshold))
+	if cfg.Enabled && !p.enabled.Load() {
+		p.mu.Lock()
+		p.samples = nil
+		p.mu.Unlock()
+	}
+	p.enabled.Store(cfg.Enabled)
+}
+
+// Profiles returns the execution profiles, by kind and then name. It is
+// nil unless profiling is enabled.
+func (k *Kernel) Profiles() []ExecutionProfile {
+	p := &k.profiler
+	if !p.enabled.Load() {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ExecutionProfile, 0, len(p.samples))
+	for key, s := range p.samples {
+		sorted := slices.Sorted(slices.Values(s.reservoir))
+		out = append(out, ExecutionProfile{
+			Kind:    key.kind,
+			Name:    key.name,
+			Count:   s.count,
+			P50:     percentile(sorted, 0.50),
+			P95:     percentile(sorted, 0.95),
+			P99:     percentile(sorted, 0.99),
+			Slowest: slices.Clone(s.slowest),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// percentile returns the nearest-rank q-quantile of sorted.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// profileLocked records an execution of p's kind that took d and ended at
+// now.
+func (c *Container) profileLocked(kind string, p *Process, d time.Duration, traceID uint64, now time.Time) {
+	k := c.kernel
+	if k == nil || p.debug || !k.profiler.enabled.Load() {
+		return
+	}
+	pr := &k.profiler
+	pr.mu.Lock()
+	if pr.samples == nil {
+		pr.samples = make(map[profileKey]*execSamples)
+	}
+	key := profileKey{kind, p.Name}
+	s := pr.samples[key]
+	if s == nil {
+		s = &execSamples{}
+		pr.samples[key] = s
+	}
+	s.count++
+	if len(s.reservoir) < profileReservoirSize {
+		s.reservoir = append(s.reservoir, d)
+	} else if i := rand.Int63n(int64(s.count)); i < profileReservoirSize {
+		s.reservoir[i] = d
+	}
+	if len(s.slowest) < profileSlowest || d > s.slowest[len(s.slowest)-1].Duration {
+		exec := SlowExecution{Duration: d, Container: c.ID, PID: p.PID, TraceID: traceID, At: now}
+		i := sort.Search(len(s.slowest), func(i int) bool { return s.slowest[i].Duration < d })
+		s.slowest = slices.Insert(s.slowest, i, exec)
+		if len(s.slowest) > profileSlowest {
+			s.slowest = s.slowest[:profileSlowest]
+		}
+	}
+	pr.mu.Unlock()
+
+	if threshold := time.Duration(pr.threshold.Load()); threshold > 0 && d > threshold {
+		fmt.Printf("[Kernel] Slow %s of %s in %s: %v\n", kind, p.Name, c.Name, d)
+		c.emit(EventSlowExecution, p, fmt.Sprintf("%s took %v, threshold %v", kind, d, threshold))
+	}
+}
+
+// profileActionLocked records the run of p's action that just finished.
+func (c *Container) profileActionLocked(p *Process) {
+	var traceID uint64
+	if p.handle != nil {
+		traceID = p.handle.traceID // of the last message it handled
+	}
+	c.profileLocked(ProfileAction, p, p.finishedAt.Sub(p.startedAt), traceID, p.finishedAt)
+}
+
+// requestReceivedLocked starts timing the handling of request m, if
+// profiling is enabled.
+func (h *Handle) requestReceivedLocked(m Message, now time.Time) {
+	k := h.container.kernel
+	if k == nil || !k.profiler.enabled.Load() {
+		return
+	}
+	if h.handling == nil {
+		h.handling = make(map[uint64]time.Time)
+	}
+	h.handling[m.RequestID] = now
+}
+
+// requestRepliedLocked records the handling of request req, answered at
+// now.
+func (h *Handle) requestRepliedLocked(req Message, now time.Time) {
+	since, ok := h.handling[req.RequestID]
+	if !ok {
+		return
+	}
+	delete(h.handling, req.RequestID)
+	h.container.profileLocked(ProfileHandler, h.proc, now.Sub(since), req.TraceID, now)
+}
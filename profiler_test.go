@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProfilingActionPercentiles(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.SetProfiling(ProfilingConfig{Enabled: true})
+	c := newTestContainer(t, k, "batch")
+	// Twenty runs of "job" taking 1s to 20s.
+	for i := 1; i <= 20; i++ {
+		release := make(chan struct{})
+		p := &Process{Name: "job", Action: blockUntil(release)}
+		c.AddProcess(p)
+		if i == 1 {
+			if err := c.StartProcesses(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		eventually(t, "job running", func() bool { return stateOf(c, p) == Running })
+		clk.Advance(time.Duration(i) * time.Second)
+		close(release)
+		waitDone(t, p)
+	}
+
+	profiles := k.Profiles()
+	if len(profiles) != 1 {
+		t.Fatalf("profiles = %+v", profiles)
+	}
+	got := profiles[0]
+	if got.Kind != ProfileAction || got.Name != "job" || got.Count != 20 {
+		t.Errorf("profile %s/%s with %d runs", got.Kind, got.Name, got.Count)
+	}
+	if got.P50 != 10*time.Second || got.P95 != 19*time.Second || got.P99 != 20*time.Second {
+		t.Errorf("p50 %v, p95 %v, p99 %v; want 10s, 19s, 20s", got.P50, got.P95, got.P99)
+	}
+	if len(got.Slowest) != profileSlowest || got.Slowest[0].Duration != 20*time.Second || got.Slowest[4].Duration != 16*time.Second {
+		t.Errorf("slowest = %+v, want 20s down to 16s", got.Slowest)
+	}
+	if s := k.Stats().Profiles; len(s) != 1 {
+		t.Errorf("Stats().Profiles = %+v", s)
+	}
+
+	k.SetProfiling(ProfilingConfig{})
+	if p := k.Profiles(); p != nil {
+		t.Errorf("profiles while disabled = %+v", p)
+	}
+	k.SetProfiling(ProfilingConfig{Enabled: true})
+	if p := k.Profiles(); len(p) != 0 {
+		t.Errorf("re-enabling kept %+v", p)
+	}
+}
+
+func TestProfilingHandlersAndSlowExecutions(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.SetProfiling(ProfilingConfig{Enabled: true, SlowThreshold: 3 * time.Second})
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventSlowExecution}})
+	defer cancel()
+	api := newTestContainer(t, k, "api")
+	received, proceed := make(chan struct{}), make(chan struct{})
+	api.AddProcess(&Process{Name: "echo", Action: func(ctx context.Context, h *Handle) error {
+		for {
+			req, err := h.Recv()
+			if err != nil {
+				return nil
+			}
+			received <- struct{}{}
+			<-proceed
+			h.Reply(req, req.Payload)
+		}
+	}})
+	if err := api.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer api.StopProcesses()
+	send, results := requestClient(t, k)
+
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second} {
+		send <- "ping"
+		<-received
+		clk.Advance(d)
+		proceed <- struct{}{}
+		if err := <-results; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the execution over the threshold is reported.
+	if e := nextEvent(t, events); e.ContainerID != "api" || e.Detail != "handler took 4s, threshold 3s" {
+		t.Errorf("event = %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+
+	var handler ExecutionProfile
+	for _, p := range k.Profiles() {
+		if p.Kind == ProfileHandler {
+			handler = p
+		}
+	}
+	if handler.Name != "echo" || handler.Count != 4 || handler.P50 != 2*time.Second || handler.P95 != 4*time.Second {
+		t.Errorf("handler profile = %+v", handler)
+	}
+	if slow := handler.Slowest[0]; slow.Duration != 4*time.Second || slow.Container != "api" || slow.TraceID == 0 {
+		t.Errorf("slowest = %+v, want the 4s request with its trace", slow)
+	}
+
+	rec := httptest.NewRecorder()
+	k.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/bvisor/profiles", nil))
+	var served []ExecutionProfile
+	mustDecode(t, rec.Body.Bytes(), &served)
+	if len(served) != 1 || served[0].Count != 4 {
+		t.Errorf("/debug/bvisor/profiles = %+v", served)
+	}
+}
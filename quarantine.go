@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Quarantine ---
+
+// ErrQuarantined is returned when starting a quarantined container.
+var ErrQuarantined = errors.New("container is quarantined")
+
+// QuarantinePolicy quarantines a container automatically once Failures of
+// its processes have failed within Window; a zero Window counts every
+// failure since the container was last released. Zero Failures disables
+// automatic quarantine.
+type QuarantinePolicy struct {
+	Failures int
+	Window   time.Duration
+}
+
+// noteFailureLocked records a failed process and quarantines the container
+// if that takes it past its QuarantinePolicy.
+func (c *Container) noteFailureLocked(p *Process) {
+	policy := c.Quarantine
+	if p.State != Failed || policy.Failures <= 0 || c.quarantined {
+		return
+	}
+	now := c.now()
+	c.failures = append(c.failures, now)
+	if policy.Window > 0 {
+		i := 0
+		for i < len(c.failures) && now.Sub(c.failures[i]) > policy.Window {
+			i++
+		}
+		c.failures = c.failures[i:]
+	}
+	if len(c.failures) >= policy.Failures {
+		c.quarantineLocked(fmt.Sprintf("%d process failures", len(c.failures)))
+	}
+}
+
+// quarantineLocked stops the container from scheduling: queued processes
+// stay queued, running ones are left to finish, and services and dispatch
+// pools route around it.
+func (c *Container) quarantineLocked(reason string) {
+	c.quarantined = true
+	if c.State != ContainerStopped {
+		c.State = ContainerQuarantined
+	}
+	fmt.Printf("[Kernel] Quarantined container %s: %s\n", c.Name, reason)
+	c.emit(EventQuarantined, nil, reason)
+}
+
+// Quarantine quarantines container id by hand. It stays quarantined, and
+// StartProcesses fails with ErrQuarantined, until Unquarantine.
+func (k *Kernel) Quarantine(id, reason string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.quarantined {
+		c.quarantineLocked(reason)
+	}
+	return nil
+}
+
+// Unquarantine releases container id and resumes scheduling if it was
+// running when quarantined. Its failure count starts afresh.
+func (k *Kernel) Unquarantine(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.quarantined {
+		return nil
+	}
+	c.quarantined = false
+	c.failures = nil
+	fmt.Printf("[Kernel] Released container %s from quarantine\n", c.Name)
+	c.emit(EventUnquarantined, nil, "")
+	switch {
+	case c.State != ContainerQuarantined:
+	case c.frozen:
+		c.State = ContainerFrozen
+	default:
+		c.State = ContainerRunning
+		c.scheduleLocked()
+	}
+	return nil
+}
+
+// IsQuarantined reports whether the container is quarantined.
+func (c *Container) IsQuarantined() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quarantined
+}
+
+// takesTraffic reports whether services and dispatch pools may route to
+// the container.
+func (c *Container) takesTraffic() bool {
+	return c.State != ContainerStopped && c.State != ContainerQuarantined
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRepeatedFailuresQuarantineContainer(t *testing.T) {
+	k, clk := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventQuarantined, EventUnquarantined}})
+	defer cancel()
+	c := newTestContainer(t, k, "flaky")
+	c.MaxRunning = 1
+	c.Quarantine = QuarantinePolicy{Failures: 3, Window: time.Minute}
+	var procs []*Process
+	for i := 0; i < 5; i++ {
+		p := &Process{Name: fmt.Sprintf("job-%d", i), Action: func(ctx context.Context, h *Handle) error {
+			return errors.New("boom")
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventQuarantined || e.Detail != "3 process failures" {
+		t.Errorf("event = %+v", e)
+	}
+	for _, p := range procs[:3] {
+		waitDone(t, p)
+	}
+	if !c.IsQuarantined() || c.Inspect().State != ContainerQuarantined {
+		t.Fatalf("state = %v after three failures, want quarantined", c.Inspect().State)
+	}
+	for _, p := range procs[3:] {
+		if got := stateOf(c, p); got != Pending {
+			t.Errorf("%s = %v while quarantined, want still queued", p.Name, got)
+		}
+	}
+	c.AddProcess(&Process{Name: "late", Action: noop})
+	if err := c.StartProcesses(); !errors.Is(err, ErrQuarantined) {
+		t.Errorf("StartProcesses while quarantined: %v", err)
+	}
+
+	// Released, the rest run; two more failures are under the threshold.
+	clk.Advance(time.Hour)
+	if err := k.Unquarantine("flaky"); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventUnquarantined {
+		t.Errorf("event = %+v", e)
+	}
+	for _, p := range procs[3:] {
+		waitDone(t, p)
+	}
+	if c.IsQuarantined() {
+		t.Error("quarantined again by failures under the threshold")
+	}
+}
+
+func TestQuarantineFailureWindow(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "flaky")
+	c.Quarantine = QuarantinePolicy{Failures: 2, Window: time.Minute}
+	fail := func() {
+		t.Helper()
+		p := &Process{Name: "job", Action: func(ctx context.Context, h *Handle) error {
+			return errors.New("boom")
+		}}
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, p)
+	}
+	fail()
+	clk.Advance(2 * time.Minute)
+	fail()
+	if c.IsQuarantined() {
+		t.Fatal("failures further apart than the window quarantined the container")
+	}
+	clk.Advance(30 * time.Second)
+	fail()
+	if !c.IsQuarantined() {
+		t.Error("two failures within the window did not quarantine the container")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --- Delivery Receipts ---
+
+// receiptLimit bounds the tracked messages; the oldest are forgotten first.
+const receiptLimit = 4096
+
+// MessageStatus is how far a message has got towards its recipient.
+type MessageStatus int
+
+const (
+	// MessageQueued is held by the kernel for later delivery, see SendAfter.
+	MessageQueued MessageStatus = iota
+	// MessageDelivered is in the recipient's inbox, or on its way to a
+	// waiting requester.
+	MessageDelivered
+	// MessageConsumed was taken by a process with Recv or as a reply.
+	MessageConsumed
+	// MessageExpired was still unread when its recipient was removed.
+	MessageExpired
+	// MessageDropped was dead-lettered, or cancelled before delivery.
+	MessageDropped
+)
+
+func (s MessageStatus) String() string {
+	switch s {
+	case MessageQueued:
+		return "Queued"
+	case MessageDelivered:
+		return "Delivered"
+	case MessageConsumed:
+		return "Consumed"
+	case MessageExpired:
+		return "Expired"
+	case MessageDropped:
+		return "Dropped"
+	}
+	return fmt.Sprintf("MessageStatus(%d)", int(s))
+}
+
+// final reports whether s can no longer change.
+func (s MessageStatus) final() bool {
+	return s >= MessageConsumed
+}
+
+// MessageReceipt is the delivery record of one message.
+type MessageReceipt struct {
+	ID     uint64
+	From   string
+	To     string
+	Status MessageStatus
+	Reason string    // why the message was dropped or expired
+	At     time.Time // when Status was reached, on the kernel clock
+}
+
+// receiptLog tracks the status of recent messages. It has its own mutex so
+// container code can record consumption without taking the kernel lock.
+type receiptLog struct {
+	mu       sync.Mutex
+	receipts map[uint64]*MessageReceipt
+	order    []uint64 // message IDs, oldest first
+}
+
+// record sets the status of m, tracking it if it is new. A final status is
+// not changed.
+func (l *receiptLog) record(m Message, status MessageStatus, reason string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.receipts == nil {
+		l.receipts = make(map[uint64]*MessageReceipt)
+	}
+	r, ok := l.receipts[m.ID]
+	if !ok {
+		r = &MessageReceipt{ID: m.ID, From: m.From, To: m.To}
+		l.receipts[m.ID] = r
+		l.order = append(l.order, m.ID)
+		if len(l.order) > receiptLimit {
+			delete(l.receipts, l.order[0])
+			l.order = l.order[1:]
+		}
+	} else if r.Status.final() {
+		return
+	}
+	r.To = m.To
+	r.Status, r.Reason, r.At = status, reason, at
+}
+
+// update moves an already tracked message to status.
+func (l *receiptLog) update(id uint64, status MessageStatus, reason string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.receipts[id]; ok && !r.Status.final() {
+		r.Status, r.Reason, r.At = status, reason, at
+	}
+}
+
+// MessageStatus reports the delivery status of message id. It reports
+// false if the message is unknown or has been forgotten.
+func (k *Kernel) MessageStatus(id uint64) (MessageStatus, bool) {
+	r, ok := k.MessageReceipt(id)
+	return r.Status, ok
+}
+
+// MessageReceipt returns the delivery record of message id.
+func (k *Kernel) MessageReceipt(id uint64) (MessageReceipt, bool) {
+	l := &k.receipts
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.receipts[id]; ok {
+		return *r, true
+	}
+	return MessageReceipt{}, false
+}
+
+// expireInboxLocked marks the unread messages of a removed container as
+// expired. The caller holds the container lock.
+func (c *Container) expireInboxLocked() {
+	k := c.kernel
+	if k == nil {
+		return
+	}
+	now := k.Clock.Now()
+	for _, m := range c.inbox {
+		k.receipts.update(m.ID, MessageExpired, "recipient removed", now)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMessageStatusFollowsDelivery(t *testing.T) {
+	k, clk := newTestKernel(t)
+	newTestContainer(t, k, "sender")
+	inbox := newTestContainer(t, k, "inbox")
+	inbox.AddProcess(&Process{Name: "listener"})
+	if err := k.SendMessage("sender", "inbox", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	id := inbox.Inbox()[0].ID
+	if s, ok := k.MessageStatus(id); !ok || s != MessageDelivered {
+		t.Fatalf("status = %v, %v; want Delivered", s, ok)
+	}
+
+	clk.Advance(time.Second)
+	got := make(chan Message, 1)
+	reader := &Process{Name: "reader", Action: func(ctx context.Context, h *Handle) error {
+		m, err := h.Recv()
+		got <- m
+		return err
+	}}
+	inbox.AddProcess(reader)
+	if err := inbox.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, reader)
+	if m := <-got; m.ID != id {
+		t.Fatalf("read message %d, want %d", m.ID, id)
+	}
+	r, ok := k.MessageReceipt(id)
+	if !ok || r.Status != MessageConsumed || r.From != "sender" || r.To != "inbox" || !r.At.Equal(testEpoch.Add(time.Second)) {
+		t.Errorf("receipt = %+v, %v; want consumed at 1s", r, ok)
+	}
+	if _, ok := k.MessageStatus(id + 1000); ok {
+		t.Error("status of a message never sent")
+	}
+}
+
+func TestMessageStatusDroppedAndExpired(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "sender")
+	doomed := newTestContainer(t, k, "doomed")
+	doomed.AddProcess(&Process{Name: "listener"})
+
+	sm, err := k.SendAfter("sender", "doomed", "later", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := k.MessageStatus(sm.MessageID); s != MessageQueued {
+		t.Errorf("scheduled message is %v, want Queued", s)
+	}
+	sm.Cancel()
+	if r, _ := k.MessageReceipt(sm.MessageID); r.Status != MessageDropped {
+		t.Errorf("cancelled message: %+v, want Dropped", r)
+	}
+
+	if err := k.SendMessage("sender", "doomed", "unread"); err != nil {
+		t.Fatal(err)
+	}
+	id := doomed.Inbox()[0].ID
+	if err := k.RemoveContainer("doomed"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := k.MessageStatus(id); s != MessageExpired {
+		t.Errorf("unread message of a removed container is %v, want Expired", s)
+	}
+}
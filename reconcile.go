@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// --- Reconciliation ---
+
+// DesiredState declares every container the kernel should have.
+type DesiredState struct {
+	Containers []DesiredContainer `json:"containers"`
+}
+
+// DesiredContainer declares a container and how many copies of each of its
+// processes should be queued or running.
+type DesiredContainer struct {
+	ContainerSpec
+	Processes []DesiredProcess `json:"processes,omitempty"`
+}
+
+// DesiredProcess asks for Count live processes named Name, built from the
+// spec's Kind.
+type DesiredProcess struct {
+	ProcessSpec
+	Count int `json:"count"`
+}
+
+// Reconcile actions.
+const (
+	ReconcileCreate = "create"
+	ReconcileUpdate = "update"
+	ReconcileStart  = "start"
+	ReconcileAdd    = "add"
+	ReconcileStop   = "stop"
+	ReconcileRemove = "remove"
+)
+
+// ReconcileAction is one change Reconcile makes, or would make.
+type ReconcileAction struct {
+	Op        string
+	Container string
+	Process   string // for add and stop
+	Count     int    // processes added or stopped
+}
+
+func (a ReconcileAction) String() string {
+	if a.Process != "" {
+		return fmt.Sprintf("%s %d %s in %s", a.Op, a.Count, a.Process, a.Container)
+	}
+	return fmt.Sprintf("%s %s", a.Op, a.Container)
+}
+
+// Reconcile makes one pass converging the kernel on desired: containers
+// that are missing are created and ones not declared are removed,
+// declared containers get their definition updated and are started, and
+// processes are added or stopped until each declared name has Count live
+// copies. Live processes with undeclared names are stopped; the newest
+// copies are stopped first. Quarantined and frozen containers keep their
+// processes as they are. Reconciling the same state again changes nothing.
+func (k *Kernel) Reconcile(desired DesiredState) error {
+	_, err := k.reconcile(desired, true)
+	return err
+}
+
+// ReconcilePlan returns the actions Reconcile would take, without taking
+// them; it is empty once the kernel has converged.
+func (k *Kernel) ReconcilePlan(desired DesiredState) ([]ReconcileAction, error) {
+	return k.reconcile(desired, false)
+}
+
+func (k *Kernel) reconcile(desired DesiredState, apply bool) ([]ReconcileAction, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	specs := make([]locatedSpec, len(desired.Containers))
+	byID := make(map[string]DesiredContainer, len(desired.Containers))
+	for i, dc := range desired.Containers {
+		specs[i] = locatedSpec{Spec: Spec{ContainerSpec: dc.ContainerSpec}, File: "<desired>", Line: i + 1}
+		for _, dp := range dc.Processes {
+			specs[i].Processes = append(specs[i].Processes, dp.ProcessSpec)
+		}
+		byID[dc.ID] = dc
+	}
+	ordered, errs := k.validateSpecs(specs)
+	for i, dc := range desired.Containers {
+		for _, dp := range dc.Processes {
+			if dp.Count < 0 {
+				errs = append(errs, specs[i].errorf("container %s: process %s: negative count %d", dc.ID, dp.Name, dp.Count))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var actions []ReconcileAction
+	for _, ls := range ordered {
+		dc := byID[ls.ID]
+		dc.DependsOn = ls.DependsOn
+		acts, err := k.reconcileContainerLocked(dc, apply)
+		actions = append(actions, acts...)
+		if err != nil {
+			return actions, err
+		}
+	}
+	var stale []*Container
+	for id, c := range k.Containers {
+		if _, ok := byID[id]; !ok {
+			stale = append(stale, c)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].ID < stale[j].ID })
+	for _, c := range stale {
+		actions = append(actions, ReconcileAction{Op: ReconcileRemove, Container: c.ID})
+		if apply {
+			k.removeContainerLocked(c)
+		}
+	}
+	if apply {
+		for _, a := range actions {
+			fmt.Printf("[Kernel] Reconcile: %v\n", a)
+		}
+	}
+	return actions, nil
+}
+
+func (k *Kernel) reconcileContainerLocked(dc DesiredContainer, apply bool) ([]ReconcileAction, error) {
+	var actions []ReconcileAction
+	spec := dc.ContainerSpec
+	c, ok := k.Containers[spec.ID]
+	if !ok {
+		actions = append(actions, ReconcileAction{Op: ReconcileCreate, Container: spec.ID})
+		if !apply {
+			for _, dp := range dc.Processes {
+				if dp.Count > 0 {
+					actions = append(actions, ReconcileAction{Op: ReconcileAdd, Container: spec.ID, Process: dp.Name, Count: dp.Count})
+				}
+			}
+			return append(actions, ReconcileAction{Op: ReconcileStart, Container: spec.ID}), nil
+		}
+		c = k.createContainerLocked(spec.ID, spec.Name, spec.MemoryMB)
+	}
+
+	c.mu.Lock()
+	if ok && !c.matchesSpecLocked(spec) {
+		actions = append(actions, ReconcileAction{Op: ReconcileUpdate, Container: spec.ID})
+	}
+	if apply {
+		c.applySpecLocked(spec)
+	}
+	if c.State == ContainerQuarantined || c.State == ContainerFrozen {
+		c.mu.Unlock()
+		return actions, nil
+	}
+
+	want := make(map[string]int, len(dc.Processes))
+	for _, dp := range dc.Processes {
+		want[dp.Name] = dp.Count
+	}
+	live := make(map[string][]*Process)
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled, Running:
+			if !p.debug {
+				live[p.Name] = append(live[p.Name], p)
+			}
+		}
+	}
+	for _, dp := range dc.Processes {
+		n := dp.Count - len(live[dp.Name])
+		if n <= 0 {
+			continue
+		}
+		actions = append(actions, ReconcileAction{Op: ReconcileAdd, Container: c.ID, Process: dp.Name, Count: n})
+		for ; apply && n > 0; n-- {
+			p, err := k.newProcessLocked(dp.ProcessSpec)
+			if err != nil {
+				c.mu.Unlock()
+				return actions, fmt.Errorf("container %s: %w", c.ID, err)
+			}
+			c.addProcessLocked(p)
+		}
+	}
+	names := slices.Sorted(maps.Keys(live))
+	for _, name := range names {
+		procs := live[name]
+		n := len(procs) - want[name]
+		if n <= 0 {
+			continue
+		}
+		actions = append(actions, ReconcileAction{Op: ReconcileStop, Container: c.ID, Process: name, Count: n})
+		if !apply {
+			continue
+		}
+		for _, p := range procs[len(procs)-n:] {
+			c.finishLocked(p, Stopped)
+			if p.cancel != nil {
+				p.cancel()
+			}
+		}
+	}
+	running := c.State == ContainerRunning
+	c.mu.Unlock()
+
+	if !running {
+		actions = append(actions, ReconcileAction{Op: ReconcileStart, Container: c.ID})
+		if apply {
+			if err := c.StartProcesses(); err != nil {
+				return actions, err
+			}
+		}
+	} else if apply {
+		c.mu.Lock()
+		c.scheduleLocked()
+		c.mu.Unlock()
+	}
+	return actions, nil
+}
+
+// matchesSpecLocked reports whether c is already defined as spec says.
+func (c *Container) matchesSpecLocked(spec ContainerSpec) bool {
+	return c.Name == spec.Name && c.MemoryMB == spec.MemoryMB &&
+		maps.Equal(c.Labels, spec.Labels) && maps.Equal(c.Env, spec.Env) &&
+		slices.Equal(c.Volumes, spec.Volumes) && slices.Equal(c.DependsOn, spec.DependsOn) &&
+		c.StopPriority == spec.StopPriority && c.BootPhase == spec.BootPhase
+}
+
+// applySpecLocked defines c as spec says.
+func (c *Container) applySpecLocked(spec ContainerSpec) {
+	c.Name = spec.Name
+	c.MemoryMB = spec.MemoryMB
+	c.Labels = copyStringMap(spec.Labels)
+	c.Env = copyStringMap(spec.Env)
+	c.Volumes = append([]string(nil), spec.Volumes...)
+	c.DependsOn = append([]string(nil), spec.DependsOn...)
+	c.StopPriority = spec.StopPriority
+	c.BootPhase = spec.BootPhase
+}
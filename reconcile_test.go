@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// liveByName counts c's queued and running processes by name.
+func liveByName(c *Container) map[string]int {
+	out := map[string]int{}
+	c.EachProcess(func(p *Process) {
+		switch p.State {
+		case Pending, Throttled, Running:
+			out[p.Name]++
+		}
+	})
+	return out
+}
+
+func webAndCache(web int) DesiredState {
+	return DesiredState{Containers: []DesiredContainer{
+		{
+			ContainerSpec: ContainerSpec{ID: "api", Name: "api", MemoryMB: 512, DependsOn: []string{"cache"}},
+			Processes:     []DesiredProcess{{ProcessSpec: ProcessSpec{Name: "web", Kind: "server"}, Count: web}},
+		},
+		{
+			ContainerSpec: ContainerSpec{ID: "cache", Name: "cache", MemoryMB: 256},
+			Processes:     []DesiredProcess{{ProcessSpec: ProcessSpec{Name: "redis", Kind: "server"}, Count: 1}},
+		},
+	}}
+}
+
+func TestReconcileConverges(t *testing.T) {
+	k, _ := newTestKernel(t)
+	var runs atomic.Int32
+	registerScriptKinds(k, &runs)
+	newTestContainer(t, k, "stale")
+	desired := webAndCache(2)
+
+	plan, err := k.ReconcilePlan(desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(plan) != "[create cache add 1 redis in cache start cache create api add 2 web in api start api remove stale]" {
+		t.Errorf("plan = %v", plan)
+	}
+	if err := k.Reconcile(desired); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Containers["stale"]; ok || len(k.Containers) != 2 {
+		t.Errorf("%d containers, want just api and cache", len(k.Containers))
+	}
+	api, cache := k.Containers["api"], k.Containers["cache"]
+	if got := liveByName(api); got["web"] != 2 || len(got) != 1 {
+		t.Errorf("api processes = %v", got)
+	}
+	if got := liveByName(cache); got["redis"] != 1 || len(got) != 1 {
+		t.Errorf("cache processes = %v", got)
+	}
+	eventually(t, "three servers running", func() bool { return runs.Load() == 3 })
+
+	// A second pass has nothing to do.
+	if plan, err := k.ReconcilePlan(desired); err != nil || len(plan) != 0 {
+		t.Errorf("plan after converging = %v, %v", plan, err)
+	}
+	if err := k.Reconcile(desired); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.Processes) != 2 || len(cache.Processes) != 1 || runs.Load() != 3 {
+		t.Errorf("reconciling again changed processes: api %d, cache %d, runs %d", len(api.Processes), len(cache.Processes), runs.Load())
+	}
+
+	// Scaling down stops the newest copy.
+	if err := k.Reconcile(webAndCache(1)); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, api.Processes[1])
+	if got := stateOf(api, api.Processes[0]); got != Running {
+		t.Errorf("oldest web = %v, want kept running", got)
+	}
+	api.StopProcesses()
+	cache.StopProcesses()
+}
+
+func TestReconcileRejectsNegativeCount(t *testing.T) {
+	k, _ := newTestKernel(t)
+	registerScriptKinds(k, new(atomic.Int32))
+	if err := k.Reconcile(webAndCache(-1)); err == nil || len(k.Containers) != 0 {
+		t.Errorf("Reconcile = %v with %d containers, want an error and no changes", err, len(k.Containers))
+	}
+	var errs SpecErrors
+	if err := k.Reconcile(webAndCache(-1)); !errors.As(err, &errs) {
+		t.Errorf("error %T, want SpecErrors", err)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Topic Retention and Replay ---
+
+// replayRetry is how long a replay waits for room in a full mailbox.
+const replayRetry = 10 * time.Millisecond
+
+// TopicRetention keeps a topic's published messages for replay to new
+// subscribers: at most MaxMessages of them, and none older than MaxAge.
+// Zero fields do not bound retention; the zero value retains nothing.
+type TopicRetention struct {
+	MaxMessages int
+	MaxAge      time.Duration
+}
+
+func (r TopicRetention) enabled() bool {
+	return r.MaxMessages > 0 || r.MaxAge > 0
+}
+
+type retainedMessage struct {
+	msg Message
+	at  time.Time // kernel time of publication
+}
+
+// topicLog numbers a topic's messages and retains them under its
+// retention. Entries a replay has not reached yet are kept regardless,
+// since live publication skips replaying subscribers. It is guarded by
+// the kernel lock.
+type topicLog struct {
+	retention TopicRetention
+	last      uint64 // offset of the latest message
+	entries   []retainedMessage
+	replays   map[*topicSub]struct{}
+}
+
+func (l *topicLog) append(m Message, now time.Time) {
+	if l.retention.enabled() || len(l.replays) > 0 {
+		l.entries = append(l.entries, retainedMessage{msg: m, at: now})
+	}
+	l.prune(now)
+}
+
+func (l *topicLog) prune(now time.Time) {
+	drop := 0
+	if !l.retention.enabled() {
+		drop = len(l.entries)
+	}
+	if max := l.retention.MaxMessages; max > 0 && len(l.entries)-drop > max {
+		drop = len(l.entries) - max
+	}
+	if age := l.retention.MaxAge; age > 0 {
+		for drop < len(l.entries) && now.Sub(l.entries[drop].at) > age {
+			drop++
+		}
+	}
+	for sub := range l.replays {
+		for drop > 0 && l.entries[drop-1].msg.Offset >= sub.next {
+			drop--
+		}
+	}
+	if drop > 0 {
+		clear(l.entries[:drop])
+		l.entries = l.entries[drop:]
+	}
+}
+
+// next returns the oldest retained message matching from, or false.
+func (l *topicLog) next(from replayFrom) (Message, bool) {
+	for _, e := range l.entries {
+		if e.msg.Offset >= from.offset && !e.at.Before(from.time) {
+			return e.msg, true
+		}
+	}
+	return Message{}, false
+}
+
+// topicLogLocked returns topic's log, creating it on first use.
+func (k *Kernel) topicLogLocked(topic string) *topicLog {
+	if k.topicLogs == nil {
+		k.topicLogs = make(map[string]*topicLog)
+	}
+	l := k.topicLogs[topic]
+	if l == nil {
+		l = &topicLog{}
+		k.topicLogs[topic] = l
+	}
+	return l
+}
+
+// SetTopicRetention sets how many of topic's messages are kept for
+// replay. Retention is kept in memory: it lasts as long as the kernel.
+func (k *Kernel) SetTopicRetention(topic string, retention TopicRetention) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l := k.topicLogLocked(topic)
+	l.retention = retention
+	l.prune(k.Clock.Now())
+}
+
+// TopicOffset returns the offset of the latest message published to
+// topic, zero if there has been none. Offsets start at 1.
+func (k *Kernel) TopicOffset(topic string) uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if l := k.topicLogs[topic]; l != nil {
+		return l.last
+	}
+	return 0
+}
+
+// replayFrom selects the retained messages a subscription starts with.
+type replayFrom struct {
+	offset uint64
+	time   time.Time
+}
+
+// SubscribeOption configures SubscribeTopic.
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	replay *replayFrom
+	rate   float64
+}
+
+// FromBeginning replays every retained message before live delivery.
+func FromBeginning() SubscribeOption {
+	return func(cfg *subscribeConfig) { cfg.replay = &replayFrom{} }
+}
+
+// FromTime replays the retained messages published at or after t.
+func FromTime(t time.Time) SubscribeOption {
+	return func(cfg *subscribeConfig) { cfg.replay = &replayFrom{time: t} }
+}
+
+// FromOffset replays the retained messages from offset on. A consumer
+// that has handled up to Message.Offset n resumes with FromOffset(n+1).
+func FromOffset(offset uint64) SubscribeOption {
+	return func(cfg *subscribeConfig) { cfg.replay = &replayFrom{offset: offset} }
+}
+
+// ReplayRate paces replay to at most perSecond messages on the kernel
+// clock; by default replay runs as fast as the subscriber's mailbox
+// accepts.
+func ReplayRate(perSecond float64) SubscribeOption {
+	return func(cfg *subscribeConfig) { cfg.rate = perSecond }
+}
+
+// topicSub is one container's subscription to a topic. While it replays,
+// live publications skip it; the replay catches up with them from the log,
+// which keeps every entry from offset next on until the replay ends.
+type topicSub struct {
+	replaying bool
+	next      uint64
+}
+
+// startReplayLocked begins replaying topic to id, if anything retained
+// matches from. Otherwise the subscription is live straight away.
+func (k *Kernel) startReplayLocked(topic, id string, sub *topicSub, from replayFrom, rate float64) {
+	l := k.topicLogLocked(topic)
+	l.prune(k.Clock.Now())
+	m, ok := l.next(from)
+	if !ok {
+		k.replayCaughtUpLocked(topic, id, 0, l.last)
+		return
+	}
+	sub.replaying, sub.next = true, m.Offset
+	if l.replays == nil {
+		l.replays = make(map[*topicSub]struct{})
+	}
+	l.replays[sub] = struct{}{}
+	go k.replay(topic, id, sub, from, rate)
+}
+
+// endReplayLocked stops sub, a subscription to topic, from holding back
+// the pruning of topic's log.
+func (k *Kernel) endReplayLocked(topic string, sub *topicSub) {
+	if l := k.topicLogs[topic]; l != nil && sub != nil && sub.replaying {
+		sub.replaying = false
+		delete(l.replays, sub)
+		l.prune(k.Clock.Now())
+	}
+}
+
+// replay delivers retained messages to id one at a time, taking the kernel
+// lock only for each delivery so publishers are not held up. When no
+// retained message is left it switches the subscription to live delivery
+// under the same lock hold, so nothing is missed or repeated.
+func (k *Kernel) replay(topic, id string, sub *topicSub, from replayFrom, rate float64) {
+	replayed := 0
+	for {
+		k.mu.Lock()
+		if k.topics[topic][id] != sub {
+			k.endReplayLocked(topic, sub)
+			k.mu.Unlock()
+			return // unsubscribed meanwhile
+		}
+		l := k.topicLogs[topic]
+		l.prune(k.Clock.Now())
+		m, ok := l.next(from)
+		if !ok {
+			k.endReplayLocked(topic, sub)
+			k.replayCaughtUpLocked(topic, id, replayed, l.last)
+			k.mu.Unlock()
+			return
+		}
+		wait := time.Duration(0)
+		if rate > 0 {
+			wait = time.Duration(float64(time.Second) / rate)
+		}
+		if m.From != id {
+			_, err := k.deliverToLocked(m, id)
+			switch {
+			case errors.Is(err, ErrMailboxFull):
+				k.mu.Unlock()
+				<-k.Clock.After(max(wait, replayRetry))
+				continue
+			case err != nil:
+				m.To = id
+				k.deadLetterLocked(m, err.Error())
+			default:
+				replayed++
+			}
+		}
+		from = replayFrom{offset: m.Offset + 1}
+		sub.next = from.offset
+		k.mu.Unlock()
+		if wait > 0 {
+			<-k.Clock.After(wait)
+		}
+	}
+}
+
+func (k *Kernel) replayCaughtUpLocked(topic, id string, replayed int, offset uint64) {
+	detail := fmt.Sprintf("%s: replayed %d, live after offset %d", topic, replayed, offset)
+	fmt.Printf("[Kernel] Subscription of %s to %s\n", id, detail)
+	k.emit(EventReplayCaughtUp, id, "", detail)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newsKernel returns a kernel with a "pub" container that has published
+// n messages, "m1" to "mn", to topic "news" under retention, and a "late"
+// container able to receive.
+func newsKernel(t *testing.T, retention TopicRetention, n int) (*Kernel, *FakeClock, *Container) {
+	t.Helper()
+	k, clk := newTestKernel(t)
+	newTestContainer(t, k, "pub")
+	late := newTestContainer(t, k, "late")
+	late.AddProcess(&Process{Name: "listener"})
+	k.SetTopicRetention("news", retention)
+	for i := 1; i <= n; i++ {
+		if _, err := k.Publish("news", "pub", fmt.Sprintf("m%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k, clk, late
+}
+
+// received lists c's inbox as payload@offset, marking replayed messages
+// with a star.
+func received(c *Container) string {
+	var out []string
+	for _, m := range c.Inbox() {
+		s := fmt.Sprintf("%s@%d", m.Payload, m.Offset)
+		if m.Replayed {
+			s += "*"
+		}
+		out = append(out, s)
+	}
+	return fmt.Sprint(out)
+}
+
+func TestReplayFromBeginningThenLive(t *testing.T) {
+	k, _, late := newsKernel(t, TopicRetention{MaxMessages: 10}, 3)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventReplayCaughtUp}})
+	defer cancel()
+	if err := k.SubscribeTopic("news", "late", FromBeginning()); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "late" || e.Detail != "news: replayed 3, live after offset 3" {
+		t.Errorf("event = %+v", e)
+	}
+	if _, err := k.Publish("news", "pub", "m4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := received(late); got != "[m1@1* m2@2* m3@3* m4@4]" {
+		t.Errorf("inbox = %s, want the retained messages replayed, then live", got)
+	}
+	if got := k.TopicOffset("news"); got != 4 {
+		t.Errorf("TopicOffset = %d", got)
+	}
+}
+
+func TestReplayResumesFromOffset(t *testing.T) {
+	k, _, late := newsKernel(t, TopicRetention{MaxMessages: 10}, 5)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventReplayCaughtUp}})
+	defer cancel()
+	// The consumer has handled up to offset 3.
+	if err := k.SubscribeTopic("news", "late", FromOffset(4)); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events)
+	if got := received(late); got != "[m4@4* m5@5*]" {
+		t.Errorf("inbox = %s, want only the unseen messages", got)
+	}
+}
+
+func TestRetentionBoundsReplay(t *testing.T) {
+	k, clk, late := newsKernel(t, TopicRetention{MaxMessages: 2, MaxAge: time.Hour}, 4)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventReplayCaughtUp}})
+	defer cancel()
+	if err := k.SubscribeTopic("news", "late", FromBeginning()); err != nil {
+		t.Fatal(err)
+	}
+	nextEvent(t, events)
+	if got := received(late); got != "[m3@3* m4@4*]" {
+		t.Errorf("inbox = %s, want the two retained messages", got)
+	}
+
+	clk.Advance(time.Hour + time.Second)
+	if _, err := k.Publish("news", "pub", "m5"); err != nil {
+		t.Fatal(err)
+	}
+	early := newTestContainer(t, k, "early")
+	early.AddProcess(&Process{Name: "listener"})
+	if err := k.SubscribeTopic("news", "early", FromTime(testEpoch)); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Detail != "news: replayed 1, live after offset 5" {
+		t.Errorf("event = %+v", e)
+	}
+	if got := received(early); got != "[m5@5*]" {
+		t.Errorf("inbox = %s, want the messages older than MaxAge pruned", got)
+	}
+}
+
+func TestReplayRatePacesDelivery(t *testing.T) {
+	k, clk, late := newsKernel(t, TopicRetention{MaxMessages: 10}, 3)
+	if err := k.SubscribeTopic("news", "late", FromBeginning(), ReplayRate(1)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		eventually(t, fmt.Sprintf("replayed message %d", i), func() bool { return len(late.Inbox()) == i })
+		waitForWaiters(t, clk, 1)
+		if got := len(late.Inbox()); got != i {
+			t.Fatalf("%d messages replayed within a second, want %d", got, i)
+		}
+		if i == 1 {
+			// Publishing is not held up by the replay, and the
+			// replay picks the message up from the log.
+			if _, err := k.Publish("news", "pub", "m4"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		clk.Advance(time.Second)
+	}
+	if got := received(late); got != "[m1@1* m2@2* m3@3* m4@4*]" {
+		t.Errorf("inbox = %s", got)
+	}
+}
+
+func TestReplayKeepsMessagesPublishedWhileMailboxIsFull(t *testing.T) {
+	k, clk, late := newsKernel(t, TopicRetention{MaxMessages: 2}, 3)
+	late.MailboxLimit = 1
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventReplayCaughtUp}})
+	defer cancel()
+	if err := k.SubscribeTopic("news", "late", FromBeginning()); err != nil {
+		t.Fatal(err)
+	}
+	// The replay delivers m2 and waits for room for m3; what is
+	// published meanwhile skips late and goes past MaxMessages.
+	waitForWaiters(t, clk, 1)
+	for i := 4; i <= 6; i++ {
+		if _, err := k.Publish("news", "pub", fmt.Sprintf("m%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for {
+		eventually(t, "a replayed message", func() bool { return len(late.Inbox()) > 0 })
+		for _, m := range late.DrainInbox() {
+			got = append(got, fmt.Sprintf("%s@%d", m.Payload, m.Offset))
+		}
+		if len(got) == 5 {
+			break
+		}
+		waitForWaiters(t, clk, 1)
+		clk.Advance(replayRetry)
+	}
+	if fmt.Sprint(got) != "[m2@2 m3@3 m4@4 m5@5 m6@6]" {
+		t.Errorf("received %v, want every message from the first retained one on", got)
+	}
+	if e := nextEvent(t, events); e.Detail != "news: replayed 5, live after offset 6" {
+		t.Errorf("event = %+v", e)
+	}
+}
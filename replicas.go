@@ -0,0 +1,62 @@
+package main
+
+import "errors"
+
+// --- Process Replicas ---
+
+type replicaStatus struct {
+	state ProcessState
+	err   error
+}
+
+// ReplicaDetail is the observable state of one replica of a process.
+type ReplicaDetail struct {
+	Index int
+	State ProcessState
+	Err   string
+}
+
+// replicaDoneLocked records that replica i returned err and reports whether
+// it was the last one still running.
+func (p *Process) replicaDoneLocked(i int, err error) bool {
+	r := &p.replicas[i]
+	switch {
+	case p.State != Running:
+		r.state = p.State
+	case err != nil:
+		r.state, r.err = Failed, err
+	default:
+		r.state = Completed
+	}
+	for _, r := range p.replicas {
+		if r.state == Running {
+			return false
+		}
+	}
+	return true
+}
+
+// replicaErrLocked joins the errors of every failed replica.
+func (p *Process) replicaErrLocked() error {
+	var errs []error
+	for _, r := range p.replicas {
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Process) replicaDetails() []ReplicaDetail {
+	if p.replicas == nil {
+		return nil
+	}
+	out := make([]ReplicaDetail, len(p.replicas))
+	for i, r := range p.replicas {
+		out[i] = ReplicaDetail{Index: i, State: r.state}
+		if r.err != nil {
+			out[i].Err = r.err.Error()
+		}
+	}
+	return out
+}
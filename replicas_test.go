@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplicasCompleteTogether(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "workers")
+	var runs atomic.Int32
+	release := [3]chan struct{}{make(chan struct{}), make(chan struct{}), make(chan struct{})}
+	p := &Process{Name: "fan", Replicas: 3, Action: func(ctx context.Context, h *Handle) error {
+		runs.Add(1)
+		<-release[h.Replica()]
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "three replicas to start", func() bool { return runs.Load() == 3 })
+
+	close(release[0])
+	close(release[2])
+	eventually(t, "two replicas to finish", func() bool {
+		return c.Inspect().Processes[0].Replicas[2].State == Completed
+	})
+	info := c.Inspect().Processes[0]
+	if info.State != Running {
+		t.Errorf("process %v with a replica still running", info.State)
+	}
+	for i, want := range []ProcessState{Completed, Running, Completed} {
+		if got := info.Replicas[i]; got.Index != i || got.State != want {
+			t.Errorf("replica %d = %+v, want %v", i, got, want)
+		}
+	}
+
+	close(release[1])
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Completed {
+		t.Errorf("process = %v, want Completed", got)
+	}
+	if runs.Load() != 3 {
+		t.Errorf("action ran %d times, want 3", runs.Load())
+	}
+}
+
+func TestFailedReplicaFailsProcess(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "workers")
+	p := &Process{Name: "fan", Replicas: 2, Action: func(ctx context.Context, h *Handle) error {
+		if h.Replica() == 1 {
+			return errors.New("bad shard")
+		}
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	info := c.Inspect().Processes[0]
+	if info.State != Failed {
+		t.Errorf("process = %v, want Failed", info.State)
+	}
+	if r := info.Replicas[1]; r.State != Failed || r.Err != "bad shard" {
+		t.Errorf("replica 1 = %+v", r)
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// --- Warm Standby Replication ---
+
+type KernelRole int32
+
+const (
+	// RolePrimary is an authoritative kernel that accepts mutations.
+	RolePrimary KernelRole = iota
+	// RoleStandby mirrors a primary and refuses mutations until promoted.
+	RoleStandby
+	// RoleFenced is a former primary that learned its standby was promoted.
+	RoleFenced
+)
+
+func (r KernelRole) String() string {
+	switch r {
+	case RolePrimary:
+		return "Primary"
+	case RoleStandby:
+		return "Standby"
+	case RoleFenced:
+		return "Fenced"
+	}
+	return fmt.Sprintf("KernelRole(%d)", int32(r))
+}
+
+var (
+	ErrNotAuthoritative = errors.New("kernel is a standby and not authoritative")
+	ErrFenced           = errors.New("kernel was fenced after its standby was promoted")
+	ErrReplicationGap   = errors.New("replication stream lost events")
+)
+
+// replicationBufferSize is the event buffer of a replication link. A
+// standby that falls further behind loses events and reports
+// ErrReplicationGap.
+const replicationBufferSize = 4096
+
+// Role reports whether the kernel is primary, standby or fenced.
+func (k *Kernel) Role() KernelRole {
+	return KernelRole(k.role.Load())
+}
+
+// checkAuthoritative returns an error unless the kernel may accept
+// mutations.
+func (k *Kernel) checkAuthoritative() error {
+	switch k.Role() {
+	case RoleStandby:
+		return ErrNotAuthoritative
+	case RoleFenced:
+		return ErrFenced
+	}
+	return nil
+}
+
+// Replication streams a primary kernel's events to a standby, which applies
+// them to shadow copies of the primary's containers and processes. Shadow
+// processes never run their actions.
+type Replication struct {
+	primary *Kernel
+	standby *Kernel
+	stop    func()
+	done    chan struct{}
+	applied atomic.Uint64 // primary sequence number applied so far
+
+	mu  sync.Mutex
+	err error
+}
+
+// ReplicateTo makes standby a warm standby of k. The standby is seeded with
+// a copy of k's current containers and then follows k's event stream.
+func (k *Kernel) ReplicateTo(standby *Kernel) *Replication {
+	r := &Replication{primary: k, standby: standby, done: make(chan struct{})}
+	standby.role.Store(int32(RoleStandby))
+	standby.mu.Lock()
+	standby.replication = r
+	standby.mu.Unlock()
+
+	events, stop, seq := k.events.subscribeSized(0, replicationBufferSize, EventFilter{})
+	r.stop = stop
+	r.applied.Store(seq)
+	r.seed()
+	go r.loop(events)
+	return r
+}
+
+// Lag reports how many primary events the standby has not applied yet.
+func (r *Replication) Lag() uint64 {
+	last := r.primary.events.lastSeq()
+	applied := r.applied.Load()
+	if applied >= last {
+		return 0
+	}
+	return last - applied
+}
+
+// Err reports a replication failure such as ErrReplicationGap.
+func (r *Replication) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Stop detaches the standby from the primary's event stream.
+func (r *Replication) Stop() {
+	r.stop()
+	<-r.done
+}
+
+func (r *Replication) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Replication) loop(events <-chan Event) {
+	defer close(r.done)
+	for e := range events {
+		if want := r.applied.Load() + 1; e.Seq != want {
+			r.fail(fmt.Errorf("%w: expected event %d, got %d", ErrReplicationGap, want, e.Seq))
+		}
+		r.apply(e)
+		r.applied.Store(e.Seq)
+	}
+}
+
+// seed copies every container the primary has right now.
+func (r *Replication) seed() {
+	r.primary.mu.Lock()
+	ids := make([]string, 0, len(r.primary.Containers))
+	for id := range r.primary.Containers {
+		ids = append(ids, id)
+	}
+	r.primary.mu.Unlock()
+	for _, id := range ids {
+		r.copyContainer(id)
+	}
+}
+
+// copyContainer creates (or refreshes) the shadow of primary container id
+// with all of its processes.
+func (r *Replication) copyContainer(id string) {
+	r.primary.mu.Lock()
+	pc, ok := r.primary.Containers[id]
+	r.primary.mu.Unlock()
+	if !ok {
+		return
+	}
+	pc.mu.Lock()
+	spec := ContainerSpec{
+		ID: pc.ID, Name: pc.Name, MemoryMB: pc.MemoryMB,
+		Labels: copyStringMap(pc.Labels), Env: copyStringMap(pc.Env),
+		Volumes: append([]string(nil), pc.Volumes...), DependsOn: append([]string(nil), pc.DependsOn...),
+		StopPriority: pc.StopPriority, BootPhase: pc.BootPhase,
+	}
+	state := pc.State
+	procs := make([]*Process, 0, len(pc.Processes))
+	for _, p := range pc.Processes {
+		procs = append(procs, shadowProcess(p))
+	}
+	pc.mu.Unlock()
+
+	s := r.standby
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, exists := s.Containers[id]
+	if !exists {
+		sc = s.createContainerLocked(spec.ID, spec.Name, spec.MemoryMB)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.Labels, sc.Env, sc.Volumes, sc.DependsOn = spec.Labels, spec.Env, spec.Volumes, spec.DependsOn
+	sc.StopPriority, sc.BootPhase = spec.StopPriority, spec.BootPhase
+	sc.State = state
+	for _, sp := range procs {
+		if existing := sc.processByPIDLocked(sp.PID); existing != nil {
+			continue
+		}
+		sc.Processes = append(sc.Processes, sp)
+		s.notePIDLocked(sp.PID)
+	}
+}
+
+// copyProcess adds the shadow of process pid in primary container id.
+func (r *Replication) copyProcess(id string, pid int) {
+	r.primary.mu.Lock()
+	pc, ok := r.primary.Containers[id]
+	r.primary.mu.Unlock()
+	if !ok {
+		return
+	}
+	pc.mu.Lock()
+	p := pc.processByPIDLocked(pid)
+	var sp *Process
+	if p != nil {
+		sp = shadowProcess(p)
+	}
+	pc.mu.Unlock()
+	if sp == nil {
+		return
+	}
+	s := r.standby
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.Containers[id]
+	if !ok {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.processByPIDLocked(pid) == nil {
+		sc.Processes = append(sc.Processes, sp)
+		s.notePIDLocked(pid)
+	}
+}
+
+// shadowProcess copies a process definition and state without its action.
+// The caller holds the owning container's lock.
+func shadowProcess(p *Process) *Process {
+	return &Process{
+		PID:              p.PID,
+		Name:             p.Name,
+		Priority:         p.Priority,
+		State:            p.State,
+		Err:              p.Err,
+		MemoryMB:         p.MemoryMB,
+		Kind:             p.Kind,
+		KindVersion:      p.KindVersion,
+		Params:           copyStringMap(p.Params),
+		ConcurrencyGroup: p.ConcurrencyGroup,
+		WaitReason:       p.WaitReason,
+		addedAt:          p.addedAt,
+		startedAt:        p.startedAt,
+		finishedAt:       p.finishedAt,
+	}
+}
+
+// apply mirrors one primary event onto the standby.
+func (r *Replication) apply(e Event) {
+	s := r.standby
+	switch e.Kind {
+	case EventContainerCreated, EventLabelsChanged, EventContainerRestored:
+		r.copyContainer(e.ContainerID)
+		return
+	case EventProcessAdded:
+		r.copyProcess(e.ContainerID, e.PID)
+		return
+	case EventContainerRemoved:
+		s.mu.Lock()
+		if sc, ok := s.Containers[e.ContainerID]; ok {
+			s.removeContainerLocked(sc)
+		}
+		s.mu.Unlock()
+		return
+	case EventMessageSent:
+		s.messagesSent.Add(1)
+		return
+	}
+
+	s.mu.Lock()
+	sc, ok := s.Containers[e.ContainerID]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	switch e.Kind {
+	case EventContainerStarted:
+		sc.State = ContainerRunning
+		return
+	case EventContainerRenamed:
+		sc.Name = e.Detail
+		return
+	case EventQuarantined:
+		sc.quarantined = true
+		if sc.State != ContainerStopped {
+			sc.State = ContainerQuarantined
+		}
+		return
+	case EventUnquarantined:
+		sc.quarantined = false
+		if sc.State == ContainerQuarantined {
+			sc.State = ContainerRunning
+			if sc.frozen {
+				sc.State = ContainerFrozen
+			}
+		}
+		return
+	case EventFrozen:
+		sc.frozen = true
+		if sc.State == ContainerRunning {
+			sc.State = ContainerFrozen
+		}
+		return
+	case EventUnfrozen:
+		sc.frozen = false
+		if sc.State == ContainerFrozen {
+			sc.State = ContainerRunning
+		}
+		return
+	case EventContainerStopped:
+		sc.State = ContainerStopped
+		for _, p := range sc.Processes {
+			switch p.State {
+			case Pending, Throttled, Running:
+				p.State = Stopped
+				p.finishedAt = e.Time
+				p.closeDone()
+			}
+		}
+		return
+	}
+	p := sc.processByPIDLocked(e.PID)
+	if p == nil {
+		return
+	}
+	switch e.Kind {
+	case EventProcessWaiting:
+		p.State, p.WaitReason = Pending, e.Detail
+	case EventProcessThrottled:
+		p.State = Throttled
+	case EventProcessStarted:
+		p.State, p.WaitReason, p.startedAt = Running, "", e.Time
+	case EventProcessCompleted:
+		p.State, p.finishedAt = Completed, e.Time
+		p.closeDone()
+	case EventProcessFailed:
+		p.State, p.finishedAt, p.Err = Failed, e.Time, errors.New(e.Detail)
+		p.closeDone()
+	case EventProcessKilled:
+		p.State, p.finishedAt = Killed, e.Time
+		p.closeDone()
+	}
+}
+
+// Promote makes a standby authoritative. Replication stops, the old primary
+// is fenced so it refuses further mutations, and unfinished shadow processes
+// whose kind is registered here are rebuilt and handed to the scheduler.
+// Unfinished processes of unknown kinds are marked Stopped.
+func (k *Kernel) Promote() error {
+	k.mu.Lock()
+	r := k.replication
+	k.mu.Unlock()
+	if k.Role() != RoleStandby || r == nil {
+		return fmt.Errorf("promote: kernel is %s, not a standby", k.Role())
+	}
+	r.Stop()
+	r.primary.role.Store(int32(RoleFenced))
+	r.primary.emit(EventKernelFenced, "", "", "standby promoted")
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.replication = nil
+	k.role.Store(int32(RolePrimary))
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			switch p.State {
+			case Pending, Throttled, Running:
+			default:
+				continue
+			}
+			factory, _, _, err := k.resolveKindLocked(p.kindRef())
+			if err != nil {
+				p.State = Stopped
+				p.Err = fmt.Errorf("not restarted after promotion: %w", err)
+				p.closeDone()
+				continue
+			}
+			built := factory(ProcessSpec{Name: p.Name, Kind: p.kindRef(), Priority: p.Priority, Params: copyStringMap(p.Params)})
+			p.Action = built.Action
+			p.State = Pending
+		}
+		c.scheduleLocked()
+		c.mu.Unlock()
+	}
+	k.emit(EventKernelPromoted, "", "", "")
+	return nil
+}
+
+// notePIDLocked keeps the PID allocator ahead of PIDs copied from a primary.
+func (k *Kernel) notePIDLocked(pid int) {
+	for {
+		cur := k.lastPID.Load()
+		if int64(pid) <= cur || k.lastPID.CompareAndSwap(cur, int64(pid)) {
+			return
+		}
+	}
+}
+
+func (c *Container) processByPIDLocked(pid int) *Process {
+	for _, p := range c.Processes {
+		if p.PID == pid {
+			return p
+		}
+	}
+	return nil
+}
+
+// checkAuthoritative is Kernel.checkAuthoritative for containers; detached
+// containers are always authoritative.
+func (c *Container) checkAuthoritative() error {
+	if c.kernel == nil {
+		return nil
+	}
+	return c.kernel.checkAuthoritative()
+}
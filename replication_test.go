@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// stateCounts is the part of KernelStats a standby mirrors.
+func stateCounts(s KernelStats) [9]int {
+	return [9]int{s.Containers, s.Processes, s.Running, s.Stopped, s.Completed, s.Failed, s.Killed, s.Pending, s.Messages}
+}
+
+// registerScriptKinds registers the kinds of the scripted run on k;
+// "server" runs until stopped and counts its runs in runs.
+func registerScriptKinds(k *Kernel, runs *atomic.Int32) {
+	k.RegisterKind("task", func(ProcessSpec) *Process { return &Process{Action: noop} })
+	k.RegisterKind("broken", func(ProcessSpec) *Process {
+		return &Process{Action: func(context.Context, *Handle) error { return errors.New("boom") }}
+	})
+	k.RegisterKind("server", func(ProcessSpec) *Process {
+		return &Process{Action: func(ctx context.Context, h *Handle) error {
+			runs.Add(1)
+			<-ctx.Done()
+			return nil
+		}}
+	})
+}
+
+func TestStandbyMirrorsPrimaryAndTakesOver(t *testing.T) {
+	var primaryRuns, standbyRuns atomic.Int32
+	primary, _ := newTestKernel(t)
+	registerScriptKinds(primary, &primaryRuns)
+	standby, _ := newTestKernel(t)
+	registerScriptKinds(standby, &standbyRuns)
+
+	// A container that exists before replication starts is seeded.
+	api := newTestContainer(t, primary, "api")
+	repl := primary.ReplicateTo(standby)
+	if standby.Role() != RoleStandby {
+		t.Fatalf("standby role = %v", standby.Role())
+	}
+	worker := newTestContainer(t, primary, "worker")
+	worker.SetLabels(map[string]string{"tier": "batch"})
+
+	var procs []*Process
+	for _, spec := range []struct {
+		c    *Container
+		kind string
+	}{{api, "server"}, {worker, "task"}, {worker, "broken"}} {
+		p, err := primary.NewProcess(ProcessSpec{Name: spec.kind, Kind: spec.kind})
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec.c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	for _, c := range []*Container{api, worker} {
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitDone(t, procs[1])
+	waitDone(t, procs[2])
+	if err := primary.SendMessage("worker", "api", "done"); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the standby to catch up", func() bool { return repl.Lag() == 0 })
+	eventually(t, "the standby stats to match", func() bool {
+		return stateCounts(standby.Stats()) == stateCounts(primary.Stats())
+	})
+	if err := repl.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := standby.Containers["worker"].Inspect().Labels["tier"]; got != "batch" {
+		t.Errorf("standby worker label = %q", got)
+	}
+	if standbyRuns.Load() != 0 {
+		t.Error("the standby ran a shadow process")
+	}
+	if _, err := standby.CreateContainer("x", "x", 1); !errors.Is(err, ErrNotAuthoritative) {
+		t.Errorf("standby CreateContainer: %v, want ErrNotAuthoritative", err)
+	}
+
+	if err := standby.Promote(); err != nil {
+		t.Fatal(err)
+	}
+	if primary.Role() != RoleFenced || standby.Role() != RolePrimary {
+		t.Fatalf("roles after promotion: primary %v, standby %v", primary.Role(), standby.Role())
+	}
+	if _, err := primary.CreateContainer("x", "x", 1); !errors.Is(err, ErrFenced) {
+		t.Errorf("old primary CreateContainer: %v, want ErrFenced", err)
+	}
+	if err := primary.SendMessage("worker", "api", "late"); !errors.Is(err, ErrFenced) {
+		t.Errorf("old primary SendMessage: %v, want ErrFenced", err)
+	}
+	eventually(t, "the server to restart on the new primary", func() bool { return standbyRuns.Load() == 1 })
+	if _, err := standby.CreateContainer("x", "x", 1); err != nil {
+		t.Errorf("promoted CreateContainer: %v", err)
+	}
+	api.StopProcesses()
+	standby.Containers["api"].StopProcesses()
+}
+
+func TestPromoteRequiresStandby(t *testing.T) {
+	k, _ := newTestKernel(t)
+	if err := k.Promote(); err == nil {
+		t.Error("promoted a primary")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Request/Reply ---
+
+// ErrNoPendingRequest is returned by Handle.Reply when nobody is waiting for
+// the reply any more, because the requester gave up or was already answered.
+var ErrNoPendingRequest = errors.New("no pending request")
+
+// pendingRequest is a Request whose sender is waiting for the reply.
+type pendingRequest struct {
+	reply chan Message
+}
+
+// Request sends payload to toID like Send and waits for the reply. The
+// message carries the caller's effective priority: the process that takes
+// it with Recv inherits that priority until it calls Reply or finishes, so a
+// low-priority handler is not starved by medium-priority work while a
+// high-priority process waits on it. Request returns the context error if
+// the caller is stopped first, and ErrCircuitOpen without sending if the
+// kernel's CircuitBreaker has opened the circuit of toID.
+func (h *Handle) Request(toID, payload string) (Message, error) {
+	return h.request(toID, payload, nil)
+}
+
+// RequestTimeout is Request that gives up with ErrRequestTimeout after
+// timeout on the kernel clock. Timeouts count as failures for the
+// circuit breaker.
+func (h *Handle) RequestTimeout(toID, payload string, timeout time.Duration) (Message, error) {
+	return h.request(toID, payload, h.after(timeout))
+}
+
+func (h *Handle) request(toID, payload string, timeout <-chan time.Time) (Message, error) {
+	var reply Message
+	err := h.syscall("request", toID, func() error {
+		if err := h.checkCapability(CapSendMessages, "request"); err != nil {
+			return err
+		}
+		c := h.container
+		k := c.kernel
+		if k == nil {
+			return fmt.Errorf("%w: container %s is not attached to a kernel", ErrContainerNotFound, c.ID)
+		}
+		if err := k.checkAuthoritative(); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		m := h.tracedLocked(Message{From: c.ID, To: toID, Payload: payload, Priority: h.proc.effectivePriorityLocked()})
+		c.mu.Unlock()
+
+		pr := &pendingRequest{reply: make(chan Message, 1)}
+		k.mu.Lock()
+		probe, err := k.circuitAllowLocked(toID)
+		if err != nil {
+			k.mu.Unlock()
+			return err
+		}
+		targetID, err := k.resolveDestinationLocked(c.ID, toID, "")
+		if err != nil {
+			fmt.Printf("[Kernel] Messaging error: %v\n", err)
+			k.circuitRecordLocked(toID, probe, true)
+			k.mu.Unlock()
+			return err
+		}
+		if cached, ok := k.cachedReplyLocked(targetID, m); ok {
+			k.circuitRecordLocked(toID, probe, false)
+			k.mu.Unlock()
+			reply = cached
+			c.mu.Lock()
+			h.handlingLocked(reply)
+			c.mu.Unlock()
+			return nil
+		}
+		id := k.lastMsgID.Add(1)
+		k.requests[id] = pr
+		m.RequestID = id
+		if _, err = k.deliverToLocked(m, targetID); err != nil {
+			delete(k.requests, id)
+			k.circuitRecordLocked(toID, probe, true)
+		}
+		k.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case reply = <-pr.reply:
+			c.mu.Lock()
+			h.handlingLocked(reply)
+			c.mu.Unlock()
+			k.mu.Lock()
+			k.circuitRecordLocked(toID, probe, false)
+			k.mu.Unlock()
+			return nil
+		case <-timeout:
+			k.mu.Lock()
+			delete(k.requests, id)
+			k.circuitRecordLocked(toID, probe, true)
+			k.mu.Unlock()
+			return fmt.Errorf("%w: %s", ErrRequestTimeout, toID)
+		case <-h.context().Done():
+			k.mu.Lock()
+			delete(k.requests, id)
+			if probe {
+				k.circuitReleaseProbeLocked(toID)
+			}
+			k.mu.Unlock()
+			return h.context().Err()
+		}
+	})
+	return reply, err
+}
+
+// Reply answers req, a message obtained from Recv, and gives back any
+// priority the caller inherited from it. If the container has a
+// RequestCache the reply is cached for later requests with the same
+// payload.
+func (h *Handle) Reply(req Message, payload string) error {
+	return h.reply(req, payload, true)
+}
+
+// ReplyUncached is Reply for an answer that must not be served from the
+// request cache, such as one that depends on state other than the payload.
+func (h *Handle) ReplyUncached(req Message, payload string) error {
+	return h.reply(req, payload, false)
+}
+
+func (h *Handle) reply(req Message, payload string, cacheable bool) error {
+	return h.syscall("reply", req.From, func() error {
+		if err := h.checkCapability(CapSendMessages, "reply"); err != nil {
+			return err
+		}
+		if req.RequestID == 0 {
+			return fmt.Errorf("message %d is not a request", req.ID)
+		}
+		c := h.container
+		c.mu.Lock()
+		c.restorePriorityLocked(h.proc, req.RequestID)
+		h.requestRepliedLocked(req, c.now())
+		if cacheable {
+			c.cacheReplyLocked(req.Payload, payload)
+		}
+		c.mu.Unlock()
+
+		k := c.kernel
+		if k == nil {
+			return ErrNoPendingRequest
+		}
+		k.mu.Lock()
+		pr, ok := k.requests[req.RequestID]
+		delete(k.requests, req.RequestID)
+		var m Message
+		if ok {
+			m = Message{
+				ID:          k.lastMsgID.Add(1),
+				From:        c.ID,
+				To:          req.From,
+				Payload:     payload,
+				SentAt:      c.LocalNow(),
+				RequestID:   req.RequestID,
+				TraceID:     req.TraceID,
+				CausationID: req.ID,
+			}
+			k.messagesSent.Add(1)
+			k.traces.record(m, TraceDelivered, "")
+			k.receipts.record(m, MessageDelivered, "", k.Clock.Now())
+			k.publish(Event{Kind: EventMessageSent, ContainerID: c.ID, Detail: req.From, TraceID: m.TraceID})
+		}
+		k.mu.Unlock()
+		if !ok {
+			return fmt.Errorf("%w: request %d", ErrNoPendingRequest, req.RequestID)
+		}
+		pr.reply <- m
+		return nil
+	})
+}
+
+// EffectivePriority reports the process's priority including any inherited
+// from requests it is handling.
+func (h *Handle) EffectivePriority() int {
+	h.container.mu.Lock()
+	defer h.container.mu.Unlock()
+	return h.proc.effectivePriorityLocked()
+}
+
+// Bounds of Process.Nice.
+const (
+	MinNice = -20
+	MaxNice = 19
+)
+
+// effectivePriorityLocked is the higher of p's own priority, adjusted by
+// its nice value, and the priorities lent to it by waiting requesters. A
+// lent priority is not reniced, so a niced server still serves an urgent
+// requester promptly. The caller holds the container lock.
+func (p *Process) effectivePriorityLocked() int {
+	prio := p.Priority - min(max(p.Nice, MinNice), MaxNice)
+	for _, lent := range p.inherited {
+		prio = max(prio, lent)
+	}
+	return prio
+}
+
+// inheritPriorityLocked lends p the priority of request m, which p has just
+// received.
+func (c *Container) inheritPriorityLocked(p *Process, m Message) {
+	before := p.effectivePriorityLocked()
+	if p.inherited == nil {
+		p.inherited = make(map[uint64]int)
+	}
+	p.inherited[m.RequestID] = m.Priority
+	if after := p.effectivePriorityLocked(); after > before {
+		c.emit(EventPriorityInherited, p, fmt.Sprintf("priority %d -> %d while handling request %d from %s", before, after, m.RequestID, m.From))
+	}
+}
+
+// restorePriorityLocked takes back the priority lent to p by request id.
+func (c *Container) restorePriorityLocked(p *Process, id uint64) {
+	if _, ok := p.inherited[id]; !ok {
+		return
+	}
+	before := p.effectivePriorityLocked()
+	delete(p.inherited, id)
+	if after := p.effectivePriorityLocked(); after < before {
+		c.emit(EventPriorityRestored, p, fmt.Sprintf("priority %d -> %d after replying to request %d", before, after, id))
+	}
+}
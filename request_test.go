@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRequestLendsPriorityToHandler(t *testing.T) {
+	k, _ := newTestKernel(t)
+	srv := newTestContainer(t, k, "db")
+	cli := newTestContainer(t, k, "web")
+	received := make(chan struct{})
+	release := make(chan struct{})
+	var after int
+	handler := &Process{Name: "handler", Priority: 1, Action: func(ctx context.Context, h *Handle) error {
+		req, err := h.Recv()
+		if err != nil {
+			return err
+		}
+		close(received)
+		<-release
+		if err := h.Reply(req, "rows"); err != nil {
+			return err
+		}
+		after = h.EffectivePriority()
+		return nil
+	}}
+	var reply Message
+	urgent := &Process{Name: "urgent", Priority: 9, Action: func(ctx context.Context, h *Handle) error {
+		var err error
+		reply, err = h.Request("db", "query")
+		return err
+	}}
+	srv.AddProcess(handler)
+	cli.AddProcess(urgent)
+	for _, c := range []*Container{srv, cli} {
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	<-received
+	if got := srv.Inspect().Processes[0].EffectivePriority; got != 9 {
+		t.Errorf("handler effective priority %d while urgent waits, want 9", got)
+	}
+	if got := stateOf(cli, urgent); got != Running {
+		t.Errorf("urgent = %v, want blocked in Request", got)
+	}
+	close(release)
+	waitDone(t, urgent)
+	waitDone(t, handler)
+	if reply.Payload != "rows" {
+		t.Errorf("reply = %q", reply.Payload)
+	}
+	if after != 1 {
+		t.Errorf("handler effective priority %d after replying, want its own 1", after)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- Request Cache ---
+
+// RequestCachePolicy enables a container's request cache: a Request whose
+// payload matches one the container has already replied to gets the same
+// reply back without the message being delivered. Replies are kept for TTL,
+// which must be positive to enable the cache, and at most MaxEntries are
+// kept, the ones closest to expiry making way first; zero means no limit.
+// The cache is only sound for handlers whose reply depends on nothing but
+// the payload; see Handle.ReplyUncached and Kernel.InvalidateRequestCache.
+type RequestCachePolicy struct {
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// RequestCacheStats reports a container's request cache.
+type RequestCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+type requestCache struct {
+	entries map[string]*cachedReply // by payload hash
+	hits    int64
+	misses  int64
+}
+
+type cachedReply struct {
+	request string // payload of the request, for prefix invalidation
+	reply   string
+	expires time.Time
+}
+
+func requestCacheKey(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedReplyLocked answers req from targetID's request cache, counting a
+// hit or miss if the cache is enabled.
+func (k *Kernel) cachedReplyLocked(targetID string, req Message) (Message, bool) {
+	to, ok := k.Containers[targetID]
+	if !ok {
+		return Message{}, false
+	}
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	if to.RequestCache.TTL <= 0 {
+		return Message{}, false
+	}
+	now := to.now()
+	e, ok := to.requestCache.entries[requestCacheKey(req.Payload)]
+	if !ok || !now.Before(e.expires) {
+		to.requestCache.misses++
+		return Message{}, false
+	}
+	to.requestCache.hits++
+	m := Message{
+		ID:          k.lastMsgID.Add(1),
+		From:        targetID,
+		To:          req.From,
+		Payload:     e.reply,
+		SentAt:      to.localTime(now),
+		TraceID:     req.TraceID,
+		CausationID: req.CausationID,
+		Cached:      true,
+	}
+	if m.TraceID == 0 {
+		m.TraceID = m.ID
+	}
+	k.receipts.record(m, MessageDelivered, "", now)
+	return m, true
+}
+
+// cacheReplyLocked stores the reply to request payload in the container's
+// request cache, if enabled.
+func (c *Container) cacheReplyLocked(request, reply string) {
+	policy := c.RequestCache
+	if policy.TTL <= 0 {
+		return
+	}
+	rc := &c.requestCache
+	if rc.entries == nil {
+		rc.entries = make(map[string]*cachedReply)
+	}
+	now := c.now()
+	key := requestCacheKey(request)
+	if _, ok := rc.entries[key]; !ok && policy.MaxEntries > 0 {
+		for k, e := range rc.entries {
+			if !now.Before(e.expires) {
+				delete(rc.entries, k)
+			}
+		}
+		for len(rc.entries) >= policy.MaxEntries {
+			var oldest string
+			for k, e := range rc.entries {
+				if oldest == "" || e.expires.Before(rc.entries[oldest].expires) {
+					oldest = k
+				}
+			}
+			delete(rc.entries, oldest)
+		}
+	}
+	rc.entries[key] = &cachedReply{request: request, reply: reply, expires: now.Add(policy.TTL)}
+}
+
+// InvalidateRequestCache drops the cached replies of container containerID
+// to requests whose payload starts with keyPrefix, or all of them for an
+// empty prefix, and returns how many were dropped. Call it when the state
+// behind the handler's answers changes.
+func (k *Kernel) InvalidateRequestCache(containerID, keyPrefix string) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	c, ok := k.Containers[containerID]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, e := range c.requestCache.entries {
+		if strings.HasPrefix(e.request, keyPrefix) {
+			delete(c.requestCache.entries, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (k *Kernel) requestCacheStatsLocked() map[string]RequestCacheStats {
+	var out map[string]RequestCacheStats
+	for id, c := range k.Containers {
+		c.mu.Lock()
+		if c.RequestCache.TTL > 0 || c.requestCache.hits+c.requestCache.misses > 0 {
+			if out == nil {
+				out = make(map[string]RequestCacheStats)
+			}
+			out[id] = RequestCacheStats{
+				Entries: len(c.requestCache.entries),
+				Hits:    c.requestCache.hits,
+				Misses:  c.requestCache.misses,
+			}
+		}
+		c.mu.Unlock()
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCache(t *testing.T) {
+	k, clk := newTestKernel(t)
+	api := newTestContainer(t, k, "api")
+	api.RequestCache = RequestCachePolicy{TTL: time.Minute}
+	var handled atomic.Int32
+	api.AddProcess(&Process{Name: "upper", Action: func(ctx context.Context, h *Handle) error {
+		for {
+			req, err := h.Recv()
+			if err != nil {
+				return nil
+			}
+			handled.Add(1)
+			if strings.HasPrefix(req.Payload, "time") {
+				h.ReplyUncached(req, clk.Now().String())
+			} else {
+				h.Reply(req, strings.ToUpper(req.Payload))
+			}
+		}
+	}})
+	if err := api.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer api.StopProcesses()
+
+	payloads, replies := make(chan string), make(chan Message)
+	client := newTestContainer(t, k, "client")
+	client.AddProcess(&Process{Name: "caller", Action: func(ctx context.Context, h *Handle) error {
+		for payload := range payloads {
+			reply, err := h.Request("api", payload)
+			if err != nil {
+				t.Errorf("Request(%q): %v", payload, err)
+			}
+			replies <- reply
+		}
+		return nil
+	}})
+	if err := client.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer close(payloads)
+	request := func(payload string, wantCached bool) {
+		t.Helper()
+		before := handled.Load()
+		payloads <- payload
+		reply := <-replies
+		if reply.Cached != wantCached || (handled.Load() == before) != wantCached {
+			t.Errorf("%q: cached = %v, handler ran %d times; want cached = %v",
+				payload, reply.Cached, handled.Load()-before, wantCached)
+		}
+		if !strings.HasPrefix(payload, "time") && reply.Payload != strings.ToUpper(payload) {
+			t.Errorf("%q: reply = %q", payload, reply.Payload)
+		}
+	}
+
+	request("user:1", false)
+	request("user:1", true)
+	request("user:2", false)
+	request("time", false)
+	request("time", false)
+
+	clk.Advance(time.Minute)
+	request("user:1", false)
+
+	if n, err := k.InvalidateRequestCache("api", "user:"); err != nil || n != 2 {
+		t.Errorf("InvalidateRequestCache = %d, %v; want both user entries", n, err)
+	}
+	request("user:2", false)
+	request("user:2", true)
+
+	if s := k.Stats().RequestCaches["api"]; s.Hits != 2 || s.Misses != 6 || s.Entries != 1 {
+		t.Errorf("stats = %+v, want 2 hits, 6 misses and 1 entry", s)
+	}
+}
+
+func TestRequestCacheEvictsNearestExpiry(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	c.RequestCache = RequestCachePolicy{TTL: time.Minute, MaxEntries: 2}
+	c.mu.Lock()
+	c.cacheReplyLocked("a", "A")
+	clk.Advance(time.Second)
+	c.cacheReplyLocked("b", "B")
+	c.cacheReplyLocked("c", "C")
+	_, a := c.requestCache.entries[requestCacheKey("a")]
+	n := len(c.requestCache.entries)
+	c.mu.Unlock()
+	if a || n != 2 {
+		t.Errorf("after a third entry: %d entries, a kept = %v; want a evicted", n, a)
+	}
+}
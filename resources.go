@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// --- Simulated Handles ---
+
+// ErrHandleBudget is returned by Handle.Open when the process or container
+// handle budget is used up.
+var ErrHandleBudget = errors.New("handle budget exhausted")
+
+// Resource is a simulated handle (connection, file descriptor, ...) opened
+// by a process. Closing it returns its slot to the budgets.
+type Resource struct {
+	Name string
+
+	proc      *Process
+	container *Container
+	closed    bool
+}
+
+// Open acquires a handle on resource, counted against the process's and the
+// container's HandleBudget. Handles still open when the action returns are
+// closed by the kernel and reported with an EventLeakDetected.
+func (h *Handle) Open(resource string) (*Resource, error) {
+	if err := h.checkCapability(CapOpenHandles, "open"); err != nil {
+		return nil, err
+	}
+	c, p := h.container, h.proc
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p.HandleBudget > 0 && len(p.resources) >= p.HandleBudget {
+		return nil, fmt.Errorf("%w: process %s holds %d", ErrHandleBudget, p.Name, len(p.resources))
+	}
+	if c.HandleBudget > 0 && c.openHandles >= c.HandleBudget {
+		return nil, fmt.Errorf("%w: container %s holds %d", ErrHandleBudget, c.Name, c.openHandles)
+	}
+	r := &Resource{Name: resource, proc: p, container: c}
+	p.resources = append(p.resources, r)
+	c.openHandles++
+	return r, nil
+}
+
+// Close releases the handle. Closing it again is a no-op.
+func (r *Resource) Close() error {
+	r.container.mu.Lock()
+	defer r.container.mu.Unlock()
+	r.container.releaseLocked(r)
+	return nil
+}
+
+func (c *Container) releaseLocked(r *Resource) {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	c.openHandles--
+	p := r.proc
+	for i, open := range p.resources {
+		if open == r {
+			p.resources = append(p.resources[:i], p.resources[i+1:]...)
+			break
+		}
+	}
+}
+
+// closeLeakedLocked closes the handles p left open when its action returned.
+func (c *Container) closeLeakedLocked(p *Process) {
+	if len(p.resources) == 0 {
+		return
+	}
+	names := make([]string, 0, len(p.resources))
+	for _, r := range append([]*Resource(nil), p.resources...) {
+		names = append(names, r.Name)
+		c.releaseLocked(r)
+	}
+	if c.kernel != nil {
+		c.kernel.leakedHandles.Add(int64(len(names)))
+	}
+	fmt.Printf("[Kernel] Process %s in %s leaked %d handle(s): %s\n", p.Name, c.Name, len(names), strings.Join(names, ", "))
+	c.emit(EventLeakDetected, p, strings.Join(names, ","))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHandleBudgetAndLeakDetection(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "app")
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventLeakDetected}})
+	defer cancel()
+
+	var overBudget, reopen error
+	p := &Process{Name: "pool", HandleBudget: 2, Action: func(ctx context.Context, h *Handle) error {
+		db, _ := h.Open("db")
+		h.Open("cache")
+		_, overBudget = h.Open("queue")
+		db.Close()
+		db.Close() // closing twice is harmless
+		_, reopen = h.Open("queue")
+		return nil // cache and queue leak
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+
+	if !errors.Is(overBudget, ErrHandleBudget) {
+		t.Errorf("third open: %v, want ErrHandleBudget", overBudget)
+	}
+	if reopen != nil {
+		t.Errorf("open after close: %v", reopen)
+	}
+	if e := nextEvent(t, events); e.Process != "pool" || e.Detail != "cache,queue" {
+		t.Errorf("event = %+v", e)
+	}
+	s := k.Stats()
+	if s.OpenHandles != 0 || s.LeakedHandles != 2 {
+		t.Errorf("stats: %d open, %d leaked, want 0 and 2", s.OpenHandles, s.LeakedHandles)
+	}
+}
+
+func TestContainerHandleBudget(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "app")
+	c.HandleBudget = 1
+	release := make(chan struct{})
+	opened := make(chan struct{})
+	holder := &Process{Name: "holder", Action: func(ctx context.Context, h *Handle) error {
+		r, err := h.Open("conn")
+		close(opened)
+		if err != nil {
+			return err
+		}
+		<-release
+		return r.Close()
+	}}
+	var second error
+	other := &Process{Name: "other", Action: func(ctx context.Context, h *Handle) error {
+		<-opened
+		_, second = h.Open("conn")
+		return nil
+	}}
+	c.AddProcess(holder)
+	c.AddProcess(other)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, other)
+	if !errors.Is(second, ErrHandleBudget) {
+		t.Errorf("open over the container budget: %v", second)
+	}
+	if got := c.Inspect().Processes[0].OpenHandles; len(got) != 1 || got[0] != "conn" {
+		t.Errorf("holder open handles = %v", got)
+	}
+	close(release)
+	waitDone(t, holder)
+	if n := k.Stats().LeakedHandles; n != 0 {
+		t.Errorf("%d handles leaked after a clean close", n)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// --- Action Helpers ---
+
+// WithRetry wraps action so that it is attempted up to n times, waiting
+// backoff between attempts, until it returns nil. It gives up early when ctx
+// is done. The error of the last attempt is returned if all of them fail.
+// Waits use the kernel clock when the action runs inside a process. A failed
+// attempt counts as a failure for the process outbox. A process with a
+// Backoff waits as it says instead of backoff.
+func WithRetry(n int, backoff time.Duration, action ActionFunc) ActionFunc {
+	if n < 1 {
+		n = 1
+	}
+	return func(ctx context.Context, h *Handle) error {
+		var err error
+		for attempt := 1; attempt <= n; attempt++ {
+			if err = action(ctx, h); err == nil {
+				return nil
+			}
+			if attempt == n {
+				break
+			}
+			h.abortOutbox()
+			wait := backoff
+			if h != nil && h.proc != nil && h.proc.Backoff.Initial > 0 {
+				wait = h.proc.Backoff.Delay(attempt, h.jitter)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-h.after(wait):
+			}
+		}
+		return fmt.Errorf("after %d attempts: %w", n, err)
+	}
+}
+
+// Backoff is an exponential backoff: the first wait is Initial and each
+// one after it is Multiplier times longer, up to Max. Jitter, in [0, 1],
+// shortens each wait by up to that fraction at random, so processes that
+// failed together do not retry in lockstep.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration // zero means no cap
+	Multiplier float64       // zero means 2
+	Jitter     float64
+}
+
+// Delay is the wait before retry n, from 1. random returns a number in
+// [0, 1) and is only called when b has Jitter.
+func (b Backoff) Delay(n int, random func() float64) time.Duration {
+	mult := b.Multiplier
+	if mult == 0 {
+		mult = 2
+	}
+	d := float64(b.Initial) * math.Pow(mult, float64(max(n, 1)-1))
+	if b.Max > 0 {
+		d = min(d, float64(b.Max))
+	}
+	if b.Jitter > 0 {
+		d -= d * min(b.Jitter, 1) * random()
+	}
+	return time.Duration(d)
+}
+
+// jitter draws from the kernel's Rand, so a fixed seed gives reproducible
+// waits.
+func (h *Handle) jitter() float64 {
+	if h.container == nil || h.container.kernel == nil {
+		return rand.Float64()
+	}
+	k := h.container.kernel
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.Rand.Float64()
+}
+
+// after is Clock.After on the process's kernel clock, with d measured on
+// the container's clock, falling back to the wall clock for handles that
+// are not attached to a kernel.
+func (h *Handle) after(d time.Duration) <-chan time.Time {
+	if h == nil || h.container == nil || h.container.kernel == nil {
+		return time.After(d)
+	}
+	return h.container.kernel.Clock.After(h.container.kernelDuration(d))
+}
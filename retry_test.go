@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failTimes returns an action failing its first n calls, and a pointer to
+// its call count.
+func failTimes(n int) (ActionFunc, *int) {
+	calls := 0
+	return func(context.Context, *Handle) error {
+		calls++
+		if calls <= n {
+			return errors.New("transient")
+		}
+		return nil
+	}, &calls
+}
+
+func TestWithRetrySucceedsWithinAttempts(t *testing.T) {
+	action, calls := failTimes(2)
+	if err := WithRetry(3, 0, action)(context.Background(), nil); err != nil {
+		t.Fatalf("err = %v, want success on the third attempt", err)
+	}
+	if *calls != 3 {
+		t.Errorf("%d attempts, want 3", *calls)
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	action, calls := failTimes(5)
+	err := WithRetry(3, 0, action)(context.Background(), nil)
+	if err == nil || err.Error() != "after 3 attempts: transient" {
+		t.Errorf("err = %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("%d attempts, want 3", *calls)
+	}
+}
+
+func TestWithRetryStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	action, calls := failTimes(5)
+	if err := WithRetry(3, time.Hour, action)(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if *calls != 1 {
+		t.Errorf("%d attempts after cancellation, want 1", *calls)
+	}
+}
+
+func TestWithRetryWaitsOnKernelClock(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	action, _ := failTimes(2)
+	p := &Process{Name: "flaky", Action: WithRetry(3, time.Minute, action)}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(time.Minute)
+	}
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Completed {
+		t.Errorf("flaky = %v, want Completed", got)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	for n, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		if got := b.Delay(n, nil); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", n, got, want)
+		}
+	}
+	b.Jitter = 0.5
+	if got := b.Delay(1, func() float64 { return 0.5 }); got != 75*time.Millisecond {
+		t.Errorf("jittered Delay(1) = %v, want 75ms", got)
+	}
+}
+
+func TestWithRetryFollowsProcessBackoff(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	var calls atomic.Int32
+	action := func(context.Context, *Handle) error {
+		if calls.Add(1) <= 5 {
+			return errors.New("transient")
+		}
+		return nil
+	}
+	p := &Process{
+		Name:    "flaky",
+		Backoff: Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3},
+		Action:  WithRetry(6, time.Hour, action),
+	}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for i, wait := range []time.Duration{
+		100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second,
+	} {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(wait - time.Millisecond)
+		if got := calls.Load(); got != int32(i+1) {
+			t.Fatalf("attempt %d started %v into a %v wait", got, wait-time.Millisecond, wait)
+		}
+		clk.Advance(time.Millisecond)
+		eventually(t, fmt.Sprintf("attempt %d", i+2), func() bool { return calls.Load() == int32(i+2) })
+	}
+	waitDone(t, p)
+	if got := stateOf(c, p); got != Completed {
+		t.Errorf("flaky = %v, want Completed", got)
+	}
+}
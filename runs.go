@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// --- Run History ---
+
+// RunRecord is the lasting record of one run of a process, kept after the
+// process and its container are gone.
+type RunRecord struct {
+	ID         uint64            `json:"id"`
+	PID        int               `json:"pid"`
+	Container  string            `json:"container"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Outcome    string            `json:"outcome"` // the terminal process state
+	Error      string            `json:"error,omitempty"`
+	Result     json.RawMessage   `json:"result,omitempty"` // see Handle.SetResult
+
+	// Restarts is how many earlier runs the same process had.
+	Restarts int `json:"restarts,omitempty"`
+	// Job and Attempt identify a job attempt, see CreateJob. Schedule
+	// lists the windows of a scheduled process, see Process.Schedule.
+	Job      string `json:"job,omitempty"`
+	Attempt  int    `json:"attempt,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// Duration is how long the run took.
+func (r RunRecord) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// RunRetention bounds the run history: records beyond MaxRuns, oldest
+// first, and records that finished more than MaxAge ago are dropped. Zero
+// fields do not bound it.
+type RunRetention struct {
+	MaxRuns int
+	MaxAge  time.Duration
+}
+
+// RunFilter selects run records. Empty fields match everything; the time
+// range applies to when runs finished, from Since up to but excluding
+// Until.
+type RunFilter struct {
+	Container string
+	Name      string
+	Outcome   string
+	Since     time.Time
+	Until     time.Time
+}
+
+func (f RunFilter) matches(r *RunRecord) bool {
+	return (f.Container == "" || r.Container == f.Container) &&
+		(f.Name == "" || r.Name == f.Name) &&
+		(f.Outcome == "" || r.Outcome == f.Outcome) &&
+		(f.Since.IsZero() || !r.FinishedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || r.FinishedAt.Before(f.Until))
+}
+
+// RunStats aggregates the runs of one process name.
+type RunStats struct {
+	Runs        int
+	Completed   int
+	Failed      int
+	Killed      int
+	Stopped     int
+	SuccessRate float64 // Completed / Runs
+	MeanRuntime time.Duration
+}
+
+// runHistory holds the run records, oldest first. It has its own mutex so
+// runs are recorded under the container lock alone.
+type runHistory struct {
+	mu      sync.Mutex
+	records []*RunRecord
+	file    *os.File // appended to, one JSON record per line, if set
+}
+
+// recordRunLocked adds the run p has just finished to the kernel's run
+// history. The caller holds the container lock.
+func (c *Container) recordRunLocked(p *Process) {
+	k := c.kernel
+	if k == nil || p.debug {
+		return
+	}
+	r := &RunRecord{
+		ID:         k.lastRunID.Add(1),
+		PID:        p.PID,
+		Container:  c.ID,
+		Name:       p.Name,
+		Kind:       p.kindRef(),
+		Params:     copyStringMap(p.Params),
+		StartedAt:  p.startedAt,
+		FinishedAt: p.finishedAt,
+		Outcome:    p.State.String(),
+		Restarts:   p.runs - 1,
+	}
+	if p.Err != nil {
+		r.Error = p.Err.Error()
+	}
+	if p.hasResult {
+		if b, err := json.Marshal(p.result); err == nil {
+			r.Result = b
+		}
+	}
+	if p.jobAttempt > 0 {
+		r.Job, r.Attempt = c.ID, p.jobAttempt
+	}
+	if p.Schedule != nil {
+		r.Schedule = p.Schedule.String()
+	}
+	k.runs.add(r, k.RunRetention, k.Clock.Now())
+}
+
+func (h *runHistory) add(r *RunRecord, retention RunRetention, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	h.pruneLocked(retention, now)
+	if h.file == nil {
+		return
+	}
+	b, _ := json.Marshal(r) // cannot fail: Result is already valid JSON
+	if _, err := h.file.Write(append(b, '\n')); err != nil {
+		fmt.Printf("[Kernel] Cannot persist run %d of %s: %v\n", r.ID, r.Name, err)
+	}
+}
+
+func (h *runHistory) pruneLocked(retention RunRetention, now time.Time) {
+	drop := 0
+	if retention.MaxRuns > 0 && len(h.records) > retention.MaxRuns {
+		drop = len(h.records) - retention.MaxRuns
+	}
+	if retention.MaxAge > 0 {
+		for drop < len(h.records) && now.Sub(h.records[drop].FinishedAt) > retention.MaxAge {
+			drop++
+		}
+	}
+	if drop > 0 {
+		clear(h.records[:drop])
+		h.records = h.records[drop:]
+	}
+}
+
+// OpenRunHistory backs the run history with the file at path: records
+// already in it are loaded, subject to RunRetention, and new runs are
+// appended to it. Retention does not shrink the file. Call it before any
+// process runs, so that loaded and new run IDs do not collide.
+func (k *Kernel) OpenRunHistory(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	var loaded []*RunRecord
+	scan := bufio.NewScanner(f)
+	scan.Buffer(nil, 1<<20)
+	for scan.Scan() {
+		r := new(RunRecord)
+		if err := json.Unmarshal(scan.Bytes(), r); err != nil {
+			f.Close()
+			return fmt.Errorf("run history %s: %w", path, err)
+		}
+		loaded = append(loaded, r)
+	}
+	if err := scan.Err(); err != nil {
+		f.Close()
+		return fmt.Errorf("run history %s: %w", path, err)
+	}
+	h := &k.runs
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.file != nil {
+		h.file.Close()
+	}
+	h.file = f
+	h.records = append(loaded, h.records...)
+	sort.SliceStable(h.records, func(i, j int) bool { return h.records[i].FinishedAt.Before(h.records[j].FinishedAt) })
+	for _, r := range loaded {
+		if r.ID > k.lastRunID.Load() {
+			k.lastRunID.Store(r.ID)
+		}
+	}
+	h.pruneLocked(k.RunRetention, k.Clock.Now())
+	return nil
+}
+
+// Runs returns the recorded runs matching filter, oldest first.
+func (k *Kernel) Runs(filter RunFilter) []RunRecord {
+	h := &k.runs
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pruneLocked(k.RunRetention, k.Clock.Now())
+	var out []RunRecord
+	for _, r := range h.records {
+		if filter.matches(r) {
+			rc := *r
+			rc.Params = copyStringMap(r.Params)
+			out = append(out, rc)
+		}
+	}
+	return out
+}
+
+// RunStats aggregates the runs matching filter by process name, e.g. how
+// often Backup failed this week:
+//
+//	k.RunStats(RunFilter{Name: "Backup", Since: now.AddDate(0, 0, -7)})
+func (k *Kernel) RunStats(filter RunFilter) map[string]RunStats {
+	out := make(map[string]RunStats)
+	total := make(map[string]time.Duration)
+	for _, r := range k.Runs(filter) {
+		s := out[r.Name]
+		s.Runs++
+		switch r.Outcome {
+		case Completed.String():
+			s.Completed++
+		case Failed.String():
+			s.Failed++
+		case Killed.String():
+			s.Killed++
+		case Stopped.String():
+			s.Stopped++
+		}
+		total[r.Name] += r.Duration()
+		out[r.Name] = s
+	}
+	for name, s := range out {
+		s.SuccessRate = float64(s.Completed) / float64(s.Runs)
+		s.MeanRuntime = total[name] / time.Duration(s.Runs)
+		out[name] = s
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// scriptWeek runs a Backup that takes an hour, failing on the second and
+// fifth days, and an instant Report, once a day for a week starting at
+// testEpoch.
+func scriptWeek(t *testing.T, k *Kernel, clk *FakeClock) {
+	t.Helper()
+	c := newTestContainer(t, k, "ops")
+	for day := 0; day < 7; day++ {
+		clk.Advance(testEpoch.Add(time.Duration(day) * 24 * time.Hour).Sub(clk.Now()))
+		fail := day == 1 || day == 4
+		backup := &Process{Name: "Backup", Action: func(ctx context.Context, h *Handle) error {
+			if err := h.Sleep(time.Hour); err != nil {
+				return err
+			}
+			if fail {
+				return errors.New("disk full")
+			}
+			return nil
+		}}
+		report := &Process{Name: "Report", Action: noop}
+		c.AddProcess(backup)
+		c.AddProcess(report)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t, report)
+		waitForWaiters(t, clk, 1)
+		clk.Advance(time.Hour)
+		waitDone(t, backup)
+	}
+}
+
+func TestRunHistoryOverAWeek(t *testing.T) {
+	k, clk := newTestKernel(t)
+	scriptWeek(t, k, clk)
+
+	stats := k.RunStats(RunFilter{})
+	backup := stats["Backup"]
+	if backup.Runs != 7 || backup.Completed != 5 || backup.Failed != 2 || backup.MeanRuntime != time.Hour {
+		t.Errorf("Backup stats = %+v", backup)
+	}
+	if math.Abs(backup.SuccessRate-5.0/7) > 1e-9 {
+		t.Errorf("Backup success rate = %v, want 5/7", backup.SuccessRate)
+	}
+	if r := stats["Report"]; r.Runs != 7 || r.SuccessRate != 1 || r.MeanRuntime != 0 {
+		t.Errorf("Report stats = %+v", r)
+	}
+
+	// Days 2 to 4 include the second failure.
+	midweek := RunFilter{Name: "Backup", Since: testEpoch.Add(48 * time.Hour), Until: testEpoch.Add(120 * time.Hour)}
+	if s := k.RunStats(midweek)["Backup"]; s.Runs != 3 || s.Failed != 1 {
+		t.Errorf("midweek Backup stats = %+v, want 3 runs, 1 failed", s)
+	}
+	failures := k.Runs(RunFilter{Container: "ops", Outcome: "Failed"})
+	if len(failures) != 2 {
+		t.Fatalf("failed runs = %+v", failures)
+	}
+	if r := failures[0]; r.Name != "Backup" || r.Error != "disk full" ||
+		!r.StartedAt.Equal(testEpoch.Add(24*time.Hour)) || r.Duration() != time.Hour {
+		t.Errorf("first failure = %+v", r)
+	}
+	if got := k.Runs(RunFilter{Container: "elsewhere"}); len(got) != 0 {
+		t.Errorf("runs of another container = %+v", got)
+	}
+
+	// The week ends at day 6, 01:00. Three days back keeps the Backup that
+	// finished at day 3, 01:00 but not that day's Report.
+	k.RunRetention = RunRetention{MaxAge: 72 * time.Hour}
+	if got := len(k.Runs(RunFilter{})); got != 7 {
+		t.Errorf("%d runs within three days, want 7", got)
+	}
+	k.RunRetention = RunRetention{MaxRuns: 2}
+	if runs := k.Runs(RunFilter{}); len(runs) != 2 || runs[1].Name != "Backup" {
+		t.Errorf("runs kept = %+v, want the last two", runs)
+	}
+}
+
+func TestRunHistoryFilePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	k, clk := newTestKernel(t)
+	if err := k.OpenRunHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	scriptWeek(t, k, clk)
+
+	reopened, _ := newTestKernel(t)
+	if err := reopened.OpenRunHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	if s := reopened.RunStats(RunFilter{Name: "Backup"})["Backup"]; s.Runs != 7 || s.Failed != 2 {
+		t.Errorf("reloaded Backup stats = %+v", s)
+	}
+	c := newTestContainer(t, reopened, "next")
+	p := &Process{Name: "Report", Action: noop}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	runs := reopened.Runs(RunFilter{Container: "next"})
+	if len(runs) != 1 || runs[0].ID != 15 {
+		t.Errorf("new run after reload = %+v, want ID 15", runs)
+	}
+}
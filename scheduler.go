@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --- Scheduler ---
+
+// Reasons a Pending process has not been admitted yet.
+const (
+	WaitGroupThrottled = "GroupThrottled"
+	WaitAdmission      = "AdmissionDenied"
+	WaitCPUWeight      = "CPUWeightLimit"
+	WaitMemory         = "MemoryLimit"
+	WaitMaxRunning     = "MaxRunning"
+	WaitNotPicked      = "NotPicked"
+	WaitPreviousRun    = "PreviousRunExiting" // requeued before its last action returned
+)
+
+// ErrAdmissionRejected, when wrapped by an AdmissionController error, fails
+// the process instead of leaving it queued.
+var ErrAdmissionRejected = errors.New("admission rejected")
+
+// ErrCPUWeightLimit fails a process whose CPUWeight alone exceeds its
+// container's CPUWeightLimit, since it could never be admitted.
+var ErrCPUWeightLimit = errors.New("process CPU weight exceeds container limit")
+
+// AdmissionController decides whether p may start in c. A nil error admits
+// the process; an error wrapping ErrAdmissionRejected fails it; any other
+// error keeps it Pending until the next scheduling pass. It is called with
+// the container locked and must not call back into c or the kernel.
+type AdmissionController func(c *Container, p *Process) error
+
+// scheduleLocked admits the container's pending processes in the order the
+// kernel's Scheduler picks them, by default highest priority first and in
+// arrival order among equals. A process that cannot be admitted stays
+// Pending with a WaitReason (or becomes Throttled when out of CPU credits)
+// and is reconsidered on the next scheduling pass.
+func (c *Container) scheduleLocked() {
+	defer c.checkQueueLocked()
+	if c.State != ContainerRunning {
+		return
+	}
+	now := c.now()
+	var candidates []*Process
+	running := 0
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled:
+			if p.liveGoroutines() > 0 {
+				c.waitLocked(p, WaitPreviousRun)
+				continue
+			}
+			if c.inScheduleLocked(p, now) {
+				candidates = append(candidates, p)
+			}
+		case Running:
+			if !p.debug {
+				running++
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		c.checkMemoryLocked()
+		return
+	}
+	slots := len(candidates)
+	if c.MaxRunning > 0 {
+		slots = min(slots, max(c.MaxRunning-running, 0))
+	}
+	picked := make(map[*Process]bool, slots)
+	if slots > 0 {
+		for _, p := range c.pickLocked(candidates, slots) {
+			picked[p] = true
+			if c.admitLocked(p, now) {
+				c.startLocked(p, now)
+			}
+		}
+	}
+	reason := WaitNotPicked
+	if slots < len(candidates) {
+		reason = WaitMaxRunning
+	}
+	for _, p := range candidates {
+		if !picked[p] && p.State == Pending {
+			c.waitLocked(p, reason)
+		}
+	}
+	c.checkMemoryLocked()
+}
+
+// admitLocked reports whether p may start now. Checks that reserve a shared
+// resource come last so a later rejection cannot leak the reservation.
+func (c *Container) admitLocked(p *Process, now time.Time) bool {
+	if p.CPUCredits != nil {
+		p.CPUCredits.settle(now, false)
+		if p.CPUCredits.Exhausted() {
+			if p.State != Throttled {
+				fmt.Printf("[Kernel] Throttled process %s in %s: CPU credits exhausted\n", p.Name, c.Name)
+				c.emit(EventProcessThrottled, p, "CPU credits exhausted")
+			}
+			p.State = Throttled
+			c.wakeOnRefillLocked(p, now)
+			return false
+		}
+		p.State = Pending
+	}
+	if c.CPUWeightLimit > 0 {
+		if p.CPUWeight > c.CPUWeightLimit {
+			p.Err = fmt.Errorf("%w: %.2f > %.2f", ErrCPUWeightLimit, p.CPUWeight, c.CPUWeightLimit)
+			c.finishLocked(p, Failed)
+			c.emit(EventProcessFailed, p, p.Err.Error())
+			return false
+		}
+		if c.runningCPUWeightLocked()+p.CPUWeight > c.CPUWeightLimit {
+			c.waitLocked(p, WaitCPUWeight)
+			return false
+		}
+	}
+	if !p.debug && p.MemoryMB > 0 && c.memoryUsageLocked()+p.MemoryMB > c.MemoryMB {
+		c.waitLocked(p, WaitMemory)
+		return false
+	}
+	if c.kernel != nil && c.kernel.AdmissionController != nil {
+		if err := c.kernel.AdmissionController(c, p); err != nil {
+			if errors.Is(err, ErrAdmissionRejected) {
+				p.Err = err
+				c.finishLocked(p, Failed)
+				c.emit(EventProcessFailed, p, err.Error())
+				return false
+			}
+			c.waitLocked(p, WaitAdmission)
+			return false
+		}
+	}
+	if p.ConcurrencyGroup != "" && c.kernel != nil && !c.kernel.groups.tryAcquire(p.ConcurrencyGroup) {
+		c.waitLocked(p, WaitGroupThrottled)
+		return false
+	}
+	return true
+}
+
+// runningCPUWeightLocked sums the CPUWeight of the running processes.
+func (c *Container) runningCPUWeightLocked() float64 {
+	var w float64
+	for _, p := range c.Processes {
+		if p.State == Running && !p.debug {
+			w += p.CPUWeight
+		}
+	}
+	return w
+}
+
+// AvailableCPUWeight reports how much CPU weight more processes may use
+// before CPUWeightLimit queues them; +Inf if the container has no limit.
+func (c *Container) AvailableCPUWeight() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.CPUWeightLimit <= 0 {
+		return math.Inf(1)
+	}
+	return max(c.CPUWeightLimit-c.runningCPUWeightLocked(), 0)
+}
+
+// waitLocked records why p is still pending, emitting an event when the
+// reason changes.
+func (c *Container) waitLocked(p *Process, reason string) {
+	if p.WaitReason == reason {
+		return
+	}
+	p.WaitReason = reason
+	c.emit(EventProcessWaiting, p, reason)
+}
+
+func (c *Container) startLocked(p *Process, now time.Time) {
+	p.State = Running
+	p.WaitReason = ""
+	p.startedAt = now
+	p.runs++
+	if c.kernel != nil && !p.debug {
+		c.kernel.schedLatency.observe(now.Sub(p.addedAt))
+	}
+	p.finishedAt = time.Time{}
+	p.usedMB, p.peakMB = p.MemoryMB, p.MemoryMB
+	p.stopping = false
+	p.stopStep = StopStepNone
+	p.result, p.hasResult = nil, false
+	c.observeLoadLocked()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), containerKey{}, c))
+	p.cancel = cancel
+	p.handle = newHandle(c, p)
+	p.handle.ctx = ctx
+	p.handle.run = &runState{done: make(chan struct{}), live: max(p.Replicas, 1)}
+	c.emit(EventProcessStarted, p, "")
+	// Process goroutines carry pprof labels so goroutine profiles
+	// attribute them to their container and process.
+	labels := pprof.Labels("bvisor_container", c.ID, "bvisor_pid", strconv.Itoa(p.PID), "bvisor_process", p.Name)
+	if p.Replicas <= 1 {
+		p.replicas = nil
+		go pprof.Do(ctx, labels, func(ctx context.Context) { c.run(ctx, p, p.handle) })
+		return
+	}
+	p.replicas = make([]replicaStatus, p.Replicas)
+	for i := range p.replicas {
+		p.replicas[i].state = Running
+		h := p.handle
+		if i > 0 {
+			h = p.handle.forReplica(i)
+		}
+		go pprof.Do(ctx, labels, func(ctx context.Context) { c.run(ctx, p, h) })
+	}
+}
+
+// kick asks the kernel to run a scheduling pass over every running
+// container. It is used when a kernel-wide resource frees up; the pass runs
+// asynchronously because callers usually hold a container lock, and
+// concurrent kicks are coalesced.
+func (k *Kernel) kick() {
+	if !k.kickPending.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		k.kickPending.Store(false)
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		for _, c := range k.Containers {
+			c.mu.Lock()
+			c.scheduleLocked()
+			c.mu.Unlock()
+		}
+	}()
+}
+
+// --- Concurrency Groups ---
+
+// concurrencyGroups is a set of kernel-wide counting semaphores keyed by
+// group name. A group without a limit admits everything.
+type concurrencyGroups struct {
+	mu     sync.Mutex
+	groups map[string]*groupSlots
+}
+
+type groupSlots struct {
+	limit   int // zero means unlimited
+	running int
+}
+
+func (g *concurrencyGroups) get(name string) *groupSlots {
+	if g.groups == nil {
+		g.groups = make(map[string]*groupSlots)
+	}
+	s, ok := g.groups[name]
+	if !ok {
+		s = &groupSlots{}
+		g.groups[name] = s
+	}
+	return s
+}
+
+func (g *concurrencyGroups) tryAcquire(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := g.get(name)
+	if s.limit > 0 && s.running >= s.limit {
+		return false
+	}
+	s.running++
+	return true
+}
+
+func (g *concurrencyGroups) release(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s := g.get(name); s.running > 0 {
+		s.running--
+	}
+}
+
+// GroupStats reports the occupancy of a concurrency group.
+type GroupStats struct {
+	Limit   int `json:"limit"`
+	Running int `json:"running"`
+	Waiting int `json:"waiting"`
+}
+
+// SetGroupLimit sets how many processes of group may run at once across the
+// kernel; zero removes the limit. The new limit applies to future
+// admissions: lowering it does not stop running processes, raising it
+// admits waiters straight away.
+func (k *Kernel) SetGroupLimit(group string, limit int) {
+	k.groups.mu.Lock()
+	k.groups.get(group).limit = limit
+	k.groups.mu.Unlock()
+	k.kick()
+}
+
+// WithConcurrencyGroup tags p as a member of group and returns p.
+func (p *Process) WithConcurrencyGroup(group string) *Process {
+	p.ConcurrencyGroup = group
+	return p
+}
+
+// groupStatsLocked builds per-group occupancy. The caller holds k.mu.
+func (k *Kernel) groupStatsLocked() map[string]GroupStats {
+	k.groups.mu.Lock()
+	out := make(map[string]GroupStats, len(k.groups.groups))
+	for name, s := range k.groups.groups {
+		out[name] = GroupStats{Limit: s.limit, Running: s.running}
+	}
+	k.groups.mu.Unlock()
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			if p.State == Pending && p.WaitReason == WaitGroupThrottled {
+				gs := out[p.ConcurrencyGroup]
+				gs.Waiting++
+				out[p.ConcurrencyGroup] = gs
+			}
+		}
+		c.mu.Unlock()
+	}
+	return out
+}
+
+// --- Idle Detection ---
+
+// WaitForIdle blocks until no process in any container is Running, Pending
+// or Throttled, or until ctx is done. Processes added while waiting are
+// taken into account.
+func (k *Kernel) WaitForIdle(ctx context.Context) error {
+	events, stop := k.Subscribe()
+	defer stop()
+	for {
+		if k.idle() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-events:
+		}
+	}
+}
+
+func (k *Kernel) idle() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			switch p.State {
+			case Running, Pending, Throttled:
+				c.mu.Unlock()
+				return false
+			}
+		}
+		c.mu.Unlock()
+	}
+	return true
+}
+
+// IsIdle reports whether the container has no running or queued process.
+func (c *Container) IsIdle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.hasProcessInLocked(Running, Pending, Throttled)
+}
+
+// noteIdleLocked records when the container last became idle, for
+// IdleTimeout.
+func (c *Container) noteIdleLocked() {
+	if !c.hasProcessInLocked(Running, Pending, Throttled) {
+		c.idleSince = c.now()
+	}
+}
+
+// SweepIdle stops every running container that has been idle for longer
+// than its IdleTimeout, or removes it if IdleRemove is set, and returns the
+// IDs it acted on. Idleness is measured from the last process finishing, or
+// from the first sweep that saw the container idle.
+func (k *Kernel) SweepIdle() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.Clock.Now()
+	var swept []string
+	for _, c := range k.sortedContainersLocked() {
+		c.mu.Lock()
+		if c.IdleTimeout <= 0 || c.State != ContainerRunning || c.hasProcessInLocked(Running, Pending, Throttled) {
+			c.mu.Unlock()
+			continue
+		}
+		if c.idleSince.IsZero() {
+			c.idleSince = now
+		}
+		idle := now.Sub(c.idleSince)
+		if idle < c.IdleTimeout {
+			c.mu.Unlock()
+			continue
+		}
+		action := "stopped"
+		if c.IdleRemove {
+			action = "removed"
+		}
+		fmt.Printf("[Kernel] Container %s idle for %v: %s\n", c.Name, idle, action)
+		c.emit(EventContainerIdle, nil, action)
+		if !c.IdleRemove {
+			c.stopProcessesLocked()
+		}
+		c.mu.Unlock()
+		if c.IdleRemove {
+			k.removeContainerLocked(c)
+		}
+		swept = append(swept, c.ID)
+	}
+	return swept
+}
+
+// StartIdleSweeper runs SweepIdle every interval on the kernel clock until
+// the returned function is called.
+func (k *Kernel) StartIdleSweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	tick := k.Clock.After(interval)
+	go func() {
+		for {
+			select {
+			case <-tick:
+				k.SweepIdle()
+				tick = k.Clock.After(interval)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// --- Queue Depth ---
+
+// QueueDepth reports how many of the container's processes are waiting to
+// be admitted (Pending or Throttled).
+func (c *Container) QueueDepth() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.queueDepthLocked()
+}
+
+func (c *Container) queueDepthLocked() int {
+	n := 0
+	for _, p := range c.Processes {
+		if p.State == Pending || p.State == Throttled {
+			n++
+		}
+	}
+	return n
+}
+
+// TotalQueueDepth is QueueDepth summed over every container.
+func (k *Kernel) TotalQueueDepth() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n := 0
+	for _, c := range k.Containers {
+		n += c.QueueDepth()
+	}
+	return n
+}
+
+// checkQueueLocked raises an EventQueueBacklog when the container's queue
+// grows past Kernel.QueueDepthAlert, and re-arms once it is back under.
+func (c *Container) checkQueueLocked() {
+	if c.kernel == nil || c.kernel.QueueDepthAlert <= 0 {
+		return
+	}
+	depth := c.queueDepthLocked()
+	switch {
+	case depth > c.kernel.QueueDepthAlert && !c.queueAlerted:
+		c.queueAlerted = true
+		fmt.Printf("[Kernel] Queue backlog in %s: %d waiting (limit %d)\n", c.Name, depth, c.kernel.QueueDepthAlert)
+		c.emit(EventQueueBacklog, nil, fmt.Sprintf("%d waiting, limit %d", depth, c.kernel.QueueDepthAlert))
+	case depth <= c.kernel.QueueDepthAlert:
+		c.queueAlerted = false
+	}
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// inFlight counts the actions running at once and remembers the most seen.
+type inFlight struct {
+	mu       sync.Mutex
+	now, max int
+}
+
+func (f *inFlight) enter() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now++
+	f.max = max(f.max, f.now)
+}
+
+func (f *inFlight) leave() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now--
+}
+
+func (f *inFlight) peak() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.max
+}
+
+func TestConcurrencyGroupLimitsAcrossContainers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.SetGroupLimit("backup", 2)
+	var flight inFlight
+	release := make(chan struct{})
+	var procs []*Process
+	for i := 0; i < 5; i++ {
+		c, ok := k.Containers[fmt.Sprint("c", i%3)]
+		if !ok {
+			c = newTestContainer(t, k, fmt.Sprint("c", i%3))
+			if err := c.StartProcesses(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		p := (&Process{Name: fmt.Sprint("backup", i), Action: func(ctx context.Context, h *Handle) error {
+			flight.enter()
+			defer flight.leave()
+			<-release
+			return nil
+		}}).WithConcurrencyGroup("backup")
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+
+	gs := k.Stats().Groups["backup"]
+	if gs != (GroupStats{Limit: 2, Running: 2, Waiting: 3}) {
+		t.Fatalf("group stats = %+v, want 2 running and 3 waiting", gs)
+	}
+	waiting := 0
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			if p.State == Pending && p.WaitReason == WaitGroupThrottled {
+				waiting++
+			}
+		}
+		c.mu.Unlock()
+	}
+	if waiting != 3 {
+		t.Errorf("%d processes waiting with %s, want 3", waiting, WaitGroupThrottled)
+	}
+
+	k.SetGroupLimit("backup", 3)
+	eventually(t, "a waiter to be admitted", func() bool { return k.Stats().Groups["backup"].Running == 3 })
+	if gs := k.Stats().Groups["backup"]; gs.Waiting != 2 {
+		t.Errorf("after raising the limit: %+v, want 2 waiting", gs)
+	}
+
+	close(release)
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if peak := flight.peak(); peak > 3 {
+		t.Errorf("%d backups ran at once, limit was at most 3", peak)
+	}
+	if gs := k.Stats().Groups["backup"]; gs.Running != 0 || gs.Waiting != 0 {
+		t.Errorf("after all finished: %+v", gs)
+	}
+}
+
+func TestConcurrencyGroupNeverExceedsLimit(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.SetGroupLimit("backup", 2)
+	var flight inFlight
+	var finished atomic.Int32
+	var procs []*Process
+	for i := 0; i < 3; i++ {
+		c := newTestContainer(t, k, fmt.Sprint("c", i))
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 4; j++ {
+			p := (&Process{Name: "backup", Action: func(ctx context.Context, h *Handle) error {
+				flight.enter()
+				defer flight.leave()
+				finished.Add(1)
+				return nil
+			}}).WithConcurrencyGroup("backup")
+			c.AddProcess(p)
+			procs = append(procs, p)
+		}
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if peak := flight.peak(); peak > 2 {
+		t.Errorf("%d backups ran at once, limit 2", peak)
+	}
+	if finished.Load() != 12 {
+		t.Errorf("%d backups ran, want 12", finished.Load())
+	}
+}
+
+func TestWaitForIdleCoversProcessesAddedWhileWaiting(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	release := make(chan struct{})
+	follow := &Process{Name: "follow-up", Action: blockUntil(release)}
+	first := &Process{Name: "first", Action: func(ctx context.Context, h *Handle) error {
+		c.AddProcess(follow)
+		return nil
+	}}
+	c.AddProcess(first)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+
+	idle := make(chan error, 1)
+	go func() { idle <- k.WaitForIdle(context.Background()) }()
+	waitDone(t, first)
+	select {
+	case err := <-idle:
+		t.Fatalf("WaitForIdle returned %v while follow-up was %v", err, stateOf(c, follow))
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-idle:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForIdle did not return")
+	}
+	if got := stateOf(c, follow); got != Completed {
+		t.Errorf("follow-up = %v when idle, want Completed", got)
+	}
+}
+
+func TestWaitForIdleHonoursContext(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	c.AddProcess(&Process{Name: "forever", Action: blockUntil(nil)})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := k.WaitForIdle(ctx); err != context.DeadlineExceeded {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestAdmissionControllerRejectsAndDefers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	var open atomic.Bool
+	k.AdmissionController = func(c *Container, p *Process) error {
+		switch {
+		case p.Name == "blocked":
+			return fmt.Errorf("%w: %s is blocked", ErrAdmissionRejected, p.Name)
+		case p.Name == "deferred" && !open.Load():
+			return errors.New("maintenance window")
+		}
+		return nil
+	}
+	c := newTestContainer(t, k, "jobs")
+	var ran atomic.Int32
+	count := func(ctx context.Context, h *Handle) error {
+		ran.Add(1)
+		return nil
+	}
+	blocked := &Process{Name: "blocked", Action: count}
+	deferred := &Process{Name: "deferred", Action: count}
+	allowed := &Process{Name: "allowed", Action: count}
+	for _, p := range []*Process{blocked, deferred, allowed} {
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, blocked)
+	waitDone(t, allowed)
+	if got := stateOf(c, blocked); got != Failed {
+		t.Errorf("blocked = %v, want Failed", got)
+	}
+	if d := c.Inspect().Processes[1]; d.State != Pending || d.WaitReason != WaitAdmission {
+		t.Errorf("deferred = %v waiting for %q", d.State, d.WaitReason)
+	}
+	if ran.Load() != 1 {
+		t.Fatalf("%d actions ran, want only allowed", ran.Load())
+	}
+
+	open.Store(true)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, deferred)
+	if ran.Load() != 2 {
+		t.Errorf("%d actions ran, want allowed and deferred", ran.Load())
+	}
+}
+
+func TestQueueDepthAndBacklogAlert(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.SetGroupLimit("io", 1)
+	k.QueueDepthAlert = 2
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventQueueBacklog}})
+	defer cancel()
+	c := newTestContainer(t, k, "disk")
+	other := newTestContainer(t, k, "net")
+	for _, started := range []*Container{c, other} {
+		if err := started.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release := make(chan struct{})
+	var procs []*Process
+	for i := 0; i < 4; i++ {
+		p := (&Process{Name: fmt.Sprint("write", i), Action: blockUntil(release)}).WithConcurrencyGroup("io")
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	other.AddProcess((&Process{Name: "fetch", Action: blockUntil(release)}).WithConcurrencyGroup("io"))
+
+	if got := c.QueueDepth(); got != 3 {
+		t.Errorf("QueueDepth = %d, want 3 behind the running write", got)
+	}
+	if got := k.TotalQueueDepth(); got != 4 {
+		t.Errorf("TotalQueueDepth = %d, want 4", got)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "disk" || e.Detail != "3 waiting, limit 2" {
+		t.Errorf("event = %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("alert raised again before re-arming: %+v", e)
+	default:
+	}
+
+	close(release)
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if got := c.QueueDepth(); got != 0 {
+		t.Errorf("QueueDepth = %d after the backlog cleared", got)
+	}
+}
+
+func TestCPUWeightLimitQueuesProcesses(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	if got := c.AvailableCPUWeight(); !math.IsInf(got, 1) {
+		t.Errorf("AvailableCPUWeight without a limit = %v, want +Inf", got)
+	}
+	c.CPUWeightLimit = 1
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	var releases []chan struct{}
+	var procs []*Process
+	for i := 0; i < 3; i++ {
+		release := make(chan struct{})
+		p := &Process{Name: fmt.Sprint("job", i), CPUWeight: 0.5, Action: blockUntil(release)}
+		c.AddProcess(p)
+		releases = append(releases, release)
+		procs = append(procs, p)
+	}
+	huge := &Process{Name: "huge", CPUWeight: 2, Action: noop}
+	c.AddProcess(huge)
+
+	if d := c.Inspect().Processes[2]; d.State != Pending || d.WaitReason != WaitCPUWeight {
+		t.Errorf("job2 = %v waiting for %q, want queued on CPU weight", d.State, d.WaitReason)
+	}
+	if got := c.AvailableCPUWeight(); got != 0 {
+		t.Errorf("AvailableCPUWeight = %v with the limit reached", got)
+	}
+	if got := stateOf(c, huge); got != Failed || !errors.Is(huge.Err, ErrCPUWeightLimit) {
+		t.Errorf("huge = %v (%v), want Failed with ErrCPUWeightLimit", got, huge.Err)
+	}
+
+	close(releases[0])
+	waitDone(t, procs[0])
+	eventually(t, "job2 to be admitted", func() bool { return stateOf(c, procs[2]) == Running })
+	if got := c.AvailableCPUWeight(); got != 0 {
+		t.Errorf("AvailableCPUWeight = %v after job2 took the freed weight", got)
+	}
+	for _, release := range releases[1:] {
+		close(release)
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if got := c.AvailableCPUWeight(); got != 1 {
+		t.Errorf("AvailableCPUWeight = %v when idle, want 1", got)
+	}
+}
+
+func TestIdleContainerAutoStops(t *testing.T) {
+	k, clk := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventContainerIdle}})
+	defer cancel()
+	c := newTestContainer(t, k, "jobs")
+	c.IdleTimeout = time.Minute
+	scratch := newTestContainer(t, k, "scratch")
+	scratch.IdleTimeout = time.Minute
+	scratch.IdleRemove = true
+	release := make(chan struct{})
+	p := &Process{Name: "work", Action: blockUntil(release)}
+	c.AddProcess(p)
+	for _, started := range []*Container{c, scratch} {
+		if err := started.StartProcesses(); err != nil && !errors.Is(err, ErrNoPendingProcesses) {
+			t.Fatal(err)
+		}
+	}
+	if c.IsIdle() || !scratch.IsIdle() {
+		t.Fatalf("IsIdle: jobs %v, scratch %v", c.IsIdle(), scratch.IsIdle())
+	}
+
+	stop := k.StartIdleSweeper(10 * time.Second)
+	defer stop()
+	waitForWaiters(t, clk, 1)
+	clk.Advance(10 * time.Second) // scratch is first seen idle
+	close(release)
+	waitDone(t, p)
+	if !c.IsIdle() {
+		t.Fatal("jobs not idle once its process finished")
+	}
+
+	for i := 0; i < 5; i++ {
+		waitForWaiters(t, clk, 1)
+		clk.Advance(10 * time.Second)
+	}
+	waitForWaiters(t, clk, 1) // the sweep at 60s has run
+	select {
+	case e := <-events:
+		t.Fatalf("swept before the timeout: %+v", e)
+	default:
+	}
+	clk.Advance(10 * time.Second)
+	if e := nextEvent(t, events); e.ContainerID != "jobs" || e.Detail != "stopped" {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := nextEvent(t, events); e.ContainerID != "scratch" || e.Detail != "removed" {
+		t.Errorf("second event = %+v", e)
+	}
+	waitForWaiters(t, clk, 1)
+	if got := k.SweepIdle(); len(got) != 0 {
+		t.Errorf("SweepIdle = %v after the sweeper stopped everything idle", got)
+	}
+	if got := c.Inspect().State; got != ContainerStopped {
+		t.Errorf("jobs = %v, want stopped", got)
+	}
+	if _, ok := k.Containers["scratch"]; ok {
+		t.Error("scratch was not removed")
+	}
+}
+
+func TestNiceYieldsSlotsWithoutChangingPriority(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "jobs")
+	c.MaxRunning = 1
+	var mu sync.Mutex
+	var order []string
+	var procs []*Process
+	for _, spec := range []struct {
+		name string
+		nice int
+	}{{"niced", 10}, {"plain", 0}, {"eager", -5}, {"clamped", 100}} {
+		p := &Process{Name: spec.name, Priority: 5, Nice: spec.nice, Action: func(ctx context.Context, h *Handle) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, h.proc.Name)
+			return nil
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	var effective []int
+	for _, d := range c.Inspect().Processes {
+		effective = append(effective, d.EffectivePriority)
+	}
+	if fmt.Sprint(effective) != "[-5 5 10 -14]" {
+		t.Errorf("effective priorities = %v, want [-5 5 10 -14]", effective)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	if fmt.Sprint(order) != "[eager plain niced clamped]" {
+		t.Errorf("run order = %v, want the nicest first", order)
+	}
+	if d := c.Inspect().Processes[0]; d.Priority != 5 || d.Nice != 10 {
+		t.Errorf("niced = priority %d nice %d, want 5 and 10 untouched", d.Priority, d.Nice)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Scheduler Plugins ---
+
+// ProcessView is a read-only copy of a process as seen by a Scheduler.
+type ProcessView struct {
+	PID               int
+	Name              string
+	State             ProcessState
+	Priority          int
+	Nice              int
+	EffectivePriority int // Priority adjusted by Nice and inheritance
+	CPUWeight         float64
+	ConcurrencyGroup  string
+	Kind              string
+	Params            map[string]string
+	AddedAt           time.Time
+}
+
+// SchedulerView is a read-only copy of one container's admission state.
+// Queue holds the Pending and Throttled processes in arrival order.
+type SchedulerView struct {
+	Container        string
+	Queue            []ProcessView
+	Running          []ProcessView
+	CPUWeightLimit   float64 // zero means unlimited
+	RunningCPUWeight float64
+}
+
+// Scheduler orders a container's queue for admission. PickNext returns, in
+// the order to try them, at most availableSlots processes taken from
+// view.Queue; each is still subject to the usual admission checks (CPU
+// credits, CPU weight, AdmissionController, concurrency groups). The hooks
+// are called on every scheduling pass, process arrival and completion, with
+// the container locked, so they must be quick, must not call back into the
+// kernel, and must be safe for concurrent use across containers.
+type Scheduler interface {
+	OnProcessQueued(container string, p ProcessView)
+	PickNext(view SchedulerView, availableSlots int) []ProcessView
+	OnProcessFinished(container string, p ProcessView)
+}
+
+// KernelOption configures NewKernel.
+type KernelOption func(*Kernel)
+
+// WithScheduler replaces the built-in PriorityScheduler.
+func WithScheduler(s Scheduler) KernelOption {
+	return func(k *Kernel) {
+		k.scheduler = s
+	}
+}
+
+// PriorityScheduler admits the highest effective priority first, in arrival
+// order among equals. It is the default.
+type PriorityScheduler struct{}
+
+func (PriorityScheduler) OnProcessQueued(string, ProcessView)   {}
+func (PriorityScheduler) OnProcessFinished(string, ProcessView) {}
+
+func (PriorityScheduler) PickNext(view SchedulerView, availableSlots int) []ProcessView {
+	q := append([]ProcessView(nil), view.Queue...)
+	sort.SliceStable(q, func(i, j int) bool {
+		return q[i].EffectivePriority > q[j].EffectivePriority
+	})
+	return q[:min(availableSlots, len(q))]
+}
+
+// FairShareScheduler shares slots between the owners of queued processes,
+// where a process's owner is its ConcurrencyGroup or, without one, its
+// name. Each slot goes to the owner with the fewest running processes,
+// and within an owner to the highest effective priority, so one owner
+// flooding the queue cannot starve the others.
+type FairShareScheduler struct{}
+
+func (FairShareScheduler) OnProcessQueued(string, ProcessView)   {}
+func (FairShareScheduler) OnProcessFinished(string, ProcessView) {}
+
+func (FairShareScheduler) PickNext(view SchedulerView, availableSlots int) []ProcessView {
+	owner := func(p ProcessView) string {
+		if p.ConcurrencyGroup != "" {
+			return p.ConcurrencyGroup
+		}
+		return p.Name
+	}
+	running := make(map[string]int)
+	for _, p := range view.Running {
+		running[owner(p)]++
+	}
+	q := append([]ProcessView(nil), view.Queue...)
+	sort.SliceStable(q, func(i, j int) bool {
+		return q[i].EffectivePriority > q[j].EffectivePriority
+	})
+	var picked []ProcessView
+	for len(picked) < availableSlots && len(q) > 0 {
+		best := 0
+		for i, p := range q {
+			if running[owner(p)] < running[owner(q[best])] {
+				best = i
+			}
+		}
+		running[owner(q[best])]++
+		picked = append(picked, q[best])
+		q = append(q[:best], q[best+1:]...)
+	}
+	return picked
+}
+
+// view returns a read-only copy of p. The caller holds the container lock.
+func (p *Process) view() ProcessView {
+	return ProcessView{
+		PID:               p.PID,
+		Name:              p.Name,
+		State:             p.State,
+		Priority:          p.Priority,
+		Nice:              p.Nice,
+		EffectivePriority: p.effectivePriorityLocked(),
+		CPUWeight:         p.CPUWeight,
+		ConcurrencyGroup:  p.ConcurrencyGroup,
+		Kind:              p.kindRef(),
+		Params:            copyStringMap(p.Params),
+		AddedAt:           p.addedAt,
+	}
+}
+
+// scheduler returns the kernel's scheduler, or the built-in one.
+func (c *Container) scheduler() Scheduler {
+	if c.kernel != nil && c.kernel.scheduler != nil {
+		return c.kernel.scheduler
+	}
+	return PriorityScheduler{}
+}
+
+// schedulerFaultLocked reports a misbehaving scheduler.
+func (c *Container) schedulerFaultLocked(p *Process, format string, args ...any) {
+	detail := fmt.Sprintf(format, args...)
+	fmt.Printf("[Kernel] Scheduler fault in %s: %s\n", c.Name, detail)
+	c.emit(EventSchedulerFault, p, detail)
+}
+
+// notifySchedulerLocked runs a scheduler hook, recovering from panics.
+func (c *Container) notifySchedulerLocked(p *Process, hook func(Scheduler, string, ProcessView)) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.schedulerFaultLocked(p, "hook panicked: %v", r)
+		}
+	}()
+	hook(c.scheduler(), c.ID, p.view())
+}
+
+// pickLocked asks the scheduler which of candidates to try, in order. Picks
+// that are not in candidates, or repeat an earlier pick, are dropped with
+// an EventSchedulerFault. If PickNext panics the pass falls back to
+// arrival order.
+func (c *Container) pickLocked(candidates []*Process, slots int) (order []*Process) {
+	byPID := make(map[int]*Process, len(candidates))
+	view := SchedulerView{Container: c.ID, CPUWeightLimit: c.CPUWeightLimit}
+	for _, p := range candidates {
+		byPID[p.PID] = p
+		view.Queue = append(view.Queue, p.view())
+	}
+	for _, p := range c.Processes {
+		if p.State == Running {
+			view.Running = append(view.Running, p.view())
+			view.RunningCPUWeight += p.CPUWeight
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			c.schedulerFaultLocked(nil, "PickNext panicked, falling back to FIFO: %v", r)
+			order = append([]*Process(nil), candidates...)
+			sort.SliceStable(order, func(i, j int) bool { return order[i].addedAt.Before(order[j].addedAt) })
+			order = order[:min(slots, len(order))]
+		}
+	}()
+	for _, v := range c.scheduler().PickNext(view, slots) {
+		p, ok := byPID[v.PID]
+		switch {
+		case !ok:
+			c.schedulerFaultLocked(nil, "picked PID %d, which is not queued", v.PID)
+		case len(order) == slots:
+			c.schedulerFaultLocked(p, "picked more than %d processes", slots)
+		default:
+			order = append(order, p)
+			delete(byPID, v.PID) // a second pick of the same PID is unknown
+		}
+	}
+	return order
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// shortestFirst admits the process with the smallest declared "duration"
+// parameter first.
+type shortestFirst struct{}
+
+func (shortestFirst) OnProcessQueued(string, ProcessView)   {}
+func (shortestFirst) OnProcessFinished(string, ProcessView) {}
+
+func (shortestFirst) PickNext(view SchedulerView, slots int) []ProcessView {
+	q := append([]ProcessView(nil), view.Queue...)
+	duration := func(p ProcessView) int {
+		d, _ := strconv.Atoi(p.Params["duration"])
+		return d
+	}
+	sort.SliceStable(q, func(i, j int) bool { return duration(q[i]) < duration(q[j]) })
+	return q[:min(slots, len(q))]
+}
+
+// rogueScheduler misbehaves according to mode.
+type rogueScheduler struct{ mode string }
+
+func (rogueScheduler) OnProcessQueued(string, ProcessView)   {}
+func (rogueScheduler) OnProcessFinished(string, ProcessView) {}
+
+func (s rogueScheduler) PickNext(view SchedulerView, slots int) []ProcessView {
+	switch s.mode {
+	case "panic":
+		panic("bad plugin")
+	case "unknown":
+		return []ProcessView{{PID: 9999}}
+	}
+	return nil
+}
+
+// runSerially adds processes with the given names and declared durations
+// to a container that runs one at a time, starts it and returns the names
+// in completion order.
+func runSerially(t *testing.T, k *Kernel, durations map[string]string, names ...string) []string {
+	t.Helper()
+	c := newTestContainer(t, k, "jobs")
+	c.MaxRunning = 1
+	var mu sync.Mutex
+	var done []string
+	var procs []*Process
+	for _, name := range names {
+		p := &Process{Name: name, Params: map[string]string{"duration": durations[name]}, Action: func(ctx context.Context, h *Handle) error {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, h.proc.Name)
+			return nil
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return done
+}
+
+func TestPluginSchedulerDrivesCompletionOrder(t *testing.T) {
+	durations := map[string]string{"long": "30", "short": "10", "medium": "20"}
+	k := NewKernel(WithScheduler(shortestFirst{}))
+	k.Clock = NewFakeClock(testEpoch)
+	if got := runSerially(t, k, durations, "long", "short", "medium"); fmt.Sprint(got) != "[short medium long]" {
+		t.Errorf("completion order = %v, want shortest declared duration first", got)
+	}
+}
+
+func TestPanickingSchedulerFallsBackToFIFO(t *testing.T) {
+	k := NewKernel(WithScheduler(rogueScheduler{mode: "panic"}))
+	k.Clock = NewFakeClock(testEpoch)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventSchedulerFault}})
+	defer cancel()
+	if got := runSerially(t, k, nil, "a", "b", "c"); fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("completion order = %v, want arrival order", got)
+	}
+	if e := nextEvent(t, events); e.Detail != "PickNext panicked, falling back to FIFO: bad plugin" {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+func TestSchedulerPickingUnknownProcessIsRejected(t *testing.T) {
+	k := NewKernel(WithScheduler(rogueScheduler{mode: "unknown"}))
+	k.Clock = NewFakeClock(testEpoch)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventSchedulerFault}})
+	defer cancel()
+	c := newTestContainer(t, k, "jobs")
+	c.AddProcess(&Process{Name: "job", Action: noop})
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Detail != "picked PID 9999, which is not queued" {
+		t.Errorf("event = %+v", e)
+	}
+	if d := c.Inspect().Processes[0]; d.State != Pending || d.WaitReason != WaitNotPicked {
+		t.Errorf("job = %v waiting for %q, want left queued", d.State, d.WaitReason)
+	}
+}
+
+func TestFairShareSchedulerSpreadsSlots(t *testing.T) {
+	view := SchedulerView{
+		Queue: []ProcessView{
+			{PID: 1, Name: "a", ConcurrencyGroup: "flood", EffectivePriority: 5},
+			{PID: 2, Name: "b", ConcurrencyGroup: "flood", EffectivePriority: 5},
+			{PID: 3, Name: "c", ConcurrencyGroup: "flood", EffectivePriority: 5},
+			{PID: 4, Name: "d", EffectivePriority: 1},
+		},
+		Running: []ProcessView{{PID: 5, Name: "e", ConcurrencyGroup: "flood"}},
+	}
+	var pids []int
+	for _, p := range (FairShareScheduler{}).PickNext(view, 2) {
+		pids = append(pids, p.PID)
+	}
+	if fmt.Sprint(pids) != "[4 1]" {
+		t.Errorf("fair share picked %v, want the lone owner first, then the flood", pids)
+	}
+	pids = nil
+	for _, p := range (PriorityScheduler{}).PickNext(view, 2) {
+		pids = append(pids, p.PID)
+	}
+	if fmt.Sprint(pids) != "[1 2]" {
+		t.Errorf("priority picked %v, want [1 2]", pids)
+	}
+}
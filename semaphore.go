@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --- Semaphores ---
+
+var (
+	// ErrSemaphoreExists is returned by NewSemaphore for a name in use.
+	ErrSemaphoreExists = errors.New("semaphore already exists")
+	// ErrSemaphoreNotFound is returned for a semaphore never created.
+	ErrSemaphoreNotFound = errors.New("semaphore not found")
+	// ErrSemaphoreNotHeld is returned by Handle.Release when the process
+	// holds no permit of the semaphore.
+	ErrSemaphoreNotHeld = errors.New("semaphore permit not held")
+)
+
+// semaphore is a kernel-wide counting semaphore. It has its own mutex so
+// permits can be returned under a container lock.
+type semaphore struct {
+	name    string
+	size    int
+	mu      sync.Mutex
+	held    int
+	waiting int
+	ready   chan struct{} // closed and replaced whenever a permit is returned
+}
+
+// SemaphoreInfo describes a semaphore's permits.
+type SemaphoreInfo struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	Held    int    `json:"held"`
+	Waiting int    `json:"waiting"`
+}
+
+// NewSemaphore creates a semaphore with n permits that processes in any
+// container take with Handle.Acquire and return with Handle.Release,
+// to coordinate access to a shared resource.
+func (k *Kernel) NewSemaphore(name string, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("semaphore %s: size must be positive, got %d", name, n)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.semaphores[name]; ok {
+		return fmt.Errorf("%w: %s", ErrSemaphoreExists, name)
+	}
+	if k.semaphores == nil {
+		k.semaphores = make(map[string]*semaphore)
+	}
+	k.semaphores[name] = &semaphore{name: name, size: n, ready: make(chan struct{})}
+	return nil
+}
+
+// Semaphores lists the semaphores in name order.
+func (k *Kernel) Semaphores() []SemaphoreInfo {
+	k.mu.Lock()
+	sems := make([]*semaphore, 0, len(k.semaphores))
+	for _, s := range k.semaphores {
+		sems = append(sems, s)
+	}
+	k.mu.Unlock()
+	out := make([]SemaphoreInfo, 0, len(sems))
+	for _, s := range sems {
+		s.mu.Lock()
+		out = append(out, SemaphoreInfo{Name: s.name, Size: s.size, Held: s.held, Waiting: s.waiting})
+		s.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (k *Kernel) semaphore(name string) (*semaphore, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s, ok := k.semaphores[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSemaphoreNotFound, name)
+	}
+	return s, nil
+}
+
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held--
+	close(s.ready)
+	s.ready = make(chan struct{})
+}
+
+// Acquire takes a permit of semaphore name, waiting until one is free. It
+// returns early with the context error when the process is stopped.
+// Permits still held when the action returns are given back by the kernel
+// and reported with an EventLeakDetected.
+func (h *Handle) Acquire(name string) error {
+	return h.syscall("acquire", name, func() error {
+		c, p := h.container, h.proc
+		if c.kernel == nil {
+			return fmt.Errorf("%w: %s", ErrSemaphoreNotFound, name)
+		}
+		if p.debug {
+			return ErrDebugReadOnly
+		}
+		s, err := c.kernel.semaphore(name)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.waiting++
+		for s.held >= s.size {
+			ready := s.ready
+			s.mu.Unlock()
+			select {
+			case <-ready:
+			case <-h.context().Done():
+				s.mu.Lock()
+				s.waiting--
+				s.mu.Unlock()
+				return h.context().Err()
+			}
+			s.mu.Lock()
+		}
+		s.waiting--
+		s.held++
+		s.mu.Unlock()
+
+		c.mu.Lock()
+		p.permits = append(p.permits, s)
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+// Release returns a permit of semaphore name taken with Acquire.
+func (h *Handle) Release(name string) error {
+	return h.syscall("release", name, func() error {
+		c, p := h.container, h.proc
+		c.mu.Lock()
+		i := slices.IndexFunc(p.permits, func(s *semaphore) bool { return s.name == name })
+		if i < 0 {
+			c.mu.Unlock()
+			return fmt.Errorf("%w: %s", ErrSemaphoreNotHeld, name)
+		}
+		s := p.permits[i]
+		p.permits = slices.Delete(p.permits, i, i+1)
+		c.mu.Unlock()
+		s.release()
+		return nil
+	})
+}
+
+// releasePermitsLocked returns the permits p still held when its action
+// returned.
+func (c *Container) releasePermitsLocked(p *Process) {
+	if len(p.permits) == 0 {
+		return
+	}
+	names := make([]string, len(p.permits))
+	for i, s := range p.permits {
+		names[i] = "semaphore:" + s.name
+		s.release()
+	}
+	p.permits = nil
+	fmt.Printf("[Kernel] Process %s in %s leaked %d semaphore permit(s): %s\n", p.Name, c.Name, len(names), strings.Join(names, ", "))
+	c.emit(EventLeakDetected, p, strings.Join(names, ","))
+}
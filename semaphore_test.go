@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSemaphoreSerializesProcessesAcrossContainers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	if err := k.NewSemaphore("db", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.NewSemaphore("db", 2); !errors.Is(err, ErrSemaphoreExists) {
+		t.Errorf("creating db twice: %v", err)
+	}
+	entered, release := make(chan string), make(chan struct{})
+	var procs []*Process
+	for _, id := range []string{"a", "b", "c"} {
+		c := newTestContainer(t, k, id)
+		p := &Process{Name: "writer", Action: func(ctx context.Context, h *Handle) error {
+			if err := h.Acquire("db"); err != nil {
+				return err
+			}
+			entered <- h.container.ID
+			<-release
+			return h.Release("db")
+		}}
+		c.AddProcess(p)
+		procs = append(procs, p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 3; i > 0; i-- {
+		id := <-entered
+		seen[id] = true
+		eventually(t, "the others waiting", func() bool {
+			s := k.Semaphores()[0]
+			return s.Held == 1 && s.Waiting == i-1
+		})
+		select {
+		case other := <-entered:
+			t.Fatalf("%s entered while %s held the only permit", other, id)
+		default:
+		}
+		release <- struct{}{}
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+		if p.State != Completed {
+			t.Errorf("writer = %v", p.State)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("entered: %v, want each container once", seen)
+	}
+	if s := k.Semaphores()[0]; s != (SemaphoreInfo{Name: "db", Size: 1}) {
+		t.Errorf("semaphore = %+v after every writer released", s)
+	}
+}
+
+func TestSemaphorePermitLeakIsReturned(t *testing.T) {
+	k, _ := newTestKernel(t)
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventLeakDetected}})
+	defer cancel()
+	if err := k.NewSemaphore("db", 1); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestContainer(t, k, "leaky")
+	var releaseErr error
+	p := &Process{Name: "forgetful", Action: func(ctx context.Context, h *Handle) error {
+		releaseErr = h.Release("db")
+		return h.Acquire("db")
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if !errors.Is(releaseErr, ErrSemaphoreNotHeld) {
+		t.Errorf("releasing before acquiring: %v", releaseErr)
+	}
+	if e := nextEvent(t, events); e.Detail != "semaphore:db" {
+		t.Errorf("event = %+v", e)
+	}
+	if s := k.Semaphores()[0]; s.Held != 0 {
+		t.Errorf("semaphore = %+v, want the leaked permit returned", s)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// --- Services ---
+
+// servicePrefix marks a message destination as a service name rather than a
+// container ID, e.g. "svc:database".
+const servicePrefix = "svc:"
+
+var (
+	ErrServiceNotFound = errors.New("service not found")
+	ErrNoReadyBackend  = errors.New("service has no ready backend")
+)
+
+// service is a named set of backend containers. Plain sends are spread
+// round-robin; with sticky sessions enabled each session key keeps hitting
+// the same backend while it stays ready.
+type service struct {
+	name     string
+	backends []string
+	next     int
+	sticky   *stickyTable
+}
+
+// RegisterService adds containers as backends of service name, creating the
+// service if needed.
+func (k *Kernel) RegisterService(name string, containerIDs ...string) error {
+	if err := k.checkAuthoritative(); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, id := range containerIDs {
+		if _, ok := k.Containers[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+		}
+	}
+	svc, ok := k.services[name]
+	if !ok {
+		svc = &service{name: name}
+		k.services[name] = svc
+	}
+	for _, id := range containerIDs {
+		if !containsString(svc.backends, id) {
+			svc.backends = append(svc.backends, id)
+		}
+	}
+	return nil
+}
+
+// DeregisterService removes a backend from service name.
+func (k *Kernel) DeregisterService(name, containerID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if svc, ok := k.services[name]; ok {
+		svc.backends = removeString(svc.backends, containerID)
+	}
+}
+
+// EnableStickySessions turns on session affinity for service name, keeping
+// at most maxSessions assignments (least recently used are evicted).
+func (k *Kernel) EnableStickySessions(name string, maxSessions int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	svc, ok := k.services[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+	}
+	svc.sticky = newStickyTable(maxSessions)
+	return nil
+}
+
+// ResolveService picks the backend container for a send to service name.
+// sessionKey is only used when sticky sessions are enabled; an empty key
+// falls back to round-robin.
+func (k *Kernel) ResolveService(name, sessionKey string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.resolveLocked(name, sessionKey)
+}
+
+func (k *Kernel) resolveLocked(name, sessionKey string) (string, error) {
+	svc, ok := k.services[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+	}
+	ready := k.readyBackendsLocked(svc)
+	if len(ready) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrNoReadyBackend, name)
+	}
+	if svc.sticky == nil || sessionKey == "" {
+		id := ready[svc.next%len(ready)]
+		svc.next++
+		return id, nil
+	}
+	prev, had := svc.sticky.get(sessionKey)
+	if had && containsString(ready, prev) {
+		return prev, nil
+	}
+	id := rendezvous(sessionKey, ready)
+	svc.sticky.put(sessionKey, id)
+	if had {
+		k.emit(EventSessionReassigned, id, "", fmt.Sprintf("service %s session %s moved from %s", name, sessionKey, prev))
+	}
+	return id, nil
+}
+
+// readyBackendsLocked lists the backends of svc that can take traffic.
+func (k *Kernel) readyBackendsLocked(svc *service) []string {
+	var ready []string
+	for _, id := range svc.backends {
+		if c, ok := k.Containers[id]; ok && c.takesTraffic() {
+			ready = append(ready, id)
+		}
+	}
+	return ready
+}
+
+// resolveDestinationLocked maps a message destination to a container ID.
+// Resolution overrides are consulted first. Service destinations are
+// resolved with sessionKey, defaulting to the sender's ID.
+func (k *Kernel) resolveDestinationLocked(fromID, toID, sessionKey string) (string, error) {
+	if target, ok := k.overrideLocked(fromID, toID); ok {
+		return target, nil
+	}
+	name, ok := strings.CutPrefix(toID, servicePrefix)
+	if !ok {
+		return toID, nil
+	}
+	if sessionKey == "" {
+		sessionKey = fromID
+	}
+	return k.resolveLocked(name, sessionKey)
+}
+
+// removeBackendLocked drops a removed container from every service.
+func (k *Kernel) removeBackendLocked(containerID string) {
+	for _, svc := range k.services {
+		svc.backends = removeString(svc.backends, containerID)
+	}
+}
+
+// --- Resolution Overrides ---
+
+// ResolutionOverride redirects a destination, like a hosts-file entry: a
+// message from a container in Scope addressed to Name goes to Target
+// instead. An empty Scope applies to every sender.
+type ResolutionOverride struct {
+	Scope  string
+	Name   string // a container ID or "svc:<name>"
+	Target string // a container ID
+}
+
+// SetResolutionOverride redirects destination name to targetID for senders
+// in scope: a container ID, or "" for every sender. Overrides win over
+// service discovery and plain container IDs alike, and a sender-scoped
+// override wins over a kernel-wide one. Adding one emits an
+// EventOverrideAdded, so a test fixture that leaks is visible.
+func (k *Kernel) SetResolutionOverride(scope, name, targetID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Containers[targetID]; !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, targetID)
+	}
+	if k.overrides == nil {
+		k.overrides = make(map[string]map[string]string)
+	}
+	if k.overrides[scope] == nil {
+		k.overrides[scope] = make(map[string]string)
+	}
+	k.overrides[scope][name] = targetID
+	fmt.Printf("[Kernel] Resolution override: %s -> %s for %s\n", name, targetID, overrideScope(scope))
+	k.emit(EventOverrideAdded, targetID, "", fmt.Sprintf("%s -> %s for %s", name, targetID, overrideScope(scope)))
+	return nil
+}
+
+// RemoveResolutionOverride drops the override of name in scope and reports
+// whether there was one.
+func (k *Kernel) RemoveResolutionOverride(scope, name string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	target, ok := k.overrides[scope][name]
+	if !ok {
+		return false
+	}
+	delete(k.overrides[scope], name)
+	if len(k.overrides[scope]) == 0 {
+		delete(k.overrides, scope)
+	}
+	k.emit(EventOverrideRemoved, target, "", fmt.Sprintf("%s for %s", name, overrideScope(scope)))
+	return true
+}
+
+// ResolutionOverrides lists the overrides in place, ordered by scope and
+// name.
+func (k *Kernel) ResolutionOverrides() []ResolutionOverride {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var out []ResolutionOverride
+	for scope, names := range k.overrides {
+		for name, target := range names {
+			out = append(out, ResolutionOverride{Scope: scope, Name: name, Target: target})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// overrideLocked looks up the override of toID for a sender.
+func (k *Kernel) overrideLocked(fromID, toID string) (string, bool) {
+	if target, ok := k.overrides[fromID][toID]; ok {
+		return target, true
+	}
+	target, ok := k.overrides[""][toID]
+	return target, ok
+}
+
+func overrideScope(scope string) string {
+	if scope == "" {
+		return "all senders"
+	}
+	return scope
+}
+
+// rendezvous returns the backend with the highest hash weight for key, so a
+// key only moves when its chosen backend goes away.
+func rendezvous(key string, backends []string) string {
+	var best string
+	var bestScore uint64
+	for _, b := range backends {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(b))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+// stickyTable is a bounded LRU map of session key to backend.
+type stickyTable struct {
+	max   int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type stickyEntry struct {
+	key, backend string
+}
+
+func newStickyTable(max int) *stickyTable {
+	if max < 1 {
+		max = 1
+	}
+	return &stickyTable{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (t *stickyTable) get(key string) (string, bool) {
+	el, ok := t.items[key]
+	if !ok {
+		return "", false
+	}
+	t.order.MoveToFront(el)
+	return el.Value.(*stickyEntry).backend, true
+}
+
+func (t *stickyTable) put(key, backend string) {
+	if el, ok := t.items[key]; ok {
+		el.Value.(*stickyEntry).backend = backend
+		t.order.MoveToFront(el)
+		return
+	}
+	t.items[key] = t.order.PushFront(&stickyEntry{key: key, backend: backend})
+	for t.order.Len() > t.max {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.items, oldest.Value.(*stickyEntry).key)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
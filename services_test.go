@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newService registers containers ids as backends of service name.
+func newService(t *testing.T, k *Kernel, name string, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		newTestContainer(t, k, id)
+	}
+	if err := k.RegisterService(name, ids...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustResolve(t *testing.T, k *Kernel, name, key string) string {
+	t.Helper()
+	id, err := k.ResolveService(name, key)
+	if err != nil {
+		t.Fatalf("ResolveService(%s, %q): %v", name, key, err)
+	}
+	return id
+}
+
+func TestServiceRoundRobin(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "db", "db1", "db2", "db3")
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, mustResolve(t, k, "db", "session"))
+	}
+	if fmt.Sprint(got) != "[db1 db2 db3 db1]" {
+		t.Errorf("non-sticky sends went to %v, want round-robin", got)
+	}
+}
+
+func TestStickySessionsHitSameBackend(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "db", "db1", "db2", "db3")
+	if err := k.EnableStickySessions("db", 100); err != nil {
+		t.Fatal(err)
+	}
+	used := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("user", i)
+		first := mustResolve(t, k, "db", key)
+		used[first] = true
+		for j := 0; j < 5; j++ {
+			if got := mustResolve(t, k, "db", key); got != first {
+				t.Fatalf("key %s moved from %s to %s", key, first, got)
+			}
+		}
+	}
+	if len(used) < 2 {
+		t.Errorf("20 keys all hashed to %v", used)
+	}
+
+	// Sends without a key are sticky to the sending container.
+	newTestContainer(t, k, "web")
+	if err := k.SendMessage("web", "svc:db", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	want := mustResolve(t, k, "db", "web")
+	if got := k.Containers[want].Inspect().InboxDepth; got != 1 {
+		t.Errorf("backend %s has %d messages, want the send from web", want, got)
+	}
+}
+
+func TestStickySessionReassignedOnBackendRemoval(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "db", "db1", "db2", "db3")
+	if err := k.EnableStickySessions("db", 100); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventSessionReassigned}})
+	defer cancel()
+
+	keys := map[string]string{}
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprint("user", i)
+		keys[key] = mustResolve(t, k, "db", key)
+	}
+	victim := keys["user0"]
+	if err := k.RemoveContainer(victim); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := mustResolve(t, k, "db", "user0")
+	if moved == victim {
+		t.Fatalf("user0 still routed to removed backend %s", victim)
+	}
+	e := nextEvent(t, events)
+	if e.ContainerID != moved || e.Detail != fmt.Sprintf("service db session user0 moved from %s", victim) {
+		t.Errorf("event = %+v", e)
+	}
+	for key, backend := range keys {
+		if backend == victim {
+			continue
+		}
+		if got := mustResolve(t, k, "db", key); got != backend {
+			t.Errorf("%s moved from %s to %s though its backend stayed", key, backend, got)
+		}
+	}
+
+	k.DeregisterService("db", moved)
+	if got := mustResolve(t, k, "db", "user0"); got == moved || got == victim {
+		t.Errorf("user0 routed to %s after deregistration", got)
+	}
+}
+
+func TestStickySessionsEvictLeastRecentlyUsed(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "db", "db1", "db2")
+	if err := k.EnableStickySessions("db", 2); err != nil {
+		t.Fatal(err)
+	}
+	mustResolve(t, k, "db", "a")
+	mustResolve(t, k, "db", "b")
+	mustResolve(t, k, "db", "a") // b is now least recently used
+	mustResolve(t, k, "db", "c")
+
+	table := k.services["db"].sticky
+	if len(table.items) != 2 {
+		t.Fatalf("%d sessions kept, want the bound of 2", len(table.items))
+	}
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := table.items[key]; ok != want {
+			t.Errorf("session %s kept = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestResolveServiceErrors(t *testing.T) {
+	k, _ := newTestKernel(t)
+	if _, err := k.ResolveService("nope", ""); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("unknown service: %v", err)
+	}
+	newService(t, k, "db", "db1")
+	k.Containers["db1"].StopProcesses()
+	if _, err := k.ResolveService("db", ""); !errors.Is(err, ErrNoReadyBackend) {
+		t.Errorf("stopped backend: %v", err)
+	}
+}
+
+func TestResolutionOverrideRedirectsScopedSender(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newService(t, k, "database", "db1")
+	for _, id := range []string{"mock", "app", "other"} {
+		newTestContainer(t, k, id).AddProcess(&Process{Name: "listener"})
+	}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventOverrideAdded, EventOverrideRemoved}})
+	defer cancel()
+	if err := k.SetResolutionOverride("app", "svc:database", "mock"); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextEvent(t, events); e.Kind != EventOverrideAdded || e.ContainerID != "mock" || e.Detail != "svc:database -> mock for app" {
+		t.Errorf("added event = %+v", e)
+	}
+	if got := k.ResolutionOverrides(); fmt.Sprint(got) != "[{app svc:database mock}]" {
+		t.Errorf("ResolutionOverrides = %v", got)
+	}
+
+	depth := func(id string) int { return k.Containers[id].Inspect().InboxDepth }
+	for _, from := range []string{"app", "other"} {
+		if err := k.SendMessage(from, "svc:database", "query"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if depth("mock") != 1 || depth("db1") != 1 {
+		t.Errorf("mock has %d messages and db1 %d, want one each", depth("mock"), depth("db1"))
+	}
+
+	if !k.RemoveResolutionOverride("app", "svc:database") {
+		t.Fatal("override not found for removal")
+	}
+	if e := nextEvent(t, events); e.Kind != EventOverrideRemoved || e.Detail != "svc:database for app" {
+		t.Errorf("removed event = %+v", e)
+	}
+	if err := k.SendMessage("app", "svc:database", "query"); err != nil {
+		t.Fatal(err)
+	}
+	if depth("mock") != 1 || depth("db1") != 2 {
+		t.Errorf("after removal mock has %d messages and db1 %d, want routing restored", depth("mock"), depth("db1"))
+	}
+	if k.RemoveResolutionOverride("app", "svc:database") {
+		t.Error("removed the override twice")
+	}
+}
+
+func TestResolutionOverrideScopes(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, id := range []string{"real", "everyone", "mine", "app", "other"} {
+		newTestContainer(t, k, id).AddProcess(&Process{Name: "listener"})
+	}
+	if err := k.SetResolutionOverride("", "real", "everyone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetResolutionOverride("app", "real", "mine"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetResolutionOverride("", "real", "missing"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("override to a missing target: %v", err)
+	}
+	for from, want := range map[string]string{"app": "mine", "other": "everyone"} {
+		if err := k.SendMessage(from, "real", "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if got := k.Containers[want].Inspect().InboxDepth; got != 1 {
+			t.Errorf("message from %s: %s holds %d messages, want 1", from, want, got)
+		}
+	}
+	if got := k.Containers["real"].Inspect().InboxDepth; got != 0 {
+		t.Errorf("real received %d messages through its overrides", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// --- Snapshots ---
+
+// ProcessSnapshot is the recorded state of one process.
+type ProcessSnapshot struct {
+	PID   int    `json:"pid"`
+	State string `json:"state"`
+	ProcessSpec
+}
+
+// ContainerSnapshot is the recorded state of one container: its definition,
+// its state and its processes in the order they were added.
+type ContainerSnapshot struct {
+	ContainerSpec
+	State     string            `json:"state"`
+	CPULoad   float64           `json:"cpu_load"`
+	Processes []ProcessSnapshot `json:"processes,omitempty"`
+
+	Outcomes       OutcomeCounts   `json:"outcomes"`
+	LongestRunning *LongestRunning `json:"longest_running,omitempty"`
+}
+
+// KernelSnapshot is a point-in-time copy of every container, keyed by ID.
+type KernelSnapshot struct {
+	Taken      time.Time                    `json:"taken"`
+	Containers map[string]ContainerSnapshot `json:"containers"`
+}
+
+// SnapshotDelta holds what changed between two snapshots: containers that
+// were added or changed in any way, in full, and the IDs of containers
+// that were removed.
+type SnapshotDelta struct {
+	Since   time.Time                    `json:"since"`
+	Taken   time.Time                    `json:"taken"`
+	Changed map[string]ContainerSnapshot `json:"changed,omitempty"`
+	Removed []string                     `json:"removed,omitempty"`
+}
+
+// Empty reports whether the delta changes nothing but the snapshot time.
+func (d SnapshotDelta) Empty() bool {
+	return len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Snapshot captures the definition and state of every container and
+// process.
+func (k *Kernel) Snapshot() KernelSnapshot {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s := KernelSnapshot{Taken: k.Clock.Now(), Containers: make(map[string]ContainerSnapshot, len(k.Containers))}
+	for id, c := range k.Containers {
+		c.mu.Lock()
+		s.Containers[id] = c.snapshotLocked()
+		c.mu.Unlock()
+	}
+	return s
+}
+
+func (c *Container) snapshotLocked() ContainerSnapshot {
+	cs := ContainerSnapshot{
+		ContainerSpec: ContainerSpec{
+			ID:        c.ID,
+			Name:      c.Name,
+			MemoryMB:  c.MemoryMB,
+			Labels:    copyStringMap(c.Labels),
+			Env:       copyStringMap(c.Env),
+			Volumes:   append([]string(nil), c.Volumes...),
+			DependsOn: append([]string(nil), c.DependsOn...),
+
+			StopPriority: c.StopPriority,
+			BootPhase:    c.BootPhase,
+		},
+		State:          c.State.String(),
+		CPULoad:        c.CPULoad,
+		Outcomes:       c.outcomes,
+		LongestRunning: c.longestRunningLocked(),
+	}
+	for _, p := range c.Processes {
+		cs.Processes = append(cs.Processes, ProcessSnapshot{
+			PID:   p.PID,
+			State: p.State.String(),
+			ProcessSpec: ProcessSpec{
+				Name:     p.Name,
+				Kind:     p.kindRef(),
+				Priority: p.Priority,
+				Params:   copyStringMap(p.Params),
+			},
+		})
+	}
+	return cs
+}
+
+// SnapshotDelta takes a snapshot and returns only what differs from since.
+// Applying the delta to since with ApplyDelta yields the new snapshot.
+func (k *Kernel) SnapshotDelta(since KernelSnapshot) SnapshotDelta {
+	return DiffSnapshots(since, k.Snapshot())
+}
+
+// DiffSnapshots returns the delta that turns snapshot from into snapshot to.
+func DiffSnapshots(from, to KernelSnapshot) SnapshotDelta {
+	d := SnapshotDelta{Since: from.Taken, Taken: to.Taken}
+	for id, cs := range to.Containers {
+		if old, ok := from.Containers[id]; ok && reflect.DeepEqual(old, cs) {
+			continue
+		}
+		if d.Changed == nil {
+			d.Changed = make(map[string]ContainerSnapshot)
+		}
+		d.Changed[id] = cs
+	}
+	for id := range from.Containers {
+		if _, ok := to.Containers[id]; !ok {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	sort.Strings(d.Removed)
+	return d
+}
+
+// ApplyDelta returns s updated by d. s itself is left unchanged; the
+// result shares container snapshots with s and d, which are not modified
+// afterwards.
+func (s KernelSnapshot) ApplyDelta(d SnapshotDelta) KernelSnapshot {
+	out := KernelSnapshot{Taken: d.Taken, Containers: make(map[string]ContainerSnapshot, len(s.Containers)+len(d.Changed))}
+	for id, cs := range s.Containers {
+		out.Containers[id] = cs
+	}
+	for _, id := range d.Removed {
+		delete(out.Containers, id)
+	}
+	for id, cs := range d.Changed {
+		out.Containers[id] = cs
+	}
+	return out
+}
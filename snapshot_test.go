@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotDeltaRebuildsFullSnapshot(t *testing.T) {
+	k, clk := newTestKernel(t)
+	for _, id := range []string{"api", "cache", "worker"} {
+		newTestContainer(t, k, id)
+	}
+	old := k.Snapshot()
+
+	clk.Advance(time.Minute)
+	k.Containers["api"].SetLabels(map[string]string{"tier": "web"})
+	p := &Process{Name: "job", Action: noop}
+	k.Containers["worker"].AddProcess(p)
+	if err := k.Containers["worker"].StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if err := k.RemoveContainer("cache"); err != nil {
+		t.Fatal(err)
+	}
+	newTestContainer(t, k, "db")
+
+	d := k.SnapshotDelta(old)
+	fresh := k.Snapshot()
+	var changed []string
+	for _, id := range []string{"api", "cache", "db", "worker"} {
+		if _, ok := d.Changed[id]; ok {
+			changed = append(changed, id)
+		}
+	}
+	if fmt.Sprint(changed) != "[api db worker]" || fmt.Sprint(d.Removed) != "[cache]" {
+		t.Errorf("delta changed %v and removed %v, want [api db worker] and [cache]", changed, d.Removed)
+	}
+	if !d.Since.Equal(old.Taken) || !d.Taken.Equal(fresh.Taken) {
+		t.Errorf("delta spans %v to %v", d.Since, d.Taken)
+	}
+	if got := old.ApplyDelta(d); !reflect.DeepEqual(got, fresh) {
+		t.Errorf("old + delta =\n%+v\nwant\n%+v", got, fresh)
+	}
+	if _, ok := old.Containers["cache"]; !ok {
+		t.Error("ApplyDelta modified the old snapshot")
+	}
+
+	if d := k.SnapshotDelta(fresh); !d.Empty() {
+		t.Errorf("delta with nothing changed = %+v", d)
+	}
+}
+
+func TestSetCPULoadShowsInSnapshot(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	for _, tc := range []struct{ set, want float64 }{
+		{42.5, 42.5},
+		{-3, 0},
+		{250, 100},
+	} {
+		c.SetCPULoad(tc.set)
+		if got := k.Snapshot().Containers["web"].CPULoad; got != tc.want {
+			t.Errorf("SetCPULoad(%v): snapshot load %v, want %v", tc.set, got, tc.want)
+		}
+		clk.Advance(time.Second)
+	}
+	if got := c.Peaks().CPU; got != (Peak{Value: 100, At: testEpoch.Add(2 * time.Second)}) {
+		t.Errorf("CPU peak = %+v, want the clamped 100 at 2s", got)
+	}
+}
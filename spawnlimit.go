@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// --- Spawn Limits ---
+
+// ErrSpawnLimitExceeded is returned by Handle.Spawn when the kernel's
+// SpawnRate or MaxTotalProcesses would be exceeded, and by
+// RestoreContainer when MaxTotalProcesses would be.
+var ErrSpawnLimitExceeded = errors.New("spawn limit exceeded")
+
+// spawnBucket is a token bucket refilled at Kernel.SpawnRate per second,
+// holding at most one second's worth of spawns.
+type spawnBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends one token if one is available at now.
+func (b *spawnBucket) take(rate float64, now time.Time) bool {
+	burst := math.Max(rate, 1)
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// liveProcessesLocked counts the processes that have not finished, across
+// all containers.
+func (k *Kernel) liveProcessesLocked() int {
+	n := 0
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		for _, p := range c.Processes {
+			switch p.State {
+			case Pending, Throttled, Running:
+				n++
+			}
+		}
+		c.mu.Unlock()
+	}
+	return n
+}
+
+// admitSpawnLocked checks a Spawn against MaxTotalProcesses and SpawnRate.
+func (k *Kernel) admitSpawnLocked() error {
+	if k.MaxTotalProcesses > 0 {
+		if n := k.liveProcessesLocked(); n >= k.MaxTotalProcesses {
+			return fmt.Errorf("%w: %d processes, limit %d", ErrSpawnLimitExceeded, n, k.MaxTotalProcesses)
+		}
+	}
+	if k.SpawnRate > 0 && !k.spawns.take(k.SpawnRate, k.Clock.Now()) {
+		return fmt.Errorf("%w: more than %g spawns per second", ErrSpawnLimitExceeded, k.SpawnRate)
+	}
+	return nil
+}
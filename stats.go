@@ -0,0 +1,115 @@
+package main
+
+// --- Kernel Stats ---
+
+// KernelStats is a point-in-time summary of the kernel.
+type KernelStats struct {
+	Containers int `json:"containers"`
+	Processes  int `json:"processes"`
+	Running    int `json:"running"`
+	Stopped    int `json:"stopped"`
+	Completed  int `json:"completed"`
+	Throttled  int `json:"throttled"`
+	Failed     int `json:"failed"`
+	Killed     int `json:"killed"`
+	Pending    int `json:"pending"`
+	MemoryMB   int `json:"memory_mb"`
+	Messages   int `json:"messages"`
+
+	// MailboxBytes is the payload bytes waiting in inboxes, summed over
+	// all containers.
+	MailboxBytes int `json:"mailbox_bytes"`
+
+	// LeakedGoroutines counts action goroutines abandoned by Drain.
+	LeakedGoroutines int64 `json:"leaked_goroutines"`
+
+	// OpenHandles counts handles currently held through Handle.Open;
+	// LeakedHandles counts handles the kernel had to close after their
+	// action returned.
+	OpenHandles   int   `json:"open_handles"`
+	LeakedHandles int64 `json:"leaked_handles"`
+
+	// TimeAcceleration is the kernel clock's acceleration factor: 1 for
+	// the wall clock and 0 for clocks without a rate, such as FakeClock.
+	TimeAcceleration float64 `json:"time_acceleration"`
+
+	// Groups reports concurrency group occupancy by group name.
+	Groups map[string]GroupStats `json:"groups,omitempty"`
+
+	// WarmPools reports warm pool occupancy and claim latency by pool
+	// name.
+	WarmPools map[string]WarmPoolStats `json:"warm_pools,omitempty"`
+
+	// Circuits reports circuit breaker state by Request target.
+	Circuits map[string]CircuitStats `json:"circuits,omitempty"`
+
+	// StopSteps counts the processes stopped by escalating stops, by the
+	// step they stopped at: "signal", "cancel" or "kill".
+	StopSteps map[string]int64 `json:"stop_steps,omitempty"`
+
+	// RequestCaches reports request cache hits and misses by container
+	// ID, for containers with a RequestCache.
+	RequestCaches map[string]RequestCacheStats `json:"request_caches,omitempty"`
+
+	// Concurrency reports the tuned limit by container ID, for containers
+	// with adaptive concurrency.
+	Concurrency map[string]ConcurrencyStats `json:"concurrency,omitempty"`
+
+	// Peaks are the kernel-wide high-water marks, see Kernel.ResetPeaks.
+	Peaks Peaks `json:"peaks"`
+
+	// Profiles is nil unless profiling is enabled via SetProfiling.
+	Profiles []ExecutionProfile `json:"profiles,omitempty"`
+
+	// Internals is nil unless instrumentation is enabled via
+	// SetInstrumentation.
+	Internals *InternalStats `json:"internals,omitempty"`
+}
+
+// Stats returns a snapshot of kernel-wide counters.
+func (k *Kernel) Stats() KernelStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var s KernelStats
+	s.Containers = len(k.Containers)
+	for _, c := range k.Containers {
+		c.mu.Lock()
+		s.MemoryMB += c.MemoryMB
+		s.OpenHandles += c.openHandles
+		s.MailboxBytes += c.inboxBytes
+		for _, p := range c.Processes {
+			s.Processes++
+			switch p.State {
+			case Running:
+				s.Running++
+			case Stopped:
+				s.Stopped++
+			case Completed:
+				s.Completed++
+			case Throttled:
+				s.Throttled++
+			case Failed:
+				s.Failed++
+			case Killed:
+				s.Killed++
+			case Pending:
+				s.Pending++
+			}
+		}
+		c.mu.Unlock()
+	}
+	s.Messages = int(k.messagesSent.Load())
+	s.TimeAcceleration = k.TimeAcceleration()
+	s.LeakedGoroutines = k.LeakedGoroutines()
+	s.LeakedHandles = k.leakedHandles.Load()
+	s.Groups = k.groupStatsLocked()
+	s.WarmPools = k.warmPoolStatsLocked()
+	s.Circuits = k.circuitStatsLocked()
+	s.StopSteps = k.stopStepStats()
+	s.RequestCaches = k.requestCacheStatsLocked()
+	s.Concurrency = k.concurrencyStatsLocked()
+	s.Peaks = k.peaks.snapshot()
+	s.Profiles = k.Profiles()
+	s.Internals = k.internalStatsLocked()
+	return s
+}
@@ -0,0 +1,39 @@
+package main
+
+import "errors"
+
+// --- Strict Mode ---
+
+// Errors returned in place of a silent no-op while Kernel.StrictMode is set.
+var (
+	// ErrNothingToStop: StopProcesses on a container with no queued or
+	// running process.
+	ErrNothingToStop = errors.New("no process to stop")
+	// ErrNoPendingProcesses: StartProcesses on a container with no queued
+	// process.
+	ErrNoPendingProcesses = errors.New("no pending process to start")
+	// ErrNoContainers: Monitor on a kernel without containers.
+	ErrNoContainers = errors.New("no containers")
+	// ErrNoReceiver: a message to a container with no queued or running
+	// process, which nothing would read.
+	ErrNoReceiver = errors.New("no process to receive the message")
+)
+
+// strict reports whether k is in strict mode; detached containers never
+// are.
+func (k *Kernel) strict() bool {
+	return k != nil && k.StrictMode
+}
+
+// hasProcessInLocked reports whether any of the container's processes is in
+// one of states.
+func (c *Container) hasProcessInLocked(states ...ProcessState) bool {
+	for _, p := range c.Processes {
+		for _, s := range states {
+			if p.State == s {
+				return true
+			}
+		}
+	}
+	return false
+}
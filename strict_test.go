@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStrictModeRejectsNoOps(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		k, _ := newTestKernel(t)
+		k.StrictMode = strict
+		check := func(op string, err, sentinel error) {
+			t.Helper()
+			if !strict {
+				sentinel = nil
+			}
+			if (sentinel == nil && err != nil) || !errors.Is(err, sentinel) {
+				t.Errorf("strict=%v %s: err = %v, want %v", strict, op, err, sentinel)
+			}
+		}
+
+		check("Monitor", k.MonitorTo(io.Discard, 0, 1), ErrNoContainers)
+		empty := newTestContainer(t, k, "empty")
+		check("StartProcesses", empty.StartProcesses(), ErrNoPendingProcesses)
+		check("StopProcesses", empty.StopProcesses(), ErrNothingToStop)
+		newTestContainer(t, k, "sender")
+		check("SendMessage", k.SendMessage("sender", "empty", "hello"), ErrNoReceiver)
+	}
+}
+
+func TestStrictModeAllowsRealWork(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.StrictMode = true
+	c := newTestContainer(t, k, "jobs")
+	p := &Process{Name: "forever", Action: blockUntil(nil)}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	newTestContainer(t, k, "client")
+	if err := k.SendMessage("client", "jobs", "hello"); err != nil {
+		t.Errorf("SendMessage to a running process: %v", err)
+	}
+	if err := k.MonitorTo(io.Discard, 0, 1); err != nil {
+		t.Errorf("Monitor: %v", err)
+	}
+	if err := c.StopProcesses(); err != nil {
+		t.Errorf("StopProcesses: %v", err)
+	}
+	waitDone(t, p)
+}
+
+func TestStrictStartAllSkipsEmptyContainers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.StrictMode = true
+	newTestContainer(t, k, "config")
+	app := newTestContainer(t, k, "app")
+	app.BootPhase = 1
+	p := &Process{Name: "serve", Action: blockUntil(nil)}
+	app.AddProcess(p)
+	if err := k.StartAll(); err != nil {
+		t.Fatalf("StartAll halted on a container with nothing queued: %v", err)
+	}
+	defer app.StopProcesses()
+	eventually(t, "the later phase to start", func() bool { return stateOf(app, p) == Running })
+}
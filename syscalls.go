@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// --- Syscalls ---
+
+// syscallLogSize bounds the kernel's syscall log; the oldest records are
+// dropped first.
+const syscallLogSize = 4096
+
+// SyscallRecord is one syscall made by a process through its Handle.
+type SyscallRecord struct {
+	Seq       uint64
+	Time      time.Time
+	Container string
+	PID       int
+	Process   string
+	Call      string
+	Args      string
+	Err       string // set when the syscall failed or was denied
+}
+
+type syscallLog struct {
+	mu      sync.Mutex
+	seq     uint64
+	records []SyscallRecord
+}
+
+func (l *syscallLog) add(r SyscallRecord) SyscallRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	r.Seq = l.seq
+	l.records = append(l.records, r)
+	if len(l.records) > syscallLogSize {
+		l.records = l.records[len(l.records)-syscallLogSize:]
+	}
+	return r
+}
+
+// SyscallLog returns the recorded syscalls, oldest first.
+func (k *Kernel) SyscallLog() []SyscallRecord {
+	k.syscalls.mu.Lock()
+	defer k.syscalls.mu.Unlock()
+	return append([]SyscallRecord(nil), k.syscalls.records...)
+}
+
+// ExitError is returned by Handle.Exit for a non-zero exit code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// syscall runs the kernel's SyscallFilter, executes do unless the filter
+// refused, and records the outcome.
+func (h *Handle) syscall(call, args string, do func() error) error {
+	c, p := h.container, h.proc
+	r := SyscallRecord{Time: c.now(), Container: c.ID, PID: p.PID, Process: p.Name, Call: call, Args: args}
+	k := c.kernel
+	var err error
+	if k != nil && k.SyscallFilter != nil {
+		err = k.SyscallFilter(r)
+	}
+	if err == nil && do != nil {
+		err = do()
+	}
+	if err != nil {
+		r.Err = err.Error()
+	}
+	if k != nil {
+		k.syscalls.add(r)
+	}
+	return err
+}
+
+// context returns the process's context, which is cancelled when it is
+// stopped or killed.
+func (h *Handle) context() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+// GetPID returns the process's PID.
+func (h *Handle) GetPID() int {
+	h.syscall("getpid", "", nil)
+	return h.proc.PID
+}
+
+// Sleep waits d on the kernel clock. It returns early with the context error
+// when the process is stopped.
+func (h *Handle) Sleep(d time.Duration) error {
+	return h.syscall("sleep", d.String(), func() error {
+		select {
+		case <-h.after(d):
+			return nil
+		case <-h.context().Done():
+			return h.context().Err()
+		}
+	})
+}
+
+// Spawn adds p to the caller's container and returns its PID. It fails
+// with ErrSpawnLimitExceeded beyond the kernel's SpawnRate or
+// MaxTotalProcesses.
+func (h *Handle) Spawn(p *Process) (int, error) {
+	err := h.syscall("spawn", p.Name, func() error {
+		if err := h.checkCapability(CapSpawnProcesses, "spawn"); err != nil {
+			return err
+		}
+		c := h.container
+		if err := c.checkAuthoritative(); err != nil {
+			return err
+		}
+		k := c.kernel
+		if k == nil {
+			c.AddProcess(p)
+			return nil
+		}
+		// The limits are checked and the process added under the kernel
+		// lock, so concurrent spawns cannot overshoot them.
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if err := k.admitSpawnLocked(); err != nil {
+			fmt.Printf("[Kernel] Refusing to spawn %s in %s: %v\n", p.Name, c.Name, err)
+			return err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.addProcessLocked(p)
+		c.scheduleLocked()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return p.PID, nil
+}
+
+// Send sends msg from the caller's container to toID, which may be a
+// container ID or a "svc:" service name.
+func (h *Handle) Send(toID, msg string) error {
+	return h.syscall("send", toID, func() error {
+		if err := h.checkCapability(CapSendMessages, "send"); err != nil {
+			return err
+		}
+		k := h.container.kernel
+		if k == nil {
+			return fmt.Errorf("%w: container %s is not attached to a kernel", ErrContainerNotFound, h.container.ID)
+		}
+		return k.sendMessage(h.traced(Message{From: h.container.ID, To: toID, Payload: msg}), "")
+	})
+}
+
+// SendAfter schedules msg for toID once delay has passed on the
+// container's clock; see Kernel.SendAfter and Container.SetClockSkew.
+func (h *Handle) SendAfter(toID, msg string, delay time.Duration) (*ScheduledMessage, error) {
+	var sm *ScheduledMessage
+	err := h.syscall("sendafter", toID, func() error {
+		if err := h.checkCapability(CapSendMessages, "sendafter"); err != nil {
+			return err
+		}
+		k := h.container.kernel
+		if k == nil {
+			return fmt.Errorf("%w: container %s is not attached to a kernel", ErrContainerNotFound, h.container.ID)
+		}
+		var err error
+		sm, err = k.sendAfter(h.traced(Message{From: h.container.ID, To: toID, Payload: msg}), h.container.kernelDuration(delay))
+		return err
+	})
+	return sm, err
+}
+
+// Broadcast sends msg to every other container and returns how many copies
+// were delivered.
+func (h *Handle) Broadcast(msg string) (int, error) {
+	n := 0
+	err := h.syscall("broadcast", "", func() error {
+		if err := h.checkCapability(CapSendMessages, "broadcast"); err != nil {
+			return err
+		}
+		k := h.container.kernel
+		if k == nil {
+			return fmt.Errorf("%w: container %s is not attached to a kernel", ErrContainerNotFound, h.container.ID)
+		}
+		var err error
+		n, err = k.broadcast(h.traced(Message{From: h.container.ID, Payload: msg}))
+		return err
+	})
+	return n, err
+}
+
+// Recv takes the oldest message from the caller's container inbox, waiting
+// for one to arrive if it is empty. It returns the context error when the
+// process is stopped first.
+func (h *Handle) Recv() (Message, error) {
+	var m Message
+	err := h.syscall("recv", "", func() error {
+		c := h.container
+		if h.proc.debug {
+			return ErrDebugReadOnly
+		}
+		for {
+			c.mu.Lock()
+			if len(c.inbox) > 0 {
+				m = c.inbox[0]
+				c.inbox = c.inbox[1:]
+				c.inboxBytes -= len(m.Payload)
+				c.observeLoadLocked()
+				if m.RequestID != 0 {
+					c.inheritPriorityLocked(h.proc, m)
+				}
+				h.handlingLocked(m)
+				c.mu.Unlock()
+				return nil
+			}
+			if c.inboxReady == nil {
+				c.inboxReady = make(chan struct{})
+			}
+			ready := c.inboxReady
+			c.mu.Unlock()
+			select {
+			case <-ready:
+			case <-h.context().Done():
+				return h.context().Err()
+			}
+		}
+	})
+	return m, err
+}
+
+// Exit ends the action with code; the action should return its result.
+// Zero means success; other codes fail the process with an *ExitError and
+// are recorded in Process.ExitCode.
+func (h *Handle) Exit(code int) error {
+	h.syscall("exit", fmt.Sprint(code), nil)
+	if code == 0 {
+		return nil
+	}
+	return &ExitError{Code: code}
+}
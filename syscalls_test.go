@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSyscallsRecordedInOrder(t *testing.T) {
+	k, clk := newTestKernel(t)
+	peer := newTestContainer(t, k, "peer")
+	peer.AddProcess(&Process{Name: "listener"})
+	c := newTestContainer(t, k, "app")
+	var pid int
+	var got Message
+	p := &Process{Name: "init", Action: func(ctx context.Context, h *Handle) error {
+		pid = h.GetPID()
+		if _, err := h.Spawn(&Process{Name: "child", Action: noop}); err != nil {
+			return err
+		}
+		if err := h.Send("peer", "hello"); err != nil {
+			return err
+		}
+		if err := h.Sleep(time.Second); err != nil {
+			return err
+		}
+		var err error
+		if got, err = h.Recv(); err != nil {
+			return err
+		}
+		return h.Exit(3)
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitForWaiters(t, clk, 1)
+	clk.Advance(time.Second)
+	if err := k.SendMessage("peer", "app", "ping"); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+
+	var exit *ExitError
+	c.mu.Lock()
+	err, code := p.Err, p.ExitCode
+	c.mu.Unlock()
+	if !errors.As(err, &exit) || exit.Code != 3 || code != 3 {
+		t.Errorf("err = %v, exit code %d, want exit status 3", err, code)
+	}
+	if pid != p.PID || got.Payload != "ping" {
+		t.Errorf("GetPID = %d (PID %d), Recv = %q", pid, p.PID, got.Payload)
+	}
+
+	var calls []string
+	for i, r := range k.SyscallLog() {
+		if r.Seq != uint64(i+1) || r.Container != "app" || r.PID != p.PID || r.Process != "init" || r.Err != "" {
+			t.Errorf("record %d = %+v", i, r)
+		}
+		calls = append(calls, r.Call+"("+r.Args+")")
+	}
+	if want := "[getpid() spawn(child) send(peer) sleep(1s) recv() exit(3)]"; fmt.Sprint(calls) != want {
+		t.Errorf("syscalls = %v, want %s", calls, want)
+	}
+}
+
+func TestSyscallFilterDenies(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.SyscallFilter = func(r SyscallRecord) error {
+		if r.Call == "spawn" {
+			return errors.New("spawn not allowed")
+		}
+		return nil
+	}
+	c := newTestContainer(t, k, "app")
+	var spawnErr error
+	p := &Process{Name: "init", Action: func(ctx context.Context, h *Handle) error {
+		_, spawnErr = h.Spawn(&Process{Name: "child", Action: noop})
+		return nil
+	}}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, p)
+	if spawnErr == nil || len(c.Inspect().Processes) != 1 {
+		t.Fatalf("spawn err = %v with the filter refusing it", spawnErr)
+	}
+	if log := k.SyscallLog(); len(log) != 1 || log[0].Err != "spawn not allowed" {
+		t.Errorf("log = %+v", log)
+	}
+}
+
+// forkBomb returns a process that spawns children running child until a
+// spawn fails, reporting how many it managed and the error that stopped it.
+func forkBomb(child ActionFunc, spawned *int, stop *error) *Process {
+	return &Process{Name: "bomb", Action: func(ctx context.Context, h *Handle) error {
+		for i := 0; i < 1000; i++ {
+			if _, err := h.Spawn(&Process{Name: "child", Action: child}); err != nil {
+				*stop = err
+				return nil
+			}
+			*spawned++
+		}
+		return nil
+	}}
+}
+
+func TestSpawnRateThrottlesForkBomb(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.SpawnRate = 5
+	c := newTestContainer(t, k, "jobs")
+	if err := c.StartProcesses(); err != nil && !errors.Is(err, ErrNoPendingProcesses) {
+		t.Fatal(err)
+	}
+	for round := 0; round < 2; round++ {
+		var spawned int
+		var stop error
+		bomb := forkBomb(noop, &spawned, &stop)
+		c.AddProcess(bomb)
+		waitDone(t, bomb)
+		if spawned != 5 || !errors.Is(stop, ErrSpawnLimitExceeded) {
+			t.Errorf("round %d: spawned %d before %v, want 5 then ErrSpawnLimitExceeded", round, spawned, stop)
+		}
+		clk.Advance(time.Second) // refills the bucket
+	}
+}
+
+func TestMaxTotalProcessesCapsForkBomb(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.MaxTotalProcesses = 10
+	c := newTestContainer(t, k, "jobs")
+	var spawned int
+	var stop error
+	bomb := forkBomb(blockUntil(nil), &spawned, &stop)
+	c.AddProcess(bomb)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	waitDone(t, bomb)
+	// The bomb itself counts towards the limit while it runs.
+	if spawned != 9 || !errors.Is(stop, ErrSpawnLimitExceeded) {
+		t.Errorf("spawned %d before %v, want 9 then ErrSpawnLimitExceeded", spawned, stop)
+	}
+	if got := k.Stats().Running; got != 9 {
+		t.Errorf("%d processes running, want the 9 children", got)
+	}
+}
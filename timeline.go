@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Container Timeline ---
+
+// timelineLimit bounds the events kept per container; the oldest are
+// dropped first.
+const timelineLimit = 1024
+
+// Timeline categories.
+const (
+	TimelineLifecycle = "lifecycle"
+	TimelineProcess   = "process"
+	TimelineMessage   = "message"
+	TimelineAlert     = "alert"
+	TimelinePeak      = "peak"
+	TimelineTruncated = "truncated" // marks entries left out by Limit
+)
+
+// timelineCategory sorts event kinds into timeline categories; kinds not
+// listed are alerts.
+func timelineCategory(kind EventKind) string {
+	switch kind {
+	case EventContainerCreated, EventContainerStarted, EventContainerStopped, EventContainerRemoved,
+		EventContainerRestored, EventContainerPurged, EventContainerRenamed,
+		EventMaintenanceStart, EventMaintenanceEnd, EventMaintenanceSkip, EventLabelsChanged, EventQuarantined, EventUnquarantined,
+		EventFrozen, EventUnfrozen, EventJobFinished, EventLimitAdjusted, EventMemoryLimitSet:
+		return TimelineLifecycle
+	case EventProcessAdded, EventProcessWaiting, EventProcessStarted, EventProcessCompleted,
+		EventProcessThrottled, EventProcessFailed, EventProcessKilled, EventForceKilled:
+		return TimelineProcess
+	case EventMessageSent, EventDeadLetter, EventReplayCaughtUp:
+		return TimelineMessage
+	}
+	return TimelineAlert
+}
+
+// TimelineEntry is one moment in a container's life. Since is the time
+// from the previous entry, zero for the first.
+type TimelineEntry struct {
+	Time     time.Time     `json:"time"`
+	Since    time.Duration `json:"since"`
+	Category string        `json:"category"`
+	Kind     string        `json:"kind"`
+	Process  string        `json:"process,omitempty"`
+	PID      int           `json:"pid,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
+}
+
+// TimelineOptions filters a timeline. Empty Categories selects all of
+// them; the time range runs from Since up to but excluding Until, and
+// zero ends leave it open. Limit keeps only the most recent entries, after
+// a TimelineTruncated entry counting the rest; zero keeps everything.
+type TimelineOptions struct {
+	Categories []string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// timelineLog keeps the events of each container. It has its own mutex
+// since events are published under container locks.
+type timelineLog struct {
+	mu     sync.Mutex
+	events map[string][]Event
+}
+
+func (l *timelineLog) record(e Event) {
+	if e.ContainerID == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e.Kind == EventContainerPurged {
+		delete(l.events, e.ContainerID)
+		return
+	}
+	if l.events == nil {
+		l.events = make(map[string][]Event)
+	}
+	l.appendLocked(e.ContainerID, e)
+	if e.Kind == EventMessageSent && e.Detail != e.ContainerID {
+		l.appendLocked(e.Detail, e) // the recipient's view
+	}
+}
+
+func (l *timelineLog) appendLocked(id string, e Event) {
+	evs := append(l.events[id], e)
+	if len(evs) > timelineLimit {
+		evs = slices.Delete(evs, 0, len(evs)-timelineLimit)
+	}
+	l.events[id] = evs
+}
+
+// Timeline merges what happened to container id into one chronological
+// list: lifecycle changes, process state transitions, messages sent and
+// received, alerts such as anomalies and memory pressure, and the times
+// its resource peaks were reached. Only the last events of each
+// container are kept, and none of a purged one.
+func (k *Kernel) Timeline(id string, opts TimelineOptions) ([]TimelineEntry, error) {
+	k.mu.Lock()
+	c, ok := k.Containers[id]
+	k.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+	}
+	k.timeline.mu.Lock()
+	evs := slices.Clone(k.timeline.events[id])
+	k.timeline.mu.Unlock()
+
+	var entries []TimelineEntry
+	for _, e := range evs {
+		entry := TimelineEntry{Time: e.Time, Category: timelineCategory(e.Kind), Kind: string(e.Kind), Process: e.Process, PID: e.PID, Detail: e.Detail}
+		if e.Kind == EventMessageSent {
+			if e.ContainerID == id {
+				entry.Detail = "to " + e.Detail
+			} else {
+				entry.Kind, entry.Detail = "MessageReceived", "from "+e.ContainerID
+			}
+		}
+		entries = append(entries, entry)
+	}
+	pk := c.Peaks()
+	for _, p := range []struct {
+		name string
+		peak Peak
+		unit string
+	}{
+		{"memory", pk.MemoryMB, "MB"},
+		{"cpu", pk.CPU, ""},
+		{"running", pk.Running, " processes"},
+		{"mailbox", pk.MailboxDepth, " messages"},
+	} {
+		if !p.peak.At.IsZero() {
+			entries = append(entries, TimelineEntry{Time: p.peak.At, Category: TimelinePeak, Kind: "Peak",
+				Detail: fmt.Sprintf("%s peak %g%s", p.name, p.peak.Value, p.unit)})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if (len(opts.Categories) == 0 || slices.Contains(opts.Categories, e.Category)) &&
+			(opts.Since.IsZero() || !e.Time.Before(opts.Since)) &&
+			(opts.Until.IsZero() || e.Time.Before(opts.Until)) {
+			kept = append(kept, e)
+		}
+	}
+	entries = kept
+	if opts.Limit > 0 && len(entries) > opts.Limit {
+		dropped := len(entries) - opts.Limit
+		marker := TimelineEntry{Time: entries[dropped-1].Time, Category: TimelineTruncated, Kind: "Truncated",
+			Detail: fmt.Sprintf("%d earlier entries omitted", dropped)}
+		entries = append([]TimelineEntry{marker}, entries[dropped:]...)
+	}
+	for i := range entries {
+		entries[i].Since = 0
+		if i > 0 {
+			entries[i].Since = entries[i].Time.Sub(entries[i-1].Time)
+		}
+	}
+	return entries, nil
+}
+
+// WriteTimeline renders entries as a chronological listing, one line per
+// entry with the time since the previous one, and process entries indented
+// under the container's own.
+func WriteTimeline(w io.Writer, entries []TimelineEntry) error {
+	for _, e := range entries {
+		indent := ""
+		if e.Process != "" {
+			indent = "  "
+		}
+		line := fmt.Sprintf("%s %10s %s%-9s %s", e.Time.Format(time.RFC3339Nano), "+"+e.Since.String(), indent, e.Category, e.Kind)
+		if e.Process != "" {
+			line += fmt.Sprintf(" %s[%d]", e.Process, e.PID)
+		}
+		if e.Detail != "" {
+			line += ": " + e.Detail
+		}
+		if _, err := io.WriteString(w, strings.TrimRight(line, " ")+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
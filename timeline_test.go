@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptLifecycle creates container web, runs a job in it for three
+// seconds while it exchanges messages with db, and returns the kernel.
+func scriptLifecycle(t *testing.T) *Kernel {
+	t.Helper()
+	k, clk := newTestKernel(t)
+	web := newTestContainer(t, k, "web")
+	newTestContainer(t, k, "db").AddProcess(&Process{Name: "listener"})
+	release := make(chan struct{})
+	job := &Process{Name: "job", Action: blockUntil(release)}
+	web.AddProcess(job)
+	if err := web.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	if err := k.SendMessage("web", "db", "query"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	if err := k.SendMessage("db", "web", "rows"); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(time.Second)
+	close(release)
+	waitDone(t, job)
+	return k
+}
+
+// describe lists entries as "+since category kind[: detail]".
+func describe(entries []TimelineEntry) []string {
+	var out []string
+	for _, e := range entries {
+		s := fmt.Sprintf("+%v %s %s", e.Since, e.Category, e.Kind)
+		if e.Detail != "" {
+			s += ": " + e.Detail
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestTimelineMergesContainerHistory(t *testing.T) {
+	k := scriptLifecycle(t)
+	entries, err := k.Timeline("web", TimelineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"+0s lifecycle ContainerCreated: web",
+		"+0s process ProcessAdded",
+		"+0s lifecycle ContainerStarted",
+		"+0s process ProcessStarted",
+		"+0s peak Peak: running peak 1 processes",
+		"+1s message MessageSent: to db",
+		"+1s message MessageReceived: from db",
+		"+0s peak Peak: mailbox peak 1 messages",
+		"+1s process ProcessCompleted",
+	}
+	if got := describe(entries); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("timeline:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if entries[3].Process != "job" || entries[3].PID == 0 {
+		t.Errorf("process entry = %+v", entries[3])
+	}
+	if _, err := k.Timeline("missing", TimelineOptions{}); err == nil {
+		t.Error("timeline of a missing container")
+	}
+}
+
+func TestTimelineFilters(t *testing.T) {
+	k := scriptLifecycle(t)
+	entries, err := k.Timeline("web", TimelineOptions{Categories: []string{TimelineLifecycle, TimelineProcess}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Category == TimelineMessage || e.Category == TimelinePeak {
+			t.Errorf("category filter let through %+v", e)
+		}
+	}
+	if len(entries) != 5 || entries[4].Since != 3*time.Second {
+		t.Errorf("filtered timeline = %v", describe(entries))
+	}
+
+	entries, _ = k.Timeline("web", TimelineOptions{Since: testEpoch.Add(time.Second), Until: testEpoch.Add(3 * time.Second)})
+	if got := fmt.Sprint(describe(entries)); got != "[+0s message MessageSent: to db +1s message MessageReceived: from db +0s peak Peak: mailbox peak 1 messages]" {
+		t.Errorf("time range = %s", got)
+	}
+
+	entries, _ = k.Timeline("web", TimelineOptions{Limit: 2})
+	if got := fmt.Sprint(describe(entries)); got != "[+0s truncated Truncated: 7 earlier entries omitted +0s peak Peak: mailbox peak 1 messages +1s process ProcessCompleted]" {
+		t.Errorf("limited timeline = %s", got)
+	}
+}
+
+func TestTimelineOverHTTP(t *testing.T) {
+	k := scriptLifecycle(t)
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		k.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+	rec := get("/debug/bvisor/containers/web/timeline?category=process&limit=1")
+	var entries []TimelineEntry
+	mustDecode(t, rec.Body.Bytes(), &entries)
+	if len(entries) != 2 || entries[1].Kind != "ProcessCompleted" {
+		t.Errorf("JSON timeline = %+v", entries)
+	}
+
+	rec = get("/debug/bvisor/containers/web/timeline?format=text&category=process")
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[2], "  process   ProcessCompleted job[2]") {
+		t.Errorf("text timeline:\n%s", rec.Body.String())
+	}
+	if !strings.HasPrefix(lines[2], "2024-01-01T00:00:03Z        +3s") {
+		t.Errorf("line = %q, want the time and the gap since the previous entry", lines[2])
+	}
+
+	for url, code := range map[string]int{
+		"/debug/bvisor/containers/missing/timeline":     404,
+		"/debug/bvisor/containers/web/timeline?since=x": 400,
+		"/debug/bvisor/containers/web/timeline?limit=x": 400,
+	} {
+		if rec := get(url); rec.Code != code {
+			t.Errorf("GET %s = %d, want %d", url, rec.Code, code)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Topics ---
+
+// SubscribeTopic makes containerID receive every message published to
+// topic. Topics need no declaration; subscribing twice is a no-op.
+//
+// With FromBeginning, FromTime or FromOffset the subscriber first receives
+// the topic's retained messages (see SetTopicRetention) in order, marked
+// Replayed, then live ones; an EventReplayCaughtUp marks the switch.
+// Messages published meanwhile are delivered by the replay, so none is
+// missed or repeated. Replaying to an existing subscription restarts it.
+func (k *Kernel) SubscribeTopic(topic, containerID string, opts ...SubscribeOption) error {
+	var cfg subscribeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Containers[containerID]; !ok {
+		return fmt.Errorf("%w: %s", ErrContainerNotFound, containerID)
+	}
+	if k.topics == nil {
+		k.topics = make(map[string]map[string]*topicSub)
+	}
+	if k.topics[topic] == nil {
+		k.topics[topic] = make(map[string]*topicSub)
+	}
+	if k.topics[topic][containerID] != nil && cfg.replay == nil {
+		return nil
+	}
+	k.endReplayLocked(topic, k.topics[topic][containerID])
+	sub := &topicSub{}
+	k.topics[topic][containerID] = sub
+	if cfg.replay != nil {
+		k.startReplayLocked(topic, containerID, sub, *cfg.replay, cfg.rate)
+	}
+	return nil
+}
+
+// UnsubscribeTopic stops delivery of topic to containerID.
+func (k *Kernel) UnsubscribeTopic(topic, containerID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.endReplayLocked(topic, k.topics[topic][containerID])
+	delete(k.topics[topic], containerID)
+	if len(k.topics[topic]) == 0 {
+		delete(k.topics, topic)
+	}
+}
+
+// TopicSubscribers returns the IDs of the containers subscribed to topic,
+// sorted.
+func (k *Kernel) TopicSubscribers(topic string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	ids := make([]string, 0, len(k.topics[topic]))
+	for id := range k.topics[topic] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// unsubscribeAllLocked drops a removed container from every topic.
+func (k *Kernel) unsubscribeAllLocked(containerID string) {
+	for topic, subs := range k.topics {
+		k.endReplayLocked(topic, subs[containerID])
+		delete(subs, containerID)
+		if len(subs) == 0 {
+			delete(k.topics, topic)
+		}
+	}
+}
+
+// Publish delivers payload from fromID to every container subscribed to
+// topic, in ID order, and returns how many copies were delivered. The
+// publisher does not receive its own messages. Copies refused by a
+// subscriber's payload or mailbox limits are dead-lettered and their
+// errors joined into the returned error.
+func (k *Kernel) Publish(topic, fromID, payload string) (int, error) {
+	return k.publishTopic(Message{From: fromID, Topic: topic, Payload: payload})
+}
+
+// publishTopic delivers a copy of m to each subscriber of m.Topic. All
+// copies share m's trace and cause.
+func (k *Kernel) publishTopic(m Message) (int, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return 0, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.Containers[m.From]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrContainerNotFound, m.From)
+	}
+	now := k.Clock.Now()
+	l := k.topicLogLocked(m.Topic)
+	l.last++
+	m.Offset = l.last
+	m.SentAt = k.Containers[m.From].localTime(now)
+	retained := m
+	retained.Replayed = true
+	l.append(retained, now)
+
+	subs := make([]string, 0, len(k.topics[m.Topic]))
+	for id, sub := range k.topics[m.Topic] {
+		if id != m.From && !sub.replaying {
+			subs = append(subs, id)
+		}
+	}
+	sort.Strings(subs)
+	n := 0
+	var refused []error
+	for _, id := range subs {
+		m.To = id
+		if _, err := k.deliverLocked(m, ""); err == nil {
+			n++
+		} else if undeliverable(err) {
+			k.deadLetterLocked(m, err.Error())
+			refused = append(refused, err)
+		}
+	}
+	return n, errors.Join(refused...)
+}
+
+// Publish sends msg to every container subscribed to topic and returns how
+// many copies were delivered.
+func (h *Handle) Publish(topic, msg string) (int, error) {
+	n := 0
+	err := h.syscall("publish", topic, func() error {
+		if err := h.checkCapability(CapSendMessages, "publish"); err != nil {
+			return err
+		}
+		k := h.container.kernel
+		if k == nil {
+			return fmt.Errorf("%w: container %s is not attached to a kernel", ErrContainerNotFound, h.container.ID)
+		}
+		var err error
+		n, err = k.publishTopic(h.traced(Message{From: h.container.ID, Topic: topic, Payload: msg}))
+		return err
+	})
+	return n, err
+}
+
+// --- Completion Records ---
+
+// CompletionRecord is published, as JSON, to a process's results topic
+// when the process reaches a terminal state.
+type CompletionRecord struct {
+	Container string        `json:"container"`
+	PID       int           `json:"pid"`
+	Name      string        `json:"name"`
+	State     string        `json:"state"`
+	Duration  time.Duration `json:"duration"` // zero if the process never started
+	Error     string        `json:"error,omitempty"`
+
+	// Result is the value passed to Handle.SetResult. ResultError is set
+	// instead when that value could not be encoded as JSON.
+	Result      json.RawMessage `json:"result,omitempty"`
+	ResultError string          `json:"result_error,omitempty"`
+}
+
+// SetResult records v as the process's result, to be included in its
+// completion record (see Process.PublishResultsTo). A later call replaces
+// an earlier one.
+func (h *Handle) SetResult(v any) {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h.proc.result, h.proc.hasResult = v, true
+}
+
+// resultsTopicLocked returns where p's completion record goes: the
+// process's own topic, else its container's.
+func (c *Container) resultsTopicLocked(p *Process) string {
+	if p.PublishResultsTo != "" {
+		return p.PublishResultsTo
+	}
+	return c.PublishResultsTo
+}
+
+// publishResultLocked sends p's completion record to its results topic, if
+// it has one. Publishing takes the kernel lock, so it happens on its own
+// goroutine; records of different processes may arrive in any order.
+func (c *Container) publishResultLocked(p *Process) {
+	topic := c.resultsTopicLocked(p)
+	if topic == "" || c.kernel == nil {
+		return
+	}
+	r := CompletionRecord{Container: c.ID, PID: p.PID, Name: p.Name, State: p.State.String()}
+	if !p.startedAt.IsZero() {
+		r.Duration = p.finishedAt.Sub(p.startedAt)
+	}
+	if p.Err != nil {
+		r.Error = p.Err.Error()
+	}
+	if p.hasResult {
+		if b, err := json.Marshal(p.result); err != nil {
+			r.ResultError = fmt.Sprintf("result not serializable: %v", err)
+		} else {
+			r.Result = b
+		}
+	}
+	b, _ := json.Marshal(r) // cannot fail: Result is already valid JSON
+	m := Message{From: c.ID, Topic: topic, Payload: string(b)}
+	if p.handle != nil {
+		m.TraceID, m.CausationID = p.handle.traceID, p.handle.causeID
+	}
+	k, name := c.kernel, p.Name
+	go func() {
+		if _, err := k.publishTopic(m); err != nil {
+			fmt.Printf("[Kernel] Dropped completion record of %s: %v\n", name, err)
+		}
+	}()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// completionRecords decodes the completion records in c's inbox by process
+// name.
+func completionRecords(t *testing.T, c *Container) map[string]CompletionRecord {
+	t.Helper()
+	records := map[string]CompletionRecord{}
+	for _, m := range c.Inbox() {
+		var r CompletionRecord
+		mustDecode(t, []byte(m.Payload), &r)
+		records[r.Name] = r
+	}
+	return records
+}
+
+func TestPublishResultsToTopic(t *testing.T) {
+	k, _ := newTestKernel(t)
+	board := newTestContainer(t, k, "board")
+	board.AddProcess(&Process{Name: "listener"})
+	if err := k.SubscribeTopic("jobs", "board"); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestContainer(t, k, "worker")
+	c.PublishResultsTo = "jobs"
+
+	procs := []*Process{
+		{Name: "ok", Action: func(ctx context.Context, h *Handle) error {
+			h.SetResult(map[string]int{"rows": 3})
+			return nil
+		}},
+		{Name: "broken", Action: func(context.Context, *Handle) error { return errors.New("disk full") }},
+		{Name: "odd", Action: func(ctx context.Context, h *Handle) error {
+			h.SetResult(make(chan int))
+			return nil
+		}},
+		{Name: "quiet", Action: noop, PublishResultsTo: "elsewhere"},
+	}
+	for _, p := range procs {
+		c.AddProcess(p)
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	eventually(t, "three completion records", func() bool { return len(board.Inbox()) == 3 })
+	records := completionRecords(t, board)
+
+	ok := records["ok"]
+	if ok.Container != "worker" || ok.PID != procs[0].PID || ok.State != Completed.String() || ok.Error != "" {
+		t.Errorf("ok record = %+v", ok)
+	}
+	var result map[string]int
+	if err := json.Unmarshal(ok.Result, &result); err != nil || result["rows"] != 3 {
+		t.Errorf("ok result = %s (%v)", ok.Result, err)
+	}
+	if r := records["broken"]; r.State != Failed.String() || r.Error != "disk full" || r.Result != nil {
+		t.Errorf("broken record = %+v", r)
+	}
+	odd, found := records["odd"]
+	if !found || odd.State != Completed.String() || odd.Result != nil || odd.ResultError == "" {
+		t.Errorf("odd record = %+v, want a serialization note instead of a result", odd)
+	}
+	if _, found := records["quiet"]; found {
+		t.Error("a process with its own topic published to the container's")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// --- Message Tracing ---
+
+// Bounds on retained traces: the oldest traces are forgotten first, and
+// hops beyond traceHopLimit are not recorded.
+const (
+	traceLimit    = 1024
+	traceHopLimit = 256
+)
+
+// TraceOutcome is what happened to one traced message.
+type TraceOutcome string
+
+const (
+	TraceDelivered    TraceOutcome = "Delivered"
+	TraceDeadLettered TraceOutcome = "DeadLettered"
+)
+
+// TraceNode is one message of a trace and the messages it caused.
+// ReceivedAt is set once a process takes the message with Recv or receives
+// it as the reply to a Request.
+type TraceNode struct {
+	Message    Message
+	Outcome    TraceOutcome
+	Reason     string // dead-letter reason
+	ReceivedAt time.Time
+	Children   []*TraceNode
+}
+
+// Depth is the number of hops on the longest path from n down to a leaf,
+// counting n.
+func (n *TraceNode) Depth() int {
+	d := 0
+	for _, c := range n.Children {
+		d = max(d, c.Depth())
+	}
+	return d + 1
+}
+
+type traceHop struct {
+	msg        Message
+	outcome    TraceOutcome
+	reason     string
+	receivedAt time.Time
+}
+
+// traceLog records the hops of recent traces. It has its own mutex so
+// container code can record receipts without taking the kernel lock.
+type traceLog struct {
+	mu     sync.Mutex
+	traces map[uint64][]*traceHop
+	order  []uint64 // trace IDs, oldest first
+}
+
+func (l *traceLog) record(m Message, outcome TraceOutcome, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.traces == nil {
+		l.traces = make(map[uint64][]*traceHop)
+	}
+	hops, ok := l.traces[m.TraceID]
+	if !ok {
+		l.order = append(l.order, m.TraceID)
+		if len(l.order) > traceLimit {
+			delete(l.traces, l.order[0])
+			l.order = l.order[1:]
+		}
+	}
+	if len(hops) >= traceHopLimit {
+		return
+	}
+	l.traces[m.TraceID] = append(hops, &traceHop{msg: m, outcome: outcome, reason: reason})
+}
+
+func (l *traceLog) received(m Message, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, h := range l.traces[m.TraceID] {
+		if h.msg.ID == m.ID {
+			h.receivedAt = at
+			return
+		}
+	}
+}
+
+// Trace returns the causal tree of traceID, rooted at the message that
+// started it, or nil if the trace is unknown or has been forgotten. Children
+// are ordered by message ID. Hops whose cause was not recorded hang off the
+// root.
+func (k *Kernel) Trace(traceID uint64) *TraceNode {
+	l := &k.traces
+	l.mu.Lock()
+	hops := l.traces[traceID]
+	nodes := make([]*TraceNode, len(hops))
+	byID := make(map[uint64]*TraceNode, len(hops))
+	for i, h := range hops {
+		nodes[i] = &TraceNode{Message: h.msg, Outcome: h.outcome, Reason: h.reason, ReceivedAt: h.receivedAt}
+		byID[h.msg.ID] = nodes[i]
+	}
+	l.mu.Unlock()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Message.ID < nodes[j].Message.ID })
+	root := nodes[0]
+	for _, n := range nodes[1:] {
+		parent, ok := byID[n.Message.CausationID]
+		if !ok || parent == n {
+			parent = root
+		}
+		parent.Children = append(parent.Children, n)
+	}
+	return root
+}
+
+// handlingLocked records that the process is now handling m, so messages it
+// sends next are caused by m. The caller holds the container lock.
+func (h *Handle) handlingLocked(m Message) {
+	h.traceID, h.causeID = m.TraceID, m.ID
+	if m.RequestID != 0 {
+		h.requestReceivedLocked(m, h.container.now())
+	}
+	if k := h.container.kernel; k != nil {
+		k.traces.received(m, h.container.now())
+		k.receipts.update(m.ID, MessageConsumed, "", h.container.now())
+	}
+}
+
+// traced returns m stamped with the trace of the message the process is
+// handling, if any, so that m becomes its child.
+func (h *Handle) traced(m Message) Message {
+	c := h.container
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return h.tracedLocked(m)
+}
+
+func (h *Handle) tracedLocked(m Message) Message {
+	m.TraceID, m.CausationID = h.traceID, h.causeID
+	return m
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// relay returns an action that receives one message and passes it on to
+// each of next; a "late:" destination is sent through SendAfter.
+func relay(next ...string) ActionFunc {
+	return func(ctx context.Context, h *Handle) error {
+		m, err := h.Recv()
+		if err != nil {
+			return err
+		}
+		for _, to := range next {
+			if late, ok := strings.CutPrefix(to, "late:"); ok {
+				if _, err := h.SendAfter(late, m.Payload+">"+late, 0); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := h.Send(to, m.Payload+">"+to); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestTraceFollowsCausalChain(t *testing.T) {
+	k, _ := newTestKernel(t)
+	var procs []*Process
+	for id, action := range map[string]ActionFunc{
+		"b": relay("c"),
+		"c": relay("d", "late:gone"),
+		"d": relay(),
+	} {
+		c := newTestContainer(t, k, id)
+		p := &Process{Name: id, Action: action}
+		c.AddProcess(p)
+		if err := c.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		procs = append(procs, p)
+	}
+	newTestContainer(t, k, "a")
+	if err := k.SendMessage("a", "b", "start"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		waitDone(t, p)
+	}
+	eventually(t, "the scheduled hop to be dead-lettered", func() bool { return len(k.DeadLetters()) == 1 })
+
+	dl := k.DeadLetters()[0]
+	root := k.Trace(dl.Message.TraceID)
+	if root == nil || root.Message.Payload != "start" || root.ReceivedAt.IsZero() {
+		t.Fatalf("root = %+v", root)
+	}
+	if d := root.Depth(); d != 3 {
+		t.Errorf("depth = %d, want 3", d)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("root children = %d, want 1", len(root.Children))
+	}
+	hop2 := root.Children[0]
+	if hop2.Message.Payload != "start>c" || hop2.Message.CausationID != root.Message.ID || len(hop2.Children) != 2 {
+		t.Fatalf("second hop = %+v", hop2)
+	}
+	delivered, dead := hop2.Children[0], hop2.Children[1]
+	if delivered.Message.Payload != "start>c>d" || delivered.Outcome != TraceDelivered || delivered.Message.CausationID != hop2.Message.ID {
+		t.Errorf("third hop = %+v", delivered)
+	}
+	if dead.Message.Payload != "start>c>gone" || dead.Outcome != TraceDeadLettered || dead.Reason != dl.Reason || dead.Reason == "" {
+		t.Errorf("dead-lettered hop = %+v", dead)
+	}
+}
+
+func TestBroadcastCopiesShareTrace(t *testing.T) {
+	k, _ := newTestKernel(t)
+	for _, id := range []string{"a", "b", "c"} {
+		newTestContainer(t, k, id).AddProcess(&Process{Name: "listener"})
+	}
+	if n, err := k.Broadcast("a", "reload"); n != 2 || err != nil {
+		t.Fatalf("Broadcast = %d, %v", n, err)
+	}
+	b, c := k.Containers["b"].Inbox()[0], k.Containers["c"].Inbox()[0]
+	if b.TraceID == 0 || b.TraceID != c.TraceID {
+		t.Fatalf("copies have traces %d and %d, want one shared trace", b.TraceID, c.TraceID)
+	}
+	root := k.Trace(b.TraceID)
+	if root == nil || root.Message.ID != b.ID || len(root.Children) != 1 || root.Children[0].Message.ID != c.ID {
+		t.Errorf("trace = %+v", root)
+	}
+}
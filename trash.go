@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// --- Container Trash ---
+
+// DefaultTrashRetention is how long a removed container stays restorable
+// when Kernel.TrashRetention is zero.
+const DefaultTrashRetention = time.Hour
+
+// trashedContainer is a container removed by RemoveContainer that
+// RestoreContainer can still bring back.
+type trashedContainer struct {
+	c         *Container
+	removedAt time.Time
+	purgeAt   time.Time
+	revive    []*Process    // live when removed, queued again on restore
+	cancel    chan struct{} // closed when the container leaves the trash
+}
+
+// TrashedContainer describes a container in the trash.
+type TrashedContainer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	RemovedAt time.Time `json:"removed_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// trashRetention returns how long removed containers stay restorable, or
+// a negative duration if they are purged right away.
+func (k *Kernel) trashRetention() time.Duration {
+	if k.TrashRetention == 0 {
+		return DefaultTrashRetention
+	}
+	return k.TrashRetention
+}
+
+// trashContainerLocked removes c like removeContainerLocked but keeps it,
+// with the processes it stopped, until it is restored or its retention
+// runs out.
+func (k *Kernel) trashContainerLocked(c *Container) {
+	retention := k.trashRetention()
+	if retention < 0 {
+		k.removeContainerLocked(c)
+		return
+	}
+	if _, ok := k.trash[c.ID]; ok {
+		k.purgeLocked(c.ID) // an older container with the same ID
+	}
+	var revive []*Process
+	c.mu.Lock()
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled, Running:
+			if !p.debug {
+				revive = append(revive, p)
+			}
+		}
+	}
+	c.mu.Unlock()
+	k.unregisterContainerLocked(c)
+
+	now := k.Clock.Now()
+	t := &trashedContainer{c: c, removedAt: now, purgeAt: now.Add(retention), revive: revive, cancel: make(chan struct{})}
+	if k.trash == nil {
+		k.trash = make(map[string]*trashedContainer)
+	}
+	k.trash[c.ID] = t
+	go k.purgeAfter(t, retention)
+}
+
+// purgeAfter purges t once its retention has passed, unless it left the
+// trash before.
+func (k *Kernel) purgeAfter(t *trashedContainer, d time.Duration) {
+	select {
+	case <-k.Clock.After(d):
+	case <-t.cancel:
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.trash[t.c.ID] == t {
+		k.purgeLocked(t.c.ID)
+	}
+}
+
+// purgeLocked forgets everything the kernel still keeps about a removed
+// container: its place in the trash, the volumes it was last placed with
+// and, through EventContainerPurged, its timeline.
+func (k *Kernel) purgeLocked(id string) {
+	if t, ok := k.trash[id]; ok {
+		delete(k.trash, id)
+		close(t.cancel)
+		fmt.Printf("[Kernel] Purged container: %s\n", t.c.Name)
+	}
+	for v, home := range k.volumeHomes {
+		if home == id {
+			delete(k.volumeHomes, v)
+		}
+	}
+	k.emit(EventContainerPurged, id, "", "")
+}
+
+// PurgeContainer removes container id for good, bypassing the trash. A
+// container already in the trash is purged from it.
+func (k *Kernel) PurgeContainer(id string) error {
+	if err := k.checkAuthoritative(); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if c, ok := k.Containers[id]; ok {
+		k.removeContainerLocked(c)
+		return nil
+	}
+	if _, ok := k.trash[id]; ok {
+		k.purgeLocked(id)
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrContainerNotFound, id)
+}
+
+// RestoreContainer brings container id back from the trash with its
+// definition and processes. It returns Stopped; the processes that were
+// queued or running when it was removed are queued again, and start with
+// it. The checks made on creation are made again: the ID must be free, the
+// name unique if RequireUniqueNames is set, and the requeued processes
+// must fit within MaxTotalProcesses. Topic subscriptions and service
+// registrations are not restored.
+func (k *Kernel) RestoreContainer(id string) (*Container, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	t, ok := k.trash[id]
+	if ok && !k.Clock.Now().Before(t.purgeAt) {
+		k.purgeLocked(id)
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not in the trash", ErrContainerNotFound, id)
+	}
+	if _, taken := k.Containers[id]; taken {
+		return nil, fmt.Errorf("%w: %s", ErrContainerExists, id)
+	}
+	c := t.c
+	c.mu.Lock()
+	name := c.Name
+	c.mu.Unlock()
+	if err := k.checkNameLocked(id, name); err != nil {
+		return nil, err
+	}
+	if k.MaxTotalProcesses > 0 {
+		if n := k.liveProcessesLocked(); n+len(t.revive) > k.MaxTotalProcesses {
+			return nil, fmt.Errorf("%w: restoring %s queues %d processes, %d of %d live", ErrSpawnLimitExceeded, id, len(t.revive), n, k.MaxTotalProcesses)
+		}
+	}
+
+	delete(k.trash, id)
+	close(t.cancel)
+	k.Containers[id] = c
+	fmt.Printf("[Kernel] Restored container: %s\n", name)
+	k.emit(EventContainerRestored, id, "", name)
+	c.mu.Lock()
+	c.requeueLocked(t.revive)
+	c.mu.Unlock()
+	return c, nil
+}
+
+// Trash lists the containers that can still be restored, in ID order.
+func (k *Kernel) Trash() []TrashedContainer {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	out := make([]TrashedContainer, 0, len(k.trash))
+	for id, t := range k.trash {
+		t.c.mu.Lock()
+		out = append(out, TrashedContainer{ID: id, Name: t.c.Name, RemovedAt: t.removedAt, PurgeAt: t.purgeAt})
+		t.c.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRestoreContainerKeepsDefinitionAndProcesses(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "web")
+	c.SetLabels(map[string]string{"tier": "web"})
+	finished := &Process{Name: "migrate", Action: noop}
+	c.AddProcess(finished)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, finished)
+	server := &Process{Name: "server", Action: blockUntil(nil)}
+	c.AddProcess(server)
+	eventually(t, "server running", func() bool { return stateOf(c, server) == Running })
+
+	if err := k.RemoveContainer("web"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := k.Containers["web"]; ok {
+		t.Fatal("removed container still registered")
+	}
+	trash := k.Trash()
+	if len(trash) != 1 || trash[0].ID != "web" || !trash[0].PurgeAt.Equal(testEpoch.Add(DefaultTrashRetention)) {
+		t.Fatalf("Trash() = %+v", trash)
+	}
+
+	got, err := k.RestoreContainer("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c || got.Inspect().State != ContainerStopped || got.Labels["tier"] != "web" || got.MemoryMB != 1024 {
+		t.Errorf("restored %+v", got.Inspect())
+	}
+	if len(k.Trash()) != 0 {
+		t.Error("restored container left in the trash")
+	}
+	if stateOf(c, finished) == Pending {
+		t.Error("a finished process was queued again")
+	}
+	if stateOf(c, server) != Pending {
+		t.Errorf("server = %v, want queued again", stateOf(c, server))
+	}
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "server running after restore", func() bool { return stateOf(c, server) == Running })
+	c.StopProcesses()
+}
+
+func TestRestoreContainerFailsOncePurged(t *testing.T) {
+	k, clk := newTestKernel(t)
+	k.TrashRetention = time.Minute
+	newTestContainer(t, k, "expired")
+	newTestContainer(t, k, "purged")
+	for _, id := range []string{"expired", "purged"} {
+		if err := k.RemoveContainer(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.PurgeContainer("purged"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.RestoreContainer("purged"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("restoring a purged container: %v", err)
+	}
+
+	clk.Advance(time.Minute)
+	if _, err := k.RestoreContainer("expired"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("restoring after the retention window: %v", err)
+	}
+	if trash := k.Trash(); len(trash) != 0 {
+		t.Errorf("Trash() = %+v, want empty", trash)
+	}
+	if err := k.PurgeContainer("expired"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("purging twice: %v", err)
+	}
+}
+
+func TestRestoreContainerRechecksQuotas(t *testing.T) {
+	k, _ := newTestKernel(t)
+	k.RequireUniqueNames = true
+	k.MaxTotalProcesses = 2
+	c := newTestContainer(t, k, "api")
+	for _, name := range []string{"a", "b"} {
+		c.AddProcess(&Process{Name: name, Action: blockUntil(nil)})
+	}
+	if err := k.RemoveContainer("api"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name is taken while api is in the trash.
+	if _, err := k.CreateContainer("api-2", "api", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.RestoreContainer("api"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("restoring over a taken name: %v", err)
+	}
+	if err := k.RenameContainer("api-2", "api-new"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two queued processes do not fit beside one live one.
+	other := newTestContainer(t, k, "other")
+	other.AddProcess(&Process{Name: "c", Action: blockUntil(nil)})
+	if _, err := k.RestoreContainer("api"); !errors.Is(err, ErrSpawnLimitExceeded) {
+		t.Errorf("restoring past MaxTotalProcesses: %v", err)
+	}
+	if len(k.Trash()) != 1 {
+		t.Error("a failed restore took the container out of the trash")
+	}
+
+	if err := k.PurgeContainer("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.RestoreContainer("api"); err != nil {
+		t.Errorf("restoring within the quota: %v", err)
+	}
+}
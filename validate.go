@@ -0,0 +1,103 @@
+package main
+
+import "fmt"
+
+// --- Preflight Validation ---
+
+// Warning is a non-fatal finding from Container.Validate.
+type Warning struct {
+	Resource  string  // "cpu" or "memory"
+	Requested float64 // summed demand of the queued and running processes
+	Limit     float64
+	Message   string
+}
+
+// Validate checks whether the CPU weights and memory of the container's
+// queued and running processes fit its limits, allowing the excess given by
+// OvercommitRatio. It only reports; nothing is changed or refused.
+func (c *Container) Validate() []Warning {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ratio := c.OvercommitRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	cpu, mem := c.requestedLocked()
+	var warnings []Warning
+	if c.CPULimit > 0 && cpu > c.CPULimit*ratio {
+		warnings = append(warnings, Warning{
+			Resource:  MetricCPU,
+			Requested: cpu,
+			Limit:     c.CPULimit,
+			Message:   fmt.Sprintf("container %s: processes request %.2f CPUs, limit is %.2f", c.ID, cpu, c.CPULimit),
+		})
+	}
+	if c.MemoryMB > 0 && float64(mem) > float64(c.MemoryMB)*ratio {
+		warnings = append(warnings, Warning{
+			Resource:  MetricMemory,
+			Requested: float64(mem),
+			Limit:     float64(c.MemoryMB),
+			Message:   fmt.Sprintf("container %s: processes request %dMB, limit is %dMB", c.ID, mem, c.MemoryMB),
+		})
+	}
+	return warnings
+}
+
+// requestedLocked sums the CPU weights and memory of the queued and running
+// processes.
+func (c *Container) requestedLocked() (cpu float64, mem int) {
+	for _, p := range c.Processes {
+		switch p.State {
+		case Pending, Throttled, Running:
+			cpu += p.CPUWeight
+			mem += p.MemoryMB
+		}
+	}
+	return cpu, mem
+}
+
+// --- Limit Violations ---
+
+// LimitViolation reports a container whose current usage exceeds one of its
+// limits.
+type LimitViolation struct {
+	ContainerID string  `json:"container_id"`
+	Metric      string  `json:"metric"` // "cpu" or "memory"
+	Limit       float64 `json:"limit"`
+	Current     float64 `json:"current"`
+}
+
+func (v LimitViolation) String() string {
+	return fmt.Sprintf("container %s over %s limit: %.2f > %.2f", v.ContainerID, v.Metric, v.Current, v.Limit)
+}
+
+// Violations lists, in container ID order, every limit currently exceeded:
+// CPULimit by the CPU weight of running processes and MemoryMB by their
+// memory usage. Unlike Validate it looks at what is running now, not at
+// what has been requested, and ignores OvercommitRatio.
+func (k *Kernel) Violations() []LimitViolation {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.violationsLocked()
+}
+
+func (k *Kernel) violationsLocked() []LimitViolation {
+	var out []LimitViolation
+	for _, c := range k.sortedContainersLocked() {
+		c.mu.Lock()
+		out = append(out, c.violationsLocked()...)
+		c.mu.Unlock()
+	}
+	return out
+}
+
+func (c *Container) violationsLocked() []LimitViolation {
+	var out []LimitViolation
+	if cpu := c.runningCPUWeightLocked(); c.CPULimit > 0 && cpu > c.CPULimit {
+		out = append(out, LimitViolation{ContainerID: c.ID, Metric: MetricCPU, Limit: c.CPULimit, Current: cpu})
+	}
+	if mem := c.memoryUsageLocked(); c.MemoryMB > 0 && mem > c.MemoryMB {
+		out = append(out, LimitViolation{ContainerID: c.ID, Metric: MetricMemory, Limit: float64(c.MemoryMB), Current: float64(mem)})
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateWarnsAboutOvercommit(t *testing.T) {
+	k, _ := newTestKernel(t)
+	c := newTestContainer(t, k, "batch")
+	c.CPULimit = 2
+	for _, p := range []*Process{
+		{Name: "a", CPUWeight: 1.5, MemoryMB: 600},
+		{Name: "b", CPUWeight: 1.5, MemoryMB: 600},
+	} {
+		c.AddProcess(p)
+	}
+
+	want := []Warning{
+		{Resource: MetricCPU, Requested: 3, Limit: 2, Message: "container batch: processes request 3.00 CPUs, limit is 2.00"},
+		{Resource: MetricMemory, Requested: 1200, Limit: 1024, Message: "container batch: processes request 1200MB, limit is 1024MB"},
+	}
+	got := c.Validate()
+	if len(got) != len(want) {
+		t.Fatalf("warnings = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("warning %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// A ratio of 1.5 tolerates both.
+	c.OvercommitRatio = 1.5
+	if got := c.Validate(); len(got) != 0 {
+		t.Errorf("warnings within the overcommit ratio: %+v", got)
+	}
+}
+
+func TestViolationsReportOverBudgetContainers(t *testing.T) {
+	k, _ := newTestKernel(t)
+	newTestContainer(t, k, "fine").AddProcess(&Process{Name: "idle", CPUWeight: 0.5, Action: blockUntil(nil)})
+	c, err := k.CreateContainer("api", "api", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.CPULimit = 1
+	c.MemoryPressure.GraceWindow = time.Hour // keep the OOM killer away
+	var procs []*Process
+	for i := 0; i < 2; i++ {
+		p := &Process{Name: fmt.Sprint("worker", i), CPUWeight: 0.75, MemoryMB: 50, Action: blockUntil(nil)}
+		c.AddProcess(p)
+		procs = append(procs, p)
+	}
+	for _, started := range []*Container{k.Containers["fine"], c} {
+		if err := started.StartProcesses(); err != nil {
+			t.Fatal(err)
+		}
+		defer started.StopProcesses()
+	}
+	eventually(t, "the workers to run", func() bool { return k.Stats().Running == 3 })
+	c.adjustMemory(procs[0], 50)
+
+	want := []LimitViolation{
+		{ContainerID: "api", Metric: MetricCPU, Limit: 1, Current: 1.5},
+		{ContainerID: "api", Metric: MetricMemory, Limit: 100, Current: 150},
+	}
+	if got := k.Violations(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Violations = %v, want %v", got, want)
+	}
+	var out bytes.Buffer
+	if err := k.MonitorTo(&out, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Violation: container api over memory limit: 150.00 > 100.00\n") {
+		t.Errorf("monitor output:\n%s", out.String())
+	}
+
+	c.mu.Lock()
+	c.killLocked(procs[1], "test")
+	c.mu.Unlock()
+	if got := k.Violations(); len(got) != 0 {
+		t.Errorf("Violations = %v once back within limits", got)
+	}
+}
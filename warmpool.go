@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --- Templates ---
+
+var ErrUnknownTemplate = errors.New("unknown container template")
+
+// RegisterTemplate stores spec under name for building containers on
+// demand, such as by warm pools. The spec's ID is ignored; each container
+// built from it gets its own. Registering a name again replaces it.
+func (k *Kernel) RegisterTemplate(name string, spec Spec) error {
+	if err := validateContainer(name, spec.Name, spec.MemoryMB); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.templates == nil {
+		k.templates = make(map[string]Spec)
+	}
+	spec.ID = ""
+	k.templates[name] = spec
+	return nil
+}
+
+// --- Warm Pools ---
+
+var ErrPoolExists = errors.New("warm pool already exists")
+var ErrUnknownPool = errors.New("unknown warm pool")
+
+// warmContainer is a container built from a template ahead of a claim. Its
+// processes have been constructed from their kinds but are not yet added,
+// so they have no PIDs and emit nothing until the container is claimed.
+type warmContainer struct {
+	c     *Container
+	procs []*Process
+}
+
+// warmPool keeps up to size containers of one template ready to claim.
+type warmPool struct {
+	template string
+	size     int
+	ready    []warmContainer
+
+	claims       int
+	coldStarts   int
+	lastLatency  time.Duration
+	totalLatency time.Duration
+	replenishing bool
+}
+
+// WarmPoolStats reports a warm pool's occupancy and claim latency.
+type WarmPoolStats struct {
+	Size       int `json:"size"`
+	Ready      int `json:"ready"`
+	Claims     int `json:"claims"`
+	ColdStarts int `json:"cold_starts"` // claims served by building a container
+
+	LastClaimLatency time.Duration `json:"last_claim_latency"`
+	MeanClaimLatency time.Duration `json:"mean_claim_latency"`
+}
+
+// ClaimOverrides are the settings a claim may apply to a pooled container.
+// Labels and env are merged over the template's; a zero Name keeps the
+// template's name.
+type ClaimOverrides struct {
+	Name   string
+	Labels map[string]string
+	Env    map[string]string
+}
+
+// CreateWarmPool builds size containers from template and keeps them ready
+// for ClaimFromPool, which is served without running the template's
+// process factories. The pool is named after the template. Warm containers
+// are not registered with the kernel and their processes do not run until
+// claimed.
+func (k *Kernel) CreateWarmPool(template string, size int) error {
+	if size < 1 {
+		return fmt.Errorf("%w: pool size must be positive, got %d", ErrInvalidContainerSpec, size)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.templates[template]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, template)
+	}
+	if _, ok := k.warmPools[template]; ok {
+		return fmt.Errorf("%w: %s", ErrPoolExists, template)
+	}
+	pool := &warmPool{template: template, size: size}
+	for len(pool.ready) < size {
+		w, err := k.buildWarmLocked(template)
+		if err != nil {
+			return err
+		}
+		pool.ready = append(pool.ready, w)
+	}
+	if k.warmPools == nil {
+		k.warmPools = make(map[string]*warmPool)
+	}
+	k.warmPools[template] = pool
+	fmt.Printf("[Kernel] Created warm pool %s with %d containers\n", template, size)
+	return nil
+}
+
+// DeleteWarmPool discards a warm pool and its unclaimed containers.
+func (k *Kernel) DeleteWarmPool(name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.warmPools[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownPool, name)
+	}
+	delete(k.warmPools, name)
+	return nil
+}
+
+// buildWarmLocked builds an unregistered container from a template.
+func (k *Kernel) buildWarmLocked(template string) (warmContainer, error) {
+	spec, ok := k.templates[template]
+	if !ok {
+		return warmContainer{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, template)
+	}
+	w := warmContainer{c: k.newContainerLocked("", spec.Name, spec.MemoryMB)}
+	w.c.Labels = copyStringMap(spec.Labels)
+	w.c.Env = copyStringMap(spec.Env)
+	w.c.Volumes = append([]string(nil), spec.Volumes...)
+	w.c.DependsOn = append([]string(nil), spec.DependsOn...)
+	w.c.StopPriority = spec.StopPriority
+	w.c.BootPhase = spec.BootPhase
+	for _, ps := range spec.Processes {
+		p, err := k.newProcessLocked(ps)
+		if err != nil {
+			return warmContainer{}, fmt.Errorf("template %s: %w", template, err)
+		}
+		w.procs = append(w.procs, p)
+	}
+	return w, nil
+}
+
+// ClaimFromPool registers a container from the named pool under id, applies
+// overrides and starts it. The pool is refilled in the background. If the
+// pool is empty the container is built on the spot instead and an
+// EventPoolEmpty is emitted.
+func (k *Kernel) ClaimFromPool(name, id string, overrides ClaimOverrides) (*Container, error) {
+	if err := k.checkAuthoritative(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	k.mu.Lock()
+	pool, ok := k.warmPools[name]
+	if !ok {
+		k.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPool, name)
+	}
+	if _, taken := k.Containers[id]; taken {
+		k.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrContainerExists, id)
+	}
+	containerName := overrides.Name
+	if containerName == "" {
+		containerName = k.templates[pool.template].Name
+	}
+	if err := k.checkNameLocked(id, containerName); err != nil {
+		k.mu.Unlock()
+		return nil, err
+	}
+	var w warmContainer
+	if n := len(pool.ready); n > 0 {
+		w, pool.ready = pool.ready[0], pool.ready[1:]
+	} else {
+		var err error
+		if w, err = k.buildWarmLocked(pool.template); err != nil {
+			k.mu.Unlock()
+			return nil, err
+		}
+		pool.coldStarts++
+		fmt.Printf("[Kernel] Warm pool %s is empty: building %s cold\n", name, id)
+		k.emit(EventPoolEmpty, id, "", name)
+	}
+	c := w.c
+	c.Name = containerName
+	for key, v := range overrides.Labels {
+		c.Labels[key] = v
+	}
+	for key, v := range overrides.Env {
+		c.Env[key] = v
+	}
+	k.registerContainerLocked(c, id)
+	c.mu.Lock()
+	for _, p := range w.procs {
+		c.addProcessLocked(p)
+	}
+	c.mu.Unlock()
+
+	latency := time.Since(start)
+	pool.claims++
+	pool.lastLatency = latency
+	pool.totalLatency += latency
+	k.replenishLocked(pool)
+	k.mu.Unlock()
+
+	c.StartProcesses()
+	return c, nil
+}
+
+// replenishLocked refills pool in the background, one container per
+// kernel lock acquisition so that claims are not held up.
+func (k *Kernel) replenishLocked(pool *warmPool) {
+	if pool.replenishing {
+		return
+	}
+	pool.replenishing = true
+	go func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		for k.warmPools[pool.template] == pool && len(pool.ready) < pool.size {
+			w, err := k.buildWarmLocked(pool.template)
+			if err != nil {
+				fmt.Printf("[Kernel] Cannot refill warm pool %s: %v\n", pool.template, err)
+				break
+			}
+			pool.ready = append(pool.ready, w)
+			k.mu.Unlock()
+			k.mu.Lock()
+		}
+		pool.replenishing = false
+	}()
+}
+
+// WarmPoolStats returns the stats of every warm pool by name.
+func (k *Kernel) WarmPoolStats() map[string]WarmPoolStats {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.warmPoolStatsLocked()
+}
+
+func (k *Kernel) warmPoolStatsLocked() map[string]WarmPoolStats {
+	if len(k.warmPools) == 0 {
+		return nil
+	}
+	out := make(map[string]WarmPoolStats, len(k.warmPools))
+	for name, pool := range k.warmPools {
+		s := WarmPoolStats{
+			Size:             pool.size,
+			Ready:            len(pool.ready),
+			Claims:           pool.claims,
+			ColdStarts:       pool.coldStarts,
+			LastClaimLatency: pool.lastLatency,
+		}
+		if pool.claims > 0 {
+			s.MeanClaimLatency = pool.totalLatency / time.Duration(pool.claims)
+		}
+		out[name] = s
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// registerWebTemplate registers template "web", whose "server" process is
+// built by a factory that records each process it builds.
+func registerWebTemplate(t *testing.T, k *Kernel) func() []*Process {
+	t.Helper()
+	var mu sync.Mutex
+	var built []*Process
+	k.RegisterKind("server", func(ProcessSpec) *Process {
+		p := &Process{Action: blockUntil(nil)}
+		mu.Lock()
+		built = append(built, p)
+		mu.Unlock()
+		return p
+	})
+	spec := Spec{
+		ContainerSpec: ContainerSpec{Name: "web", MemoryMB: 256, Labels: map[string]string{"tier": "web"}},
+		Processes:     []ProcessSpec{{Name: "server", Kind: "server"}},
+	}
+	if err := k.RegisterTemplate("web", spec); err != nil {
+		t.Fatal(err)
+	}
+	return func() []*Process {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Process(nil), built...)
+	}
+}
+
+func TestClaimFromWarmPool(t *testing.T) {
+	k, _ := newTestKernel(t)
+	built := registerWebTemplate(t, k)
+	if err := k.CreateWarmPool("web", 2); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(built()); n != 2 {
+		t.Fatalf("%d processes built for a pool of 2", n)
+	}
+	if len(k.Containers) != 0 {
+		t.Errorf("warm containers registered: %v", k.Containers)
+	}
+
+	c, err := k.ClaimFromPool("web", "web-1", ClaimOverrides{Labels: map[string]string{"user": "ann"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	if k.Containers["web-1"] != c {
+		t.Fatal("claimed container not registered under its ID")
+	}
+	// The claim is served by a pre-built container, not by the factory.
+	if c.Processes[0] != built()[0] {
+		t.Error("claim built a new process instead of using the warm one")
+	}
+	info := c.Inspect()
+	if info.Name != "web" || info.Labels["tier"] != "web" || info.Labels["user"] != "ann" {
+		t.Errorf("claimed container = %s with labels %v", info.Name, info.Labels)
+	}
+	eventually(t, "the server to run", func() bool { return stateOf(c, c.Processes[0]) == Running })
+
+	eventually(t, "the pool to refill", func() bool { return k.WarmPoolStats()["web"].Ready == 2 })
+	s := k.Stats().WarmPools["web"]
+	if s.Size != 2 || s.Claims != 1 || s.ColdStarts != 0 || s.LastClaimLatency <= 0 {
+		t.Errorf("pool stats = %+v", s)
+	}
+	if n := len(built()); n != 3 {
+		t.Errorf("%d processes built, want 3 after one refill", n)
+	}
+
+	if _, err := k.ClaimFromPool("web", "web-1", ClaimOverrides{}); !errors.Is(err, ErrContainerExists) {
+		t.Errorf("claim under a taken ID: %v", err)
+	}
+	if _, err := k.ClaimFromPool("db", "db-1", ClaimOverrides{}); !errors.Is(err, ErrUnknownPool) {
+		t.Errorf("claim from an unknown pool: %v", err)
+	}
+}
+
+func TestClaimFromEmptyPoolBuildsCold(t *testing.T) {
+	k, _ := newTestKernel(t)
+	built := registerWebTemplate(t, k)
+	if err := k.CreateWarmPool("web", 1); err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := k.SubscribeFiltered(EventFilter{Kinds: []EventKind{EventPoolEmpty}})
+	defer cancel()
+	k.mu.Lock()
+	k.warmPools["web"].ready = nil
+	k.mu.Unlock()
+
+	c, err := k.ClaimFromPool("web", "web-1", ClaimOverrides{Name: "web-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopProcesses()
+	if e := nextEvent(t, events); e.ContainerID != "web-1" || e.Detail != "web" {
+		t.Errorf("event = %+v", e)
+	}
+	if c.Processes[0] != built()[1] {
+		t.Error("cold claim did not build its own process")
+	}
+	if got := c.Inspect().Name; got != "web-a" {
+		t.Errorf("name = %q, want the override", got)
+	}
+	eventually(t, "the pool to refill", func() bool { return k.WarmPoolStats()["web"].Ready == 1 })
+	if s := k.WarmPoolStats()["web"]; s.Claims != 1 || s.ColdStarts != 1 {
+		t.Errorf("pool stats = %+v", s)
+	}
+}
+
+func TestCreateWarmPoolErrors(t *testing.T) {
+	k, _ := newTestKernel(t)
+	if err := k.CreateWarmPool("web", 1); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("unknown template: %v", err)
+	}
+	registerWebTemplate(t, k)
+	if err := k.CreateWarmPool("web", 0); !errors.Is(err, ErrInvalidContainerSpec) {
+		t.Errorf("empty pool: %v", err)
+	}
+	if err := k.CreateWarmPool("web", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.CreateWarmPool("web", 1); !errors.Is(err, ErrPoolExists) {
+		t.Errorf("second pool: %v", err)
+	}
+	if err := k.DeleteWarmPool("web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.DeleteWarmPool("web"); !errors.Is(err, ErrUnknownPool) {
+		t.Errorf("second delete: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "time"
+
+// --- Warmup and Cooldown ---
+
+// cpuFactor is the share of its CPUWeight p uses at now. It rises linearly
+// from zero over Warmup once p starts and, after p stops running, falls
+// linearly to zero over Cooldown from wherever it had reached. Without
+// either, p uses its full weight exactly while it runs. The caller holds
+// p's container lock.
+func (p *Process) cpuFactor(now time.Time) float64 {
+	if p.startedAt.IsZero() {
+		return 0
+	}
+	if p.State == Running {
+		return p.warmthAt(now)
+	}
+	if p.Cooldown <= 0 || p.finishedAt.Before(p.startedAt) {
+		return 0
+	}
+	left := 1 - float64(now.Sub(p.finishedAt))/float64(p.Cooldown)
+	return p.warmthAt(p.finishedAt) * max(left, 0)
+}
+
+// warmthAt is how far p's current run has warmed up at t.
+func (p *Process) warmthAt(t time.Time) float64 {
+	if p.Warmup <= 0 {
+		return 1
+	}
+	return min(max(float64(t.Sub(p.startedAt))/float64(p.Warmup), 0), 1)
+}
+
+// cpuUsageLocked sums the CPU its processes use at now, warming up or
+// cooling down.
+func (c *Container) cpuUsageLocked(now time.Time) float64 {
+	var cpu float64
+	for _, p := range c.Processes {
+		cpu += p.CPUWeight * p.cpuFactor(now)
+	}
+	return cpu
+}
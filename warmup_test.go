@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarmupRampsAndCooldownDecaysCPU(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	release := make(chan struct{})
+	p := &Process{Name: "server", CPUWeight: 2, Warmup: time.Minute, Cooldown: 30 * time.Second, Action: blockUntil(release)}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "server running", func() bool { return stateOf(c, p) == Running })
+	cpu := func() (float64, float64) {
+		d := c.Inspect()
+		return d.Processes[0].CPU, d.CPUUsage
+	}
+
+	for i, want := range []float64{0, 0.5, 1, 1.5, 2, 2} {
+		if i > 0 {
+			clk.Advance(15 * time.Second)
+		}
+		if got, sum := cpu(); got != want || sum != want {
+			t.Errorf("at %v: CPU %v, container %v; want %v", time.Duration(i)*15*time.Second, got, sum, want)
+		}
+	}
+
+	close(release)
+	waitDone(t, p)
+	for i, want := range []float64{2, 1, 0, 0} {
+		if i > 0 {
+			clk.Advance(15 * time.Second)
+		}
+		if got, _ := cpu(); got != want {
+			t.Errorf("%v into cooldown: CPU %v, want %v", time.Duration(i)*15*time.Second, got, want)
+		}
+	}
+}
+
+func TestCooldownStartsFromPartialWarmup(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "api")
+	release := make(chan struct{})
+	p := &Process{Name: "server", CPUWeight: 1, Warmup: time.Minute, Cooldown: time.Minute, Action: blockUntil(release)}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "server running", func() bool { return stateOf(c, p) == Running })
+	clk.Advance(30 * time.Second)
+	close(release)
+	waitDone(t, p)
+	clk.Advance(30 * time.Second)
+	if got := c.Inspect().Processes[0].CPU; got != 0.25 {
+		t.Errorf("halfway through cooldown from half warm: CPU %v, want 0.25", got)
+	}
+}
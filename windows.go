@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// --- Scheduling Windows ---
+
+// WaitSchedule is the WaitReason of a process held outside its Schedule.
+const WaitSchedule = "OutsideSchedule"
+
+// TimeWindow is a daily span of clock time, as offsets from midnight in
+// [0, 24h). A window whose End is before its Start runs over midnight; one
+// whose End equals its Start covers the whole day.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Schedule restricts when a process may be started to a set of daily
+// windows, read on its container's clock.
+type Schedule struct {
+	Windows []TimeWindow
+	// Location is the time zone the windows are in; nil uses the clock's.
+	Location *time.Location
+}
+
+// Between returns a schedule with a single window from start to end, such
+// as Between(22*time.Hour, 6*time.Hour) for nights.
+func Between(start, end time.Duration) *Schedule {
+	return &Schedule{Windows: []TimeWindow{{Start: start, End: end}}}
+}
+
+// String lists the windows as clock times, e.g. "22:00-06:00".
+func (s *Schedule) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	windows := make([]string, len(s.Windows))
+	for i, w := range s.Windows {
+		windows[i] = clock(w.Start) + "-" + clock(w.End)
+	}
+	return strings.Join(windows, ",")
+}
+
+// midnight returns the start of t's day in the schedule's time zone, and t
+// in that zone.
+func (s *Schedule) midnight(t time.Time) (time.Time, time.Time) {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), t
+}
+
+// Allows reports whether t falls in one of the windows.
+func (s *Schedule) Allows(t time.Time) bool {
+	midnight, t := s.midnight(t)
+	tod := t.Sub(midnight)
+	for _, w := range s.Windows {
+		switch {
+		case w.Start == w.End:
+			return true
+		case w.Start < w.End && tod >= w.Start && tod < w.End:
+			return true
+		case w.Start > w.End && (tod >= w.Start || tod < w.End):
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the earliest time after t at which a window opens, or
+// the zero time if the schedule has no windows.
+func (s *Schedule) NextOpen(t time.Time) time.Time {
+	midnight, t := s.midnight(t)
+	var next time.Time
+	for _, w := range s.Windows {
+		open := midnight.Add(w.Start)
+		if !open.After(t) {
+			y, m, d := midnight.Date()
+			open = time.Date(y, m, d+1, 0, 0, 0, 0, midnight.Location()).Add(w.Start)
+		}
+		if next.IsZero() || open.Before(next) {
+			next = open
+		}
+	}
+	return next
+}
+
+// inScheduleLocked reports whether p's Schedule lets it start at now. If
+// not, p waits with WaitSchedule and a scheduling pass is arranged for when
+// its next window opens.
+func (c *Container) inScheduleLocked(p *Process, now time.Time) bool {
+	if p.Schedule == nil {
+		return true
+	}
+	local := c.localTime(now)
+	if p.Schedule.Allows(local) {
+		return true
+	}
+	c.waitLocked(p, WaitSchedule)
+	next := p.Schedule.NextOpen(local)
+	if next.IsZero() || c.kernel == nil {
+		return false
+	}
+	wait := c.kernelDuration(next.Sub(local))
+	if now.Add(wait).Equal(p.windowDue) {
+		return false // already armed
+	}
+	p.windowDue = now.Add(wait)
+	k := c.kernel
+	due := k.Clock.After(wait)
+	go func() {
+		<-due
+		k.kick()
+	}()
+	return false
+}
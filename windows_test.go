@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProcessWaitsForScheduleWindow(t *testing.T) {
+	k, clk := newTestKernel(t)
+	c := newTestContainer(t, k, "office")
+	p := &Process{Name: "report", Schedule: Between(9*time.Hour, 17*time.Hour), Action: noop}
+	c.AddProcess(p)
+	if err := c.StartProcesses(); err != nil {
+		t.Fatal(err)
+	}
+	if d := c.Inspect().Processes[0]; d.State != Pending || d.WaitReason != WaitSchedule {
+		t.Fatalf("at midnight: %v waiting for %q, want held for the schedule", d.State, d.WaitReason)
+	}
+
+	waitForWaiters(t, clk, 1)
+	clk.Advance(8*time.Hour + 59*time.Minute)
+	if got := stateOf(c, p); got != Pending {
+		t.Fatalf("at 08:59: %v, want still queued", got)
+	}
+	clk.Advance(time.Minute)
+	waitDone(t, p)
+}
+
+func TestScheduleWindows(t *testing.T) {
+	at := func(h, m int) time.Time {
+		return testEpoch.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	}
+	night := Between(22*time.Hour, 6*time.Hour)
+	for _, tc := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(23, 0), true},
+		{at(5, 59), true},
+		{at(6, 0), false},
+		{at(21, 59), false},
+		{at(22, 0), true},
+	} {
+		if got := night.Allows(tc.t); got != tc.want {
+			t.Errorf("night allows %v = %v", tc.t.Format("15:04"), got)
+		}
+	}
+	if got := night.NextOpen(at(23, 0)); !got.Equal(at(46, 0)) {
+		t.Errorf("next night after 23:00 opens at %v, want tomorrow 22:00", got)
+	}
+	if got := night.String(); got != "22:00-06:00" {
+		t.Errorf("String = %q", got)
+	}
+	if !Between(time.Hour, time.Hour).Allows(at(12, 0)) {
+		t.Error("a window ending where it starts should cover the whole day")
+	}
+	if !(&Schedule{}).NextOpen(at(0, 0)).IsZero() {
+		t.Error("a schedule with no windows never opens")
+	}
+}